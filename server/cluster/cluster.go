@@ -49,18 +49,20 @@ type Cluster struct {
 	schedulerManager manager.SchedulerManager
 }
 
-func NewCluster(logger *zap.Logger, metadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath string) (*Cluster, error) {
+func NewCluster(logger *zap.Logger, metadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath string, procedureOptions procedure.ManagerOptions, schedulerOptions manager.Options) (*Cluster, error) {
 	procedureStorage := procedure.NewEtcdStorageImpl(client, rootPath, uint32(metadata.GetClusterID()))
-	procedureManager, err := procedure.NewManagerImpl(logger, metadata)
-	if err != nil {
-		return nil, errors.WithMessage(err, "create procedure manager")
-	}
 	dispatch := eventdispatch.NewDispatchImpl()
 
 	procedureIDRootPath := strings.Join([]string{rootPath, metadata.Name(), defaultProcedurePrefixKey}, "/")
 	procedureFactory := coordinator.NewFactory(logger, id.NewAllocatorImpl(logger, client, procedureIDRootPath, defaultAllocStep), dispatch, procedureStorage, metadata)
 
-	schedulerManager := manager.NewManager(logger, procedureManager, procedureFactory, metadata, client, rootPath, metadata.GetTopologyType(), metadata.GetProcedureExecutingBatchSize())
+	// The unfinished procedures persisted by the previous leader are rebuilt by the factory when the manager is started.
+	procedureManager, err := procedure.NewManagerImpl(logger, metadata, procedureStorage, procedureFactory, procedureOptions)
+	if err != nil {
+		return nil, errors.WithMessage(err, "create procedure manager")
+	}
+
+	schedulerManager := manager.NewManager(logger, procedureManager, procedureFactory, metadata, client, rootPath, metadata.GetTopologyType(), metadata.GetProcedureExecutingBatchSize(), schedulerOptions)
 
 	return &Cluster{
 		logger:           logger,
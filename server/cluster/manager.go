@@ -28,6 +28,8 @@ import (
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
 	"github.com/apache/incubator-horaedb-meta/server/id"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/pkg/errors"
@@ -79,9 +81,13 @@ type managerImpl struct {
 
 	// TODO: topologyType is used to be compatible with cluster data changes and needs to be deleted later.
 	topologyType storage.TopologyType
+	// procedureOptions is used to create the procedure managers of all the clusters.
+	procedureOptions procedure.ManagerOptions
+	// schedulerOptions is used to create the scheduler managers of all the clusters.
+	schedulerOptions manager.Options
 }
 
-func NewManagerImpl(storage storage.Storage, kv clientv3.KV, client *clientv3.Client, rootPath string, idAllocatorStep uint, topologyType storage.TopologyType) (Manager, error) {
+func NewManagerImpl(storage storage.Storage, kv clientv3.KV, client *clientv3.Client, rootPath string, idAllocatorStep uint, topologyType storage.TopologyType, procedureOptions procedure.ManagerOptions, schedulerOptions manager.Options) (Manager, error) {
 	alloc := id.NewAllocatorImpl(log.GetLogger(), kv, path.Join(rootPath, AllocClusterIDPrefix), idAllocatorStep)
 
 	manager := &managerImpl{
@@ -89,13 +95,15 @@ func NewManagerImpl(storage storage.Storage, kv clientv3.KV, client *clientv3.Cl
 		running:  false,
 		clusters: map[string]*Cluster{},
 
-		kv:              kv,
-		storage:         storage,
-		client:          client,
-		alloc:           alloc,
-		rootPath:        rootPath,
-		idAllocatorStep: idAllocatorStep,
-		topologyType:    topologyType,
+		kv:               kv,
+		storage:          storage,
+		client:           client,
+		alloc:            alloc,
+		rootPath:         rootPath,
+		idAllocatorStep:  idAllocatorStep,
+		topologyType:     topologyType,
+		procedureOptions: procedureOptions,
+		schedulerOptions: schedulerOptions,
 	}
 
 	return manager, nil
@@ -165,7 +173,7 @@ func (m *managerImpl) CreateCluster(ctx context.Context, clusterName string, opt
 		return nil, errors.WithMessage(err, "cluster load")
 	}
 
-	c, err := NewCluster(logger, clusterMetadata, m.client, m.rootPath)
+	c, err := NewCluster(logger, clusterMetadata, m.client, m.rootPath, m.procedureOptions, m.schedulerOptions)
 	if err != nil {
 		return nil, errors.WithMessage(err, "new cluster")
 	}
@@ -454,7 +462,7 @@ func (m *managerImpl) Start(ctx context.Context) error {
 		}
 
 		log.Info("open cluster successfully", zap.String("cluster", clusterMetadata.Name()))
-		c, err := NewCluster(logger, clusterMetadata, m.client, m.rootPath)
+		c, err := NewCluster(logger, clusterMetadata, m.client, m.rootPath, m.procedureOptions, m.schedulerOptions)
 		if err != nil {
 			return errors.WithMessage(err, "new cluster")
 		}
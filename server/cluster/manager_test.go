@@ -29,6 +29,8 @@ import (
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
@@ -59,7 +61,7 @@ func newTestStorage(t *testing.T) (storage.Storage, clientv3.KV, *clientv3.Clien
 }
 
 func newClusterManagerWithStorage(storage storage.Storage, kv clientv3.KV, client *clientv3.Client) (cluster.Manager, error) {
-	return cluster.NewManagerImpl(storage, kv, client, testRootPath, defaultIDAllocatorStep, defaultTopologyType)
+	return cluster.NewManagerImpl(storage, kv, client, testRootPath, defaultIDAllocatorStep, defaultTopologyType, procedure.ManagerOptions{}, manager.Options{})
 }
 
 func TestClusterManager(t *testing.T) {
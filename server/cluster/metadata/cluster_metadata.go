@@ -27,6 +27,7 @@ import (
 	"path"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/id"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -90,7 +91,8 @@ func (c *ClusterMetadata) Init(ctx context.Context) error {
 
 	createShardViews := make([]CreateShardView, 0, c.metaData.ShardTotal)
 	for i := uint32(0); i < c.metaData.ShardTotal; i++ {
-		shardID, err := c.AllocShardID(ctx)
+		// No shard exists before the cluster is initialized.
+		shardID, err := c.allocShardID(ctx, nil)
 		if err != nil {
 			return errors.WithMessage(err, "alloc shard id failed")
 		}
@@ -256,12 +258,12 @@ func (c *ClusterMetadata) MigrateTable(ctx context.Context, request MigrateTable
 		tableIDs = append(tableIDs, table.ID)
 	}
 
-	if err := c.topologyManager.RemoveTable(ctx, request.OldShardID, request.latestOldShardVersion, tableIDs); err != nil {
+	if err := c.topologyManager.RemoveTable(ctx, request.OldShardID, request.LatestOldShardVersion, tableIDs); err != nil {
 		c.logger.Error("remove table from topology")
 		return err
 	}
 
-	if err := c.topologyManager.AddTable(ctx, request.NewShardID, request.latestNewShardVersion, tables); err != nil {
+	if err := c.topologyManager.AddTable(ctx, request.NewShardID, request.LatestNewShardVersion, tables); err != nil {
 		c.logger.Error("add table from topology")
 		return err
 	}
@@ -459,6 +461,10 @@ func (c *ClusterMetadata) RegisterNode(ctx context.Context, registeredNode Regis
 	// Update shard node mapping.
 	// Check whether to update persistence data.
 	oldCache, exists := c.registeredNodesCache[registeredNode.Node.Name]
+	if exists {
+		// The load is reported separately, so keep it when the node is registered by heartbeat.
+		registeredNode.Node.NodeStats.Load = oldCache.Node.NodeStats.Load
+	}
 	c.registeredNodesCache[registeredNode.Node.Name] = registeredNode
 	enableUpdateWhenStable := c.metaData.TopologyType == storage.TopologyTypeDynamic
 	if !enableUpdateWhenStable && c.topologyManager.GetClusterState() == storage.ClusterStateStable {
@@ -506,12 +512,73 @@ func (c *ClusterMetadata) GetRegisteredNodeByName(nodeName string) (RegisteredNo
 	return registeredNode, ok
 }
 
+// UpdateNodeLoad updates the load of the registered node, the load is not persisted because it changes frequently.
+func (c *ClusterMetadata) UpdateNodeLoad(nodeName string, load storage.NodeLoad) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	registeredNode, ok := c.registeredNodesCache[nodeName]
+	if !ok {
+		return errors.WithMessagef(ErrNodeNotFound, "node is not registered, nodeName:%s", nodeName)
+	}
+	registeredNode.Node.NodeStats.Load = load
+	c.registeredNodesCache[nodeName] = registeredNode
+	return nil
+}
+
 func (c *ClusterMetadata) AllocShardID(ctx context.Context) (uint32, error) {
-	id, err := c.shardIDAlloc.Alloc(ctx)
-	if err != nil {
-		return 0, errors.WithMessage(err, "alloc shard id")
+	return c.allocShardID(ctx, c.topologyManager.GetTopology().ShardViewsMapping)
+}
+
+// allocShardID skips the ids of the existing shards, because the allocator is not aware of the shards loaded from the storage.
+func (c *ClusterMetadata) allocShardID(ctx context.Context, existingShards map[storage.ShardID]storage.ShardView) (uint32, error) {
+	for {
+		id, err := c.shardIDAlloc.Alloc(ctx)
+		if err != nil {
+			return 0, errors.WithMessage(err, "alloc shard id")
+		}
+		if _, exists := existingShards[storage.ShardID(id)]; !exists {
+			return uint32(id), nil
+		}
+	}
+}
+
+// ExpandShardTotal allocates the ids of the new shards and raises the persisted shard total accordingly.
+// The shard views are not created, so the new shards could be either created empty or split from the existing shards by the caller.
+func (c *ClusterMetadata) ExpandShardTotal(ctx context.Context, numShards uint32) ([]storage.ShardID, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	existingShards := c.topologyManager.GetTopology().ShardViewsMapping
+	shardIDs := make([]storage.ShardID, 0, numShards)
+	for uint32(len(shardIDs)) < numShards {
+		shardID, err := c.allocShardID(ctx, existingShards)
+		if err != nil {
+			c.collectShardIDs(ctx, shardIDs)
+			return nil, errors.WithMessage(err, "alloc shard id failed")
+		}
+		shardIDs = append(shardIDs, storage.ShardID(shardID))
+	}
+
+	metaData := c.metaData
+	// The shards split from the existing ones are not counted in the shard total, so count them here.
+	metaData.ShardTotal = max(metaData.ShardTotal, uint32(len(existingShards))) + numShards
+	metaData.ModifiedAt = uint64(time.Now().UnixMilli())
+	if err := c.storage.UpdateCluster(ctx, storage.UpdateClusterRequest{Cluster: metaData}); err != nil {
+		c.collectShardIDs(ctx, shardIDs)
+		return nil, errors.WithMessage(err, "update cluster")
+	}
+	c.metaData = metaData
+
+	return shardIDs, nil
+}
+
+func (c *ClusterMetadata) collectShardIDs(ctx context.Context, shardIDs []storage.ShardID) {
+	for _, shardID := range shardIDs {
+		if err := c.shardIDAlloc.Collect(ctx, uint64(shardID)); err != nil {
+			c.logger.Warn("collect shard id failed", zap.Uint32("shardID", uint32(shardID)), zap.Error(err))
+		}
 	}
-	return uint32(id), nil
 }
 
 func (c *ClusterMetadata) RouteTables(_ context.Context, schemaName string, tableNames []string) (RouteTablesResult, error) {
@@ -700,6 +767,19 @@ func (c *ClusterMetadata) CreateShardViews(ctx context.Context, views []CreateSh
 	return nil
 }
 
+// DropShardView removes the shard view of an empty shard, and the shard id will be collected for reuse.
+func (c *ClusterMetadata) DropShardView(ctx context.Context, shardID storage.ShardID, latestVersion uint64) error {
+	if err := c.topologyManager.DropShardView(ctx, shardID, latestVersion); err != nil {
+		return errors.WithMessage(err, "topology manager drop shard view")
+	}
+
+	if err := c.shardIDAlloc.Collect(ctx, uint64(shardID)); err != nil {
+		c.logger.Warn("collect shard id failed", zap.Uint32("shardID", uint32(shardID)), zap.Error(err))
+	}
+
+	return nil
+}
+
 func (c *ClusterMetadata) GetClusterSnapshot() Snapshot {
 	return Snapshot{
 		Topology:        c.topologyManager.GetTopology(),
@@ -727,6 +807,70 @@ func (c *ClusterMetadata) LoadMetadata(ctx context.Context) error {
 	return nil
 }
 
+// LoadVersioned loads the value persisted in the VersionedKey of T, and emptyValue is returned if no value is persisted.
+func LoadVersioned[T storage.VersionedValue](ctx context.Context, c *ClusterMetadata, emptyValue T) (T, error) {
+	var value T
+	result, err := c.storage.GetVersionedValue(ctx, storage.GetVersionedValueRequest{
+		ClusterID: c.clusterID,
+		Key:       value.VersionedKey(),
+		Value:     &value,
+	})
+	if err != nil {
+		return emptyValue, errors.WithMessagef(err, "load %s", value.VersionedKey())
+	}
+	if !result.Exists {
+		return emptyValue, nil
+	}
+	return value, nil
+}
+
+// UpdateVersioned persists the value into its VersionedKey, and it fails if the persisted value is not of the latestVersion.
+func UpdateVersioned[T storage.VersionedValue](ctx context.Context, c *ClusterMetadata, value T, latestVersion uint64) error {
+	if err := c.storage.UpdateVersionedValue(ctx, storage.UpdateVersionedValueRequest{
+		ClusterID:     c.clusterID,
+		Key:           value.VersionedKey(),
+		Value:         value,
+		Version:       value.GetVersion(),
+		LatestVersion: latestVersion,
+	}); err != nil {
+		return errors.WithMessagef(err, "update %s", value.VersionedKey())
+	}
+	return nil
+}
+
+// ListScheduleAuditRecords lists the schedule audit records kept in storage, and the ID of the latest one.
+func (c *ClusterMetadata) ListScheduleAuditRecords(ctx context.Context) ([]storage.ScheduleAuditRecord, uint64, error) {
+	result, err := c.storage.ListScheduleAuditRecords(ctx, storage.ListScheduleAuditRecordsRequest{ClusterID: c.clusterID})
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "list schedule audit records")
+	}
+	return result.Records, result.LatestID, nil
+}
+
+// AppendScheduleAuditRecord persists the record into the ring buffer with the capacity, the ID of the record must follow the latest one.
+func (c *ClusterMetadata) AppendScheduleAuditRecord(ctx context.Context, record storage.ScheduleAuditRecord, capacity uint64) error {
+	if err := c.storage.AppendScheduleAuditRecord(ctx, storage.AppendScheduleAuditRecordRequest{
+		ClusterID: c.clusterID,
+		Record:    record,
+		Capacity:  capacity,
+	}); err != nil {
+		return errors.WithMessage(err, "append schedule audit record")
+	}
+	return nil
+}
+
+// UpdateScheduleAuditRecord overwrites the record kept in the ring buffer with the capacity.
+func (c *ClusterMetadata) UpdateScheduleAuditRecord(ctx context.Context, record storage.ScheduleAuditRecord, capacity uint64) error {
+	if err := c.storage.UpdateScheduleAuditRecord(ctx, storage.UpdateScheduleAuditRecordRequest{
+		ClusterID: c.clusterID,
+		Record:    record,
+		Capacity:  capacity,
+	}); err != nil {
+		return errors.WithMessage(err, "update schedule audit record")
+	}
+	return nil
+}
+
 func (c *ClusterMetadata) GetShardNodes() GetShardNodesResult {
 	return c.topologyManager.GetShardNodes()
 }
@@ -739,6 +883,11 @@ func (c *ClusterMetadata) GetTablesByIDs(tableIDs []storage.TableID) []storage.T
 	return c.tableManager.GetTablesByIDs(tableIDs)
 }
 
+// GetPartitionedTables returns the partitioned tables, which are not assigned to any shard unlike their sub tables.
+func (c *ClusterMetadata) GetPartitionedTables() []storage.Table {
+	return c.tableManager.GetPartitionedTables()
+}
+
 func needUpdate(oldCache RegisteredNode, registeredNode RegisteredNode) bool {
 	if len(oldCache.ShardInfos) >= 50 {
 		return !sortCompare(oldCache.ShardInfos, registeredNode.ShardInfos)
@@ -27,8 +27,10 @@ import (
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestClusterMetadata(t *testing.T) {
@@ -233,3 +235,45 @@ func testMetadataOperation(ctx context.Context, re *require.Assertions, m *metad
 	err = m.LoadMetadata(ctx)
 	re.Error(err)
 }
+
+func TestClusterMetadataExpandShardTotal(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
+	clusterStorage := storage.NewStorageWithEtcdBackend(client, test.TestRootPath, storage.Options{
+		MaxScanLimit: 100, MinScanLimit: 10, MaxOpsPerTxn: 10,
+	})
+	cluster := storage.Cluster{
+		ID:                          0,
+		Name:                        test.ClusterName,
+		MinNodeCount:                test.DefaultNodeCount,
+		ShardTotal:                  test.DefaultShardTotal,
+		TopologyType:                storage.TopologyTypeDynamic,
+		ProcedureExecutingBatchSize: test.DefaultProcedureExecutingBatchSize,
+		CreatedAt:                   0,
+		ModifiedAt:                  0,
+	}
+	re.NoError(clusterStorage.CreateCluster(ctx, storage.CreateClusterRequest{Cluster: cluster}))
+
+	m := metadata.NewClusterMetadata(zap.NewNop(), cluster, clusterStorage, client, test.TestRootPath, test.DefaultIDAllocatorStep)
+	re.NoError(m.Init(ctx))
+	re.NoError(m.Load(ctx))
+
+	shardIDs, err := m.ExpandShardTotal(ctx, 2)
+	re.NoError(err)
+	re.Equal([]storage.ShardID{test.DefaultShardTotal, test.DefaultShardTotal + 1}, shardIDs)
+	re.Equal(uint32(test.DefaultShardTotal+2), m.GetTotalShardNum())
+	// The raised shard total is persisted.
+	re.NoError(m.LoadMetadata(ctx))
+	re.Equal(uint32(test.DefaultShardTotal+2), m.GetTotalShardNum())
+
+	// The shards loaded after restart should not be allocated again.
+	re.NoError(m.CreateShardViews(ctx, []metadata.CreateShardView{{ShardID: shardIDs[0], Tables: []storage.TableID{}}}))
+	restarted := metadata.NewClusterMetadata(zap.NewNop(), m.GetStorageMetadata(), clusterStorage, client, test.TestRootPath, test.DefaultIDAllocatorStep)
+	re.NoError(restarted.Load(ctx))
+	shardIDs, err = restarted.ExpandShardTotal(ctx, 1)
+	re.NoError(err)
+	re.Equal([]storage.ShardID{test.DefaultShardTotal + 1}, shardIDs)
+	re.Equal(uint32(test.DefaultShardTotal+3), restarted.GetTotalShardNum())
+}
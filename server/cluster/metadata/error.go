@@ -31,6 +31,7 @@ var (
 	ErrSchemaNotFound       = coderr.NewCodeError(coderr.NotFound, "schema not found")
 	ErrTableNotFound        = coderr.NewCodeError(coderr.NotFound, "table not found")
 	ErrShardNotFound        = coderr.NewCodeError(coderr.NotFound, "shard not found")
+	ErrShardNotEmpty        = coderr.NewCodeError(coderr.Internal, "shard not empty")
 	ErrVersionNotFound      = coderr.NewCodeError(coderr.NotFound, "version not found")
 	ErrNodeNotFound         = coderr.NewCodeError(coderr.NotFound, "NodeName not found")
 	ErrTableAlreadyExists   = coderr.NewCodeError(coderr.Internal, "table already exists")
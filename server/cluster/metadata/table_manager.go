@@ -41,6 +41,8 @@ type TableManager interface {
 	GetTables(schemaName string, tableNames []string) ([]storage.Table, error)
 	// GetTablesByIDs get tables with tableIDs.
 	GetTablesByIDs(tableIDs []storage.TableID) []storage.Table
+	// GetPartitionedTables get the partitioned tables of all schemas.
+	GetPartitionedTables() []storage.Table
 	// CreateTable create table with schemaName and tableName.
 	CreateTable(ctx context.Context, schemaName string, tableName string, partitionInfo storage.PartitionInfo) (storage.Table, error)
 	// DropTable drop table with schemaName and tableName.
@@ -136,6 +138,22 @@ func (m *TableManagerImpl) GetTablesByIDs(tableIDs []storage.TableID) []storage.
 	return result
 }
 
+func (m *TableManagerImpl) GetPartitionedTables() []storage.Table {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	result := make([]storage.Table, 0)
+	for _, tables := range m.schemaTables {
+		for _, table := range tables.tablesByID {
+			if table.IsPartitioned() {
+				result = append(result, table)
+			}
+		}
+	}
+
+	return result
+}
+
 func (m *TableManagerImpl) CreateTable(ctx context.Context, schemaName string, tableName string, partitionInfo storage.PartitionInfo) (storage.Table, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
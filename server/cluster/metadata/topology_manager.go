@@ -72,6 +72,8 @@ type TopologyManager interface {
 	GetClusterView() storage.ClusterView
 	// CreateShardViews create shardViews.
 	CreateShardViews(ctx context.Context, shardViews []CreateShardView) error
+	// DropShardView drop the shard view when its version is same as expect version, the shard must not contain any table.
+	DropShardView(ctx context.Context, shardID storage.ShardID, expect uint64) error
 	// UpdateShardVersionWithExpect update shard version when pre version is same as expect version.
 	UpdateShardVersionWithExpect(ctx context.Context, shardID storage.ShardID, version uint64, expect uint64) error
 	// GetTopology get current topology snapshot.
@@ -107,11 +109,12 @@ type Topology struct {
 	ClusterView       storage.ClusterView
 }
 
+// IsStable means every shard has a leader, and the followers are not counted.
 func (t *Topology) IsStable() bool {
 	if t.ClusterView.State != storage.ClusterStateStable {
 		return false
 	}
-	if len(t.ClusterView.ShardNodes) != len(t.ShardViewsMapping) {
+	if len(t.LeaderShardNodes()) != len(t.ShardViewsMapping) {
 		return false
 	}
 	return true
@@ -121,12 +124,42 @@ func (t *Topology) IsPrepareFinished() bool {
 	if t.ClusterView.State != storage.ClusterStatePrepare {
 		return false
 	}
-	if len(t.ShardViewsMapping) != len(t.ClusterView.ShardNodes) {
+	if len(t.ShardViewsMapping) != len(t.LeaderShardNodes()) {
 		return false
 	}
 	return true
 }
 
+// ShardIDBound returns the max shard id plus one, which is taken by the node picker as the total number of the shards.
+// The shard ids may not be contiguous, e.g. the shard views of the merged shards are dropped, so the number of the shards can't be used.
+func (t *Topology) ShardIDBound() uint32 {
+	bound := uint32(0)
+	for shardID := range t.ShardViewsMapping {
+		bound = max(bound, uint32(shardID)+1)
+	}
+	return bound
+}
+
+// LeaderShardNodes returns the shard nodes of the shard leaders, and there is at most one for every shard.
+func (t *Topology) LeaderShardNodes() []storage.ShardNode {
+	return t.shardNodesWithRole(storage.ShardRoleLeader)
+}
+
+// FollowerShardNodes returns the shard nodes of the follower replicas of the shards.
+func (t *Topology) FollowerShardNodes() []storage.ShardNode {
+	return t.shardNodesWithRole(storage.ShardRoleFollower)
+}
+
+func (t *Topology) shardNodesWithRole(role storage.ShardRole) []storage.ShardNode {
+	shardNodes := make([]storage.ShardNode, 0, len(t.ClusterView.ShardNodes))
+	for _, shardNode := range t.ClusterView.ShardNodes {
+		if shardNode.ShardRole == role {
+			shardNodes = append(shardNodes, shardNode)
+		}
+	}
+	return shardNodes
+}
+
 type TopologyManagerImpl struct {
 	logger       *zap.Logger
 	storage      storage.Storage
@@ -271,12 +304,14 @@ func (m *TopologyManagerImpl) RemoveTable(ctx context.Context, shardID storage.S
 		return ErrShardNotFound.WithCausef("shard id:%d", shardID)
 	}
 
+	tableIDsToRemove := make(map[storage.TableID]struct{}, len(tableIDs))
+	for _, tableID := range tableIDs {
+		tableIDsToRemove[tableID] = struct{}{}
+	}
 	newTableIDs := make([]storage.TableID, 0, len(shardView.TableIDs))
 	for _, tableID := range shardView.TableIDs {
-		for _, tableIDToRemove := range tableIDs {
-			if tableID != tableIDToRemove {
-				newTableIDs = append(newTableIDs, tableID)
-			}
+		if _, exists := tableIDsToRemove[tableID]; !exists {
+			newTableIDs = append(newTableIDs, tableID)
 		}
 	}
 
@@ -567,6 +602,32 @@ func (m *TopologyManagerImpl) CreateShardViews(ctx context.Context, createShardV
 	return nil
 }
 
+func (m *TopologyManagerImpl) DropShardView(ctx context.Context, shardID storage.ShardID, expect uint64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	shardView, ok := m.shardTablesMapping[shardID]
+	if !ok {
+		return ErrShardNotFound.WithCausef("shard id:%d", shardID)
+	}
+	if len(shardView.TableIDs) != 0 {
+		return ErrShardNotEmpty.WithCausef("shard id:%d, table count:%d", shardID, len(shardView.TableIDs))
+	}
+
+	if err := m.storage.DeleteShardView(ctx, storage.DeleteShardViewRequest{
+		ClusterID:     m.clusterID,
+		ShardID:       shardID,
+		LatestVersion: expect,
+	}); err != nil {
+		return errors.WithMessage(err, "storage delete shard view")
+	}
+
+	// Remove shard view from memory.
+	delete(m.shardTablesMapping, shardID)
+
+	return nil
+}
+
 func (m *TopologyManagerImpl) UpdateShardVersionWithExpect(ctx context.Context, shardID storage.ShardID, version uint64, expect uint64) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
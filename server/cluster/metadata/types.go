@@ -130,10 +130,11 @@ type MigrateTableRequest struct {
 	SchemaName string
 	TableNames []string
 	OldShardID storage.ShardID
-	// TODO: refactor migrate table request, simplify params.
-	latestOldShardVersion uint64
+	// LatestOldShardVersion is the version of the old shard view after the tables are removed.
+	LatestOldShardVersion uint64
 	NewShardID            storage.ShardID
-	latestNewShardVersion uint64
+	// LatestNewShardVersion is the version of the new shard view after the tables are added.
+	LatestNewShardVersion uint64
 }
 
 type ShardVersionUpdate struct {
@@ -49,6 +49,33 @@ const (
 	defaultEtcdMaxTxnOps                = 128
 	defaultEtcdLeaseTTLSec              = 10
 
+	defaultProcedureHistoryRetentionCount int   = 1000
+	defaultProcedureHistoryRetentionSec   int64 = 7 * 24 * 3600
+	defaultProcedureHistoryGCIntervalSec  int64 = 10 * 60
+
+	defaultProcedureTimeoutSec int64 = 0
+	// The transfer leader, split and scatter procedures begin their side effects as soon as they start, so no deadline is set for them.
+	defaultTransferLeaderProcedureTimeoutSec int64 = 0
+	defaultMigrateProcedureTimeoutSec        int64 = 5 * 60
+	defaultSplitProcedureTimeoutSec          int64 = 0
+	defaultMergeProcedureTimeoutSec          int64 = 5 * 60
+	defaultScatterProcedureTimeoutSec        int64 = 0
+
+	defaultEnableLoadSchedule      bool    = false
+	defaultLoadScheduleThreshold   float64 = 0.25
+	defaultLoadScheduleHysteresis  float64 = 0.1
+	defaultLoadScheduleCooldownSec int64   = 10 * 60
+
+	defaultEnableSplitSchedule            bool    = false
+	defaultSplitScheduleMaxTablesPerShard uint32  = 1000
+	defaultSplitScheduleMaxShardWriteQPS  float64 = 0
+	defaultSplitScheduleCooldownSec       int64   = 30 * 60
+
+	defaultEnableTableBalanceSchedule    bool   = false
+	defaultTableBalanceScheduleTolerance uint32 = 10
+
+	defaultScheduleAuditCapacity uint64 = 1000
+
 	defaultGrpcHandleTimeoutMs int = 60 * 1000
 	// GrpcServiceMaxSendMsgSize controls the max size of the sent message(200MB by default).
 	defaultGrpcServiceMaxSendMsgSize int = 200 * 1024 * 1024
@@ -106,6 +133,64 @@ type LimiterConfig struct {
 	Burst int `toml:"burst" env:"FLOW_LIMITER_BURST"`
 }
 
+type ProcedureHistoryConfig struct {
+	// RetentionCount is the max number of the completed procedures kept in the history, zero means no limit.
+	RetentionCount int `toml:"retention-count" env:"PROCEDURE_HISTORY_RETENTION_COUNT"`
+	// RetentionSec is the max age of the completed procedures kept in the history, zero means no limit.
+	RetentionSec int64 `toml:"retention-sec" env:"PROCEDURE_HISTORY_RETENTION_SEC"`
+	// GCIntervalSec is the interval to clean up the history, zero means the history is never cleaned up.
+	GCIntervalSec int64 `toml:"gc-interval-sec" env:"PROCEDURE_HISTORY_GC_INTERVAL_SEC"`
+}
+
+// ProcedureTimeoutConfig controls the execution deadlines of the procedures, zero means no deadline.
+// The deadline only stops the procedure before its side effects begin, e.g. before the tables are closed by the migrate procedure.
+type ProcedureTimeoutConfig struct {
+	// DefaultSec is used by the procedures whose kind has no specific deadline, such as the ddl procedures.
+	DefaultSec        int64 `toml:"default-sec" env:"PROCEDURE_TIMEOUT_DEFAULT_SEC"`
+	TransferLeaderSec int64 `toml:"transfer-leader-sec" env:"PROCEDURE_TIMEOUT_TRANSFER_LEADER_SEC"`
+	MigrateSec        int64 `toml:"migrate-sec" env:"PROCEDURE_TIMEOUT_MIGRATE_SEC"`
+	SplitSec          int64 `toml:"split-sec" env:"PROCEDURE_TIMEOUT_SPLIT_SEC"`
+	MergeSec          int64 `toml:"merge-sec" env:"PROCEDURE_TIMEOUT_MERGE_SEC"`
+	ScatterSec        int64 `toml:"scatter-sec" env:"PROCEDURE_TIMEOUT_SCATTER_SEC"`
+}
+
+// LoadScheduleConfig controls the scheduler which moves the shards from the overloaded nodes, it only works in dynamic topology.
+type LoadScheduleConfig struct {
+	Enable bool `toml:"enable" env:"LOAD_SCHEDULE_ENABLE"`
+	// Threshold is the ratio by which the load of a node exceeds the mean of the cluster to be considered overloaded.
+	Threshold float64 `toml:"threshold" env:"LOAD_SCHEDULE_THRESHOLD"`
+	// Hysteresis is subtracted from the threshold, and the overloaded node is unloaded until its load falls below the result.
+	Hysteresis float64 `toml:"hysteresis" env:"LOAD_SCHEDULE_HYSTERESIS"`
+	// CooldownSec is the min interval between two moves of the same shard.
+	CooldownSec int64 `toml:"cooldown-sec" env:"LOAD_SCHEDULE_COOLDOWN_SEC"`
+}
+
+// SplitScheduleConfig controls the scheduler which splits the shards with too many tables or too much write load, it only works in dynamic
+// topology.
+type SplitScheduleConfig struct {
+	Enable bool `toml:"enable" env:"SPLIT_SCHEDULE_ENABLE"`
+	// MaxTablesPerShard is the number of tables above which a shard is split, zero means the table count is not checked.
+	MaxTablesPerShard uint32 `toml:"max-tables-per-shard" env:"SPLIT_SCHEDULE_MAX_TABLES_PER_SHARD"`
+	// MaxShardWriteQPS is the write qps above which a shard is split, zero means the load is not checked.
+	MaxShardWriteQPS float64 `toml:"max-shard-write-qps" env:"SPLIT_SCHEDULE_MAX_SHARD_WRITE_QPS"`
+	// CooldownSec is the min interval between two splits of the same shard.
+	CooldownSec int64 `toml:"cooldown-sec" env:"SPLIT_SCHEDULE_COOLDOWN_SEC"`
+}
+
+// TableBalanceScheduleConfig controls the scheduler which migrates the tables to even out the table counts of the shards, it only works in
+// dynamic topology.
+type TableBalanceScheduleConfig struct {
+	Enable bool `toml:"enable" env:"TABLE_BALANCE_SCHEDULE_ENABLE"`
+	// Tolerance is the max difference of the table counts between the shards which is accepted without migrating any table.
+	Tolerance uint32 `toml:"tolerance" env:"TABLE_BALANCE_SCHEDULE_TOLERANCE"`
+}
+
+// ScheduleAuditConfig controls the audit log of the procedures submitted by the schedulers.
+type ScheduleAuditConfig struct {
+	// Capacity is the max number of the records kept in the audit log of every cluster, zero means nothing is recorded.
+	Capacity uint64 `toml:"capacity" env:"SCHEDULE_AUDIT_CAPACITY"`
+}
+
 // Config is server start config, it has three input modes:
 // 1. toml config file
 // 2. env variables
@@ -116,6 +201,13 @@ type Config struct {
 	EtcdLog     log.Config    `toml:"etcd-log" env:"ETCD_LOG"`
 	FlowLimiter LimiterConfig `toml:"flow-limiter" env:"FLOW_LIMITER"`
 
+	ProcedureHistory     ProcedureHistoryConfig     `toml:"procedure-history" env:"PROCEDURE_HISTORY"`
+	ProcedureTimeout     ProcedureTimeoutConfig     `toml:"procedure-timeout" env:"PROCEDURE_TIMEOUT"`
+	LoadSchedule         LoadScheduleConfig         `toml:"load-schedule" env:"LOAD_SCHEDULE"`
+	SplitSchedule        SplitScheduleConfig        `toml:"split-schedule" env:"SPLIT_SCHEDULE"`
+	TableBalanceSchedule TableBalanceScheduleConfig `toml:"table-balance-schedule" env:"TABLE_BALANCE_SCHEDULE"`
+	ScheduleAudit        ScheduleAuditConfig        `toml:"schedule-audit" env:"SCHEDULE_AUDIT"`
+
 	EnableEmbedEtcd bool   `toml:"enable-embed-etcd" env:"ENABLE_EMBED_ETCD"`
 	EtcdCaCertPath  string `toml:"etcd-ca-cert-path" env:"ETCD_CA_CERT_PATH"`
 	EtcdKeyPath     string `toml:"etcd-key-path" env:"ETCD_KEY_PATH"`
@@ -301,6 +393,38 @@ func MakeConfigParser() (*Parser, error) {
 			Limit:  defaultInitialLimiterRate,
 			Burst:  defaultInitialLimiterCapacity,
 		},
+		ProcedureHistory: ProcedureHistoryConfig{
+			RetentionCount: defaultProcedureHistoryRetentionCount,
+			RetentionSec:   defaultProcedureHistoryRetentionSec,
+			GCIntervalSec:  defaultProcedureHistoryGCIntervalSec,
+		},
+		ProcedureTimeout: ProcedureTimeoutConfig{
+			DefaultSec:        defaultProcedureTimeoutSec,
+			TransferLeaderSec: defaultTransferLeaderProcedureTimeoutSec,
+			MigrateSec:        defaultMigrateProcedureTimeoutSec,
+			SplitSec:          defaultSplitProcedureTimeoutSec,
+			MergeSec:          defaultMergeProcedureTimeoutSec,
+			ScatterSec:        defaultScatterProcedureTimeoutSec,
+		},
+		LoadSchedule: LoadScheduleConfig{
+			Enable:      defaultEnableLoadSchedule,
+			Threshold:   defaultLoadScheduleThreshold,
+			Hysteresis:  defaultLoadScheduleHysteresis,
+			CooldownSec: defaultLoadScheduleCooldownSec,
+		},
+		SplitSchedule: SplitScheduleConfig{
+			Enable:            defaultEnableSplitSchedule,
+			MaxTablesPerShard: defaultSplitScheduleMaxTablesPerShard,
+			MaxShardWriteQPS:  defaultSplitScheduleMaxShardWriteQPS,
+			CooldownSec:       defaultSplitScheduleCooldownSec,
+		},
+		TableBalanceSchedule: TableBalanceScheduleConfig{
+			Enable:    defaultEnableTableBalanceSchedule,
+			Tolerance: defaultTableBalanceScheduleTolerance,
+		},
+		ScheduleAudit: ScheduleAuditConfig{
+			Capacity: defaultScheduleAuditCapacity,
+		},
 
 		EnableEmbedEtcd: defaultEnableEmbedEtcd,
 		EtcdCaCertPath:  defaultEtcdCaCertPath,
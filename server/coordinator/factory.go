@@ -29,6 +29,10 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl/createtable"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl/droppartitiontable"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl/droptable"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/follower"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/merge"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/migrate"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/scatter"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/split"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/transferleader"
 	"github.com/apache/incubator-horaedb-meta/server/id"
@@ -39,13 +43,20 @@ import (
 )
 
 type Factory struct {
-	logger      *zap.Logger
-	idAllocator id.Allocator
-	dispatch    eventdispatch.Dispatch
-	storage     procedure.Storage
-	shardPicker *PersistShardPicker
+	logger          *zap.Logger
+	idAllocator     id.Allocator
+	dispatch        eventdispatch.Dispatch
+	storage         procedure.Storage
+	clusterMetadata *metadata.ClusterMetadata
+	shardPicker     *PersistShardPicker
+
+	// Kind -> the decoder used to rebuild the persisted procedure of this kind.
+	decoders map[procedure.Kind]DecodeFunc
 }
 
+// DecodeFunc rebuilds the procedure of a specific kind from its persisted meta.
+type DecodeFunc func(ctx context.Context, meta *procedure.Meta) (procedure.Procedure, error)
+
 type CreateTableRequest struct {
 	ClusterMetadata *metadata.ClusterMetadata
 	SourceReq       *metaservicepb.CreateTableRequest
@@ -78,6 +89,21 @@ type TransferLeaderRequest struct {
 	NewLeaderNodeName string
 }
 
+// FollowerRequest describes the follower of the shard on the node, which is opened or closed.
+type FollowerRequest struct {
+	Snapshot metadata.Snapshot
+	ShardID  storage.ShardID
+	NodeName string
+}
+
+type PromoteFollowerRequest struct {
+	Snapshot metadata.Snapshot
+	ShardID  storage.ShardID
+	// OldLeaderNodeName is empty if the old leader has been dropped from the topology.
+	OldLeaderNodeName string
+	FollowerNodeName  string
+}
+
 type SplitRequest struct {
 	ClusterMetadata *metadata.ClusterMetadata
 	SchemaName      string
@@ -85,9 +111,35 @@ type SplitRequest struct {
 	Snapshot        metadata.Snapshot
 	ShardID         storage.ShardID
 	NewShardID      storage.ShardID
+	// AllocNewShardID tells the procedure to allocate the new shard when it starts, and NewShardID is ignored then.
+	AllocNewShardID bool
 	TargetNodeName  string
 }
 
+type MigrateRequest struct {
+	ClusterMetadata *metadata.ClusterMetadata
+	Snapshot        metadata.Snapshot
+	SchemaName      string
+	TableNames      []string
+	SourceShardID   storage.ShardID
+	TargetShardID   storage.ShardID
+}
+
+type MergeRequest struct {
+	ClusterMetadata *metadata.ClusterMetadata
+	Snapshot        metadata.Snapshot
+	SourceShardID   storage.ShardID
+	TargetShardID   storage.ShardID
+}
+
+type ScatterRequest struct {
+	ClusterMetadata *metadata.ClusterMetadata
+	Snapshot        metadata.Snapshot
+	ShardNodes      []storage.ShardNode
+	OpenedShards    []storage.ShardID
+	BatchSize       uint32
+}
+
 type CreatePartitionTableRequest struct {
 	ClusterMetadata *metadata.ClusterMetadata
 	SourceReq       *metaservicepb.CreateTableRequest
@@ -102,13 +154,44 @@ type BatchRequest struct {
 }
 
 func NewFactory(logger *zap.Logger, allocator id.Allocator, dispatch eventdispatch.Dispatch, storage procedure.Storage, clusterMetadata *metadata.ClusterMetadata) *Factory {
-	return &Factory{
-		idAllocator: allocator,
-		dispatch:    dispatch,
-		storage:     storage,
-		logger:      logger,
-		shardPicker: NewPersistShardPicker(clusterMetadata, NewLeastTableShardPicker()),
+	f := &Factory{
+		idAllocator:     allocator,
+		dispatch:        dispatch,
+		storage:         storage,
+		clusterMetadata: clusterMetadata,
+		logger:          logger,
+		shardPicker:     NewPersistShardPicker(clusterMetadata, NewLeastTableShardPicker()),
+		decoders:        map[procedure.Kind]DecodeFunc{},
 	}
+
+	f.RegisterDecoder(procedure.Migrate, f.decodeMigrateProcedure)
+	f.RegisterDecoder(procedure.Merge, f.decodeMergeProcedure)
+	f.RegisterDecoder(procedure.Scatter, f.decodeScatterProcedure)
+	f.RegisterDecoder(procedure.Split, f.decodeSplitProcedure)
+	f.RegisterDecoder(procedure.TransferLeader, f.decodeTransferLeaderProcedure)
+	f.RegisterDecoder(procedure.OpenFollower, f.decodeFollowerProcedure)
+	f.RegisterDecoder(procedure.CloseFollower, f.decodeFollowerProcedure)
+	f.RegisterDecoder(procedure.PromoteFollower, f.decodeFollowerProcedure)
+	f.RegisterDecoder(procedure.CreatePartitionTable, f.decodeCreatePartitionTableProcedure)
+	f.RegisterDecoder(procedure.DropPartitionTable, f.decodeDropPartitionTableProcedure)
+
+	return f
+}
+
+// RegisterDecoder registers the decoder of the procedures of the kind, the registered one will be replaced.
+// It must be called before the procedure manager is started.
+func (f *Factory) RegisterDecoder(kind procedure.Kind, decode DecodeFunc) {
+	f.decoders[kind] = decode
+}
+
+// Decode rebuilds the procedure from its persisted meta by the decoder registered for its kind.
+func (f *Factory) Decode(ctx context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	decode, ok := f.decoders[meta.Kind]
+	if !ok {
+		return nil, errors.WithMessagef(procedure.ErrDecoderNotFound, "procedureID:%d, kind:%d", meta.ID, meta.Kind)
+	}
+
+	return decode(ctx, meta)
 }
 
 func (f *Factory) MakeCreateTableProcedure(ctx context.Context, request CreateTableRequest) (procedure.Procedure, error) {
@@ -169,8 +252,9 @@ func (f *Factory) makeCreatePartitionTableProcedure(ctx context.Context, request
 
 	snapshot := request.ClusterMetadata.GetClusterSnapshot()
 
-	nodeNames := make(map[string]int, len(snapshot.Topology.ClusterView.ShardNodes))
-	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+	leaderShardNodes := snapshot.Topology.LeaderShardNodes()
+	nodeNames := make(map[string]int, len(leaderShardNodes))
+	for _, shardNode := range leaderShardNodes {
 		nodeNames[shardNode.NodeName] = 1
 	}
 
@@ -262,6 +346,55 @@ func (f *Factory) CreateTransferLeaderProcedure(ctx context.Context, request Tra
 	})
 }
 
+func (f *Factory) CreateOpenFollowerProcedure(ctx context.Context, request FollowerRequest) (procedure.Procedure, error) {
+	id, err := f.allocProcedureID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return follower.NewOpenProcedure(follower.ProcedureParams{
+		ID:              id,
+		Dispatch:        f.dispatch,
+		Storage:         f.storage,
+		ClusterSnapshot: request.Snapshot,
+		ShardID:         request.ShardID,
+		NodeName:        request.NodeName,
+	})
+}
+
+func (f *Factory) CreateCloseFollowerProcedure(ctx context.Context, request FollowerRequest) (procedure.Procedure, error) {
+	id, err := f.allocProcedureID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return follower.NewCloseProcedure(follower.ProcedureParams{
+		ID:              id,
+		Dispatch:        f.dispatch,
+		Storage:         f.storage,
+		ClusterSnapshot: request.Snapshot,
+		ShardID:         request.ShardID,
+		NodeName:        request.NodeName,
+	})
+}
+
+func (f *Factory) CreatePromoteFollowerProcedure(ctx context.Context, request PromoteFollowerRequest) (procedure.Procedure, error) {
+	id, err := f.allocProcedureID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return follower.NewPromoteProcedure(follower.ProcedureParams{
+		ID:                id,
+		Dispatch:          f.dispatch,
+		Storage:           f.storage,
+		ClusterSnapshot:   request.Snapshot,
+		ShardID:           request.ShardID,
+		NodeName:          request.FollowerNodeName,
+		OldLeaderNodeName: request.OldLeaderNodeName,
+	})
+}
+
 func (f *Factory) CreateSplitProcedure(ctx context.Context, request SplitRequest) (procedure.Procedure, error) {
 	id, err := f.allocProcedureID(ctx)
 	if err != nil {
@@ -277,6 +410,7 @@ func (f *Factory) CreateSplitProcedure(ctx context.Context, request SplitRequest
 			ClusterSnapshot: request.Snapshot,
 			ShardID:         request.ShardID,
 			NewShardID:      request.NewShardID,
+			AllocNewShardID: request.AllocNewShardID,
 			SchemaName:      request.SchemaName,
 			TableNames:      request.TableNames,
 			TargetNodeName:  request.TargetNodeName,
@@ -284,13 +418,209 @@ func (f *Factory) CreateSplitProcedure(ctx context.Context, request SplitRequest
 	)
 }
 
+func (f *Factory) CreateMigrateProcedure(ctx context.Context, request MigrateRequest) (procedure.Procedure, error) {
+	id, err := f.allocProcedureID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewProcedure(
+		migrate.ProcedureParams{
+			ID:              id,
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterMetadata: request.ClusterMetadata,
+			ClusterSnapshot: request.Snapshot,
+			SchemaName:      request.SchemaName,
+			TableNames:      request.TableNames,
+			SourceShardID:   request.SourceShardID,
+			TargetShardID:   request.TargetShardID,
+		},
+	)
+}
+
+func (f *Factory) CreateMergeProcedure(ctx context.Context, request MergeRequest) (procedure.Procedure, error) {
+	id, err := f.allocProcedureID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return merge.NewProcedure(
+		merge.ProcedureParams{
+			ID:              id,
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterMetadata: request.ClusterMetadata,
+			ClusterSnapshot: request.Snapshot,
+			SourceShardID:   request.SourceShardID,
+			TargetShardID:   request.TargetShardID,
+		},
+	)
+}
+
+func (f *Factory) CreateScatterProcedure(ctx context.Context, request ScatterRequest) (procedure.Procedure, error) {
+	id, err := f.allocProcedureID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return scatter.NewProcedure(
+		scatter.ProcedureParams{
+			ID:              id,
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterMetadata: request.ClusterMetadata,
+			ClusterSnapshot: request.Snapshot,
+			ShardNodes:      request.ShardNodes,
+			OpenedShards:    request.OpenedShards,
+			BatchSize:       request.BatchSize,
+		},
+	)
+}
+
+func (f *Factory) decodeMigrateProcedure(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return migrate.DecodeProcedure(
+		migrate.ProcedureParams{
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterMetadata: f.clusterMetadata,
+			ClusterSnapshot: f.clusterMetadata.GetClusterSnapshot(),
+		},
+		meta,
+	)
+}
+
+func (f *Factory) decodeMergeProcedure(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return merge.DecodeProcedure(
+		merge.ProcedureParams{
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterMetadata: f.clusterMetadata,
+			ClusterSnapshot: f.clusterMetadata.GetClusterSnapshot(),
+		},
+		meta,
+	)
+}
+
+func (f *Factory) decodeScatterProcedure(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return scatter.DecodeProcedure(
+		scatter.ProcedureParams{
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterMetadata: f.clusterMetadata,
+			ClusterSnapshot: f.clusterMetadata.GetClusterSnapshot(),
+		},
+		meta,
+	)
+}
+
+func (f *Factory) decodeSplitProcedure(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return split.DecodeProcedure(
+		split.ProcedureParams{
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterMetadata: f.clusterMetadata,
+			ClusterSnapshot: f.clusterMetadata.GetClusterSnapshot(),
+		},
+		meta,
+	)
+}
+
+func (f *Factory) decodeFollowerProcedure(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return follower.DecodeProcedure(
+		follower.ProcedureParams{
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterSnapshot: f.clusterMetadata.GetClusterSnapshot(),
+		},
+		meta,
+	)
+}
+
+func (f *Factory) decodeTransferLeaderProcedure(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return transferleader.DecodeProcedure(
+		transferleader.ProcedureParams{
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			ClusterSnapshot: f.clusterMetadata.GetClusterSnapshot(),
+		},
+		meta,
+	)
+}
+
+// The client waiting for the recovered DDL procedures has gone, so the result is only logged.
+func (f *Factory) decodeCreatePartitionTableProcedure(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return createpartitiontable.DecodeProcedure(
+		createpartitiontable.ProcedureParams{
+			ClusterMetadata: f.clusterMetadata,
+			ClusterSnapshot: f.clusterMetadata.GetClusterSnapshot(),
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			OnSucceeded: func(result metadata.CreateTableResult) error {
+				f.logger.Info("recovered create partition table procedure succeeded", zap.Uint64("procedureID", meta.ID), zap.String("tableName", result.Table.Name))
+				return nil
+			},
+			OnFailed: func(err error) error {
+				f.logger.Error("recovered create partition table procedure failed", zap.Uint64("procedureID", meta.ID), zap.Error(err))
+				return nil
+			},
+		},
+		meta,
+	)
+}
+
+func (f *Factory) decodeDropPartitionTableProcedure(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return droppartitiontable.DecodeProcedure(
+		droppartitiontable.ProcedureParams{
+			ClusterMetadata: f.clusterMetadata,
+			ClusterSnapshot: f.clusterMetadata.GetClusterSnapshot(),
+			Dispatch:        f.dispatch,
+			Storage:         f.storage,
+			OnSucceeded: func(result metadata.TableInfo) error {
+				f.logger.Info("recovered drop partition table procedure succeeded", zap.Uint64("procedureID", meta.ID), zap.String("tableName", result.Name))
+				return nil
+			},
+			OnFailed: func(err error) error {
+				f.logger.Error("recovered drop partition table procedure failed", zap.Uint64("procedureID", meta.ID), zap.Error(err))
+				return nil
+			},
+		},
+		meta,
+	)
+}
+
 func (f *Factory) CreateBatchTransferLeaderProcedure(ctx context.Context, request BatchRequest) (procedure.Procedure, error) {
 	id, err := f.allocProcedureID(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return transferleader.NewBatchTransferLeaderProcedure(id, request.Batch)
+	return transferleader.NewBatchTransferLeaderProcedure(id, request.BatchType, request.Batch)
+}
+
+// DryRunFactory returns a copy of the factory for the procedures which are created to be inspected rather than submitted, so no
+// procedure id is consumed by them.
+func (f *Factory) DryRunFactory() *Factory {
+	return &Factory{
+		logger:          f.logger,
+		idAllocator:     nopIDAllocator{},
+		dispatch:        f.dispatch,
+		storage:         f.storage,
+		clusterMetadata: f.clusterMetadata,
+		shardPicker:     f.shardPicker,
+		decoders:        f.decoders,
+	}
+}
+
+// nopIDAllocator allocates zero as the id of every procedure created by the dry run factory.
+type nopIDAllocator struct{}
+
+func (nopIDAllocator) Alloc(_ context.Context) (uint64, error) {
+	return 0, nil
+}
+
+func (nopIDAllocator) Collect(_ context.Context, _ uint64) error {
+	return nil
 }
 
 func (f *Factory) allocProcedureID(ctx context.Context) (uint64, error) {
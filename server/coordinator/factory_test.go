@@ -22,12 +22,16 @@ package coordinator_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -163,9 +167,161 @@ func TestSplit(t *testing.T) {
 		Snapshot:        snapshot,
 		ShardID:         snapshot.Topology.ClusterView.ShardNodes[0].ID,
 		NewShardID:      100,
+		AllocNewShardID: false,
 		TargetNodeName:  snapshot.Topology.ClusterView.ShardNodes[0].NodeName,
 	})
 	re.NoError(err)
 	re.Equal(procedure.Split, p.Kind())
 	re.Equal(procedure.StateInit, string(p.State()))
 }
+
+func TestExpandShards(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	f, m := setupFactory(t)
+	snapshot := m.GetClusterSnapshot()
+	newShardIDs := []storage.ShardID{test.DefaultShardTotal, test.DefaultShardTotal + 1}
+	shardNodes := make(map[storage.ShardID]string, len(newShardIDs))
+	for _, shardID := range newShardIDs {
+		shardNodes[shardID] = snapshot.RegisteredNodes[0].Node.Name
+	}
+	p, err := f.CreateExpandShardsProcedure(ctx, coordinator.ExpandShardsRequest{
+		ClusterMetadata: m,
+		Snapshot:        snapshot,
+		NewShardIDs:     newShardIDs,
+		ShardNodes:      shardNodes,
+		SplitTables:     false,
+	})
+	re.NoError(err)
+	re.Equal(procedure.TransferLeader, p.Kind())
+	re.Equal(procedure.StateInit, string(p.State()))
+
+	// The views of the new shards are created empty if no table is split onto them.
+	shardViews := m.GetClusterSnapshot().Topology.ShardViewsMapping
+	for _, shardID := range newShardIDs {
+		re.Contains(shardViews, shardID)
+		re.Empty(shardViews[shardID].TableIDs)
+	}
+}
+
+// crashStorage fails all the writes after the limited ones, which simulates the crash of the server.
+type crashStorage struct {
+	procedure.Storage
+	remainingWrites int
+}
+
+func (s *crashStorage) CreateOrUpdate(ctx context.Context, meta procedure.Meta) error {
+	if s.remainingWrites == 0 {
+		return errors.New("server crashed")
+	}
+	s.remainingWrites--
+	return s.Storage.CreateOrUpdate(ctx, meta)
+}
+
+func TestRecoverProcedures(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	c := test.InitStableCluster(ctx, t)
+	m := c.GetMetadata()
+	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
+	procedureStorage := procedure.NewEtcdStorageImpl(client, test.TestRootPath, uint32(m.GetClusterID()))
+
+	snapshot := m.GetClusterSnapshot()
+	shardNode := snapshot.Topology.ClusterView.ShardNodes[0]
+	_, err := m.CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       shardNode.ID,
+		LatestVersion: 0,
+		SchemaName:    test.TestSchemaName,
+		TableName:     test.TestTableName0,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	newShardID, err := m.AllocShardID(ctx)
+	re.NoError(err)
+
+	// The split crashes after the new shard is created and the table is moved, but only the creation is persisted.
+	splitFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, &crashStorage{Storage: procedureStorage, remainingWrites: 2}, m)
+	splitProcedure, err := splitFactory.CreateSplitProcedure(ctx, coordinator.SplitRequest{
+		ClusterMetadata: m,
+		SchemaName:      test.TestSchemaName,
+		TableNames:      []string{test.TestTableName0},
+		Snapshot:        m.GetClusterSnapshot(),
+		ShardID:         shardNode.ID,
+		NewShardID:      storage.ShardID(newShardID),
+		AllocNewShardID: false,
+		TargetNodeName:  shardNode.NodeName,
+	})
+	re.NoError(err)
+	re.Error(splitProcedure.Start(ctx))
+
+	// The creation of the partition table crashes after the sub tables are created, but only the partition table is persisted.
+	createFactory := coordinator.NewFactory(zap.NewNop(), mockIDAllocator{id: 1}, test.MockDispatch{}, &crashStorage{Storage: procedureStorage, remainingWrites: 2}, m)
+	subTableNames := []string{"partition-0", "partition-1"}
+	createProcedure, err := createFactory.MakeCreateTableProcedure(ctx, coordinator.CreateTableRequest{
+		ClusterMetadata: m,
+		SourceReq: &metaservicepb.CreateTableRequest{
+			Header:           nil,
+			SchemaName:       test.TestSchemaName,
+			Name:             "partition",
+			EncodedSchema:    nil,
+			Engine:           "",
+			CreateIfNotExist: false,
+			Options:          nil,
+			PartitionTableInfo: &metaservicepb.PartitionTableInfo{
+				PartitionInfo: nil,
+				SubTableNames: subTableNames,
+			},
+		},
+		OnSucceeded: func(_ metadata.CreateTableResult) error { return nil },
+		OnFailed:    func(_ error) error { return nil },
+	})
+	re.NoError(err)
+	re.Error(createProcedure.Start(ctx))
+
+	// Both procedures are resumed from the persisted states after the restart.
+	f := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, procedureStorage, m)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), m, procedureStorage, f, procedure.ManagerOptions{})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+	defer func() {
+		re.NoError(manager.Stop(ctx))
+	}()
+
+	for _, kind := range []procedure.Kind{procedure.Split, procedure.CreatePartitionTable} {
+		kind := kind
+		re.Eventually(func() bool {
+			metas, err := procedureStorage.ListDeleted(ctx, kind, 10)
+			re.NoError(err)
+			return len(metas) == 1 && metas[0].State == procedure.StateFinished
+		}, time.Second*5, time.Millisecond*100)
+	}
+
+	table, exists, err := m.GetTable(test.TestSchemaName, test.TestTableName0)
+	re.NoError(err)
+	re.True(exists)
+	shardID, exists := m.GetTableShard(ctx, table)
+	re.True(exists)
+	re.Equal(storage.ShardID(newShardID), shardID)
+	for _, subTableName := range subTableNames {
+		table, exists, err := m.GetTable(test.TestSchemaName, subTableName)
+		re.NoError(err)
+		re.True(exists)
+		_, exists = m.GetTableShard(ctx, table)
+		re.True(exists)
+	}
+	_, exists, err = m.GetTable(test.TestSchemaName, "partition")
+	re.NoError(err)
+	re.True(exists)
+}
+
+type mockIDAllocator struct {
+	id uint64
+}
+
+func (m mockIDAllocator) Alloc(_ context.Context) (uint64, error) {
+	return m.id, nil
+}
+
+func (m mockIDAllocator) Collect(_ context.Context, _ uint64) error {
+	return nil
+}
@@ -39,7 +39,7 @@ func (p *PersistShardPicker) PickShards(ctx context.Context, snapshot metadata.S
 	result := map[string]storage.ShardNode{}
 
 	shardNodeMap := make(map[storage.ShardID]storage.ShardNode, len(tableNames))
-	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+	for _, shardNode := range snapshot.Topology.LeaderShardNodes() {
 		shardNodeMap[shardNode.ID] = shardNode
 	}
 
@@ -23,7 +23,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/assert"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -36,12 +39,14 @@ import (
 	"github.com/looplab/fsm"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 // fsm state change:
 // ┌────────┐     ┌──────────────────────┐     ┌────────────────────┐     ┌───────────┐
 // │ Begin  ├─────▶ CreatePartitionTable ├─────▶  CreateDataTables  ├──────▶  Finish  │
 // └────────┘     └──────────────────────┘     └────────────────────┘     └───────────┘
+// If any step fails, the completed steps will be compensated in the reverse order before the failure is reported.
 const (
 	eventCreatePartitionTable = "EventCreatePartitionTable"
 	eventCreateSubTables      = "EventCreateSubTables"
@@ -66,14 +71,39 @@ var (
 	}
 )
 
+// rollbackTimeout is the deadline of the compensation, which is not bounded by the deadline of the procedure.
+const rollbackTimeout = time.Second * 30
+
+// subTableStep is the last completed step of creating a sub table.
+type subTableStep int
+
+const (
+	subTableStepMetadataCreated subTableStep = iota + 1
+	subTableStepCreatedOnShard
+	subTableStepTopologyAdded
+)
+
+type subTableProgress struct {
+	ShardID storage.ShardID
+	Step    subTableStep
+}
+
 type Procedure struct {
 	fsm                        *fsm.FSM
 	params                     ProcedureParams
 	relatedVersionInfo         procedure.RelatedVersionInfo
 	createPartitionTableResult *metadata.CreateTableMetadataResult
 
+	// Protect the state and the progresses.
 	lock  sync.RWMutex
 	state procedure.State
+	// TableName -> the completed steps of the sub table, which will be compensated if the procedure fails.
+	subTableProgresses map[string]subTableProgress
+	// The recovered procedure reuses the tables created before the progresses are persisted.
+	recovered bool
+
+	// Serialize the persistence, so that the progresses persisted by the sub tables concurrently won't be overwritten by the stale ones.
+	persistLock sync.Mutex
 }
 
 type ProcedureParams struct {
@@ -89,6 +119,45 @@ type ProcedureParams struct {
 }
 
 func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	p, err := newProcedure(params)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DecodeProcedure rebuilds the persisted procedure with the current cluster snapshot in the params.
+func DecodeProcedure(params ProcedureParams, meta *procedure.Meta) (procedure.Procedure, error) {
+	var data rawData
+	if err := json.Unmarshal(meta.RawData, &data); err != nil {
+		return nil, procedure.ErrDecodeRawData.WithCausef("unmarshal raw data, procedureID:%d, err:%v", meta.ID, err)
+	}
+
+	params.ID = data.ID
+	params.SourceReq = data.SourceReq
+	params.SubTablesShards = data.SubTablesShards
+	p, err := newProcedure(params)
+	if err != nil {
+		return nil, err
+	}
+
+	p.recovered = true
+	if data.SubTableProgresses != nil {
+		p.subTableProgresses = data.SubTableProgresses
+	}
+	if data.PartitionTableCreated {
+		table, err := ddl.GetTableMetadata(params.ClusterMetadata, params.SourceReq.GetSchemaName(), params.SourceReq.GetName())
+		if err != nil {
+			return nil, errors.WithMessage(err, "get partition table")
+		}
+		p.createPartitionTableResult = &metadata.CreateTableMetadataResult{Table: table}
+	}
+	p.fsm.SetState(data.FsmState)
+
+	return p, nil
+}
+
+func newProcedure(params ProcedureParams) (*Procedure, error) {
 	relatedVersionInfo, err := buildRelatedVersionInfo(params)
 	if err != nil {
 		return nil, err
@@ -107,6 +176,9 @@ func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
 		createPartitionTableResult: nil,
 		lock:                       sync.RWMutex{},
 		state:                      procedure.StateInit,
+		subTableProgresses:         map[string]subTableProgress{},
+		recovered:                  false,
+		persistLock:                sync.Mutex{},
 	}, nil
 }
 
@@ -146,6 +218,13 @@ func (p *Procedure) Priority() procedure.Priority {
 func (p *Procedure) Start(ctx context.Context) error {
 	p.updateStateWithLock(procedure.StateRunning)
 
+	// The procedure can't be interrupted once it starts, because the partition table is created by the first step.
+	sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "create partition table procedure begin side effects")
+	}
+	ctx = sideEffectsCtx
+
 	createPartitionTableRequest := &callbackRequest{
 		ctx: ctx,
 		p:   p,
@@ -158,27 +237,21 @@ func (p *Procedure) Start(ctx context.Context) error {
 				return errors.WithMessage(err, "persist create partition table procedure")
 			}
 			if err := p.fsm.Event(eventCreatePartitionTable, createPartitionTableRequest); err != nil {
-				p.updateStateWithLock(procedure.StateFailed)
-				_ = p.params.OnFailed(err)
-				return errors.WithMessage(err, "create partition table")
+				return p.fail(ctx, errors.WithMessage(err, "create partition table"))
 			}
 		case stateCreatePartitionTable:
 			if err := p.persist(ctx); err != nil {
 				return errors.WithMessage(err, "persist create partition table procedure")
 			}
 			if err := p.fsm.Event(eventCreateSubTables, createPartitionTableRequest); err != nil {
-				p.updateStateWithLock(procedure.StateFailed)
-				_ = p.params.OnFailed(err)
-				return errors.WithMessage(err, "create data tables")
+				return p.fail(ctx, errors.WithMessage(err, "create data tables"))
 			}
 		case stateCreateSubTables:
 			if err := p.persist(ctx); err != nil {
 				return errors.WithMessage(err, "persist create partition table procedure")
 			}
 			if err := p.fsm.Event(eventFinish, createPartitionTableRequest); err != nil {
-				p.updateStateWithLock(procedure.StateFailed)
-				_ = p.params.OnFailed(err)
-				return errors.WithMessage(err, "update table shard metadata")
+				return p.fail(ctx, errors.WithMessage(err, "update table shard metadata"))
 			}
 		case stateFinish:
 			// TODO: The state update sequence here is inconsistent with the previous one. Consider reconstructing the state update logic of the state machine.
@@ -192,6 +265,126 @@ func (p *Procedure) Start(ctx context.Context) error {
 	}
 }
 
+// fail compensates the completed steps, and reports the failure to the client after that.
+func (p *Procedure) fail(ctx context.Context, err error) error {
+	p.updateStateWithLock(procedure.StateFailed)
+
+	rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	if rollbackErr := p.rollback(rollbackCtx); rollbackErr != nil {
+		log.Error("rollback create partition table failed", zap.Uint64("procedureID", p.ID()), zap.String("tableName", p.params.SourceReq.GetName()), zap.Error(rollbackErr))
+		err = errors.WithMessagef(err, "rollback failed, rollbackErr:%v", rollbackErr)
+	}
+	if persistErr := p.persist(rollbackCtx); persistErr != nil {
+		log.Warn("persist create partition table procedure failed", zap.Uint64("procedureID", p.ID()), zap.Error(persistErr))
+	}
+
+	_ = p.params.OnFailed(err)
+	return err
+}
+
+// rollback drops the created sub tables and the partition table, and all the steps will be tried even if some of them fail.
+func (p *Procedure) rollback(ctx context.Context) error {
+	p.lock.RLock()
+	tableNames := make([]string, 0, len(p.subTableProgresses))
+	for tableName := range p.subTableProgresses {
+		tableNames = append(tableNames, tableName)
+	}
+	partitionTableCreated := p.createPartitionTableResult != nil
+	p.lock.RUnlock()
+	sort.Strings(tableNames)
+
+	var failedSteps []string
+	for _, tableName := range tableNames {
+		if err := p.rollbackSubTable(ctx, tableName); err != nil {
+			failedSteps = append(failedSteps, fmt.Sprintf("drop sub table %s: %v", tableName, err))
+		}
+	}
+	if partitionTableCreated {
+		if _, err := p.params.ClusterMetadata.DropTableMetadata(ctx, p.params.SourceReq.GetSchemaName(), p.params.SourceReq.GetName()); err != nil {
+			failedSteps = append(failedSteps, fmt.Sprintf("drop partition table metadata: %v", err))
+		} else {
+			p.lock.Lock()
+			p.createPartitionTableResult = nil
+			p.lock.Unlock()
+		}
+	}
+
+	if len(failedSteps) > 0 {
+		return errors.Errorf("%s", strings.Join(failedSteps, "; "))
+	}
+	log.Info("rollback create partition table finish", zap.Uint64("procedureID", p.ID()), zap.String("tableName", p.params.SourceReq.GetName()), zap.Strings("subTables", tableNames))
+	return nil
+}
+
+func (p *Procedure) rollbackSubTable(ctx context.Context, tableName string) error {
+	params := p.params
+	p.lock.RLock()
+	progress := p.subTableProgresses[tableName]
+	p.lock.RUnlock()
+
+	table, err := ddl.GetTableMetadata(params.ClusterMetadata, params.SourceReq.GetSchemaName(), tableName)
+	if err != nil {
+		return err
+	}
+
+	if progress.Step >= subTableStepCreatedOnShard {
+		shardView, exists := params.ClusterMetadata.GetClusterSnapshot().Topology.ShardViewsMapping[progress.ShardID]
+		if !exists {
+			return errors.WithMessagef(metadata.ErrShardNotFound, "shardID:%d", progress.ShardID)
+		}
+		latestShardVersion, err := ddl.DropTableOnShard(ctx, params.ClusterMetadata, params.Dispatch, params.SourceReq.GetSchemaName(), table, metadata.ShardVersionUpdate{
+			ShardID:       progress.ShardID,
+			LatestVersion: shardView.Version,
+		})
+		if err != nil {
+			if progress.Step == subTableStepTopologyAdded {
+				return errors.WithMessage(err, "drop table on shard")
+			}
+			// The table is not added to the topology, so it can't be accessed by the client after its metadata is dropped.
+			log.Warn("drop table on shard failed, only its metadata will be dropped", zap.String("tableName", tableName), zap.Error(err))
+		}
+
+		if err == nil && progress.Step == subTableStepTopologyAdded {
+			if err := params.ClusterMetadata.DropTable(ctx, metadata.DropTableRequest{
+				SchemaName:    params.SourceReq.GetSchemaName(),
+				TableName:     tableName,
+				ShardID:       progress.ShardID,
+				LatestVersion: latestShardVersion,
+			}); err != nil {
+				return errors.WithMessage(err, "drop table")
+			}
+			p.removeSubTableProgress(tableName)
+			return nil
+		}
+	}
+
+	if _, err := params.ClusterMetadata.DropTableMetadata(ctx, params.SourceReq.GetSchemaName(), tableName); err != nil {
+		return errors.WithMessage(err, "drop table metadata")
+	}
+	p.removeSubTableProgress(tableName)
+	return nil
+}
+
+// recordSubTableProgress records and persists the completed step of the sub table, so that the step can be compensated by the recovered
+// procedure too. The failure of the persistence is tolerated, because the recovered procedure reuses the existing sub tables anyway.
+func (p *Procedure) recordSubTableProgress(ctx context.Context, tableName string, shardID storage.ShardID, step subTableStep) {
+	p.lock.Lock()
+	p.subTableProgresses[tableName] = subTableProgress{ShardID: shardID, Step: step}
+	p.lock.Unlock()
+
+	if err := p.persist(ctx); err != nil {
+		log.Warn("persist sub table progress failed", zap.Uint64("procedureID", p.ID()), zap.String("tableName", tableName), zap.Error(err))
+	}
+}
+
+func (p *Procedure) removeSubTableProgress(tableName string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.subTableProgresses, tableName)
+}
+
 func (p *Procedure) Cancel(_ context.Context) error {
 	p.updateStateWithLock(procedure.StateCancelled)
 	return nil
@@ -204,6 +397,10 @@ func (p *Procedure) State() procedure.State {
 	return p.state
 }
 
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
 type callbackRequest struct {
 	ctx context.Context
 	p   *Procedure
@@ -218,6 +415,28 @@ func createPartitionTableCallback(event *fsm.Event) {
 	}
 	params := req.p.params
 
+	// The partition table has been created by the recovered procedure.
+	req.p.lock.RLock()
+	created := req.p.createPartitionTableResult != nil
+	req.p.lock.RUnlock()
+	if created {
+		return
+	}
+	// The partition table may be created before the procedure is persisted, and it is reused so that it can be dropped by the rollback.
+	if req.p.recovered {
+		table, exists, err := params.ClusterMetadata.GetTable(params.SourceReq.GetSchemaName(), params.SourceReq.GetName())
+		if err != nil {
+			procedure.CancelEventWithLog(event, err, "get table")
+			return
+		}
+		if exists {
+			req.p.lock.Lock()
+			req.p.createPartitionTableResult = &metadata.CreateTableMetadataResult{Table: table}
+			req.p.lock.Unlock()
+			return
+		}
+	}
+
 	createTableMetadataResult, err := params.ClusterMetadata.CreateTableMetadata(req.ctx, metadata.CreateTableMetadataRequest{
 		SchemaName:    params.SourceReq.GetSchemaName(),
 		TableName:     params.SourceReq.GetName(),
@@ -227,7 +446,9 @@ func createPartitionTableCallback(event *fsm.Event) {
 		procedure.CancelEventWithLog(event, err, "create table metadata")
 		return
 	}
+	req.p.lock.Lock()
 	req.p.createPartitionTableResult = &createTableMetadataResult
+	req.p.lock.Unlock()
 }
 
 // 2. Create data tables in target nodes.
@@ -252,37 +473,35 @@ func createDataTablesCallback(event *fsm.Event) {
 		}
 		shardTableMetaDatas[subTableShard.ShardInfo.ID] = append(shardTableMetaDatas[subTableShard.ShardInfo.ID], tableMetaData)
 	}
-	succeedCh := make(chan bool)
-	errCh := make(chan error)
+	// Wait for all the sub tables to be handled, so that the compensation won't race with the creation.
+	g, _ := errgroup.WithContext(req.ctx)
 	for shardID, tableMetaDatas := range shardTableMetaDatas {
+		shardID := shardID
+		tableMetaDatas := tableMetaDatas
 		shardVersion := shardVersions[shardID]
-		go createDataTables(req, shardID, tableMetaDatas, shardVersion, succeedCh, errCh)
+		g.Go(func() error {
+			return createDataTables(req, shardID, tableMetaDatas, shardVersion)
+		})
 	}
 
-	goRoutineNumber := len(shardTableMetaDatas)
-	for {
-		select {
-		case err := <-errCh:
-			procedure.CancelEventWithLog(event, err, "create data tables")
-			return
-		case <-succeedCh:
-			goRoutineNumber--
-			if goRoutineNumber == 0 {
-				return
-			}
-		}
+	if err := g.Wait(); err != nil {
+		procedure.CancelEventWithLog(event, err, "create data tables")
+		return
 	}
 }
 
-func createDataTables(req *callbackRequest, shardID storage.ShardID, tableMetaDatas []metadata.CreateTableMetadataRequest, shardVersion uint64, succeedCh chan bool, errCh chan error) {
+func createDataTables(req *callbackRequest, shardID storage.ShardID, tableMetaDatas []metadata.CreateTableMetadataRequest, shardVersion uint64) error {
 	params := req.p.params
 
 	for _, tableMetaData := range tableMetaDatas {
-		result, err := params.ClusterMetadata.CreateTableMetadata(req.ctx, tableMetaData)
+		result, added, err := req.p.createSubTableMetadata(req.ctx, shardID, tableMetaData)
 		if err != nil {
-			errCh <- errors.WithMessage(err, "create table metadata")
-			return
+			return errors.WithMessage(err, "create table metadata")
+		}
+		if added {
+			continue
 		}
+		req.p.recordSubTableProgress(req.ctx, tableMetaData.TableName, shardID, subTableStepMetadataCreated)
 
 		shardVersionUpdate := metadata.ShardVersionUpdate{
 			ShardID:       shardID,
@@ -291,21 +510,43 @@ func createDataTables(req *callbackRequest, shardID storage.ShardID, tableMetaDa
 
 		latestShardVersion, err := ddl.CreateTableOnShard(req.ctx, params.ClusterMetadata, params.Dispatch, shardID, ddl.BuildCreateTableRequest(result.Table, shardVersionUpdate, params.SourceReq))
 		if err != nil {
-			errCh <- errors.WithMessage(err, "dispatch create table on shard")
-			return
+			return errors.WithMessage(err, "dispatch create table on shard")
 		}
+		req.p.recordSubTableProgress(req.ctx, tableMetaData.TableName, shardID, subTableStepCreatedOnShard)
 
 		err = params.ClusterMetadata.AddTableTopology(req.ctx, metadata.ShardVersionUpdate{
 			ShardID:       shardID,
 			LatestVersion: latestShardVersion,
 		}, result.Table)
 		if err != nil {
-			errCh <- errors.WithMessage(err, "create table metadata")
-			return
+			return errors.WithMessage(err, "create table metadata")
 		}
+		req.p.recordSubTableProgress(req.ctx, tableMetaData.TableName, shardID, subTableStepTopologyAdded)
 		shardVersion++
 	}
-	succeedCh <- true
+	return nil
+}
+
+// createSubTableMetadata creates the metadata of the sub table, and the recovered procedure reuses the existing one.
+// The returned bool is true if the sub table has been added to the topology, and nothing needs to be done.
+func (p *Procedure) createSubTableMetadata(ctx context.Context, shardID storage.ShardID, tableMetaData metadata.CreateTableMetadataRequest) (metadata.CreateTableMetadataResult, bool, error) {
+	params := p.params
+	if p.recovered {
+		table, exists, err := params.ClusterMetadata.GetTable(tableMetaData.SchemaName, tableMetaData.TableName)
+		if err != nil {
+			return metadata.CreateTableMetadataResult{}, false, err
+		}
+		if exists {
+			if _, added := params.ClusterMetadata.GetTableShard(ctx, table); added {
+				p.recordSubTableProgress(ctx, tableMetaData.TableName, shardID, subTableStepTopologyAdded)
+				return metadata.CreateTableMetadataResult{Table: table}, true, nil
+			}
+			return metadata.CreateTableMetadataResult{Table: table}, false, nil
+		}
+	}
+
+	result, err := params.ClusterMetadata.CreateTableMetadata(ctx, tableMetaData)
+	return result, false, err
 }
 
 func finishCallback(event *fsm.Event) {
@@ -335,6 +576,9 @@ func (p *Procedure) updateStateWithLock(state procedure.State) {
 }
 
 func (p *Procedure) persist(ctx context.Context) error {
+	p.persistLock.Lock()
+	defer p.persistLock.Unlock()
+
 	meta, err := p.convertToMeta()
 	if err != nil {
 		return errors.WithMessage(err, "convert to meta")
@@ -352,9 +596,14 @@ type rawData struct {
 	FsmState string
 	State    procedure.State
 
+	SourceReq            *metaservicepb.CreateTableRequest
 	CreateTableResult    *metadata.CreateTableResult
 	PartitionTableShards []metadata.ShardNodeWithVersion
 	SubTablesShards      []metadata.ShardNodeWithVersion
+
+	// The completed steps which haven't been compensated.
+	PartitionTableCreated bool
+	SubTableProgresses    map[string]subTableProgress
 }
 
 func (p *Procedure) convertToMeta() (procedure.Meta, error) {
@@ -365,9 +614,13 @@ func (p *Procedure) convertToMeta() (procedure.Meta, error) {
 		ID:                   p.params.ID,
 		FsmState:             p.fsm.Current(),
 		State:                p.state,
+		SourceReq:            p.params.SourceReq,
 		CreateTableResult:    nil,
 		PartitionTableShards: []metadata.ShardNodeWithVersion{},
 		SubTablesShards:      p.params.SubTablesShards,
+
+		PartitionTableCreated: p.createPartitionTableResult != nil,
+		SubTableProgresses:    p.subTableProgresses,
 	}
 	rawDataBytes, err := json.Marshal(rawData)
 	if err != nil {
@@ -21,24 +21,113 @@ package createpartitiontable_test
 
 import (
 	"context"
+	"encoding/json"
+	"sync"
 	"testing"
 
+	"github.com/apache/incubator-horaedb-meta/server/cluster"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl/createpartitiontable"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
+// failedDispatch fails to create the table whose name is failedTableName on shard.
+type failedDispatch struct {
+	test.MockDispatch
+	failedTableName string
+}
+
+func (d failedDispatch) CreateTableOnShard(_ context.Context, _ string, req eventdispatch.CreateTableOnShardRequest) (uint64, error) {
+	if req.TableInfo.Name == d.failedTableName {
+		return 0, errors.New("mock create table on shard failed")
+	}
+	return 0, nil
+}
+
 func TestCreatePartitionTable(t *testing.T) {
+	testCreatePartitionTable(t, test.MockDispatch{}, true)
+}
+
+func TestCreatePartitionTableRollback(t *testing.T) {
+	testCreatePartitionTable(t, failedDispatch{failedTableName: "p2"}, false)
+}
+
+// recordingStorage is the MockStorage which records the persisted metas, and it fails to persist more than maxMetas metas.
+type recordingStorage struct {
+	test.MockStorage
+	lock     sync.Mutex
+	metas    []procedure.Meta
+	maxMetas int
+}
+
+func (s *recordingStorage) CreateOrUpdate(_ context.Context, meta procedure.Meta) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.metas) >= s.maxMetas {
+		return errors.New("mock persist procedure failed")
+	}
+	s.metas = append(s.metas, meta)
+	return nil
+}
+
+func (s *recordingStorage) getMetas() []procedure.Meta {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]procedure.Meta{}, s.metas...)
+}
+
+func TestCreatePartitionTableRecoverRollback(t *testing.T) {
 	re := require.New(t)
 	ctx := context.Background()
-	dispatch := test.MockDispatch{}
-	s := test.NewTestStorage(t)
 	c := test.InitStableCluster(ctx, t)
 
+	// The procedure crashes after the partition table is created, before its state is persisted.
+	crashedStorage := &recordingStorage{MockStorage: test.MockStorage{}, lock: sync.Mutex{}, metas: nil, maxMetas: 1}
+	p, err := createpartitiontable.NewProcedure(newProcedureParams(ctx, t, c, test.MockDispatch{}, crashedStorage))
+	re.NoError(err)
+	re.Error(p.Start(ctx))
+	_, exists, err := c.GetMetadata().GetTable(test.TestSchemaName, test.TestTableName0)
+	re.NoError(err)
+	re.True(exists)
+
+	// The recovered procedure fails to create the sub tables, and all the created tables should be dropped.
+	recoveredStorage := &recordingStorage{MockStorage: test.MockStorage{}, lock: sync.Mutex{}, metas: nil, maxMetas: 100}
+	p, err = createpartitiontable.DecodeProcedure(newProcedureParams(ctx, t, c, failedDispatch{failedTableName: "p2"}, recoveredStorage), &crashedStorage.getMetas()[0])
+	re.NoError(err)
+	re.Error(p.Start(ctx))
+	for _, tableName := range []string{test.TestTableName0, "p1", "p2"} {
+		_, exists, err := c.GetMetadata().GetTable(test.TestSchemaName, tableName)
+		re.NoError(err)
+		re.False(exists)
+	}
+
+	// The progress of every sub table is persisted once its step is completed.
+	persistedSubTables := make(map[string]struct{})
+	for _, meta := range recoveredStorage.getMetas() {
+		var data struct {
+			SubTableProgresses map[string]json.RawMessage
+		}
+		re.NoError(json.Unmarshal(meta.RawData, &data))
+		for tableName := range data.SubTableProgresses {
+			persistedSubTables[tableName] = struct{}{}
+		}
+	}
+	re.Contains(persistedSubTables, "p1")
+	re.Contains(persistedSubTables, "p2")
+}
+
+func newProcedureParams(ctx context.Context, t *testing.T, c *cluster.Cluster, dispatch eventdispatch.Dispatch, s procedure.Storage) createpartitiontable.ProcedureParams {
+	re := require.New(t)
+
 	shardNode := c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes[0]
 
 	request := &metaservicepb.CreateTableRequest{
@@ -55,6 +144,7 @@ func TestCreatePartitionTable(t *testing.T) {
 
 	shardPicker := coordinator.NewLeastTableShardPicker()
 	subTableShards, err := shardPicker.PickShards(ctx, c.GetMetadata().GetClusterSnapshot(), len(request.GetPartitionTableInfo().SubTableNames))
+	re.NoError(err)
 
 	shardNodesWithVersion := make([]metadata.ShardNodeWithVersion, 0, len(subTableShards))
 	for _, subTableShard := range subTableShards {
@@ -71,8 +161,7 @@ func TestCreatePartitionTable(t *testing.T) {
 		})
 	}
 
-	re.NoError(err)
-	procedure, err := createpartitiontable.NewProcedure(createpartitiontable.ProcedureParams{
+	return createpartitiontable.ProcedureParams{
 		ID:              0,
 		ClusterMetadata: c.GetMetadata(),
 		ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
@@ -86,9 +175,30 @@ func TestCreatePartitionTable(t *testing.T) {
 		OnFailed: func(err error) error {
 			return nil
 		},
-	})
+	}
+}
+
+func testCreatePartitionTable(t *testing.T, dispatch eventdispatch.Dispatch, succeed bool) {
+	re := require.New(t)
+	ctx := context.Background()
+	s := test.NewTestStorage(t)
+	c := test.InitStableCluster(ctx, t)
+
+	params := newProcedureParams(ctx, t, c, dispatch, s)
+	procedure, err := createpartitiontable.NewProcedure(params)
 	re.NoError(err)
 
 	err = procedure.Start(ctx)
-	re.NoError(err)
+	if !succeed {
+		re.Error(err)
+	} else {
+		re.NoError(err)
+	}
+
+	// The created tables should be dropped if the procedure fails.
+	for _, tableName := range []string{params.SourceReq.GetName(), "p1", "p2"} {
+		_, exists, err := c.GetMetadata().GetTable(test.TestSchemaName, tableName)
+		re.NoError(err)
+		re.Equal(succeed, exists)
+	}
 }
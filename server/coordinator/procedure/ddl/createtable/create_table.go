@@ -288,6 +288,14 @@ func (p *Procedure) Start(ctx context.Context) error {
 				return err
 			}
 		case stateCheckTableExists:
+			// The procedure can't be interrupted once the table metadata is created.
+			sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+			if err != nil {
+				_ = p.params.OnFailed(err)
+				return errors.WithMessage(err, "create table procedure begin side effects")
+			}
+			ctx = sideEffectsCtx
+			req.ctx = ctx
 			if err := p.fsm.Event(eventCreateMetadata, req); err != nil {
 				_ = p.params.OnFailed(err)
 				return err
@@ -321,6 +329,10 @@ func (p *Procedure) State() procedure.State {
 	return p.state
 }
 
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
 func (p *Procedure) updateState(state procedure.State) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
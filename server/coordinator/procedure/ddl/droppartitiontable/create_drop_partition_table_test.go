@@ -22,6 +22,7 @@ package droppartitiontable_test
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster"
@@ -35,6 +36,7 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/metaservicepb"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -88,6 +90,46 @@ func TestCreateAndDropPartitionTable(t *testing.T) {
 	}
 }
 
+// failOnceDispatch fails to drop the table whose name is failedTableName on shard for the first time.
+type failOnceDispatch struct {
+	test.MockDispatch
+
+	lock            sync.Mutex
+	failedTableName string
+	failed          bool
+}
+
+func (d *failOnceDispatch) DropTableOnShard(_ context.Context, _ string, req eventdispatch.DropTableOnShardRequest) (uint64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if req.TableInfo.Name == d.failedTableName && !d.failed {
+		d.failed = true
+		return 0, errors.New("mock drop table on shard failed")
+	}
+	return 0, nil
+}
+
+func TestDropPartitionTableRollForward(t *testing.T) {
+	ctx := context.Background()
+	c := test.InitStableCluster(ctx, t)
+	s := test.NewTestStorage(t)
+
+	shardNode := c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes[0]
+	tableName := test.TestTableName0
+	subTableNames := genSubTables(tableName, 4)
+	testCreatePartitionTable(ctx, t, test.MockDispatch{}, c, s, coordinator.NewLeastTableShardPicker(), shardNode.NodeName, tableName, subTableNames)
+
+	// The first sub table is always dropped before the failure, so the procedure should roll forward.
+	dispatch := &failOnceDispatch{failedTableName: subTableNames[1]}
+	testDropPartitionTable(t, dispatch, c, s, shardNode.NodeName, tableName, subTableNames)
+
+	checkTable(t, c, tableName, false)
+	for _, subTableName := range subTableNames {
+		checkTable(t, c, subTableName, false)
+	}
+}
+
 func testCreatePartitionTable(ctx context.Context, t *testing.T, dispatch eventdispatch.Dispatch, c *cluster.Cluster, s procedure.Storage, shardPicker coordinator.ShardPicker, nodeName string, tableName string, subTableNames []string) {
 	re := require.New(t)
 
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
@@ -42,6 +43,8 @@ import (
 // ┌────────┐     ┌────────────────┐     ┌────────────────────┐      ┌───────────┐
 // │ Begin  ├─────▶  DropDataTable ├─────▶ DropPartitionTable ├──────▶  Finish   │
 // └────────┘     └────────────────┘     └────────────────────┘      └───────────┘
+// The dropped sub tables can't be recovered, so once any of them is dropped, the procedure rolls forward on failure:
+// the remaining sub tables and the partition table will be dropped with retries.
 const (
 	eventDropDataTable      = "EventDropDataTable"
 	eventDropPartitionTable = "EventDropPartitionTable"
@@ -66,14 +69,18 @@ var (
 	}
 )
 
+// rollForwardTimeout is the deadline of the roll forward, which is not bounded by the deadline of the procedure.
+const rollForwardTimeout = time.Second * 30
+
 type Procedure struct {
 	fsm                *fsm.FSM
 	params             ProcedureParams
 	relatedVersionInfo procedure.RelatedVersionInfo
 
-	// Protect the state.
-	lock  sync.RWMutex
-	state procedure.State
+	// Protect the state and the dropped sub tables.
+	lock             sync.RWMutex
+	state            procedure.State
+	droppedSubTables map[string]struct{}
 }
 
 type ProcedureParams struct {
@@ -104,9 +111,43 @@ func NewProcedure(params ProcedureParams) (*Procedure, bool, error) {
 		relatedVersionInfo: relatedVersionInfo,
 		lock:               sync.RWMutex{},
 		state:              stateBegin,
+		droppedSubTables:   map[string]struct{}{},
 	}, true, nil
 }
 
+// DecodeProcedure rebuilds the persisted procedure with the current cluster snapshot in the params.
+func DecodeProcedure(params ProcedureParams, meta *procedure.Meta) (procedure.Procedure, error) {
+	var data rawData
+	if err := json.Unmarshal(meta.RawData, &data); err != nil {
+		return nil, procedure.ErrDecodeRawData.WithCausef("unmarshal raw data, procedureID:%d, err:%v", meta.ID, err)
+	}
+
+	params.ID = data.ID
+	params.SourceReq = data.DropTableRequest
+	p, _, err := NewProcedure(params)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tableName := range data.DroppedSubTables {
+		p.droppedSubTables[tableName] = struct{}{}
+	}
+	fsmState := data.FsmState
+	// The partition table may have been dropped before the state is persisted, and nothing is left to do.
+	if fsmState == stateDropDataTable || fsmState == stateDropPartitionTable {
+		_, exists, err := params.ClusterMetadata.GetTable(params.SourceReq.GetSchemaName(), params.SourceReq.GetName())
+		if err != nil {
+			return nil, errors.WithMessage(err, "get partition table")
+		}
+		if !exists {
+			fsmState = stateFinish
+		}
+	}
+	p.fsm.SetState(fsmState)
+
+	return p, nil
+}
+
 func buildRelatedVersionInfo(params ProcedureParams) (procedure.RelatedVersionInfo, error) {
 	tableShardMapping := make(map[storage.TableID]storage.ShardID, len(params.SourceReq.PartitionTableInfo.GetSubTableNames()))
 	for shardID, shardView := range params.ClusterSnapshot.Topology.ShardViewsMapping {
@@ -161,6 +202,13 @@ func (p *Procedure) Priority() procedure.Priority {
 func (p *Procedure) Start(ctx context.Context) error {
 	p.updateStateWithLock(procedure.StateRunning)
 
+	// The procedure can't be interrupted once it starts, because the sub tables are dropped by the first step.
+	sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "drop partition table procedure begin side effects")
+	}
+	ctx = sideEffectsCtx
+
 	dropPartitionTableRequest := &callbackRequest{
 		ctx: ctx,
 		p:   p,
@@ -175,18 +223,14 @@ func (p *Procedure) Start(ctx context.Context) error {
 				return errors.WithMessage(err, "drop partition table procedure persist")
 			}
 			if err := p.fsm.Event(eventDropDataTable, dropPartitionTableRequest); err != nil {
-				p.updateStateWithLock(procedure.StateFailed)
-				_ = p.params.OnFailed(err)
-				return errors.WithMessage(err, "drop partition table procedure")
+				return p.fail(ctx, dropPartitionTableRequest, errors.WithMessage(err, "drop partition table procedure"))
 			}
 		case stateDropDataTable:
 			if err := p.persist(ctx); err != nil {
 				return errors.WithMessage(err, "drop partition table procedure persist")
 			}
 			if err := p.fsm.Event(eventDropPartitionTable, dropPartitionTableRequest); err != nil {
-				p.updateStateWithLock(procedure.StateFailed)
-				_ = p.params.OnFailed(err)
-				return errors.WithMessage(err, "drop partition table procedure drop data table")
+				return p.fail(ctx, dropPartitionTableRequest, errors.WithMessage(err, "drop partition table procedure drop data table"))
 			}
 		case stateDropPartitionTable:
 			if err := p.persist(ctx); err != nil {
@@ -208,6 +252,150 @@ func (p *Procedure) Start(ctx context.Context) error {
 	}
 }
 
+// fail tries to roll forward if any sub table has been dropped, otherwise the failure is reported to the client directly.
+func (p *Procedure) fail(ctx context.Context, req *callbackRequest, err error) error {
+	if !p.hasDroppedSubTables() {
+		p.updateStateWithLock(procedure.StateFailed)
+		_ = p.params.OnFailed(err)
+		return err
+	}
+
+	log.Warn("drop partition table failed after some sub tables are dropped, try to roll forward", zap.Uint64("procedureID", p.ID()), zap.String("tableName", req.tableName()), zap.Error(err))
+	rollForwardCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollForwardTimeout)
+	defer cancel()
+	if rollForwardErr := p.rollForward(rollForwardCtx, req); rollForwardErr != nil {
+		p.updateStateWithLock(procedure.StateFailed)
+		err = errors.WithMessagef(err, "roll forward failed, droppedSubTables:%v, rollForwardErr:%v", p.droppedSubTableNames(), rollForwardErr)
+		if persistErr := p.persist(rollForwardCtx); persistErr != nil {
+			log.Warn("persist drop partition table procedure failed", zap.Uint64("procedureID", p.ID()), zap.Error(persistErr))
+		}
+		_ = p.params.OnFailed(err)
+		return err
+	}
+
+	p.updateStateWithLock(procedure.StateFinished)
+	if err := p.persist(rollForwardCtx); err != nil {
+		_ = p.params.OnFailed(err)
+		return errors.WithMessage(err, "drop partition table procedure persist")
+	}
+	return nil
+}
+
+// rollForward drops the remaining sub tables and the partition table, and reports the success to the client.
+func (p *Procedure) rollForward(ctx context.Context, req *callbackRequest) error {
+	params := p.params
+
+	for _, tableName := range params.SourceReq.PartitionTableInfo.GetSubTableNames() {
+		if p.isSubTableDropped(tableName) {
+			continue
+		}
+		if err := procedure.Retry(ctx, procedure.DispatchRetryPolicy, func() error {
+			return p.dropSubTable(ctx, tableName)
+		}); err != nil {
+			return errors.WithMessagef(err, "drop sub table, table:%s", tableName)
+		}
+	}
+
+	if req.table == nil {
+		var dropTableMetadataResult metadata.DropTableMetadataResult
+		if err := procedure.Retry(ctx, procedure.DispatchRetryPolicy, func() error {
+			result, err := params.ClusterMetadata.DropTableMetadata(ctx, req.schemaName(), req.tableName())
+			dropTableMetadataResult = result
+			return err
+		}); err != nil {
+			return errors.WithMessagef(err, "drop partition table metadata, table:%s", req.tableName())
+		}
+		req.table = &dropTableMetadataResult.Table
+	}
+
+	log.Info("roll forward drop partition table finish", zap.Uint64("procedureID", p.ID()), zap.String("tableName", req.tableName()))
+	return params.OnSucceeded(buildTableInfo(req))
+}
+
+// dropSubTable drops the sub table with the latest shard version, it is used when the versions recorded at the beginning are stale.
+func (p *Procedure) dropSubTable(ctx context.Context, tableName string) error {
+	params := p.params
+
+	table, exists, err := params.ClusterMetadata.GetTable(params.SourceReq.GetSchemaName(), tableName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		p.recordDroppedSubTable(tableName)
+		return nil
+	}
+
+	shardVersions := make(map[storage.ShardID]uint64)
+	for shardID, shardView := range params.ClusterMetadata.GetClusterSnapshot().Topology.ShardViewsMapping {
+		shardVersions[shardID] = shardView.Version
+	}
+	shardVersionUpdate, shardExists, err := ddl.BuildShardVersionUpdate(table, params.ClusterMetadata, shardVersions)
+	if err != nil {
+		return err
+	}
+	if !shardExists {
+		if _, err := params.ClusterMetadata.DropTableMetadata(ctx, params.SourceReq.GetSchemaName(), tableName); err != nil {
+			return err
+		}
+		p.recordDroppedSubTable(tableName)
+		return nil
+	}
+
+	latestShardVersion, err := ddl.DropTableOnShard(ctx, params.ClusterMetadata, params.Dispatch, params.SourceReq.GetSchemaName(), table, shardVersionUpdate)
+	if err != nil {
+		return err
+	}
+	if err := params.ClusterMetadata.DropTable(ctx, metadata.DropTableRequest{
+		SchemaName:    params.SourceReq.GetSchemaName(),
+		TableName:     tableName,
+		ShardID:       shardVersionUpdate.ShardID,
+		LatestVersion: latestShardVersion,
+	}); err != nil {
+		return err
+	}
+	p.recordDroppedSubTable(tableName)
+	return nil
+}
+
+func (p *Procedure) recordDroppedSubTable(tableName string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.droppedSubTables[tableName] = struct{}{}
+}
+
+func (p *Procedure) isSubTableDropped(tableName string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	_, dropped := p.droppedSubTables[tableName]
+	return dropped
+}
+
+func (p *Procedure) hasDroppedSubTables() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return len(p.droppedSubTables) > 0
+}
+
+func (p *Procedure) droppedSubTableNames() []string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.droppedSubTableNamesLocked()
+}
+
+func (p *Procedure) droppedSubTableNamesLocked() []string {
+	tableNames := make([]string, 0, len(p.droppedSubTables))
+	for _, tableName := range p.params.SourceReq.PartitionTableInfo.GetSubTableNames() {
+		if _, dropped := p.droppedSubTables[tableName]; dropped {
+			tableNames = append(tableNames, tableName)
+		}
+	}
+	return tableNames
+}
+
 func (p *Procedure) Cancel(_ context.Context) error {
 	p.updateStateWithLock(procedure.StateCancelled)
 	return nil
@@ -220,6 +408,10 @@ func (p *Procedure) State() procedure.State {
 	return p.state
 }
 
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
 func (p *Procedure) updateStateWithLock(state procedure.State) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -248,6 +440,7 @@ func (p *Procedure) convertToMeta() (procedure.Meta, error) {
 		FsmState:         p.fsm.Current(),
 		State:            p.state,
 		DropTableRequest: p.params.SourceReq,
+		DroppedSubTables: p.droppedSubTableNamesLocked(),
 	}
 	rawDataBytes, err := json.Marshal(rawData)
 	if err != nil {
@@ -272,6 +465,8 @@ type rawData struct {
 	State    procedure.State
 
 	DropTableRequest *metaservicepb.DropTableRequest
+	// The dropped sub tables, which are used to decide whether to roll forward.
+	DroppedSubTables []string
 }
 
 type callbackRequest struct {
@@ -329,6 +524,7 @@ func dropDataTablesCallback(event *fsm.Event) {
 				procedure.CancelEventWithLog(event, err, "drop table metadata", zap.String("tableName", tableName))
 				return
 			}
+			req.p.recordDroppedSubTable(tableName)
 			continue
 		}
 
@@ -376,21 +572,23 @@ func finishCallback(event *fsm.Event) {
 	}
 	log.Info("drop partition table finish")
 
-	tableInfo := metadata.TableInfo{
-		ID:            request.table.ID,
-		Name:          request.table.Name,
-		SchemaID:      request.table.SchemaID,
-		SchemaName:    request.p.params.SourceReq.GetSchemaName(),
-		PartitionInfo: storage.PartitionInfo{Info: nil},
-		CreatedAt:     0,
-	}
-
-	if err = request.p.params.OnSucceeded(tableInfo); err != nil {
+	if err = request.p.params.OnSucceeded(buildTableInfo(request)); err != nil {
 		procedure.CancelEventWithLog(event, err, "drop partition table on succeeded")
 		return
 	}
 }
 
+func buildTableInfo(req *callbackRequest) metadata.TableInfo {
+	return metadata.TableInfo{
+		ID:            req.table.ID,
+		Name:          req.table.Name,
+		SchemaID:      req.table.SchemaID,
+		SchemaName:    req.p.params.SourceReq.GetSchemaName(),
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+		CreatedAt:     0,
+	}
+}
+
 func dispatchDropDataTable(req *callbackRequest, dispatch eventdispatch.Dispatch, clusterMetadata *metadata.ClusterMetadata, shardID storage.ShardID, schema string, tableNames []string, shardVersion uint64) error {
 	for _, tableName := range tableNames {
 		table, err := ddl.GetTableMetadata(clusterMetadata, req.schemaName(), tableName)
@@ -417,6 +615,7 @@ func dispatchDropDataTable(req *callbackRequest, dispatch eventdispatch.Dispatch
 		if err != nil {
 			return errors.WithMessagef(err, "drop table, table:%s", tableName)
 		}
+		req.p.recordDroppedSubTable(tableName)
 
 		shardVersion++
 	}
@@ -254,6 +254,13 @@ func (p *Procedure) Kind() procedure.Kind {
 func (p *Procedure) Start(ctx context.Context) error {
 	p.updateState(procedure.StateRunning)
 
+	// The procedure can't be interrupted once it starts, because the table is dropped by the first step.
+	sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "drop table procedure begin side effects")
+	}
+	ctx = sideEffectsCtx
+
 	req := &callbackRequest{
 		ctx:          ctx,
 		p:            p,
@@ -289,6 +296,10 @@ func (p *Procedure) State() procedure.State {
 	return p.state
 }
 
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
 func (p *Procedure) updateState(state procedure.State) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -28,20 +28,30 @@ import (
 	"github.com/pkg/errors"
 )
 
+// defaultPriorityAgingInterval is the waiting time for a ready procedure to raise its priority value by one.
+const defaultPriorityAgingInterval = time.Second * 5
+
 type procedureScheduleEntry struct {
 	procedure Procedure
 	runAfter  time.Time
+	// readyAt is the runAfter of the first push, and it is kept when the procedure is pushed back, so the procedure ages from it.
+	readyAt time.Time
 }
 
+// DelayQueue pops the ready procedures by priority, and the ready procedures with the same priority are popped in the order of readyAt.
+// The priority of a ready procedure is raised as it waits since readyAt, so the procedures with low priority won't be starved even if
+// they are pushed back repeatedly.
 type DelayQueue struct {
-	maxLen int
+	maxLen        int
+	agingInterval time.Duration
 
 	// This lock is used to protect the following fields.
-	lock      sync.RWMutex
-	heapQueue *heapPriorityQueue
-	// existingProcs is used to record procedures has been pushed into the queue,
+	lock sync.RWMutex
+	// heapQueues contains a queue for each priority.
+	heapQueues map[Priority]*heapPriorityQueue
+	// existingProcs is used to record procedures has been pushed into the queue and their priorities,
 	// and they will be used to verify the addition of duplicate elements.
-	existingProcs map[uint64]struct{}
+	existingProcs map[uint64]Priority
 }
 
 // heapPriorityQueue is no internal lock,
@@ -90,11 +100,12 @@ func (q *heapPriorityQueue) Peek() any {
 
 func NewProcedureDelayQueue(maxLen int) *DelayQueue {
 	return &DelayQueue{
-		maxLen: maxLen,
+		maxLen:        maxLen,
+		agingInterval: defaultPriorityAgingInterval,
 
 		lock:          sync.RWMutex{},
-		heapQueue:     &heapPriorityQueue{procedures: []*procedureScheduleEntry{}},
-		existingProcs: map[uint64]struct{}{},
+		heapQueues:    map[Priority]*heapPriorityQueue{},
+		existingProcs: map[uint64]Priority{},
 	}
 }
 
@@ -102,14 +113,47 @@ func (q *DelayQueue) Len() int {
 	q.lock.RLock()
 	defer q.lock.RUnlock()
 
-	return q.heapQueue.Len()
+	return len(q.existingProcs)
+}
+
+// LenByPriority returns the number of the procedures of each priority in the queue.
+func (q *DelayQueue) LenByPriority() map[Priority]int {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	lens := make(map[Priority]int, len(q.heapQueues))
+	for priority, heapQueue := range q.heapQueues {
+		if heapQueue.Len() > 0 {
+			lens[priority] = heapQueue.Len()
+		}
+	}
+	return lens
 }
 
 func (q *DelayQueue) Push(p Procedure, delay time.Duration) error {
+	runAfter := time.Now().Add(delay)
+	return q.pushEntry(&procedureScheduleEntry{
+		procedure: p,
+		runAfter:  runAfter,
+		readyAt:   runAfter,
+	})
+}
+
+// pushBack pushes the popped entry back with the delay, and the time it has waited is kept.
+func (q *DelayQueue) pushBack(entry *procedureScheduleEntry, delay time.Duration) error {
+	return q.pushEntry(&procedureScheduleEntry{
+		procedure: entry.procedure,
+		runAfter:  time.Now().Add(delay),
+		readyAt:   entry.readyAt,
+	})
+}
+
+func (q *DelayQueue) pushEntry(entry *procedureScheduleEntry) error {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	if q.heapQueue.Len() >= q.maxLen {
+	p := entry.procedure
+	if len(q.existingProcs) >= q.maxLen {
 		return errors.WithMessage(ErrQueueFull, fmt.Sprintf("queue max length is %d", q.maxLen))
 	}
 
@@ -117,30 +161,108 @@ func (q *DelayQueue) Push(p Procedure, delay time.Duration) error {
 		return errors.WithMessage(ErrPushDuplicatedProcedure, fmt.Sprintf("procedure has been pushed, %v", p))
 	}
 
-	heap.Push(q.heapQueue, &procedureScheduleEntry{
-		procedure: p,
-		runAfter:  time.Now().Add(delay),
-	})
-	q.existingProcs[p.ID()] = struct{}{}
+	priority := p.Priority()
+	heapQueue, ok := q.heapQueues[priority]
+	if !ok {
+		heapQueue = &heapPriorityQueue{procedures: []*procedureScheduleEntry{}}
+		q.heapQueues[priority] = heapQueue
+	}
+	heap.Push(heapQueue, entry)
+	q.existingProcs[p.ID()] = priority
 
 	return nil
 }
 
+// Pop returns the ready procedure with the highest effective priority, and nil will be returned if no procedure is ready.
 func (q *DelayQueue) Pop() Procedure {
+	entry := q.pop()
+	if entry == nil {
+		return nil
+	}
+	return entry.procedure
+}
+
+// pop removes the ready entry with the highest effective priority from the queue.
+func (q *DelayQueue) pop() *procedureScheduleEntry {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	if q.heapQueue.Len() == 0 {
+	now := time.Now()
+	var selectedQueue *heapPriorityQueue
+	var selectedIndex int
+	var selectedEntry *procedureScheduleEntry
+	var selectedPriority int64
+	for priority, heapQueue := range q.heapQueues {
+		index, entry := earliestReadyEntry(heapQueue, now)
+		if entry == nil {
+			continue
+		}
+
+		effectivePriority := q.effectivePriority(priority, now.Sub(entry.readyAt))
+		if selectedEntry == nil || effectivePriority < selectedPriority ||
+			(effectivePriority == selectedPriority && entry.readyAt.Before(selectedEntry.readyAt)) {
+			selectedQueue = heapQueue
+			selectedIndex = index
+			selectedEntry = entry
+			selectedPriority = effectivePriority
+		}
+	}
+	if selectedEntry == nil {
 		return nil
 	}
 
-	entry := q.heapQueue.Peek().(*procedureScheduleEntry)
-	if time.Now().Before(entry.runAfter) {
-		return nil
+	heap.Remove(selectedQueue, selectedIndex)
+	delete(q.existingProcs, selectedEntry.procedure.ID())
+
+	return selectedEntry
+}
+
+// earliestReadyEntry returns the ready entry with the earliest readyAt, and nil will be returned if no entry is ready.
+// The head has the earliest runAfter, so no entry is ready if the head is not ready.
+func earliestReadyEntry(heapQueue *heapPriorityQueue, now time.Time) (int, *procedureScheduleEntry) {
+	if heapQueue.Len() == 0 || now.Before(heapQueue.Peek().(*procedureScheduleEntry).runAfter) {
+		return 0, nil
 	}
 
-	heap.Pop(q.heapQueue)
-	delete(q.existingProcs, entry.procedure.ID())
+	var earliestIndex int
+	var earliestEntry *procedureScheduleEntry
+	for i, entry := range heapQueue.procedures {
+		if now.Before(entry.runAfter) {
+			continue
+		}
+		if earliestEntry == nil || entry.readyAt.Before(earliestEntry.readyAt) {
+			earliestIndex = i
+			earliestEntry = entry
+		}
+	}
+	return earliestIndex, earliestEntry
+}
 
-	return entry.procedure
+// effectivePriority decreases the priority value by one for every agingInterval waited, and lower value means higher priority.
+func (q *DelayQueue) effectivePriority(priority Priority, waited time.Duration) int64 {
+	if q.agingInterval <= 0 {
+		return int64(priority)
+	}
+	return int64(priority) - int64(waited/q.agingInterval)
+}
+
+// Remove removes the procedure from the queue, nil will be returned if it is not in the queue.
+func (q *DelayQueue) Remove(procedureID uint64) Procedure {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	priority, exists := q.existingProcs[procedureID]
+	if !exists {
+		return nil
+	}
+
+	heapQueue := q.heapQueues[priority]
+	for i, entry := range heapQueue.procedures {
+		if entry.procedure.ID() == procedureID {
+			heap.Remove(heapQueue, i)
+			delete(q.existingProcs, procedureID)
+			return entry.procedure
+		}
+	}
+	return nil
 }
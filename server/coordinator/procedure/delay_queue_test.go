@@ -28,7 +28,11 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-type TestProcedure struct{ ProcedureID uint64 }
+type TestProcedure struct {
+	ProcedureID uint64
+	// ProcedurePriority is zero means PriorityLow.
+	ProcedurePriority Priority
+}
 
 func (t TestProcedure) RelatedVersionInfo() RelatedVersionInfo {
 	return RelatedVersionInfo{
@@ -39,7 +43,10 @@ func (t TestProcedure) RelatedVersionInfo() RelatedVersionInfo {
 }
 
 func (t TestProcedure) Priority() Priority {
-	return PriorityLow
+	if t.ProcedurePriority == 0 {
+		return PriorityLow
+	}
+	return t.ProcedurePriority
 }
 
 func (t TestProcedure) ID() uint64 {
@@ -108,3 +115,88 @@ func TestDelayQueue(t *testing.T) {
 	p0 = queue.Pop()
 	re.Equal(uint64(0), p0.ID())
 }
+
+func TestDelayQueueRemove(t *testing.T) {
+	re := require.New(t)
+
+	queue := NewProcedureDelayQueue(3)
+	for id := uint64(0); id < 3; id++ {
+		re.NoError(queue.Push(TestProcedure{ProcedureID: id}, time.Millisecond*time.Duration(id)))
+	}
+
+	re.Nil(queue.Remove(3))
+	p := queue.Remove(1)
+	re.NotNil(p)
+	re.Equal(uint64(1), p.ID())
+	re.Equal(2, queue.Len())
+	re.Nil(queue.Remove(1))
+
+	// The removed procedure can be pushed again.
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 1}, time.Millisecond*10))
+
+	time.Sleep(time.Millisecond * 20)
+	re.Equal(uint64(0), queue.Pop().ID())
+	re.Equal(uint64(2), queue.Pop().ID())
+	re.Equal(uint64(1), queue.Pop().ID())
+	re.Nil(queue.Pop())
+}
+
+func TestDelayQueuePriority(t *testing.T) {
+	re := require.New(t)
+
+	queue := NewProcedureDelayQueue(10)
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 0, ProcedurePriority: PriorityLow}, 0))
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 1, ProcedurePriority: PriorityMed}, 0))
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 2, ProcedurePriority: PriorityHigh}, 0))
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 3, ProcedurePriority: PriorityHigh}, time.Millisecond*50))
+	re.Equal(map[Priority]int{PriorityLow: 1, PriorityMed: 1, PriorityHigh: 2}, queue.LenByPriority())
+
+	// The procedure which is not ready won't block the ready ones with lower priority.
+	re.Equal(uint64(2), queue.Pop().ID())
+	re.Equal(uint64(1), queue.Pop().ID())
+	re.Equal(uint64(0), queue.Pop().ID())
+	re.Nil(queue.Pop())
+	re.Equal(map[Priority]int{PriorityHigh: 1}, queue.LenByPriority())
+
+	time.Sleep(time.Millisecond * 60)
+	re.Equal(uint64(3), queue.Pop().ID())
+	re.Equal(0, queue.Len())
+}
+
+func TestDelayQueueAging(t *testing.T) {
+	re := require.New(t)
+
+	queue := NewProcedureDelayQueue(10)
+	queue.agingInterval = time.Millisecond * 10
+
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 0, ProcedurePriority: PriorityLow}, 0))
+	// The low priority procedure has waited long enough to be raised above the high priority one.
+	time.Sleep(time.Millisecond * 100)
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 1, ProcedurePriority: PriorityHigh}, 0))
+	re.Equal(uint64(0), queue.Pop().ID())
+	re.Equal(uint64(1), queue.Pop().ID())
+	re.Nil(queue.Pop())
+}
+
+func TestDelayQueueAgingWithPushBack(t *testing.T) {
+	re := require.New(t)
+
+	queue := NewProcedureDelayQueue(10)
+	queue.agingInterval = time.Millisecond * 10
+
+	// The low priority procedure fails to get the shard locks repeatedly, and it is pushed back every time.
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 0, ProcedurePriority: PriorityLow}, 0))
+	for i := 0; i < 10; i++ {
+		entry := queue.pop()
+		re.NotNil(entry)
+		re.Equal(uint64(0), entry.procedure.ID())
+		re.NoError(queue.pushBack(entry, time.Millisecond*5))
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// The time waited before the push backs is kept, so it is raised above the new high priority one.
+	re.NoError(queue.Push(TestProcedure{ProcedureID: 1, ProcedurePriority: PriorityHigh}, 0))
+	re.Equal(uint64(0), queue.Pop().ID())
+	re.Equal(uint64(1), queue.Pop().ID())
+	re.Nil(queue.Pop())
+}
@@ -22,25 +22,37 @@ package procedure
 import "github.com/apache/incubator-horaedb-meta/pkg/coderr"
 
 var (
-	ErrShardLeaderNotFound     = coderr.NewCodeError(coderr.Internal, "shard leader not found")
-	ErrShardNotMatch           = coderr.NewCodeError(coderr.Internal, "target shard not match to persis data")
-	ErrProcedureNotFound       = coderr.NewCodeError(coderr.Internal, "procedure not found")
-	ErrClusterConfigChanged    = coderr.NewCodeError(coderr.Internal, "cluster config changed")
-	ErrTableNotExists          = coderr.NewCodeError(coderr.Internal, "table not exists")
-	ErrTableAlreadyExists      = coderr.NewCodeError(coderr.Internal, "table already exists")
-	ErrListRunningProcedure    = coderr.NewCodeError(coderr.Internal, "procedure type not match")
-	ErrListProcedure           = coderr.NewCodeError(coderr.Internal, "list running procedure")
-	ErrDecodeRawData           = coderr.NewCodeError(coderr.Internal, "decode raw data")
-	ErrEncodeRawData           = coderr.NewCodeError(coderr.Internal, "encode raw data")
-	ErrGetRequest              = coderr.NewCodeError(coderr.Internal, "get request from event")
-	ErrNodeNumberNotEnough     = coderr.NewCodeError(coderr.Internal, "node number not enough")
-	ErrEmptyPartitionNames     = coderr.NewCodeError(coderr.Internal, "partition names is empty")
-	ErrDropTableResult         = coderr.NewCodeError(coderr.Internal, "length of shard not correct")
-	ErrPickShard               = coderr.NewCodeError(coderr.Internal, "pick shard failed")
-	ErrSubmitProcedure         = coderr.NewCodeError(coderr.Internal, "submit new procedure")
-	ErrQueueFull               = coderr.NewCodeError(coderr.Internal, "queue is full, unable to offer more data")
-	ErrPushDuplicatedProcedure = coderr.NewCodeError(coderr.Internal, "try to push duplicated procedure")
-	ErrShardNumberNotEnough    = coderr.NewCodeError(coderr.Internal, "shard number not enough")
-	ErrEmptyBatchProcedure     = coderr.NewCodeError(coderr.Internal, "procedure batch is empty")
-	ErrMergeBatchProcedure     = coderr.NewCodeError(coderr.Internal, "failed to merge procedures batch")
+	ErrShardLeaderNotFound      = coderr.NewCodeError(coderr.Internal, "shard leader not found")
+	ErrShardFollowerNotFound    = coderr.NewCodeError(coderr.Internal, "shard follower not found")
+	ErrInvalidFollower          = coderr.NewCodeError(coderr.InvalidParams, "invalid follower")
+	ErrShardNotMatch            = coderr.NewCodeError(coderr.Internal, "target shard not match to persis data")
+	ErrProcedureNotFound        = coderr.NewCodeError(coderr.Internal, "procedure not found")
+	ErrClusterConfigChanged     = coderr.NewCodeError(coderr.Internal, "cluster config changed")
+	ErrTableNotExists           = coderr.NewCodeError(coderr.Internal, "table not exists")
+	ErrTableAlreadyExists       = coderr.NewCodeError(coderr.Internal, "table already exists")
+	ErrListRunningProcedure     = coderr.NewCodeError(coderr.Internal, "procedure type not match")
+	ErrListProcedure            = coderr.NewCodeError(coderr.Internal, "list running procedure")
+	ErrDecodeRawData            = coderr.NewCodeError(coderr.Internal, "decode raw data")
+	ErrEncodeRawData            = coderr.NewCodeError(coderr.Internal, "encode raw data")
+	ErrGetRequest               = coderr.NewCodeError(coderr.Internal, "get request from event")
+	ErrNodeNumberNotEnough      = coderr.NewCodeError(coderr.Internal, "node number not enough")
+	ErrEmptyPartitionNames      = coderr.NewCodeError(coderr.Internal, "partition names is empty")
+	ErrDropTableResult          = coderr.NewCodeError(coderr.Internal, "length of shard not correct")
+	ErrPickShard                = coderr.NewCodeError(coderr.Internal, "pick shard failed")
+	ErrSubmitProcedure          = coderr.NewCodeError(coderr.Internal, "submit new procedure")
+	ErrQueueFull                = coderr.NewCodeError(coderr.Internal, "queue is full, unable to offer more data")
+	ErrPushDuplicatedProcedure  = coderr.NewCodeError(coderr.Internal, "try to push duplicated procedure")
+	ErrShardNumberNotEnough     = coderr.NewCodeError(coderr.Internal, "shard number not enough")
+	ErrEmptyBatchProcedure      = coderr.NewCodeError(coderr.Internal, "procedure batch is empty")
+	ErrMergeBatchProcedure      = coderr.NewCodeError(coderr.Internal, "failed to merge procedures batch")
+	ErrEmptyMigrateTables       = coderr.NewCodeError(coderr.InvalidParams, "tables to migrate is empty")
+	ErrMigrateToSameShard       = coderr.NewCodeError(coderr.InvalidParams, "source shard and target shard are the same")
+	ErrMigrateTableNotInShard   = coderr.NewCodeError(coderr.InvalidParams, "table to migrate is not in the source shard")
+	ErrMergeToSameShard         = coderr.NewCodeError(coderr.InvalidParams, "merge shard into itself")
+	ErrInvalidScatterAssignment = coderr.NewCodeError(coderr.InvalidParams, "invalid scatter assignment")
+	ErrDecoderNotFound          = coderr.NewCodeError(coderr.Internal, "procedure decoder not found")
+	ErrProcedureCompleted       = coderr.NewCodeError(coderr.Internal, "procedure has been completed")
+	ErrProcedureOutdated        = coderr.NewCodeError(coderr.Internal, "procedure is outdated")
+	ErrProcedureTimeout         = coderr.NewCodeError(coderr.Internal, "procedure execution timeout")
+	ErrProcedureUninterruptible = coderr.NewCodeError(coderr.Internal, "procedure can't be interrupted after its side effects begin")
 )
@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"sync"
+)
+
+type interruptGuardKey struct{}
+
+// interruptGuard decides whether the running procedure can still be interrupted, and it is carried by the context of the procedure.
+type interruptGuard struct {
+	lock             sync.Mutex
+	interrupted      bool
+	sideEffectsBegun bool
+}
+
+func newInterruptGuard() *interruptGuard {
+	return &interruptGuard{
+		lock:             sync.Mutex{},
+		interrupted:      false,
+		sideEffectsBegun: false,
+	}
+}
+
+// interrupt marks the procedure interrupted, and false is returned if the procedure has begun its side effects.
+func (g *interruptGuard) interrupt() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.sideEffectsBegun {
+		return false
+	}
+	g.interrupted = true
+	return true
+}
+
+// BeginSideEffects must be called by the procedure before its first step which can't be undone by stopping the procedure, e.g. closing
+// the tables of the shard, and the procedure can't be cancelled after that.
+// The returned context is detached from the cancellation and the deadline of ctx, so the remaining steps will be run to the end.
+// The error is returned if the procedure has been interrupted, and it should be stopped without any side effect.
+func BeginSideEffects(ctx context.Context) (context.Context, error) {
+	guard, ok := ctx.Value(interruptGuardKey{}).(*interruptGuard)
+	if !ok {
+		// The procedure is not started by the manager, e.g. in the tests.
+		return ctx, nil
+	}
+
+	guard.lock.Lock()
+	defer guard.lock.Unlock()
+
+	if guard.interrupted {
+		return ctx, context.Canceled
+	}
+	if err := ctx.Err(); err != nil {
+		return ctx, err
+	}
+	guard.sideEffectsBegun = true
+	return context.WithoutCancel(ctx), nil
+}
+
+// sideEffectsBegun tells whether the procedure running with ctx has begun its side effects.
+func sideEffectsBegun(ctx context.Context) bool {
+	guard, ok := ctx.Value(interruptGuardKey{}).(*interruptGuard)
+	if !ok {
+		return false
+	}
+
+	guard.lock.Lock()
+	defer guard.lock.Unlock()
+
+	return guard.sideEffectsBegun
+}
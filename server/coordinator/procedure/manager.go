@@ -29,9 +29,29 @@ type Manager interface {
 	// Stop must be called before manager is dropped.
 	Stop(ctx context.Context) error
 
-	// Submit procedure to be executed asynchronously.
+	// Submit procedure to be executed asynchronously, and the ID of the procedure doing the work will be returned.
+	// If an equivalent procedure is already waiting or running, the submitted one will be dropped and the ID of the existing one will be returned.
 	// TODO: change result type, add channel to get whether the procedure executed successfully
-	Submit(ctx context.Context, procedure Procedure) error
+	Submit(ctx context.Context, procedure Procedure) (uint64, error)
 	// ListRunningProcedure return immutable procedures info.
 	ListRunningProcedure(ctx context.Context) ([]*Info, error)
+	// ListWaitingDepth returns the number of the waiting procedures of each priority.
+	ListWaitingDepth(ctx context.Context) (map[Priority]int, error)
+	// GetProcedure returns the detail of the waiting, running or recently completed procedure.
+	GetProcedure(ctx context.Context, procedureID uint64) (*Detail, error)
+	// ListHistoryProcedure returns the completed procedures in the history matching the filter.
+	ListHistoryProcedure(ctx context.Context, filter HistoryFilter) ([]*HistoryInfo, error)
+	// CancelProcedure cancels the waiting or running procedure, and the shard locks held by it will be released once it stops.
+	CancelProcedure(ctx context.Context, procedureID uint64) error
+	// AddArchiveListener registers the listener which is called after the completed procedure is archived.
+	AddArchiveListener(listener ArchiveListener)
+}
+
+// ArchiveListener is notified of the final state of the completed procedure, and it must not block.
+type ArchiveListener func(ctx context.Context, procedureID uint64, info ArchiveInfo)
+
+// Decoder rebuilds the procedure from its persisted meta.
+type Decoder interface {
+	// Decode rebuilds the procedure, and the rebuilt procedure will be resumed from the persisted state.
+	Decode(ctx context.Context, meta *Meta) (Procedure, error)
 }
@@ -21,12 +21,14 @@ package procedure
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/lock"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
@@ -36,11 +38,51 @@ const (
 	defaultWaitingQueueDelay         = time.Millisecond * 500
 	defaultPromoteDelay              = time.Millisecond * 100
 	defaultProcedureWorkerChanBufSiz = 10
+	defaultCompletedRecordsLen       = 100
 )
 
+// persistableKinds contains the kinds of the procedures which may be persisted.
+var persistableKinds = []Kind{Create, Delete, TransferLeader, Migrate, Split, Merge, Scatter, CreateTable, DropTable, CreatePartitionTable, DropPartitionTable, OpenFollower, CloseFollower, PromoteFollower}
+
+// HistoryOptions is used to control the retention of the procedures in the history.
+type HistoryOptions struct {
+	// RetentionCount is the max number of the procedures kept in the history, zero means no limit.
+	RetentionCount int
+	// RetentionAge is the max age of the procedures kept in the history, zero means no limit.
+	RetentionAge time.Duration
+	// GCInterval is the interval to clean up the history, zero means the history is never cleaned up.
+	GCInterval time.Duration
+}
+
+// TimeoutOptions is used to control the execution deadlines of the procedures.
+// The deadline only stops the procedure before its side effects begin, see BeginSideEffects, and every kind of procedure begins them
+// before its first step which can't be undone, so the procedure is never left halfway by the deadline.
+type TimeoutOptions struct {
+	// Default is the deadline of the procedures whose kind has no specific deadline, zero means no deadline.
+	Default time.Duration
+	// Kind -> the deadline of the procedures of the kind, zero means no deadline.
+	KindTimeouts map[Kind]time.Duration
+}
+
+func (o TimeoutOptions) timeout(kind Kind) time.Duration {
+	if timeout, exists := o.KindTimeouts[kind]; exists {
+		return timeout
+	}
+	return o.Default
+}
+
+type ManagerOptions struct {
+	History HistoryOptions
+	Timeout TimeoutOptions
+}
+
 type ManagerImpl struct {
 	logger   *zap.Logger
 	metadata *metadata.ClusterMetadata
+	storage  Storage
+	// Decoder is used to rebuild the unfinished procedures persisted in the storage.
+	decoder Decoder
+	options ManagerOptions
 
 	// ProcedureShardLock is used to ensure the consistency of procedures' concurrent running on shard, that is to say, only one procedure is allowed to run on a specific shard.
 	procedureShardLock *lock.EntryLock
@@ -55,6 +97,53 @@ type ManagerImpl struct {
 	// There is only one procedure running for every shard.
 	// It will be removed when the procedure is finished or failed.
 	runningProcedures map[storage.ShardID]Procedure
+	// Records of the waiting and running procedures, and the recently completed ones.
+	records map[uint64]*procedureRecord
+	// DedupKey -> ID of the waiting or running procedure, it is used to filter the duplicate submitted procedures.
+	dedupKeys map[string]uint64
+	// IDs of the completed procedures in the completion order, the oldest one will be evicted from the records when it exceeds defaultCompletedRecordsLen.
+	completedProcedureIDs []uint64
+	archiveListeners      []ArchiveListener
+}
+
+// procedureRecord records the runtime information of a submitted procedure, and it is protected by the lock of the manager.
+type procedureRecord struct {
+	procedure Procedure
+	startTime time.Time
+	lastErr   error
+	completed bool
+	cancelled bool
+	// Cancel the context of the running procedure, it is nil if the procedure has not been started.
+	cancel context.CancelFunc
+	// Guard tells whether the running procedure can be interrupted, it is nil if the procedure has not been started.
+	guard *interruptGuard
+}
+
+func (r *procedureRecord) detail() *Detail {
+	var fsmState string
+	if getter, ok := r.procedure.(FsmStateGetter); ok {
+		fsmState = getter.FsmState()
+	}
+
+	state := r.procedure.State()
+	if r.cancelled {
+		state = StateCancelled
+	}
+
+	var lastErr string
+	if r.lastErr != nil {
+		lastErr = r.lastErr.Error()
+	}
+
+	return &Detail{
+		ID:        r.procedure.ID(),
+		Kind:      r.procedure.Kind(),
+		State:     state,
+		FsmState:  fsmState,
+		ShardIDs:  sortedShardIDs(r.procedure),
+		StartTime: r.startTime,
+		LastError: lastErr,
+	}
 }
 
 func (m *ManagerImpl) Start(ctx context.Context) error {
@@ -66,8 +155,15 @@ func (m *ManagerImpl) Start(ctx context.Context) error {
 		return nil
 	}
 
+	if err := m.recoverProcedures(ctx); err != nil {
+		return errors.WithMessage(err, "recover procedures")
+	}
+
 	m.procedureWorkerChan = make(chan struct{}, defaultProcedureWorkerChanBufSiz)
 	go m.startProcedurePromote(ctx, m.procedureWorkerChan)
+	if m.options.History.GCInterval > 0 {
+		go m.startHistoryGC(ctx)
+	}
 
 	m.running = true
 
@@ -92,18 +188,28 @@ func (m *ManagerImpl) Stop(ctx context.Context) error {
 	return nil
 }
 
-// TODO: Filter duplicate submitted Procedure.
-func (m *ManagerImpl) Submit(_ context.Context, procedure Procedure) error {
+func (m *ManagerImpl) Submit(_ context.Context, procedure Procedure) (uint64, error) {
+	m.lock.Lock()
+	if key := dedupKey(procedure); len(key) != 0 {
+		if existingID, exists := m.dedupKeys[key]; exists {
+			m.lock.Unlock()
+			m.logger.Info("drop duplicate procedure", zap.Uint64("procedureID", procedure.ID()), zap.Uint64("existingProcedureID", existingID), zap.String("dedupKey", key))
+			return existingID, nil
+		}
+	}
 	if err := m.waitingProcedures.Push(procedure, 0); err != nil {
-		return err
+		m.lock.Unlock()
+		return 0, err
 	}
+	m.addRecordLocked(procedure)
+	m.lock.Unlock()
 
 	select {
 	case m.procedureWorkerChan <- struct{}{}:
 	default:
 	}
 
-	return nil
+	return procedure.ID(), nil
 }
 
 func (m *ManagerImpl) ListRunningProcedure(_ context.Context) ([]*Info, error) {
@@ -123,21 +229,326 @@ func (m *ManagerImpl) ListRunningProcedure(_ context.Context) ([]*Info, error) {
 	return procedureInfos, nil
 }
 
-func NewManagerImpl(logger *zap.Logger, metadata *metadata.ClusterMetadata) (Manager, error) {
+func (m *ManagerImpl) ListWaitingDepth(_ context.Context) (map[Priority]int, error) {
+	return m.waitingProcedures.LenByPriority(), nil
+}
+
+func (m *ManagerImpl) GetProcedure(_ context.Context, procedureID uint64) (*Detail, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	record, exists := m.records[procedureID]
+	if !exists {
+		return nil, errors.WithMessagef(ErrProcedureNotFound, "procedureID:%d", procedureID)
+	}
+	return record.detail(), nil
+}
+
+func (m *ManagerImpl) CancelProcedure(ctx context.Context, procedureID uint64) error {
+	removed, err := m.cancelProcedure(ctx, procedureID)
+	if err != nil {
+		return err
+	}
+	// The removed waiting procedure will never be started, so move it into the history here.
+	if removed != nil {
+		m.archiveProcedure(ctx, removed, nil)
+	}
+	m.logger.Info("cancel procedure", zap.Uint64("procedureID", procedureID))
+
+	return nil
+}
+
+// cancelProcedure cancels the procedure, and the procedure removed from the waiting queue will be returned.
+func (m *ManagerImpl) cancelProcedure(ctx context.Context, procedureID uint64) (Procedure, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	record, exists := m.records[procedureID]
+	if !exists {
+		return nil, errors.WithMessagef(ErrProcedureNotFound, "procedureID:%d", procedureID)
+	}
+	if record.completed {
+		return nil, errors.WithMessagef(ErrProcedureCompleted, "procedureID:%d", procedureID)
+	}
+
+	// The running procedure can't be stopped halfway once it has begun its side effects.
+	if record.guard != nil && !record.guard.interrupt() {
+		return nil, errors.WithMessagef(ErrProcedureUninterruptible, "procedureID:%d", procedureID)
+	}
+
+	record.cancelled = true
+	if err := record.procedure.Cancel(ctx); err != nil {
+		return nil, errors.WithMessagef(err, "cancel procedure, procedureID:%d", procedureID)
+	}
+
+	// The context of the running procedure is cancelled to stop it, and its shard locks will be released by its worker after it stops.
+	if record.cancel != nil {
+		record.cancel()
+		return nil, nil
+	}
+
+	// The waiting procedure holds no shard lock, just remove it from the queue.
+	// If it has been popped from the queue and is being promoted, it will be dropped by the promotion.
+	removed := m.waitingProcedures.Remove(procedureID)
+	if removed != nil {
+		m.completeRecordLocked(procedureID, nil)
+	}
+
+	return removed, nil
+}
+
+func (m *ManagerImpl) ListHistoryProcedure(ctx context.Context, filter HistoryFilter) ([]*HistoryInfo, error) {
+	kinds := filter.Kinds
+	if len(kinds) == 0 {
+		kinds = persistableKinds
+	}
+	metas, err := m.listHistory(ctx, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	matchedMetas := make([]*Meta, 0, len(metas))
+	for _, meta := range metas {
+		completedAt := time.UnixMilli(meta.CompletedAt)
+		if !filter.StartTime.IsZero() && completedAt.Before(filter.StartTime) {
+			continue
+		}
+		if !filter.EndTime.IsZero() && completedAt.After(filter.EndTime) {
+			continue
+		}
+		if filter.ShardID != nil && !containsShard(meta.ShardIDs, *filter.ShardID) {
+			continue
+		}
+		matchedMetas = append(matchedMetas, meta)
+	}
+
+	if filter.Offset >= len(matchedMetas) {
+		return []*HistoryInfo{}, nil
+	}
+	matchedMetas = matchedMetas[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matchedMetas) {
+		matchedMetas = matchedMetas[:filter.Limit]
+	}
+
+	historyInfos := make([]*HistoryInfo, 0, len(matchedMetas))
+	for _, meta := range matchedMetas {
+		historyInfos = append(historyInfos, &HistoryInfo{
+			ID:          meta.ID,
+			Kind:        meta.Kind,
+			State:       meta.State,
+			ShardIDs:    meta.ShardIDs,
+			CompletedAt: time.UnixMilli(meta.CompletedAt),
+			LastError:   meta.LastError,
+		})
+	}
+	return historyInfos, nil
+}
+
+// listHistory lists the procedures of the kinds in the history, sorted by the completion time in descending order.
+func (m *ManagerImpl) listHistory(ctx context.Context, kinds []Kind) ([]*Meta, error) {
+	var metas []*Meta
+	for _, kind := range kinds {
+		kindMetas, err := m.storage.ListDeleted(ctx, kind, metaListBatchSize)
+		if err != nil {
+			return nil, errors.WithMessagef(ErrListProcedure, "list history procedures, kind:%d, err:%v", kind, err)
+		}
+		metas = append(metas, kindMetas...)
+	}
+
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].CompletedAt != metas[j].CompletedAt {
+			return metas[i].CompletedAt > metas[j].CompletedAt
+		}
+		return metas[i].ID > metas[j].ID
+	})
+	return metas, nil
+}
+
+// archiveProcedure moves the completed procedure into the history with the cause of its failure, and nothing will be done if it is not persisted.
+func (m *ManagerImpl) archiveProcedure(ctx context.Context, p Procedure, procedureErr error) {
+	info := ArchiveInfo{
+		State:     p.State(),
+		ShardIDs:  sortedShardIDs(p),
+		LastError: "",
+	}
+	if procedureErr != nil {
+		info.State = StateFailed
+		info.LastError = procedureErr.Error()
+	}
+	m.lock.RLock()
+	if record, exists := m.records[p.ID()]; exists && record.cancelled {
+		info.State = StateCancelled
+	}
+	listeners := m.archiveListeners
+	m.lock.RUnlock()
+
+	// The batch is not persisted, so the procedures in it are archived one by one, otherwise they are left in the storage.
+	if batch, ok := p.(BatchGetter); ok {
+		for _, batchProcedure := range batch.Batch() {
+			var batchProcedureErr error
+			if batchProcedure.State() == StateFailed {
+				batchProcedureErr = procedureErr
+			}
+			m.archiveProcedure(ctx, batchProcedure, batchProcedureErr)
+		}
+	} else if err := m.storage.MarkDeleted(ctx, p.Kind(), p.ID(), info); err != nil {
+		m.logger.Warn("move procedure into history failed", zap.Uint64("procedureID", p.ID()), zap.Error(err))
+	}
+
+	for _, listener := range listeners {
+		listener(ctx, p.ID(), info)
+	}
+}
+
+func (m *ManagerImpl) AddArchiveListener(listener ArchiveListener) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.archiveListeners = append(m.archiveListeners, listener)
+}
+
+func (m *ManagerImpl) startHistoryGC(ctx context.Context) {
+	ticker := time.NewTicker(m.options.History.GCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.gcHistory(ctx); err != nil {
+				m.logger.Error("clean up procedure history failed", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// gcHistory removes the procedures exceeding the retention count or age from the history.
+func (m *ManagerImpl) gcHistory(ctx context.Context) error {
+	metas, err := m.listHistory(ctx, persistableKinds)
+	if err != nil {
+		return err
+	}
+
+	expiredBefore := time.Now().Add(-m.options.History.RetentionAge).UnixMilli()
+	removedCount := 0
+	for i, meta := range metas {
+		exceeded := m.options.History.RetentionCount > 0 && i >= m.options.History.RetentionCount
+		expired := m.options.History.RetentionAge > 0 && meta.CompletedAt < expiredBefore
+		if !exceeded && !expired {
+			continue
+		}
+		if err := m.storage.Delete(ctx, meta.Kind, meta.ID); err != nil {
+			return errors.WithMessagef(err, "delete history procedure, procedureID:%d", meta.ID)
+		}
+		removedCount++
+	}
+	if removedCount > 0 {
+		m.logger.Info("clean up procedure history", zap.Int("removedCount", removedCount))
+	}
+
+	return nil
+}
+
+func (m *ManagerImpl) completeRecord(procedureID uint64, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.completeRecordLocked(procedureID, err)
+}
+
+func (m *ManagerImpl) addRecordLocked(p Procedure) {
+	m.records[p.ID()] = &procedureRecord{procedure: p}
+	if key := dedupKey(p); len(key) != 0 {
+		m.dedupKeys[key] = p.ID()
+	}
+}
+
+func (m *ManagerImpl) completeRecordLocked(procedureID uint64, err error) {
+	record, exists := m.records[procedureID]
+	if !exists || record.completed {
+		return
+	}
+
+	// The equivalent procedures can be submitted again once this one is completed.
+	if key := dedupKey(record.procedure); len(key) != 0 && m.dedupKeys[key] == procedureID {
+		delete(m.dedupKeys, key)
+	}
+	record.completed = true
+	record.lastErr = err
+	if record.cancel != nil {
+		record.cancel()
+	}
+
+	m.completedProcedureIDs = append(m.completedProcedureIDs, procedureID)
+	if len(m.completedProcedureIDs) > defaultCompletedRecordsLen {
+		evictedID := m.completedProcedureIDs[0]
+		m.completedProcedureIDs = m.completedProcedureIDs[1:]
+		if evicted, exists := m.records[evictedID]; exists && evicted.completed {
+			delete(m.records, evictedID)
+		}
+	}
+}
+
+func NewManagerImpl(logger *zap.Logger, metadata *metadata.ClusterMetadata, procedureStorage Storage, decoder Decoder, options ManagerOptions) (Manager, error) {
 	entryLock := lock.NewEntryLock(10)
 	manager := &ManagerImpl{
-		logger:              logger,
-		metadata:            metadata,
-		procedureShardLock:  &entryLock,
-		waitingProcedures:   NewProcedureDelayQueue(defaultWaitingQueueLen),
-		procedureWorkerChan: make(chan struct{}),
-		lock:                sync.RWMutex{},
-		running:             false,
-		runningProcedures:   map[storage.ShardID]Procedure{},
+		logger:                logger,
+		metadata:              metadata,
+		storage:               procedureStorage,
+		decoder:               decoder,
+		options:               options,
+		procedureShardLock:    &entryLock,
+		waitingProcedures:     NewProcedureDelayQueue(defaultWaitingQueueLen),
+		procedureWorkerChan:   make(chan struct{}),
+		lock:                  sync.RWMutex{},
+		running:               false,
+		runningProcedures:     map[storage.ShardID]Procedure{},
+		records:               map[uint64]*procedureRecord{},
+		dedupKeys:             map[string]uint64{},
+		completedProcedureIDs: []uint64{},
+		archiveListeners:      []ArchiveListener{},
 	}
 	return manager, nil
 }
 
+// recoverProcedures rebuilds the unfinished procedures left by the previous leader and puts them into the waiting queue, so they will be resumed from the persisted state.
+// The procedures which can't be rebuilt will be marked as failed, and they will be moved into the history with the completed ones.
+func (m *ManagerImpl) recoverProcedures(ctx context.Context) error {
+	for _, kind := range persistableKinds {
+		metas, err := m.storage.List(ctx, kind, metaListBatchSize)
+		if err != nil {
+			return errors.WithMessagef(err, "list procedures, kind:%d", kind)
+		}
+
+		for _, meta := range metas {
+			if meta.State != StateInit && meta.State != StateRunning {
+				if err := m.storage.MarkDeleted(ctx, meta.Kind, meta.ID, ArchiveInfo{}); err != nil {
+					return errors.WithMessagef(err, "move procedure into history, procedureID:%d", meta.ID)
+				}
+				continue
+			}
+
+			p, err := m.decoder.Decode(ctx, meta)
+			if err != nil {
+				m.logger.Warn("decode procedure failed, mark it as failed", zap.Uint64("procedureID", meta.ID), zap.Uint("kind", uint(meta.Kind)), zap.Error(err))
+				info := ArchiveInfo{State: StateFailed, ShardIDs: nil, LastError: err.Error()}
+				if err := m.storage.MarkDeleted(ctx, meta.Kind, meta.ID, info); err != nil {
+					return errors.WithMessagef(err, "move procedure into history, procedureID:%d", meta.ID)
+				}
+				continue
+			}
+
+			if err := m.waitingProcedures.Push(p, 0); err != nil {
+				return errors.WithMessagef(err, "push recovered procedure, procedureID:%d", meta.ID)
+			}
+			m.addRecordLocked(p)
+			m.logger.Info("recover procedure", zap.Uint64("procedureID", meta.ID), zap.Uint("kind", uint(meta.Kind)))
+		}
+	}
+
+	return nil
+}
+
 func (m *ManagerImpl) startProcedurePromote(ctx context.Context, procedureWorkerChan chan struct{}) {
 	ticker := time.NewTicker(defaultPromoteDelay)
 	defer ticker.Stop()
@@ -161,29 +572,61 @@ func (m *ManagerImpl) startProcedurePromoteInternal(ctx context.Context, procedu
 	}
 
 	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	for _, newProcedure := range newProcedures {
+		record, exists := m.records[newProcedure.ID()]
+		if !exists {
+			record = &procedureRecord{procedure: newProcedure}
+			m.records[newProcedure.ID()] = record
+		}
+
+		// The procedure is cancelled after it is popped from the waiting queue, release its shard locks directly.
+		if record.cancelled {
+			m.completeRecordLocked(newProcedure.ID(), nil)
+			m.procedureShardLock.UnLock(relatedShardIDs(newProcedure))
+			go m.archiveProcedure(ctx, newProcedure, nil)
+			continue
+		}
+
 		for shardID := range newProcedure.RelatedVersionInfo().ShardWithVersion {
 			m.runningProcedures[shardID] = newProcedure
 		}
-	}
-	m.lock.Unlock()
+		var procedureCtx context.Context
+		var cancel context.CancelFunc
+		if timeout := m.options.Timeout.timeout(newProcedure.Kind()); timeout > 0 {
+			procedureCtx, cancel = context.WithTimeout(ctx, timeout)
+		} else {
+			procedureCtx, cancel = context.WithCancel(ctx)
+		}
+		guard := newInterruptGuard()
+		procedureCtx = context.WithValue(procedureCtx, interruptGuardKey{}, guard)
+		record.startTime = time.Now()
+		record.cancel = cancel
+		record.guard = guard
 
-	for _, newProcedure := range newProcedures {
 		m.logger.Info("promote procedure", zap.Uint64("procedureID", newProcedure.ID()))
-		m.startProcedureWorker(ctx, newProcedure, procedureWorkerChan)
+		m.startProcedureWorker(ctx, procedureCtx, newProcedure, procedureWorkerChan)
 	}
 }
 
-func (m *ManagerImpl) startProcedureWorker(ctx context.Context, newProcedure Procedure, procedureWorkerChan chan struct{}) {
+// startProcedureWorker starts the procedure with procedureCtx, which will be cancelled if the procedure is cancelled or exceeds its deadline.
+func (m *ManagerImpl) startProcedureWorker(ctx, procedureCtx context.Context, newProcedure Procedure, procedureWorkerChan chan struct{}) {
 	go func() {
 		start := time.Now()
 		m.logger.Info("procedure start", zap.Uint64("procedureID", newProcedure.ID()))
-		err := newProcedure.Start(ctx)
+		err := newProcedure.Start(procedureCtx)
+		// The deadline only applies to the procedure before its side effects begin.
+		if err != nil && errors.Is(procedureCtx.Err(), context.DeadlineExceeded) && !sideEffectsBegun(procedureCtx) {
+			err = ErrProcedureTimeout.WithCausef("timeout:%v, err:%v", m.options.Timeout.timeout(newProcedure.Kind()), err)
+		}
 		if err != nil {
-			m.logger.Error("procedure start failed", zap.Error(err), zap.Int64("costTime", time.Since(start).Milliseconds()))
+			m.logger.Error("procedure start failed", zap.Uint64("procedureID", newProcedure.ID()), zap.Uint("kind", uint(newProcedure.Kind())), zap.Any("shardIDs", sortedShardIDs(newProcedure)), zap.Error(err), zap.Int64("costTime", time.Since(start).Milliseconds()))
 		} else {
 			m.logger.Info("procedure start finish", zap.Uint64("procedureID", newProcedure.ID()), zap.Int64("costTime", time.Since(start).Milliseconds()))
 		}
+		m.completeRecord(newProcedure.ID(), err)
+		m.archiveProcedure(ctx, newProcedure, err)
 		for shardID := range newProcedure.RelatedVersionInfo().ShardWithVersion {
 			m.lock.Lock()
 			delete(m.runningProcedures, shardID)
@@ -209,8 +652,16 @@ func checkValid(p Procedure, clusterMetadata *metadata.ClusterMetadata) bool {
 	if relatedVersionInfo.ClusterVersion != curClusterVersion {
 		return false
 	}
+	createdShardIDs := createdShardIDs(p)
 	for shardID, version := range relatedVersionInfo.ShardWithVersion {
 		shardView, exists := curShardViews[shardID]
+		// The shard created by the procedure has been created by others.
+		if containsShard(createdShardIDs, shardID) {
+			if exists {
+				return false
+			}
+			continue
+		}
 		if !exists {
 			return false
 		}
@@ -223,37 +674,64 @@ func checkValid(p Procedure, clusterMetadata *metadata.ClusterMetadata) bool {
 
 // Promote a waiting procedure to be a running procedure.
 // One procedure may be related with multiple shards.
-func (m *ManagerImpl) promoteProcedure(_ context.Context) ([]Procedure, error) {
+func (m *ManagerImpl) promoteProcedure(ctx context.Context) ([]Procedure, error) {
 	// Get waiting procedures, it has been sorted in queue.
 	queue := m.waitingProcedures
 
 	var readyProcs []Procedure
 	// Find next valid procedure.
 	for {
-		p := queue.Pop()
-		if p == nil {
+		entry := queue.pop()
+		if entry == nil {
 			return readyProcs, nil
 		}
+		p := entry.procedure
 
 		if !checkValid(p, m.metadata) {
 			// This procedure is invalid, just remove it.
+			m.completeRecord(p.ID(), ErrProcedureOutdated)
+			m.archiveProcedure(ctx, p, ErrProcedureOutdated)
 			continue
 		}
 
 		// Try to get shard locks.
-		shardIDs := make([]uint64, 0, len(p.RelatedVersionInfo().ShardWithVersion))
-		for shardID := range p.RelatedVersionInfo().ShardWithVersion {
-			shardIDs = append(shardIDs, uint64(shardID))
-		}
-		lockResult := m.procedureShardLock.TryLock(shardIDs)
+		lockResult := m.procedureShardLock.TryLock(relatedShardIDs(p))
 		if lockResult {
 			// Get lock success, procedure will be executed.
 			readyProcs = append(readyProcs, p)
 		} else {
-			// Get lock failed, procedure will be put back into the queue.
-			if err := queue.Push(p, defaultWaitingQueueDelay); err != nil {
+			// Get lock failed, procedure will be put back into the queue, and it keeps the time it has waited.
+			if err := queue.pushBack(entry, defaultWaitingQueueDelay); err != nil {
 				return nil, err
 			}
 		}
 	}
 }
+
+func relatedShardIDs(p Procedure) []uint64 {
+	shardIDs := make([]uint64, 0, len(p.RelatedVersionInfo().ShardWithVersion))
+	for shardID := range p.RelatedVersionInfo().ShardWithVersion {
+		shardIDs = append(shardIDs, uint64(shardID))
+	}
+	return shardIDs
+}
+
+func sortedShardIDs(p Procedure) []storage.ShardID {
+	shardIDs := make([]storage.ShardID, 0, len(p.RelatedVersionInfo().ShardWithVersion))
+	for shardID := range p.RelatedVersionInfo().ShardWithVersion {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Slice(shardIDs, func(i, j int) bool {
+		return shardIDs[i] < shardIDs[j]
+	})
+	return shardIDs
+}
+
+func containsShard(shardIDs []storage.ShardID, shardID storage.ShardID) bool {
+	for _, id := range shardIDs {
+		if id == shardID {
+			return true
+		}
+	}
+	return false
+}
@@ -21,9 +21,11 @@ package procedure_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -70,12 +72,315 @@ func (m *MockProcedure) Priority() procedure.Priority {
 	return procedure.PriorityMed
 }
 
+// mockBlockingProcedure is the MockProcedure which is blocked until its ctx is done.
+type mockBlockingProcedure struct {
+	*MockProcedure
+}
+
+func (m mockBlockingProcedure) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// mockDedupProcedure is the MockProcedure which can be deduplicated by the key.
+type mockDedupProcedure struct {
+	*MockProcedure
+	key string
+}
+
+func (m mockDedupProcedure) DedupKey() string {
+	return m.key
+}
+
+// mockShardCreatingProcedure is the MockProcedure which creates the new shard at the end of its execution.
+type mockShardCreatingProcedure struct {
+	*MockProcedure
+	clusterMetadata *metadata.ClusterMetadata
+	newShardID      storage.ShardID
+}
+
+func (m mockShardCreatingProcedure) Start(ctx context.Context) error {
+	m.state = procedure.StateRunning
+	time.Sleep(m.execTime)
+	if err := m.clusterMetadata.CreateShardViews(ctx, []metadata.CreateShardView{{ShardID: m.newShardID, Tables: []storage.TableID{}}}); err != nil {
+		m.state = procedure.StateFailed
+		return err
+	}
+	m.state = procedure.StateFinished
+	return nil
+}
+
+func (m mockShardCreatingProcedure) CreatedShardIDs() []storage.ShardID {
+	return []storage.ShardID{m.newShardID}
+}
+
+// mockSideEffectProcedure is the MockProcedure which begins its side effects before its execution.
+type mockSideEffectProcedure struct {
+	*MockProcedure
+}
+
+func (m mockSideEffectProcedure) Start(ctx context.Context) error {
+	if _, err := procedure.BeginSideEffects(ctx); err != nil {
+		m.state = procedure.StateCancelled
+		return err
+	}
+	return m.MockProcedure.Start(ctx)
+}
+
+// mockBatchProcedure is the MockProcedure which runs a batch of procedures.
+type mockBatchProcedure struct {
+	*MockProcedure
+	batch []procedure.Procedure
+}
+
+func (m mockBatchProcedure) Start(ctx context.Context) error {
+	m.state = procedure.StateRunning
+	for _, p := range m.batch {
+		if err := p.Start(ctx); err != nil {
+			m.state = procedure.StateFailed
+			return err
+		}
+	}
+	m.state = procedure.StateFinished
+	return nil
+}
+
+func (m mockBatchProcedure) Batch() []procedure.Procedure {
+	return m.batch
+}
+
+type mockDecoder struct {
+	relatedVersionInfo procedure.RelatedVersionInfo
+}
+
+// Decode rebuilds the procedures of kind CreateTable, and fails on the others.
+func (d mockDecoder) Decode(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	if meta.Kind != procedure.CreateTable {
+		return nil, procedure.ErrDecoderNotFound
+	}
+	return &MockProcedure{
+		id:                 meta.ID,
+		state:              meta.State,
+		relatedVersionInfo: d.relatedVersionInfo,
+		execTime:           time.Millisecond * 300,
+	}, nil
+}
+
+type memoryStorage struct {
+	lock        sync.Mutex
+	metas       map[procedure.Kind]map[uint64]procedure.Meta
+	deletedMeta map[procedure.Kind]map[uint64]procedure.Meta
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		lock:        sync.Mutex{},
+		metas:       map[procedure.Kind]map[uint64]procedure.Meta{},
+		deletedMeta: map[procedure.Kind]map[uint64]procedure.Meta{},
+	}
+}
+
+func (s *memoryStorage) CreateOrUpdate(_ context.Context, meta procedure.Meta) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.metas[meta.Kind]; !ok {
+		s.metas[meta.Kind] = map[uint64]procedure.Meta{}
+	}
+	s.metas[meta.Kind][meta.ID] = meta
+	return nil
+}
+
+func (s *memoryStorage) CreateOrUpdateWithTTL(ctx context.Context, meta procedure.Meta, _ int64) error {
+	return s.CreateOrUpdate(ctx, meta)
+}
+
+func (s *memoryStorage) List(_ context.Context, procedureType procedure.Kind, _ int) ([]*procedure.Meta, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	metas := make([]*procedure.Meta, 0, len(s.metas[procedureType]))
+	for _, meta := range s.metas[procedureType] {
+		meta := meta
+		metas = append(metas, &meta)
+	}
+	return metas, nil
+}
+
+func (s *memoryStorage) Delete(_ context.Context, procedureType procedure.Kind, id uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.metas[procedureType], id)
+	delete(s.deletedMeta[procedureType], id)
+	return nil
+}
+
+func (s *memoryStorage) MarkDeleted(_ context.Context, procedureType procedure.Kind, id uint64, info procedure.ArchiveInfo) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	meta, ok := s.metas[procedureType][id]
+	if !ok {
+		return nil
+	}
+	delete(s.metas[procedureType], id)
+	if len(info.State) != 0 {
+		meta.State = info.State
+	}
+	meta.ShardIDs = info.ShardIDs
+	meta.LastError = info.LastError
+	meta.CompletedAt = time.Now().UnixMilli()
+	s.putDeletedLocked(meta)
+	return nil
+}
+
+func (s *memoryStorage) ListDeleted(_ context.Context, procedureType procedure.Kind, _ int) ([]*procedure.Meta, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	metas := make([]*procedure.Meta, 0, len(s.deletedMeta[procedureType]))
+	for _, meta := range s.deletedMeta[procedureType] {
+		meta := meta
+		metas = append(metas, &meta)
+	}
+	return metas, nil
+}
+
+func (s *memoryStorage) putDeleted(meta procedure.Meta) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.putDeletedLocked(meta)
+}
+
+func (s *memoryStorage) putDeletedLocked(meta procedure.Meta) {
+	if _, ok := s.deletedMeta[meta.Kind]; !ok {
+		s.deletedMeta[meta.Kind] = map[uint64]procedure.Meta{}
+	}
+	s.deletedMeta[meta.Kind][meta.ID] = meta
+}
+
+func (s *memoryStorage) get(procedureType procedure.Kind, id uint64) procedure.Meta {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.metas[procedureType][id]
+}
+
+func (s *memoryStorage) getDeleted(procedureType procedure.Kind, id uint64) procedure.Meta {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.deletedMeta[procedureType][id]
+}
+
+func TestManagerRecover(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	var shardID storage.ShardID
+	for id := range snapshot.Topology.ShardViewsMapping {
+		shardID = id
+		break
+	}
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        c.GetMetadata().GetClusterID(),
+		ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version},
+		ClusterVersion:   c.GetMetadata().GetClusterViewVersion(),
+	}
+
+	procedureStorage := newMemoryStorage()
+	// The running procedure can be decoded, and it will be resumed.
+	re.NoError(procedureStorage.CreateOrUpdate(ctx, procedure.Meta{ID: 1, Kind: procedure.CreateTable, State: procedure.StateRunning}))
+	// The finished procedure will be moved into the history.
+	re.NoError(procedureStorage.CreateOrUpdate(ctx, procedure.Meta{ID: 2, Kind: procedure.CreateTable, State: procedure.StateFinished}))
+	// The running procedure can't be decoded, and it will be marked as failed and moved into the history.
+	re.NoError(procedureStorage.CreateOrUpdate(ctx, procedure.Meta{ID: 3, Kind: procedure.Split, State: procedure.StateRunning}))
+
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedureStorage, mockDecoder{relatedVersionInfo: relatedVersionInfo}, procedure.ManagerOptions{})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	re.Equal(procedure.State(procedure.StateFailed), procedureStorage.getDeleted(procedure.Split, 3).State)
+	re.Equal(procedure.State(procedure.StateFinished), procedureStorage.getDeleted(procedure.CreateTable, 2).State)
+
+	// The recovered procedure will be promoted by the next tick.
+	time.Sleep(time.Millisecond * 150)
+	infos, err := manager.ListRunningProcedure(ctx)
+	re.NoError(err)
+	re.Equal(1, len(infos))
+	re.Equal(uint64(1), infos[0].ID)
+
+	// The resumed procedure will be moved into the history after it is finished.
+	time.Sleep(time.Millisecond * 400)
+	re.Equal([]storage.ShardID{shardID}, procedureStorage.getDeleted(procedure.CreateTable, 1).ShardIDs)
+
+	re.NoError(manager.Stop(ctx))
+}
+
+func TestManagerHistory(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	procedureStorage := newMemoryStorage()
+	now := time.Now()
+	procedureStorage.putDeleted(procedure.Meta{ID: 1, Kind: procedure.Migrate, State: procedure.StateFinished, ShardIDs: []storage.ShardID{0, 1}, CompletedAt: now.Add(-time.Hour * 3).UnixMilli()})
+	procedureStorage.putDeleted(procedure.Meta{ID: 2, Kind: procedure.Merge, State: procedure.StateFailed, ShardIDs: []storage.ShardID{1, 2}, CompletedAt: now.Add(-time.Hour * 2).UnixMilli()})
+	procedureStorage.putDeleted(procedure.Meta{ID: 3, Kind: procedure.Migrate, State: procedure.StateCancelled, ShardIDs: []storage.ShardID{2, 3}, CompletedAt: now.Add(-time.Hour).UnixMilli()})
+
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedureStorage, mockDecoder{}, procedure.ManagerOptions{
+		History: procedure.HistoryOptions{
+			RetentionCount: 2,
+			RetentionAge:   time.Minute * 150,
+			GCInterval:     time.Millisecond * 100,
+		},
+	})
+	re.NoError(err)
+
+	// The history is sorted by the completion time in descending order.
+	historyInfos, err := manager.ListHistoryProcedure(ctx, procedure.HistoryFilter{})
+	re.NoError(err)
+	re.Equal(3, len(historyInfos))
+	re.Equal(uint64(3), historyInfos[0].ID)
+	re.Equal(uint64(1), historyInfos[2].ID)
+
+	historyInfos, err = manager.ListHistoryProcedure(ctx, procedure.HistoryFilter{Kinds: []procedure.Kind{procedure.Migrate}})
+	re.NoError(err)
+	re.Equal(2, len(historyInfos))
+
+	shardID := storage.ShardID(1)
+	historyInfos, err = manager.ListHistoryProcedure(ctx, procedure.HistoryFilter{ShardID: &shardID, Offset: 1, Limit: 1})
+	re.NoError(err)
+	re.Equal(1, len(historyInfos))
+	re.Equal(uint64(1), historyInfos[0].ID)
+
+	historyInfos, err = manager.ListHistoryProcedure(ctx, procedure.HistoryFilter{StartTime: now.Add(-time.Minute * 150), EndTime: now.Add(-time.Minute * 90)})
+	re.NoError(err)
+	re.Equal(1, len(historyInfos))
+	re.Equal(uint64(2), historyInfos[0].ID)
+
+	// The procedure exceeding the retention age is cleaned up by the gc.
+	re.NoError(manager.Start(ctx))
+	time.Sleep(time.Millisecond * 250)
+	historyInfos, err = manager.ListHistoryProcedure(ctx, procedure.HistoryFilter{})
+	re.NoError(err)
+	re.Equal(2, len(historyInfos))
+	re.Equal(uint64(3), historyInfos[0].ID)
+	re.Equal(uint64(2), historyInfos[1].ID)
+
+	re.NoError(manager.Stop(ctx))
+}
+
 func TestManager(t *testing.T) {
 	ctx := context.Background()
 	re := require.New(t)
 
 	c := test.InitStableCluster(ctx, t)
-	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata())
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), test.NewTestStorage(t), mockDecoder{}, procedure.ManagerOptions{})
 	re.NoError(err)
 
 	err = manager.Start(ctx)
@@ -85,7 +390,7 @@ func TestManager(t *testing.T) {
 	// Test submit multi single shard procedure.
 	snapshot := c.GetMetadata().GetClusterSnapshot()
 	for shardID, shardView := range snapshot.Topology.ShardViewsMapping {
-		err = manager.Submit(ctx, &MockProcedure{
+		_, err = manager.Submit(ctx, &MockProcedure{
 			id:                 procedureID,
 			state:              procedure.StateInit,
 			relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: map[storage.ShardID]uint64{shardID: shardView.Version}, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
@@ -122,7 +427,7 @@ func TestManager(t *testing.T) {
 		for id, view := range snapshot.Topology.ShardViewsMapping {
 			shardWithVersions[id] = view.Version
 		}
-		err = manager.Submit(ctx, &MockProcedure{
+		_, err = manager.Submit(ctx, &MockProcedure{
 			id:                 procedureID,
 			state:              procedure.StateInit,
 			relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: shardWithVersions, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
@@ -131,7 +436,7 @@ func TestManager(t *testing.T) {
 		re.NoError(err)
 		procedureID++
 		for shardID, shardView := range snapshot.Topology.ShardViewsMapping {
-			err = manager.Submit(ctx, &MockProcedure{
+			_, err = manager.Submit(ctx, &MockProcedure{
 				id:                 procedureID,
 				state:              procedure.StateInit,
 				relatedVersionInfo: procedure.RelatedVersionInfo{ClusterID: c.GetMetadata().GetClusterID(), ShardWithVersion: map[storage.ShardID]uint64{shardID: shardView.Version}, ClusterVersion: c.GetMetadata().GetClusterViewVersion()},
@@ -175,3 +480,343 @@ func TestManager(t *testing.T) {
 		re.NoError(err)
 	}
 }
+
+func TestManagerGetAndCancelProcedure(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), test.NewTestStorage(t), mockDecoder{}, procedure.ManagerOptions{})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	var shardID storage.ShardID
+	for id := range snapshot.Topology.ShardViewsMapping {
+		shardID = id
+		break
+	}
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        c.GetMetadata().GetClusterID(),
+		ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version},
+		ClusterVersion:   c.GetMetadata().GetClusterViewVersion(),
+	}
+
+	_, err = manager.GetProcedure(ctx, 0)
+	re.Error(err)
+	re.Error(manager.CancelProcedure(ctx, 0))
+
+	// Procedure 1 is running, and procedure 2 is waiting for the shard lock held by procedure 1.
+	_, err = manager.Submit(ctx, &MockProcedure{id: 1, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: time.Millisecond * 100})
+	re.NoError(err)
+	time.Sleep(time.Millisecond * 10)
+	_, err = manager.Submit(ctx, &MockProcedure{id: 2, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: time.Millisecond * 10})
+	re.NoError(err)
+	time.Sleep(time.Millisecond * 10)
+
+	detail, err := manager.GetProcedure(ctx, 1)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateRunning), detail.State)
+	re.Equal([]storage.ShardID{shardID}, detail.ShardIDs)
+	re.False(detail.StartTime.IsZero())
+	detail, err = manager.GetProcedure(ctx, 2)
+	re.NoError(err)
+	re.True(detail.StartTime.IsZero())
+
+	// Cancel the waiting procedure, it will never be started.
+	re.NoError(manager.CancelProcedure(ctx, 2))
+	detail, err = manager.GetProcedure(ctx, 2)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateCancelled), detail.State)
+	re.Error(manager.CancelProcedure(ctx, 2))
+
+	// Cancel the running procedure, its shard lock will be released after it stops.
+	re.NoError(manager.CancelProcedure(ctx, 1))
+	time.Sleep(time.Millisecond * 150)
+	infos, err := manager.ListRunningProcedure(ctx)
+	re.NoError(err)
+	re.Equal(0, len(infos))
+	detail, err = manager.GetProcedure(ctx, 2)
+	re.NoError(err)
+	re.True(detail.StartTime.IsZero())
+
+	// The shard lock is released, so the new procedure on the same shard can be started.
+	_, err = manager.Submit(ctx, &MockProcedure{id: 3, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: time.Millisecond * 10})
+	re.NoError(err)
+	time.Sleep(time.Millisecond * 150)
+	detail, err = manager.GetProcedure(ctx, 3)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateFinished), detail.State)
+	re.Empty(detail.LastError)
+}
+
+func TestManagerCancelAfterSideEffects(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), test.NewTestStorage(t), mockDecoder{}, procedure.ManagerOptions{})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	var shardID storage.ShardID
+	for id := range snapshot.Topology.ShardViewsMapping {
+		shardID = id
+		break
+	}
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        c.GetMetadata().GetClusterID(),
+		ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version},
+		ClusterVersion:   c.GetMetadata().GetClusterViewVersion(),
+	}
+
+	_, err = manager.Submit(ctx, mockSideEffectProcedure{
+		MockProcedure: &MockProcedure{id: 1, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: time.Millisecond * 100},
+	})
+	re.NoError(err)
+	time.Sleep(time.Millisecond * 20)
+
+	// The running procedure has begun its side effects, so it can't be cancelled and will run to the end.
+	err = manager.CancelProcedure(ctx, 1)
+	re.ErrorIs(err, procedure.ErrProcedureUninterruptible)
+	time.Sleep(time.Millisecond * 150)
+	detail, err := manager.GetProcedure(ctx, 1)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateFinished), detail.State)
+	re.Empty(detail.LastError)
+}
+
+func TestManagerArchiveBatch(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	procedureStorage := newMemoryStorage()
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedureStorage, mockDecoder{}, procedure.ManagerOptions{})
+	re.NoError(err)
+	var archivedLock sync.Mutex
+	archivedStates := map[uint64]procedure.State{}
+	manager.AddArchiveListener(func(_ context.Context, procedureID uint64, info procedure.ArchiveInfo) {
+		archivedLock.Lock()
+		defer archivedLock.Unlock()
+		archivedStates[procedureID] = info.State
+	})
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	var shardID storage.ShardID
+	for id := range snapshot.Topology.ShardViewsMapping {
+		shardID = id
+		break
+	}
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        c.GetMetadata().GetClusterID(),
+		ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version},
+		ClusterVersion:   c.GetMetadata().GetClusterViewVersion(),
+	}
+
+	// The procedures in the batch are persisted on their own, while the batch is not persisted.
+	batch := make([]procedure.Procedure, 0, 2)
+	for id := uint64(2); id <= 3; id++ {
+		batch = append(batch, &MockProcedure{id: id, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: 0})
+		re.NoError(procedureStorage.CreateOrUpdate(ctx, procedure.Meta{ID: id, Kind: procedure.CreateTable, State: procedure.StateRunning}))
+	}
+	_, err = manager.Submit(ctx, mockBatchProcedure{
+		MockProcedure: &MockProcedure{id: 1, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: 0},
+		batch:         batch,
+	})
+	re.NoError(err)
+
+	// The procedures in the batch are moved into the history after the batch is finished.
+	re.Eventually(func() bool {
+		metas, err := procedureStorage.List(ctx, procedure.CreateTable, 0)
+		return err == nil && len(metas) == 0
+	}, time.Second, time.Millisecond*10)
+	historyInfos, err := manager.ListHistoryProcedure(ctx, procedure.HistoryFilter{})
+	re.NoError(err)
+	re.Equal(2, len(historyInfos))
+	for _, info := range historyInfos {
+		re.Equal(procedure.State(procedure.StateFinished), info.State)
+	}
+
+	// The listeners are notified of the batch and the procedures in it.
+	re.Eventually(func() bool {
+		archivedLock.Lock()
+		defer archivedLock.Unlock()
+		return len(archivedStates) == 3
+	}, time.Second, time.Millisecond*10)
+	for id := uint64(1); id <= 3; id++ {
+		re.Equal(procedure.State(procedure.StateFinished), archivedStates[id])
+	}
+
+	re.NoError(manager.Stop(ctx))
+}
+
+func TestManagerDedup(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), test.NewTestStorage(t), mockDecoder{}, procedure.ManagerOptions{})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	var shardID storage.ShardID
+	for id := range snapshot.Topology.ShardViewsMapping {
+		shardID = id
+		break
+	}
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        c.GetMetadata().GetClusterID(),
+		ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version},
+		ClusterVersion:   c.GetMetadata().GetClusterViewVersion(),
+	}
+	newProcedure := func(id uint64, key string) procedure.Procedure {
+		return mockDedupProcedure{
+			MockProcedure: &MockProcedure{id: id, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: time.Millisecond * 100},
+			key:           key,
+		}
+	}
+
+	key := procedure.BuildDedupKey(procedure.TransferLeader, shardID, "node0")
+	procedureID, err := manager.Submit(ctx, newProcedure(1, key))
+	re.NoError(err)
+	re.Equal(uint64(1), procedureID)
+	time.Sleep(time.Millisecond * 10)
+
+	// The equivalent procedure is dropped whether the existing one is waiting or running.
+	procedureID, err = manager.Submit(ctx, newProcedure(2, key))
+	re.NoError(err)
+	re.Equal(uint64(1), procedureID)
+	_, err = manager.GetProcedure(ctx, 2)
+	re.Error(err)
+
+	// The procedures with different keys or without key won't be deduplicated.
+	procedureID, err = manager.Submit(ctx, newProcedure(3, procedure.BuildDedupKey(procedure.TransferLeader, shardID, "node1")))
+	re.NoError(err)
+	re.Equal(uint64(3), procedureID)
+	procedureID, err = manager.Submit(ctx, newProcedure(4, ""))
+	re.NoError(err)
+	re.Equal(uint64(4), procedureID)
+	procedureID, err = manager.Submit(ctx, newProcedure(5, ""))
+	re.NoError(err)
+	re.Equal(uint64(5), procedureID)
+
+	// The equivalent procedure can be submitted again once the existing one is completed.
+	time.Sleep(time.Millisecond * 150)
+	detail, err := manager.GetProcedure(ctx, 1)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateFinished), detail.State)
+	procedureID, err = manager.Submit(ctx, newProcedure(6, key))
+	re.NoError(err)
+	re.Equal(uint64(6), procedureID)
+}
+
+func TestManagerTimeout(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	procedureStorage := newMemoryStorage()
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), procedureStorage, mockDecoder{}, procedure.ManagerOptions{
+		Timeout: procedure.TimeoutOptions{
+			Default:      0,
+			KindTimeouts: map[procedure.Kind]time.Duration{procedure.CreateTable: time.Millisecond * 50},
+		},
+	})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	var shardID storage.ShardID
+	for id := range snapshot.Topology.ShardViewsMapping {
+		shardID = id
+		break
+	}
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        c.GetMetadata().GetClusterID(),
+		ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version},
+		ClusterVersion:   c.GetMetadata().GetClusterViewVersion(),
+	}
+
+	// The procedure is persisted, so it will be moved into the history with the cause of its failure.
+	re.NoError(procedureStorage.CreateOrUpdate(ctx, procedure.Meta{ID: 1, Kind: procedure.CreateTable, State: procedure.StateRunning}))
+	_, err = manager.Submit(ctx, mockBlockingProcedure{
+		MockProcedure: &MockProcedure{id: 1, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo},
+	})
+	re.NoError(err)
+
+	time.Sleep(time.Millisecond * 200)
+	detail, err := manager.GetProcedure(ctx, 1)
+	re.NoError(err)
+	re.Contains(detail.LastError, "timeout")
+	deletedMeta := procedureStorage.getDeleted(procedure.CreateTable, 1)
+	re.Equal(procedure.State(procedure.StateFailed), deletedMeta.State)
+	re.Contains(deletedMeta.LastError, "timeout")
+
+	// The shard lock is released after the timeout.
+	infos, err := manager.ListRunningProcedure(ctx)
+	re.NoError(err)
+	re.Equal(0, len(infos))
+	_, err = manager.Submit(ctx, &MockProcedure{id: 2, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: time.Millisecond * 10})
+	re.NoError(err)
+	time.Sleep(time.Millisecond * 150)
+	detail, err = manager.GetProcedure(ctx, 2)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateFinished), detail.State)
+}
+
+func TestManagerCreateShard(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), test.NewTestStorage(t), mockDecoder{}, procedure.ManagerOptions{})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	newShardID := storage.ShardID(len(snapshot.Topology.ShardViewsMapping) + 100)
+	newProcedure := func(id uint64, shardID storage.ShardID) procedure.Procedure {
+		relatedVersionInfo := procedure.RelatedVersionInfo{
+			ClusterID:        c.GetMetadata().GetClusterID(),
+			ShardWithVersion: map[storage.ShardID]uint64{shardID: snapshot.Topology.ShardViewsMapping[shardID].Version, newShardID: 0},
+			ClusterVersion:   c.GetMetadata().GetClusterViewVersion(),
+		}
+		return mockShardCreatingProcedure{
+			MockProcedure:   &MockProcedure{id: id, state: procedure.StateInit, relatedVersionInfo: relatedVersionInfo, execTime: time.Millisecond * 100},
+			clusterMetadata: c.GetMetadata(),
+			newShardID:      newShardID,
+		}
+	}
+
+	// Both procedures create the same shard from different shards, and the first one runs although the new shard doesn't exist.
+	_, err = manager.Submit(ctx, newProcedure(1, 0))
+	re.NoError(err)
+	time.Sleep(time.Millisecond * 10)
+	_, err = manager.Submit(ctx, newProcedure(2, 1))
+	re.NoError(err)
+	time.Sleep(time.Millisecond * 10)
+
+	// The second one waits for the lock of the new shard held by the first one.
+	detail, err := manager.GetProcedure(ctx, 1)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateRunning), detail.State)
+	detail, err = manager.GetProcedure(ctx, 2)
+	re.NoError(err)
+	re.True(detail.StartTime.IsZero())
+
+	// The second one is dropped as outdated once the new shard is created by the first one.
+	time.Sleep(time.Millisecond * 700)
+	detail, err = manager.GetProcedure(ctx, 1)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateFinished), detail.State)
+	_, exists := c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping[newShardID]
+	re.True(exists)
+	detail, err = manager.GetProcedure(ctx, 2)
+	re.NoError(err)
+	re.True(detail.StartTime.IsZero())
+	re.Contains(detail.LastError, procedure.ErrProcedureOutdated.Error())
+}
@@ -0,0 +1,420 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package follower
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/looplab/fsm"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Fsm state change: Begin -> Dispatch -> Finish.
+// Dispatch sends the request of the kind to the node:
+//   - OpenFollower: open shard request with the follower role.
+//   - CloseFollower: close shard request.
+//   - PromoteFollower: open shard request with the leader role, and the node holding the follower takes over the shard without
+//     loading it from scratch. The old leader is not closed because the follower is only promoted after the node of the old leader expires.
+const (
+	eventDispatch = "EventDispatch"
+	eventFinish   = "EventFinish"
+
+	stateBegin    = "StateBegin"
+	stateDispatch = "StateDispatch"
+	stateFinish   = "StateFinish"
+)
+
+var (
+	followerEvents = fsm.Events{
+		{Name: eventDispatch, Src: []string{stateBegin}, Dst: stateDispatch},
+		{Name: eventFinish, Src: []string{stateDispatch}, Dst: stateFinish},
+	}
+	followerCallbacks = fsm.Callbacks{
+		eventDispatch: dispatchCallback,
+		eventFinish:   finishCallback,
+	}
+)
+
+type Procedure struct {
+	fsm                *fsm.FSM
+	kind               procedure.Kind
+	params             ProcedureParams
+	relatedVersionInfo procedure.RelatedVersionInfo
+
+	// Protect the state.
+	lock  sync.RWMutex
+	state procedure.State
+}
+
+// callbackRequest is fsm callbacks param.
+type callbackRequest struct {
+	ctx context.Context
+	p   *Procedure
+}
+
+type ProcedureParams struct {
+	ID uint64
+
+	Dispatch eventdispatch.Dispatch
+	Storage  procedure.Storage
+
+	ClusterSnapshot metadata.Snapshot
+
+	ShardID storage.ShardID
+	// NodeName is the node holding the follower, or the node to place it.
+	NodeName string
+	// OldLeaderNodeName is only used by the promotion, and it is empty if the old leader has been dropped from the topology.
+	OldLeaderNodeName string
+}
+
+// NewOpenProcedure creates the procedure opening a follower of the shard on the node, which should hold no replica of the shard.
+func NewOpenProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	if err := validateShardNodes(params.ClusterSnapshot.Topology, params.ShardID, params.NodeName, false); err != nil {
+		return nil, err
+	}
+	p, err := newProcedure(procedure.OpenFollower, params)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewCloseProcedure creates the procedure closing the follower of the shard on the node.
+func NewCloseProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	if err := validateShardNodes(params.ClusterSnapshot.Topology, params.ShardID, params.NodeName, true); err != nil {
+		return nil, err
+	}
+	p, err := newProcedure(procedure.CloseFollower, params)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewPromoteProcedure creates the procedure promoting the follower of the shard on the node to be the leader.
+func NewPromoteProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	if err := validateShardNodes(params.ClusterSnapshot.Topology, params.ShardID, params.NodeName, true); err != nil {
+		return nil, err
+	}
+	p, err := newProcedure(procedure.PromoteFollower, params)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DecodeProcedure rebuilds the persisted procedure of the kind with the current cluster snapshot in the params.
+func DecodeProcedure(params ProcedureParams, meta *procedure.Meta) (procedure.Procedure, error) {
+	var data rawData
+	if err := json.Unmarshal(meta.RawData, &data); err != nil {
+		return nil, procedure.ErrDecodeRawData.WithCausef("unmarshal raw data, procedureID:%d, err:%v", meta.ID, err)
+	}
+
+	params.ID = data.ID
+	params.ShardID = storage.ShardID(data.ShardID)
+	params.NodeName = data.NodeName
+	params.OldLeaderNodeName = data.OldLeaderNodeName
+	// The shard may have been opened or closed on the node, so the follower is validated only if nothing is done.
+	if data.FsmState == stateBegin {
+		if err := validateShardNodes(params.ClusterSnapshot.Topology, params.ShardID, params.NodeName, meta.Kind != procedure.OpenFollower); err != nil {
+			return nil, err
+		}
+	}
+	p, err := newProcedure(meta.Kind, params)
+	if err != nil {
+		return nil, err
+	}
+	p.fsm.SetState(data.FsmState)
+
+	return p, nil
+}
+
+func newProcedure(kind procedure.Kind, params ProcedureParams) (*Procedure, error) {
+	relatedVersionInfo, err := buildRelatedVersionInfo(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Procedure{
+		fsm:                fsm.NewFSM(stateBegin, followerEvents, followerCallbacks),
+		kind:               kind,
+		params:             params,
+		relatedVersionInfo: relatedVersionInfo,
+		lock:               sync.RWMutex{},
+		state:              procedure.StateInit,
+	}, nil
+}
+
+// validateShardNodes checks whether the node holds a follower of the shard as expected, and the leader of the shard is never on the node.
+func validateShardNodes(topology metadata.Topology, shardID storage.ShardID, nodeName string, expectFollower bool) error {
+	if _, found := topology.ShardViewsMapping[shardID]; !found {
+		return errors.WithMessagef(metadata.ErrShardNotFound, "shardID:%d", shardID)
+	}
+	if len(nodeName) == 0 {
+		return errors.WithMessagef(procedure.ErrInvalidFollower, "node of follower is empty, shardID:%d", shardID)
+	}
+
+	for _, shardNode := range topology.LeaderShardNodes() {
+		if shardNode.ID == shardID && shardNode.NodeName == nodeName {
+			return errors.WithMessagef(procedure.ErrInvalidFollower, "shard leader is on the node, shardID:%d, node:%s", shardID, nodeName)
+		}
+	}
+
+	followerFound := false
+	for _, shardNode := range topology.FollowerShardNodes() {
+		if shardNode.ID == shardID && shardNode.NodeName == nodeName {
+			followerFound = true
+		}
+	}
+	if expectFollower && !followerFound {
+		return errors.WithMessagef(procedure.ErrShardFollowerNotFound, "shardID:%d, node:%s", shardID, nodeName)
+	}
+	if !expectFollower && followerFound {
+		return errors.WithMessagef(procedure.ErrInvalidFollower, "shard follower is on the node already, shardID:%d, node:%s", shardID, nodeName)
+	}
+	return nil
+}
+
+func buildRelatedVersionInfo(params ProcedureParams) (procedure.RelatedVersionInfo, error) {
+	shardView, exists := params.ClusterSnapshot.Topology.ShardViewsMapping[params.ShardID]
+	if !exists {
+		return procedure.RelatedVersionInfo{}, errors.WithMessagef(metadata.ErrShardNotFound, "shard not found in topology, shardID:%d", params.ShardID)
+	}
+
+	return procedure.RelatedVersionInfo{
+		ClusterID:        params.ClusterSnapshot.Topology.ClusterView.ClusterID,
+		ShardWithVersion: map[storage.ShardID]uint64{params.ShardID: shardView.Version},
+		ClusterVersion:   params.ClusterSnapshot.Topology.ClusterView.Version,
+	}, nil
+}
+
+func (p *Procedure) ID() uint64 {
+	return p.params.ID
+}
+
+func (p *Procedure) Kind() procedure.Kind {
+	return p.kind
+}
+
+func (p *Procedure) RelatedVersionInfo() procedure.RelatedVersionInfo {
+	return p.relatedVersionInfo
+}
+
+// Priority of the promotion is high because the shard is unavailable until it finishes.
+func (p *Procedure) Priority() procedure.Priority {
+	if p.kind == procedure.PromoteFollower {
+		return procedure.PriorityHigh
+	}
+	return procedure.PriorityLow
+}
+
+func (p *Procedure) Start(ctx context.Context) error {
+	p.updateStateWithLock(procedure.StateRunning)
+
+	// The procedure can't be interrupted once it starts, because the shard is opened or closed on the node by the first step.
+	sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "follower procedure begin side effects")
+	}
+	ctx = sideEffectsCtx
+
+	followerRequest := callbackRequest{
+		ctx: ctx,
+		p:   p,
+	}
+
+	for {
+		switch p.fsm.Current() {
+		case stateBegin:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "follower procedure persist")
+			}
+			if err := p.fsm.Event(eventDispatch, followerRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "follower procedure dispatch")
+			}
+		case stateDispatch:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "follower procedure persist")
+			}
+			if err := p.fsm.Event(eventFinish, followerRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "follower procedure finish")
+			}
+		case stateFinish:
+			p.updateStateWithLock(procedure.StateFinished)
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "follower procedure persist")
+			}
+			return nil
+		}
+	}
+}
+
+func (p *Procedure) Cancel(_ context.Context) error {
+	p.updateStateWithLock(procedure.StateCancelled)
+	return nil
+}
+
+func (p *Procedure) State() procedure.State {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.state
+}
+
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
+// DedupKey makes the same operation on the follower of the same shard on the same node equivalent.
+func (p *Procedure) DedupKey() string {
+	return procedure.BuildDedupKey(p.kind, p.params.ShardID, p.params.NodeName)
+}
+
+// RelatedNodes returns the node holding the follower.
+func (p *Procedure) RelatedNodes() []string {
+	return []string{p.params.NodeName}
+}
+
+func dispatchCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	ctx := req.ctx
+	params := req.p.params
+
+	if req.p.kind == procedure.CloseFollower {
+		log.Info("try to close follower", zap.Uint64("procedureID", req.p.ID()), zap.Uint32("shardID", uint32(params.ShardID)), zap.String("node", params.NodeName))
+		closeShardRequest := eventdispatch.CloseShardRequest{
+			ShardID: uint32(params.ShardID),
+		}
+		// Closing shard is idempotent, so it can be retried.
+		if err := procedure.Retry(ctx, procedure.DispatchRetryPolicy, func() error {
+			return params.Dispatch.CloseShard(ctx, params.NodeName, closeShardRequest)
+		}); err != nil {
+			procedure.CancelEventWithLog(event, err, "close follower", zap.Uint32("shardID", uint32(params.ShardID)), zap.String("node", params.NodeName))
+		}
+		return
+	}
+
+	shardView, exists := params.ClusterSnapshot.Topology.ShardViewsMapping[params.ShardID]
+	if !exists {
+		procedure.CancelEventWithLog(event, metadata.ErrShardNotFound, "shard not found in topology", zap.Uint32("shardID", uint32(params.ShardID)))
+		return
+	}
+	role := storage.ShardRoleFollower
+	if req.p.kind == procedure.PromoteFollower {
+		role = storage.ShardRoleLeader
+	}
+	openShardRequest := eventdispatch.OpenShardRequest{
+		Shard: metadata.ShardInfo{
+			ID:      params.ShardID,
+			Role:    role,
+			Version: shardView.Version,
+			Status:  storage.ShardStatusUnknown,
+		},
+	}
+
+	log.Info("try to open shard", zap.Uint64("procedureID", req.p.ID()), zap.Uint32("shardID", uint32(params.ShardID)), zap.String("node", params.NodeName), zap.Int("role", int(role)))
+	// Opening shard is idempotent, so it can be retried.
+	if err := procedure.Retry(ctx, procedure.DispatchRetryPolicy, func() error {
+		return params.Dispatch.OpenShard(ctx, params.NodeName, openShardRequest)
+	}); err != nil {
+		procedure.CancelEventWithLog(event, err, "open shard", zap.Uint32("shardID", uint32(params.ShardID)), zap.String("node", params.NodeName), zap.Int("role", int(role)))
+	}
+}
+
+func finishCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+
+	log.Info("follower procedure finish", zap.Uint64("procedureID", req.p.ID()), zap.Uint("kind", uint(req.p.kind)), zap.Uint32("shardID", uint32(req.p.params.ShardID)), zap.String("node", req.p.params.NodeName), zap.String("oldLeaderNode", req.p.params.OldLeaderNodeName))
+}
+
+func (p *Procedure) updateStateWithLock(state procedure.State) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.state = state
+}
+
+func (p *Procedure) persist(ctx context.Context) error {
+	meta, err := p.convertToMeta()
+	if err != nil {
+		return errors.WithMessage(err, "convert to meta")
+	}
+	err = p.params.Storage.CreateOrUpdate(ctx, meta)
+	if err != nil {
+		return errors.WithMessage(err, "createOrUpdate procedure storage")
+	}
+	return nil
+}
+
+type rawData struct {
+	ID       uint64
+	FsmState string
+	State    procedure.State
+
+	ShardID           uint32
+	NodeName          string
+	OldLeaderNodeName string
+}
+
+func (p *Procedure) convertToMeta() (procedure.Meta, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	rawData := rawData{
+		ID:                p.params.ID,
+		FsmState:          p.fsm.Current(),
+		State:             p.state,
+		ShardID:           uint32(p.params.ShardID),
+		NodeName:          p.params.NodeName,
+		OldLeaderNodeName: p.params.OldLeaderNodeName,
+	}
+	rawDataBytes, err := json.Marshal(rawData)
+	if err != nil {
+		var emptyMeta procedure.Meta
+		return emptyMeta, procedure.ErrEncodeRawData.WithCausef("marshal raw data, procedureID:%d, err:%v", p.params.ID, err)
+	}
+
+	meta := procedure.Meta{
+		ID:    p.params.ID,
+		Kind:  p.kind,
+		State: p.state,
+
+		RawData: rawDataBytes,
+	}
+
+	return meta, nil
+}
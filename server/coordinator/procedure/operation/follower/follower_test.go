@@ -0,0 +1,136 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package follower_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/follower"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingStorage is the MockStorage which records the persisted metas.
+type recordingStorage struct {
+	test.MockStorage
+	lock  sync.Mutex
+	metas []procedure.Meta
+}
+
+func (s *recordingStorage) CreateOrUpdate(_ context.Context, meta procedure.Meta) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.metas = append(s.metas, meta)
+	return nil
+}
+
+func TestFollower(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	dispatch := test.MockDispatch{}
+	c := test.InitStableCluster(ctx, t)
+	s := &recordingStorage{MockStorage: test.MockStorage{}, lock: sync.Mutex{}, metas: nil}
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	leader := snapshot.Topology.LeaderShardNodes()[0]
+	followerNodeName := "node0"
+	if leader.NodeName == followerNodeName {
+		followerNodeName = "node1"
+	}
+	params := follower.ProcedureParams{
+		ID:                0,
+		Dispatch:          dispatch,
+		Storage:           s,
+		ClusterSnapshot:   snapshot,
+		ShardID:           leader.ID,
+		NodeName:          followerNodeName,
+		OldLeaderNodeName: "",
+	}
+
+	// The follower can't be placed on the node of the leader.
+	leaderParams := params
+	leaderParams.NodeName = leader.NodeName
+	_, err := follower.NewOpenProcedure(leaderParams)
+	re.ErrorIs(err, procedure.ErrInvalidFollower)
+
+	// The follower which doesn't exist can't be closed or promoted.
+	_, err = follower.NewCloseProcedure(params)
+	re.ErrorIs(err, procedure.ErrShardFollowerNotFound)
+	_, err = follower.NewPromoteProcedure(params)
+	re.ErrorIs(err, procedure.ErrShardFollowerNotFound)
+
+	p, err := follower.NewOpenProcedure(params)
+	re.NoError(err)
+	re.Equal(procedure.OpenFollower, p.Kind())
+	re.NoError(p.Start(ctx))
+	re.Equal(procedure.State(procedure.StateFinished), p.State())
+
+	// The procedure is persisted by every step, and it can be recovered from any of them.
+	re.Len(s.metas, 3)
+	for _, meta := range s.metas {
+		re.Equal(procedure.OpenFollower, meta.Kind)
+		recovered, err := follower.DecodeProcedure(follower.ProcedureParams{
+			ID:                0,
+			Dispatch:          dispatch,
+			Storage:           s,
+			ClusterSnapshot:   snapshot,
+			ShardID:           0,
+			NodeName:          "",
+			OldLeaderNodeName: "",
+		}, &meta)
+		re.NoError(err)
+		re.Equal(procedure.OpenFollower, recovered.Kind())
+		re.Equal([]string{followerNodeName}, recovered.(procedure.RelatedNodesGetter).RelatedNodes())
+		re.NoError(recovered.Start(ctx))
+		re.Equal(procedure.State(procedure.StateFinished), recovered.State())
+	}
+
+	// The follower is reported by the node after it is opened.
+	err = c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, append(snapshot.Topology.ClusterView.ShardNodes, storage.ShardNode{
+		ID:        leader.ID,
+		ShardRole: storage.ShardRoleFollower,
+		NodeName:  followerNodeName,
+	}))
+	re.NoError(err)
+	params.ClusterSnapshot = c.GetMetadata().GetClusterSnapshot()
+	re.True(params.ClusterSnapshot.Topology.IsStable())
+	re.Len(params.ClusterSnapshot.Topology.FollowerShardNodes(), 1)
+
+	_, err = follower.NewOpenProcedure(params)
+	re.ErrorIs(err, procedure.ErrInvalidFollower)
+
+	p, err = follower.NewPromoteProcedure(params)
+	re.NoError(err)
+	re.Equal(procedure.PromoteFollower, p.Kind())
+	re.Equal(procedure.PriorityHigh, p.Priority())
+	re.NoError(p.Start(ctx))
+	re.Equal(procedure.State(procedure.StateFinished), p.State())
+
+	p, err = follower.NewCloseProcedure(params)
+	re.NoError(err)
+	re.Equal(procedure.CloseFollower, p.Kind())
+	re.NoError(p.Start(ctx))
+	re.Equal(procedure.State(procedure.StateFinished), p.State())
+}
@@ -0,0 +1,532 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package merge
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/looplab/fsm"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Fsm state change: Begin -> CloseSourceShard -> UpdateShardTables -> OpenTables -> DropSourceShard -> Finish
+// CloseSourceShard will send close shard requests to all the nodes of the source shard.
+// UpdateShardTables will move all the tables of the source shard to the target shard in the topology.
+// OpenTables will send open table requests to the leader of the target shard.
+// DropSourceShard will remove the shard view and the shard nodes of the source shard.
+const (
+	eventCloseSourceShard  = "EventCloseSourceShard"
+	eventUpdateShardTables = "EventUpdateShardTables"
+	eventOpenTables        = "EventOpenTables"
+	eventDropSourceShard   = "EventDropSourceShard"
+	eventFinish            = "EventFinish"
+
+	stateBegin             = "StateBegin"
+	stateCloseSourceShard  = "StateCloseSourceShard"
+	stateUpdateShardTables = "StateUpdateShardTables"
+	stateOpenTables        = "StateOpenTables"
+	stateDropSourceShard   = "StateDropSourceShard"
+	stateFinish            = "StateFinish"
+)
+
+var (
+	mergeEvents = fsm.Events{
+		{Name: eventCloseSourceShard, Src: []string{stateBegin}, Dst: stateCloseSourceShard},
+		{Name: eventUpdateShardTables, Src: []string{stateCloseSourceShard}, Dst: stateUpdateShardTables},
+		{Name: eventOpenTables, Src: []string{stateUpdateShardTables}, Dst: stateOpenTables},
+		{Name: eventDropSourceShard, Src: []string{stateOpenTables}, Dst: stateDropSourceShard},
+		{Name: eventFinish, Src: []string{stateDropSourceShard}, Dst: stateFinish},
+	}
+	mergeCallbacks = fsm.Callbacks{
+		eventCloseSourceShard:  closeSourceShardCallback,
+		eventUpdateShardTables: updateShardTablesCallback,
+		eventOpenTables:        openTablesCallback,
+		eventDropSourceShard:   dropSourceShardCallback,
+		eventFinish:            finishCallback,
+	}
+)
+
+type Procedure struct {
+	fsm                *fsm.FSM
+	params             ProcedureParams
+	relatedVersionInfo procedure.RelatedVersionInfo
+
+	sourceShardNodes []storage.ShardNode
+	targetLeader     storage.ShardNode
+
+	// Protect the state and the following fields.
+	lock  sync.RWMutex
+	state procedure.State
+	// The tables moved from the source shard to the target shard.
+	mergedTables []metadata.TableInfo
+	// The latest versions of the shards after the tables are moved.
+	latestSourceShardVersion uint64
+	latestTargetShardVersion uint64
+}
+
+type ProcedureParams struct {
+	ID uint64
+
+	Dispatch eventdispatch.Dispatch
+	Storage  procedure.Storage
+
+	ClusterMetadata *metadata.ClusterMetadata
+	ClusterSnapshot metadata.Snapshot
+
+	// All the tables of the source shard will be moved into the target shard, and the source shard will be removed.
+	SourceShardID storage.ShardID
+	TargetShardID storage.ShardID
+}
+
+func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	return newProcedure(params)
+}
+
+// DecodeProcedure rebuilds the procedure from the persisted meta, the fields of params persisted in the meta will be overwritten.
+func DecodeProcedure(params ProcedureParams, meta *procedure.Meta) (procedure.Procedure, error) {
+	var data rawData
+	if err := json.Unmarshal(meta.RawData, &data); err != nil {
+		return nil, procedure.ErrDecodeRawData.WithCausef("unmarshal raw data, procedureID:%d, err:%v", meta.ID, err)
+	}
+
+	params.ID = data.ID
+	params.SourceShardID = storage.ShardID(data.SourceShardID)
+	params.TargetShardID = storage.ShardID(data.TargetShardID)
+	p, err := newProcedure(params)
+	if err != nil {
+		return nil, err
+	}
+
+	switch data.FsmState {
+	case stateUpdateShardTables, stateOpenTables, stateDropSourceShard:
+		// The tables have been moved, keep the persisted tables and versions.
+		p.mergedTables = data.MergedTables
+		p.latestSourceShardVersion = data.LatestSourceShardVersion
+		p.latestTargetShardVersion = data.LatestTargetShardVersion
+	}
+	p.fsm.SetState(data.FsmState)
+
+	return p, nil
+}
+
+func newProcedure(params ProcedureParams) (*Procedure, error) {
+	if err := validateClusterTopology(params); err != nil {
+		return nil, err
+	}
+
+	relatedVersionInfo, err := buildRelatedVersionInfo(params)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceShardNodes := make([]storage.ShardNode, 0, 1)
+	var targetLeader storage.ShardNode
+	for _, shardNode := range params.ClusterSnapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.ID == params.SourceShardID {
+			sourceShardNodes = append(sourceShardNodes, shardNode)
+		}
+		if shardNode.ID == params.TargetShardID && shardNode.ShardRole == storage.ShardRoleLeader {
+			targetLeader = shardNode
+		}
+	}
+
+	mergeFsm := fsm.NewFSM(
+		stateBegin,
+		mergeEvents,
+		mergeCallbacks,
+	)
+
+	return &Procedure{
+		fsm:                      mergeFsm,
+		params:                   params,
+		relatedVersionInfo:       relatedVersionInfo,
+		sourceShardNodes:         sourceShardNodes,
+		targetLeader:             targetLeader,
+		lock:                     sync.RWMutex{},
+		state:                    procedure.StateInit,
+		mergedTables:             []metadata.TableInfo{},
+		latestSourceShardVersion: relatedVersionInfo.ShardWithVersion[params.SourceShardID],
+		latestTargetShardVersion: relatedVersionInfo.ShardWithVersion[params.TargetShardID],
+	}, nil
+}
+
+func validateClusterTopology(params ProcedureParams) error {
+	topology := params.ClusterSnapshot.Topology
+
+	if topology.ClusterView.State != storage.ClusterStateStable {
+		log.Error("cluster state must be stable", zap.Error(metadata.ErrClusterStateInvalid))
+		return metadata.ErrClusterStateInvalid
+	}
+
+	if params.SourceShardID == params.TargetShardID {
+		return errors.WithMessagef(procedure.ErrMergeToSameShard, "shardID:%d", params.SourceShardID)
+	}
+
+	targetLeaderFound := false
+	for _, shardNode := range topology.ClusterView.ShardNodes {
+		if shardNode.ID == params.TargetShardID && shardNode.ShardRole == storage.ShardRoleLeader {
+			targetLeaderFound = true
+		}
+	}
+	if !targetLeaderFound {
+		log.Error("shard leader not found", zap.Uint32("shardID", uint32(params.TargetShardID)), zap.Error(procedure.ErrShardLeaderNotFound))
+		return errors.WithMessagef(procedure.ErrShardLeaderNotFound, "shardID:%d", params.TargetShardID)
+	}
+
+	return nil
+}
+
+func buildRelatedVersionInfo(params ProcedureParams) (procedure.RelatedVersionInfo, error) {
+	shardWithVersion := make(map[storage.ShardID]uint64, 2)
+	for _, shardID := range []storage.ShardID{params.SourceShardID, params.TargetShardID} {
+		shardView, exists := params.ClusterSnapshot.Topology.ShardViewsMapping[shardID]
+		if !exists {
+			return procedure.RelatedVersionInfo{}, errors.WithMessagef(metadata.ErrShardNotFound, "shard not found in topology, shardID:%d", shardID)
+		}
+		shardWithVersion[shardID] = shardView.Version
+	}
+
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        params.ClusterSnapshot.Topology.ClusterView.ClusterID,
+		ShardWithVersion: shardWithVersion,
+		ClusterVersion:   params.ClusterSnapshot.Topology.ClusterView.Version,
+	}
+	return relatedVersionInfo, nil
+}
+
+type callbackRequest struct {
+	ctx context.Context
+	p   *Procedure
+}
+
+func (p *Procedure) ID() uint64 {
+	return p.params.ID
+}
+
+func (p *Procedure) Kind() procedure.Kind {
+	return procedure.Merge
+}
+
+func (p *Procedure) RelatedVersionInfo() procedure.RelatedVersionInfo {
+	return p.relatedVersionInfo
+}
+
+func (p *Procedure) Priority() procedure.Priority {
+	return procedure.PriorityHigh
+}
+
+func (p *Procedure) Start(ctx context.Context) error {
+	p.updateStateWithLock(procedure.StateRunning)
+
+	// The recovered procedure may have done the side effects, so it can't be interrupted from the beginning.
+	if p.fsm.Current() != stateBegin {
+		sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "merge procedure begin side effects")
+		}
+		ctx = sideEffectsCtx
+	}
+
+	mergeCallbackRequest := callbackRequest{
+		ctx: ctx,
+		p:   p,
+	}
+
+	for {
+		switch p.fsm.Current() {
+		case stateBegin:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "merge procedure persist")
+			}
+			// The procedure can't be interrupted once the source shard is closed.
+			sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+			if err != nil {
+				return errors.WithMessage(err, "merge procedure begin side effects")
+			}
+			ctx = sideEffectsCtx
+			mergeCallbackRequest.ctx = ctx
+			if err := p.fsm.Event(eventCloseSourceShard, mergeCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "merge procedure close source shard")
+			}
+		case stateCloseSourceShard:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "merge procedure persist")
+			}
+			if err := p.fsm.Event(eventUpdateShardTables, mergeCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "merge procedure update shard tables")
+			}
+		case stateUpdateShardTables:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "merge procedure persist")
+			}
+			if err := p.fsm.Event(eventOpenTables, mergeCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "merge procedure open tables")
+			}
+		case stateOpenTables:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "merge procedure persist")
+			}
+			if err := p.fsm.Event(eventDropSourceShard, mergeCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "merge procedure drop source shard")
+			}
+		case stateDropSourceShard:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "merge procedure persist")
+			}
+			if err := p.fsm.Event(eventFinish, mergeCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "merge procedure finish")
+			}
+		case stateFinish:
+			p.updateStateWithLock(procedure.StateFinished)
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "merge procedure persist")
+			}
+			return nil
+		}
+	}
+}
+
+func (p *Procedure) Cancel(_ context.Context) error {
+	p.updateStateWithLock(procedure.StateCancelled)
+	return nil
+}
+
+func (p *Procedure) State() procedure.State {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.state
+}
+
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
+// DedupKey makes the merges between the same shards equivalent.
+func (p *Procedure) DedupKey() string {
+	return procedure.BuildDedupKey(procedure.Merge, p.params.SourceShardID, p.params.TargetShardID)
+}
+
+func (p *Procedure) updateStateWithLock(state procedure.State) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.state = state
+}
+
+func closeSourceShardCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+
+	for _, shardNode := range p.sourceShardNodes {
+		if err := procedure.Retry(req.ctx, procedure.DispatchRetryPolicy, func() error {
+			return p.params.Dispatch.CloseShard(req.ctx, shardNode.NodeName, eventdispatch.CloseShardRequest{
+				ShardID: uint32(p.params.SourceShardID),
+			})
+		}); err != nil {
+			procedure.CancelEventWithLog(event, err, "close shard", zap.Uint32("shardID", uint32(p.params.SourceShardID)), zap.String("node", shardNode.NodeName))
+			return
+		}
+	}
+}
+
+func updateShardTablesCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+	params := p.params
+
+	shardTables := params.ClusterMetadata.GetShardTables([]storage.ShardID{params.SourceShardID})
+	tables := shardTables[params.SourceShardID].Tables
+
+	// The tables are migrated by schema, and the versions of both shards are increased by every migration.
+	schemaTables := make(map[string][]string)
+	schemaNames := make([]string, 0)
+	for _, table := range tables {
+		if _, exists := schemaTables[table.SchemaName]; !exists {
+			schemaNames = append(schemaNames, table.SchemaName)
+		}
+		schemaTables[table.SchemaName] = append(schemaTables[table.SchemaName], table.Name)
+	}
+
+	latestSourceShardVersion := p.relatedVersionInfo.ShardWithVersion[params.SourceShardID]
+	latestTargetShardVersion := p.relatedVersionInfo.ShardWithVersion[params.TargetShardID]
+	for _, schemaName := range schemaNames {
+		latestSourceShardVersion++
+		latestTargetShardVersion++
+		if err := params.ClusterMetadata.MigrateTable(req.ctx, metadata.MigrateTableRequest{
+			SchemaName:            schemaName,
+			TableNames:            schemaTables[schemaName],
+			OldShardID:            params.SourceShardID,
+			LatestOldShardVersion: latestSourceShardVersion,
+			NewShardID:            params.TargetShardID,
+			LatestNewShardVersion: latestTargetShardVersion,
+		}); err != nil {
+			procedure.CancelEventWithLog(event, err, "migrate tables", zap.String("schemaName", schemaName))
+			return
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.mergedTables = tables
+	p.latestSourceShardVersion = latestSourceShardVersion
+	p.latestTargetShardVersion = latestTargetShardVersion
+}
+
+func openTablesCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+
+	p.lock.RLock()
+	tables := p.mergedTables
+	latestTargetShardVersion := p.latestTargetShardVersion
+	p.lock.RUnlock()
+
+	for _, table := range tables {
+		if err := p.params.Dispatch.OpenTableOnShard(req.ctx, p.targetLeader.NodeName, eventdispatch.OpenTableOnShardRequest{
+			UpdateShardInfo: eventdispatch.UpdateShardInfo{
+				CurrShardInfo: metadata.ShardInfo{
+					ID:      p.params.TargetShardID,
+					Role:    storage.ShardRoleLeader,
+					Version: latestTargetShardVersion,
+					Status:  storage.ShardStatusUnknown,
+				},
+			},
+			TableInfo: table,
+		}); err != nil {
+			procedure.CancelEventWithLog(event, err, "open table on shard", zap.String("tableName", table.Name), zap.Uint32("shardID", uint32(p.params.TargetShardID)))
+			return
+		}
+	}
+}
+
+func dropSourceShardCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+
+	p.lock.RLock()
+	latestSourceShardVersion := p.latestSourceShardVersion
+	p.lock.RUnlock()
+
+	if err := p.params.ClusterMetadata.DropShardView(req.ctx, p.params.SourceShardID, latestSourceShardVersion); err != nil {
+		procedure.CancelEventWithLog(event, err, "drop shard view", zap.Uint32("shardID", uint32(p.params.SourceShardID)))
+		return
+	}
+
+	if len(p.sourceShardNodes) == 0 {
+		return
+	}
+	if err := p.params.ClusterMetadata.DropShardNode(req.ctx, p.sourceShardNodes); err != nil {
+		procedure.CancelEventWithLog(event, err, "drop shard nodes", zap.Uint32("shardID", uint32(p.params.SourceShardID)))
+		return
+	}
+}
+
+func finishCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	log.Info("merge procedure finish", zap.Uint32("sourceShardID", uint32(req.p.params.SourceShardID)), zap.Uint32("targetShardID", uint32(req.p.params.TargetShardID)))
+}
+
+func (p *Procedure) persist(ctx context.Context) error {
+	meta, err := p.convertToMeta()
+	if err != nil {
+		return errors.WithMessage(err, "convert to meta")
+	}
+	err = p.params.Storage.CreateOrUpdate(ctx, meta)
+	if err != nil {
+		return errors.WithMessage(err, "createOrUpdate procedure storage")
+	}
+	return nil
+}
+
+type rawData struct {
+	ID       uint64
+	FsmState string
+	State    procedure.State
+
+	SourceShardID            uint32
+	TargetShardID            uint32
+	MergedTables             []metadata.TableInfo
+	LatestSourceShardVersion uint64
+	LatestTargetShardVersion uint64
+}
+
+func (p *Procedure) convertToMeta() (procedure.Meta, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	rawData := rawData{
+		ID:                       p.params.ID,
+		FsmState:                 p.fsm.Current(),
+		State:                    p.state,
+		SourceShardID:            uint32(p.params.SourceShardID),
+		TargetShardID:            uint32(p.params.TargetShardID),
+		MergedTables:             p.mergedTables,
+		LatestSourceShardVersion: p.latestSourceShardVersion,
+		LatestTargetShardVersion: p.latestTargetShardVersion,
+	}
+	rawDataBytes, err := json.Marshal(rawData)
+	if err != nil {
+		var emptyMeta procedure.Meta
+		return emptyMeta, procedure.ErrEncodeRawData.WithCausef("marshal raw data, procedureID:%d, err:%v", p.params.ID, err)
+	}
+
+	meta := procedure.Meta{
+		ID:    p.params.ID,
+		Kind:  procedure.Merge,
+		State: p.state,
+
+		RawData: rawDataBytes,
+	}
+
+	return meta, nil
+}
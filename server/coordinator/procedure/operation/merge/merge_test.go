@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package merge_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/merge"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	dispatch := test.MockDispatch{}
+	c := test.InitStableCluster(ctx, t)
+	s := test.NewTestStorage(t)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	targetShardID := snapshot.Topology.ClusterView.ShardNodes[0].ID
+	sourceShardID := snapshot.Topology.ClusterView.ShardNodes[1].ID
+
+	// Create one table in each shard.
+	_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       targetShardID,
+		LatestVersion: 0,
+		SchemaName:    test.TestSchemaName,
+		TableName:     test.TestTableName0,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+	_, err = c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       sourceShardID,
+		LatestVersion: 0,
+		SchemaName:    test.TestSchemaName,
+		TableName:     test.TestTableName1,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	// Merging a shard into itself is not allowed.
+	_, err = merge.NewProcedure(merge.ProcedureParams{
+		ID:              0,
+		Dispatch:        dispatch,
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
+		SourceShardID:   sourceShardID,
+		TargetShardID:   sourceShardID,
+	})
+	re.Error(err)
+
+	snapshot = c.GetMetadata().GetClusterSnapshot()
+	p, err := merge.NewProcedure(merge.ProcedureParams{
+		ID:              1,
+		Dispatch:        dispatch,
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: snapshot,
+		SourceShardID:   sourceShardID,
+		TargetShardID:   targetShardID,
+	})
+	re.NoError(err)
+	err = p.Start(ctx)
+	re.NoError(err)
+
+	// Validate merge result:
+	// 1. The source shard is removed from both shard views and cluster view.
+	// 2. All tables are moved into the target shard.
+	newSnapshot := c.GetMetadata().GetClusterSnapshot()
+	_, exists := newSnapshot.Topology.ShardViewsMapping[sourceShardID]
+	re.False(exists)
+	for _, shardNode := range newSnapshot.Topology.ClusterView.ShardNodes {
+		re.NotEqual(sourceShardID, shardNode.ID)
+	}
+	re.Equal(len(snapshot.Topology.ShardViewsMapping)-1, len(newSnapshot.Topology.ShardViewsMapping))
+	re.True(newSnapshot.Topology.IsStable())
+
+	targetShard := newSnapshot.Topology.ShardViewsMapping[targetShardID]
+	re.Equal(2, len(targetShard.TableIDs))
+	re.Equal(snapshot.Topology.ShardViewsMapping[targetShardID].Version+1, targetShard.Version)
+	for _, tableName := range []string{test.TestTableName0, test.TestTableName1} {
+		table, exists, err := c.GetMetadata().GetTable(test.TestSchemaName, tableName)
+		re.NoError(err)
+		re.True(exists)
+		shardID, exists := c.GetMetadata().GetTableShard(ctx, table)
+		re.True(exists)
+		re.Equal(targetShardID, shardID)
+	}
+}
@@ -0,0 +1,585 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/ddl"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/looplab/fsm"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Fsm state change: Begin -> CloseTables -> UpdateShardTables -> OpenTables -> Finish
+// CloseTables will send close table requests to the leader of the source shard.
+// UpdateShardTables will move the tables from the source shard to the target shard in the topology.
+// OpenTables will send open table requests to the leader of the target shard.
+const (
+	eventCloseTables       = "EventCloseTables"
+	eventUpdateShardTables = "EventUpdateShardTables"
+	eventOpenTables        = "EventOpenTables"
+	eventFinish            = "EventFinish"
+
+	stateBegin             = "StateBegin"
+	stateCloseTables       = "StateCloseTables"
+	stateUpdateShardTables = "StateUpdateShardTables"
+	stateOpenTables        = "StateOpenTables"
+	stateFinish            = "StateFinish"
+)
+
+var (
+	migrateEvents = fsm.Events{
+		{Name: eventCloseTables, Src: []string{stateBegin}, Dst: stateCloseTables},
+		{Name: eventUpdateShardTables, Src: []string{stateCloseTables}, Dst: stateUpdateShardTables},
+		{Name: eventOpenTables, Src: []string{stateUpdateShardTables}, Dst: stateOpenTables},
+		{Name: eventFinish, Src: []string{stateOpenTables}, Dst: stateFinish},
+	}
+	migrateCallbacks = fsm.Callbacks{
+		eventCloseTables:       closeTablesCallback,
+		eventUpdateShardTables: updateShardTablesCallback,
+		eventOpenTables:        openTablesCallback,
+		eventFinish:            finishCallback,
+	}
+)
+
+type Procedure struct {
+	fsm                *fsm.FSM
+	params             ProcedureParams
+	relatedVersionInfo procedure.RelatedVersionInfo
+
+	sourceLeader storage.ShardNode
+	targetLeader storage.ShardNode
+	// The latest versions of the shards after the tables are moved.
+	latestSourceShardVersion uint64
+	latestTargetShardVersion uint64
+
+	// Protect the state.
+	lock  sync.RWMutex
+	state procedure.State
+}
+
+type ProcedureParams struct {
+	ID uint64
+
+	Dispatch eventdispatch.Dispatch
+	Storage  procedure.Storage
+
+	ClusterMetadata *metadata.ClusterMetadata
+	ClusterSnapshot metadata.Snapshot
+
+	SchemaName    string
+	TableNames    []string
+	SourceShardID storage.ShardID
+	TargetShardID storage.ShardID
+}
+
+// NewProcedure creates the procedure migrating the tables, and all of them must be in the source shard.
+func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	// The tables may have been moved to the target shard when the procedure is recovered, so they are only validated here.
+	if err := validateTables(params); err != nil {
+		return nil, err
+	}
+	return newProcedure(params)
+}
+
+// DecodeProcedure rebuilds the procedure from the persisted meta, the fields of params persisted in the meta will be overwritten.
+func DecodeProcedure(params ProcedureParams, meta *procedure.Meta) (procedure.Procedure, error) {
+	var data rawData
+	if err := json.Unmarshal(meta.RawData, &data); err != nil {
+		return nil, procedure.ErrDecodeRawData.WithCausef("unmarshal raw data, procedureID:%d, err:%v", meta.ID, err)
+	}
+
+	params.ID = data.ID
+	params.SchemaName = data.SchemaName
+	params.TableNames = data.TableNames
+	params.SourceShardID = storage.ShardID(data.SourceShardID)
+	params.TargetShardID = storage.ShardID(data.TargetShardID)
+	p, err := newProcedure(params)
+	if err != nil {
+		return nil, err
+	}
+
+	fsmState := data.FsmState
+	// The tables may have been moved before the state is persisted.
+	if fsmState == stateCloseTables && p.tablesMoved() {
+		fsmState = stateUpdateShardTables
+	}
+	switch fsmState {
+	case stateUpdateShardTables, stateOpenTables:
+		// The versions of the shards have been updated, keep the persisted ones.
+		p.latestSourceShardVersion = data.LatestSourceShardVersion
+		p.latestTargetShardVersion = data.LatestTargetShardVersion
+	}
+	p.fsm.SetState(fsmState)
+
+	return p, nil
+}
+
+func newProcedure(params ProcedureParams) (*Procedure, error) {
+	if err := validateParams(params); err != nil {
+		return nil, err
+	}
+
+	sourceLeader, err := findShardLeader(params.ClusterSnapshot.Topology, params.SourceShardID)
+	if err != nil {
+		return nil, err
+	}
+	targetLeader, err := findShardLeader(params.ClusterSnapshot.Topology, params.TargetShardID)
+	if err != nil {
+		return nil, err
+	}
+
+	relatedVersionInfo, err := buildRelatedVersionInfo(params)
+	if err != nil {
+		return nil, err
+	}
+
+	migrateFsm := fsm.NewFSM(
+		stateBegin,
+		migrateEvents,
+		migrateCallbacks,
+	)
+
+	return &Procedure{
+		fsm:                      migrateFsm,
+		params:                   params,
+		relatedVersionInfo:       relatedVersionInfo,
+		sourceLeader:             sourceLeader,
+		targetLeader:             targetLeader,
+		latestSourceShardVersion: relatedVersionInfo.ShardWithVersion[params.SourceShardID] + 1,
+		latestTargetShardVersion: relatedVersionInfo.ShardWithVersion[params.TargetShardID] + 1,
+		lock:                     sync.RWMutex{},
+		state:                    procedure.StateInit,
+	}, nil
+}
+
+func validateParams(params ProcedureParams) error {
+	if len(params.TableNames) == 0 {
+		return errors.WithMessage(procedure.ErrEmptyMigrateTables, "no table to migrate")
+	}
+
+	if params.SourceShardID == params.TargetShardID {
+		return errors.WithMessagef(procedure.ErrMigrateToSameShard, "shardID:%d", params.SourceShardID)
+	}
+
+	if params.ClusterSnapshot.Topology.ClusterView.State != storage.ClusterStateStable {
+		log.Error("cluster state must be stable", zap.Error(metadata.ErrClusterStateInvalid))
+		return metadata.ErrClusterStateInvalid
+	}
+
+	return nil
+}
+
+func validateTables(params ProcedureParams) error {
+	shardTables := params.ClusterMetadata.GetShardTables([]storage.ShardID{params.SourceShardID})
+	sourceTables := make(map[string]struct{}, len(shardTables[params.SourceShardID].Tables))
+	for _, table := range shardTables[params.SourceShardID].Tables {
+		if table.SchemaName == params.SchemaName {
+			sourceTables[table.Name] = struct{}{}
+		}
+	}
+
+	for _, tableName := range params.TableNames {
+		if _, exists := sourceTables[tableName]; !exists {
+			return errors.WithMessagef(procedure.ErrMigrateTableNotInShard, "schema:%s, table:%s, shardID:%d", params.SchemaName, tableName, params.SourceShardID)
+		}
+	}
+	return nil
+}
+
+func buildRelatedVersionInfo(params ProcedureParams) (procedure.RelatedVersionInfo, error) {
+	shardWithVersion := make(map[storage.ShardID]uint64, 2)
+	for _, shardID := range []storage.ShardID{params.SourceShardID, params.TargetShardID} {
+		shardView, exists := params.ClusterSnapshot.Topology.ShardViewsMapping[shardID]
+		if !exists {
+			return procedure.RelatedVersionInfo{}, errors.WithMessagef(metadata.ErrShardNotFound, "shard not found in topology, shardID:%d", shardID)
+		}
+		shardWithVersion[shardID] = shardView.Version
+	}
+
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        params.ClusterSnapshot.Topology.ClusterView.ClusterID,
+		ShardWithVersion: shardWithVersion,
+		ClusterVersion:   params.ClusterSnapshot.Topology.ClusterView.Version,
+	}
+	return relatedVersionInfo, nil
+}
+
+func findShardLeader(topology metadata.Topology, shardID storage.ShardID) (storage.ShardNode, error) {
+	if _, exists := topology.ShardViewsMapping[shardID]; !exists {
+		log.Error("shard not found", zap.Uint32("shardID", uint32(shardID)), zap.Error(metadata.ErrShardNotFound))
+		return storage.ShardNode{}, errors.WithMessagef(metadata.ErrShardNotFound, "shardID:%d", shardID)
+	}
+
+	for _, shardNode := range topology.ClusterView.ShardNodes {
+		if shardNode.ID == shardID && shardNode.ShardRole == storage.ShardRoleLeader {
+			return shardNode, nil
+		}
+	}
+
+	log.Error("shard leader not found", zap.Uint32("shardID", uint32(shardID)), zap.Error(procedure.ErrShardLeaderNotFound))
+	return storage.ShardNode{}, errors.WithMessagef(procedure.ErrShardLeaderNotFound, "shardID:%d", shardID)
+}
+
+type callbackRequest struct {
+	ctx context.Context
+	p   *Procedure
+}
+
+func (p *Procedure) ID() uint64 {
+	return p.params.ID
+}
+
+func (p *Procedure) Kind() procedure.Kind {
+	return procedure.Migrate
+}
+
+func (p *Procedure) RelatedVersionInfo() procedure.RelatedVersionInfo {
+	return p.relatedVersionInfo
+}
+
+func (p *Procedure) Priority() procedure.Priority {
+	return procedure.PriorityMed
+}
+
+func (p *Procedure) Start(ctx context.Context) error {
+	p.updateStateWithLock(procedure.StateRunning)
+
+	// The recovered procedure may have done the side effects, so it can't be interrupted from the beginning.
+	if p.fsm.Current() != stateBegin {
+		sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "migrate procedure begin side effects")
+		}
+		ctx = sideEffectsCtx
+	}
+
+	migrateCallbackRequest := callbackRequest{
+		ctx: ctx,
+		p:   p,
+	}
+
+	for {
+		switch p.fsm.Current() {
+		case stateBegin:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "migrate procedure persist")
+			}
+			// The procedure can't be interrupted once the tables are closed.
+			sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+			if err != nil {
+				return errors.WithMessage(err, "migrate procedure begin side effects")
+			}
+			ctx = sideEffectsCtx
+			migrateCallbackRequest.ctx = ctx
+			if err := p.fsm.Event(eventCloseTables, migrateCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "migrate procedure close tables")
+			}
+		case stateCloseTables:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "migrate procedure persist")
+			}
+			if err := p.fsm.Event(eventUpdateShardTables, migrateCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "migrate procedure update shard tables")
+			}
+		case stateUpdateShardTables:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "migrate procedure persist")
+			}
+			if err := p.fsm.Event(eventOpenTables, migrateCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "migrate procedure open tables")
+			}
+		case stateOpenTables:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "migrate procedure persist")
+			}
+			if err := p.fsm.Event(eventFinish, migrateCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "migrate procedure finish")
+			}
+		case stateFinish:
+			p.updateStateWithLock(procedure.StateFinished)
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "migrate procedure persist")
+			}
+			return nil
+		}
+	}
+}
+
+func (p *Procedure) Cancel(_ context.Context) error {
+	p.updateStateWithLock(procedure.StateCancelled)
+	return nil
+}
+
+func (p *Procedure) State() procedure.State {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.state
+}
+
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
+// DedupKey makes the migrations of the same tables between the same shards equivalent.
+func (p *Procedure) DedupKey() string {
+	tableNames := append([]string{}, p.params.TableNames...)
+	sort.Strings(tableNames)
+	return procedure.BuildDedupKey(procedure.Migrate, p.params.SourceShardID, p.params.TargetShardID, p.params.SchemaName, strings.Join(tableNames, ","))
+}
+
+func (p *Procedure) updateStateWithLock(state procedure.State) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.state = state
+}
+
+// tablesMoved returns whether all the tables have been moved to the target shard in the topology.
+func (p *Procedure) tablesMoved() bool {
+	shardTables := p.params.ClusterMetadata.GetShardTables([]storage.ShardID{p.params.TargetShardID})
+	targetTables := make(map[string]struct{}, len(shardTables[p.params.TargetShardID].Tables))
+	for _, table := range shardTables[p.params.TargetShardID].Tables {
+		if table.SchemaName == p.params.SchemaName {
+			targetTables[table.Name] = struct{}{}
+		}
+	}
+
+	for _, tableName := range p.params.TableNames {
+		if _, exists := targetTables[tableName]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Procedure) buildTableInfos() ([]metadata.TableInfo, error) {
+	tableInfos := make([]metadata.TableInfo, 0, len(p.params.TableNames))
+	for _, tableName := range p.params.TableNames {
+		table, err := ddl.GetTableMetadata(p.params.ClusterMetadata, p.params.SchemaName, tableName)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "get table metadata, tableName:%s", tableName)
+		}
+		tableInfos = append(tableInfos, metadata.TableInfo{
+			ID:            table.ID,
+			Name:          table.Name,
+			SchemaID:      table.SchemaID,
+			SchemaName:    p.params.SchemaName,
+			PartitionInfo: table.PartitionInfo,
+			CreatedAt:     table.CreatedAt,
+		})
+	}
+	return tableInfos, nil
+}
+
+func closeTablesCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+
+	tableInfos, err := p.buildTableInfos()
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "build table infos")
+		return
+	}
+
+	closedTableInfos := make([]metadata.TableInfo, 0, len(tableInfos))
+	for _, tableInfo := range tableInfos {
+		if err := p.params.Dispatch.CloseTableOnShard(req.ctx, p.sourceLeader.NodeName, eventdispatch.CloseTableOnShardRequest{
+			UpdateShardInfo: eventdispatch.UpdateShardInfo{
+				CurrShardInfo: metadata.ShardInfo{
+					ID:      p.params.SourceShardID,
+					Role:    storage.ShardRoleLeader,
+					Version: p.latestSourceShardVersion,
+					Status:  storage.ShardStatusUnknown,
+				},
+			},
+			TableInfo: tableInfo,
+		}); err != nil {
+			// The tables are still in the source shard in the topology, so the closed ones are reopened there instead of being left closed.
+			p.reopenTablesOnSourceShard(req.ctx, closedTableInfos)
+			procedure.CancelEventWithLog(event, err, "close table on shard", zap.String("tableName", tableInfo.Name), zap.Uint32("shardID", uint32(p.params.SourceShardID)))
+			return
+		}
+		closedTableInfos = append(closedTableInfos, tableInfo)
+	}
+}
+
+// reopenTablesOnSourceShard reopens the closed tables on the source shard, and the failures are only logged because the close failure
+// will be reported anyway.
+func (p *Procedure) reopenTablesOnSourceShard(ctx context.Context, tableInfos []metadata.TableInfo) {
+	for _, tableInfo := range tableInfos {
+		if err := procedure.Retry(ctx, procedure.DispatchRetryPolicy, func() error {
+			return p.params.Dispatch.OpenTableOnShard(ctx, p.sourceLeader.NodeName, eventdispatch.OpenTableOnShardRequest{
+				UpdateShardInfo: eventdispatch.UpdateShardInfo{
+					CurrShardInfo: metadata.ShardInfo{
+						ID:      p.params.SourceShardID,
+						Role:    storage.ShardRoleLeader,
+						Version: p.latestSourceShardVersion,
+						Status:  storage.ShardStatusUnknown,
+					},
+				},
+				TableInfo: tableInfo,
+			})
+		}); err != nil {
+			log.Error("reopen table on source shard failed", zap.String("tableName", tableInfo.Name), zap.Uint32("shardID", uint32(p.params.SourceShardID)), zap.Error(err))
+		}
+	}
+}
+
+func updateShardTablesCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+
+	if err := p.params.ClusterMetadata.MigrateTable(req.ctx, metadata.MigrateTableRequest{
+		SchemaName:            p.params.SchemaName,
+		TableNames:            p.params.TableNames,
+		OldShardID:            p.params.SourceShardID,
+		LatestOldShardVersion: p.latestSourceShardVersion,
+		NewShardID:            p.params.TargetShardID,
+		LatestNewShardVersion: p.latestTargetShardVersion,
+	}); err != nil {
+		procedure.CancelEventWithLog(event, err, "update shard tables")
+		return
+	}
+}
+
+func openTablesCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+
+	tableInfos, err := p.buildTableInfos()
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "build table infos")
+		return
+	}
+
+	// The tables have been moved to the target shard in the topology, and opening table is idempotent, so it is retried to avoid
+	// leaving the tables closed.
+	for _, tableInfo := range tableInfos {
+		if err := procedure.Retry(req.ctx, procedure.DispatchRetryPolicy, func() error {
+			return p.params.Dispatch.OpenTableOnShard(req.ctx, p.targetLeader.NodeName, eventdispatch.OpenTableOnShardRequest{
+				UpdateShardInfo: eventdispatch.UpdateShardInfo{
+					CurrShardInfo: metadata.ShardInfo{
+						ID:      p.params.TargetShardID,
+						Role:    storage.ShardRoleLeader,
+						Version: p.latestTargetShardVersion,
+						Status:  storage.ShardStatusUnknown,
+					},
+				},
+				TableInfo: tableInfo,
+			})
+		}); err != nil {
+			procedure.CancelEventWithLog(event, err, "open table on shard", zap.String("tableName", tableInfo.Name), zap.Uint32("shardID", uint32(p.params.TargetShardID)))
+			return
+		}
+	}
+}
+
+func finishCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	log.Info("migrate procedure finish", zap.Uint32("sourceShardID", uint32(req.p.params.SourceShardID)), zap.Uint32("targetShardID", uint32(req.p.params.TargetShardID)), zap.Strings("tableNames", req.p.params.TableNames))
+}
+
+func (p *Procedure) persist(ctx context.Context) error {
+	meta, err := p.convertToMeta()
+	if err != nil {
+		return errors.WithMessage(err, "convert to meta")
+	}
+	err = p.params.Storage.CreateOrUpdate(ctx, meta)
+	if err != nil {
+		return errors.WithMessage(err, "createOrUpdate procedure storage")
+	}
+	return nil
+}
+
+type rawData struct {
+	ID       uint64
+	FsmState string
+	State    procedure.State
+
+	SchemaName               string
+	TableNames               []string
+	SourceShardID            uint32
+	TargetShardID            uint32
+	LatestSourceShardVersion uint64
+	LatestTargetShardVersion uint64
+}
+
+func (p *Procedure) convertToMeta() (procedure.Meta, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	rawData := rawData{
+		ID:                       p.params.ID,
+		FsmState:                 p.fsm.Current(),
+		State:                    p.state,
+		SchemaName:               p.params.SchemaName,
+		TableNames:               p.params.TableNames,
+		SourceShardID:            uint32(p.params.SourceShardID),
+		TargetShardID:            uint32(p.params.TargetShardID),
+		LatestSourceShardVersion: p.latestSourceShardVersion,
+		LatestTargetShardVersion: p.latestTargetShardVersion,
+	}
+	rawDataBytes, err := json.Marshal(rawData)
+	if err != nil {
+		var emptyMeta procedure.Meta
+		return emptyMeta, procedure.ErrEncodeRawData.WithCausef("marshal raw data, procedureID:%d, err:%v", p.params.ID, err)
+	}
+
+	meta := procedure.Meta{
+		ID:    p.params.ID,
+		Kind:  procedure.Migrate,
+		State: p.state,
+
+		RawData: rawDataBytes,
+	}
+
+	return meta, nil
+}
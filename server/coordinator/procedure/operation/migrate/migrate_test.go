@@ -0,0 +1,367 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package migrate_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/migrate"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// slowOpenDispatch is the MockDispatch which takes the delay to open the tables, unless its ctx is done.
+type slowOpenDispatch struct {
+	test.MockDispatch
+	delay time.Duration
+}
+
+func (d slowOpenDispatch) OpenTableOnShard(ctx context.Context, _ string, _ eventdispatch.OpenTableOnShardRequest) error {
+	select {
+	case <-time.After(d.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flakyDispatch is the MockDispatch which fails to close the tables in closeFailures, and fails to open the tables for openFailures
+// times. The tables opened successfully are recorded by the shards.
+type flakyDispatch struct {
+	test.MockDispatch
+	closeFailures map[string]struct{}
+	openFailures  int
+
+	lock         sync.Mutex
+	openedTables map[storage.ShardID][]string
+}
+
+func (d *flakyDispatch) CloseTableOnShard(_ context.Context, _ string, req eventdispatch.CloseTableOnShardRequest) error {
+	if _, exists := d.closeFailures[req.TableInfo.Name]; exists {
+		return errors.New("close table failed")
+	}
+	return nil
+}
+
+func (d *flakyDispatch) OpenTableOnShard(_ context.Context, _ string, req eventdispatch.OpenTableOnShardRequest) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.openFailures > 0 {
+		d.openFailures--
+		return errors.New("open table failed")
+	}
+	shardID := req.UpdateShardInfo.CurrShardInfo.ID
+	d.openedTables[shardID] = append(d.openedTables[shardID], req.TableInfo.Name)
+	return nil
+}
+
+type migrateDecoder struct {
+	params migrate.ProcedureParams
+}
+
+func (d migrateDecoder) Decode(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return migrate.DecodeProcedure(d.params, meta)
+}
+
+func TestMigrate(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	dispatch := test.MockDispatch{}
+	c := test.InitStableCluster(ctx, t)
+	s := test.NewTestStorage(t)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	sourceShardID := snapshot.Topology.ClusterView.ShardNodes[0].ID
+	targetShardID := snapshot.Topology.ClusterView.ShardNodes[1].ID
+
+	// Create some tables in the source shard.
+	for _, tableName := range []string{test.TestTableName0, test.TestTableName1} {
+		_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+			ShardID:       sourceShardID,
+			LatestVersion: 0,
+			SchemaName:    test.TestSchemaName,
+			TableName:     tableName,
+			PartitionInfo: storage.PartitionInfo{Info: nil},
+		})
+		re.NoError(err)
+	}
+
+	// Migrating tables to the same shard is not allowed.
+	_, err := migrate.NewProcedure(migrate.ProcedureParams{
+		ID:              0,
+		Dispatch:        dispatch,
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
+		SchemaName:      test.TestSchemaName,
+		TableNames:      []string{test.TestTableName0},
+		SourceShardID:   sourceShardID,
+		TargetShardID:   sourceShardID,
+	})
+	re.Error(err)
+
+	// Migrating the tables which are not in the source shard is not allowed.
+	for _, invalidParams := range []struct {
+		sourceShardID storage.ShardID
+		tableNames    []string
+	}{
+		{sourceShardID: sourceShardID, tableNames: []string{test.TestTableName0, "not_exist"}},
+		{sourceShardID: targetShardID, tableNames: []string{test.TestTableName0}},
+	} {
+		_, err = migrate.NewProcedure(migrate.ProcedureParams{
+			ID:              0,
+			Dispatch:        dispatch,
+			Storage:         s,
+			ClusterMetadata: c.GetMetadata(),
+			ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
+			SchemaName:      test.TestSchemaName,
+			TableNames:      invalidParams.tableNames,
+			SourceShardID:   invalidParams.sourceShardID,
+			TargetShardID:   snapshot.Topology.ClusterView.ShardNodes[2].ID,
+		})
+		re.ErrorIs(err, procedure.ErrMigrateTableNotInShard)
+	}
+
+	// Migrate all tables from the source shard to the target shard.
+	snapshot = c.GetMetadata().GetClusterSnapshot()
+	p, err := migrate.NewProcedure(migrate.ProcedureParams{
+		ID:              1,
+		Dispatch:        dispatch,
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: snapshot,
+		SchemaName:      test.TestSchemaName,
+		TableNames:      []string{test.TestTableName0, test.TestTableName1},
+		SourceShardID:   sourceShardID,
+		TargetShardID:   targetShardID,
+	})
+	re.NoError(err)
+	err = p.Start(ctx)
+	re.NoError(err)
+
+	// Validate migrate result:
+	// 1. The source shard contains no table and the target shard contains all the migrated tables.
+	// 2. The versions of both shards are increased.
+	newSnapshot := c.GetMetadata().GetClusterSnapshot()
+	sourceShard := newSnapshot.Topology.ShardViewsMapping[sourceShardID]
+	targetShard := newSnapshot.Topology.ShardViewsMapping[targetShardID]
+	re.Equal(0, len(sourceShard.TableIDs))
+	re.Equal(2, len(targetShard.TableIDs))
+	re.Equal(snapshot.Topology.ShardViewsMapping[sourceShardID].Version+1, sourceShard.Version)
+	re.Equal(snapshot.Topology.ShardViewsMapping[targetShardID].Version+1, targetShard.Version)
+
+	for _, tableName := range []string{test.TestTableName0, test.TestTableName1} {
+		table, exists, err := c.GetMetadata().GetTable(test.TestSchemaName, tableName)
+		re.NoError(err)
+		re.True(exists)
+		shardID, exists := c.GetMetadata().GetTableShard(ctx, table)
+		re.True(exists)
+		re.Equal(targetShardID, shardID)
+	}
+}
+
+func TestDecodeMigrateProcedure(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	dispatch := test.MockDispatch{}
+	c := test.InitStableCluster(ctx, t)
+	s := test.NewTestStorage(t)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	sourceShardID := snapshot.Topology.ClusterView.ShardNodes[0].ID
+	targetShardID := snapshot.Topology.ClusterView.ShardNodes[1].ID
+
+	_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       sourceShardID,
+		LatestVersion: 0,
+		SchemaName:    test.TestSchemaName,
+		TableName:     test.TestTableName0,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	// The procedure is persisted after the tables are closed on the source shard.
+	rawData, err := json.Marshal(map[string]any{
+		"ID":            1,
+		"FsmState":      "StateCloseTables",
+		"State":         procedure.StateRunning,
+		"SchemaName":    test.TestSchemaName,
+		"TableNames":    []string{test.TestTableName0},
+		"SourceShardID": sourceShardID,
+		"TargetShardID": targetShardID,
+	})
+	re.NoError(err)
+
+	p, err := migrate.DecodeProcedure(migrate.ProcedureParams{
+		Dispatch:        dispatch,
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
+	}, &procedure.Meta{ID: 1, Kind: procedure.Migrate, State: procedure.StateRunning, RawData: rawData})
+	re.NoError(err)
+	re.Equal(uint64(1), p.ID())
+
+	// The procedure is resumed and the table is moved to the target shard.
+	re.NoError(p.Start(ctx))
+	re.Equal(procedure.State(procedure.StateFinished), p.State())
+	table, exists, err := c.GetMetadata().GetTable(test.TestSchemaName, test.TestTableName0)
+	re.NoError(err)
+	re.True(exists)
+	shardID, exists := c.GetMetadata().GetTableShard(ctx, table)
+	re.True(exists)
+	re.Equal(targetShardID, shardID)
+}
+
+func TestMigrateTimeoutAfterCloseTables(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	dispatch := slowOpenDispatch{MockDispatch: test.MockDispatch{}, delay: time.Millisecond * 150}
+	c := test.InitStableCluster(ctx, t)
+	s := test.NewTestStorage(t)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	sourceShardID := snapshot.Topology.ClusterView.ShardNodes[0].ID
+	targetShardID := snapshot.Topology.ClusterView.ShardNodes[1].ID
+
+	_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+		ShardID:       sourceShardID,
+		LatestVersion: 0,
+		SchemaName:    test.TestSchemaName,
+		TableName:     test.TestTableName0,
+		PartitionInfo: storage.PartitionInfo{Info: nil},
+	})
+	re.NoError(err)
+
+	params := migrate.ProcedureParams{
+		ID:              1,
+		Dispatch:        dispatch,
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
+		SchemaName:      test.TestSchemaName,
+		TableNames:      []string{test.TestTableName0},
+		SourceShardID:   sourceShardID,
+		TargetShardID:   targetShardID,
+	}
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), s, migrateDecoder{params: params}, procedure.ManagerOptions{
+		Timeout: procedure.TimeoutOptions{
+			Default:      0,
+			KindTimeouts: map[procedure.Kind]time.Duration{procedure.Migrate: time.Millisecond * 50},
+		},
+	})
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	// The deadline is exceeded while the tables are being opened on the target shard, which is after the tables are closed on the
+	// source shard, so the procedure keeps running until the tables are moved instead of leaving them closed.
+	p, err := migrate.NewProcedure(params)
+	re.NoError(err)
+	_, err = manager.Submit(ctx, p)
+	re.NoError(err)
+	time.Sleep(time.Millisecond * 400)
+
+	detail, err := manager.GetProcedure(ctx, 1)
+	re.NoError(err)
+	re.Equal(procedure.State(procedure.StateFinished), detail.State)
+	re.Empty(detail.LastError)
+	table, exists, err := c.GetMetadata().GetTable(test.TestSchemaName, test.TestTableName0)
+	re.NoError(err)
+	re.True(exists)
+	shardID, exists := c.GetMetadata().GetTableShard(ctx, table)
+	re.True(exists)
+	re.Equal(targetShardID, shardID)
+}
+
+func TestMigrateCompensation(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	c := test.InitStableCluster(ctx, t)
+	s := test.NewTestStorage(t)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	sourceShardID := snapshot.Topology.ClusterView.ShardNodes[0].ID
+	targetShardID := snapshot.Topology.ClusterView.ShardNodes[1].ID
+
+	for _, tableName := range []string{test.TestTableName0, test.TestTableName1} {
+		_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+			ShardID:       sourceShardID,
+			LatestVersion: 0,
+			SchemaName:    test.TestSchemaName,
+			TableName:     tableName,
+			PartitionInfo: storage.PartitionInfo{Info: nil},
+		})
+		re.NoError(err)
+	}
+
+	newProcedure := func(id uint64, dispatch eventdispatch.Dispatch) procedure.Procedure {
+		p, err := migrate.NewProcedure(migrate.ProcedureParams{
+			ID:              id,
+			Dispatch:        dispatch,
+			Storage:         s,
+			ClusterMetadata: c.GetMetadata(),
+			ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
+			SchemaName:      test.TestSchemaName,
+			TableNames:      []string{test.TestTableName0, test.TestTableName1},
+			SourceShardID:   sourceShardID,
+			TargetShardID:   targetShardID,
+		})
+		re.NoError(err)
+		return p
+	}
+
+	// The second table fails to be closed, so the first one is reopened on the source shard and no table is moved.
+	dispatch := &flakyDispatch{
+		MockDispatch:  test.MockDispatch{},
+		closeFailures: map[string]struct{}{test.TestTableName1: {}},
+		openFailures:  0,
+		lock:          sync.Mutex{},
+		openedTables:  map[storage.ShardID][]string{},
+	}
+	p := newProcedure(1, dispatch)
+	re.Error(p.Start(ctx))
+	re.Equal(procedure.State(procedure.StateFailed), p.State())
+	re.Equal(map[storage.ShardID][]string{sourceShardID: {test.TestTableName0}}, dispatch.openedTables)
+	re.Equal(2, len(c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping[sourceShardID].TableIDs))
+
+	// The tables fail to be opened on the target shard for the first time, and they are opened after the retry.
+	dispatch = &flakyDispatch{
+		MockDispatch:  test.MockDispatch{},
+		closeFailures: map[string]struct{}{},
+		openFailures:  1,
+		lock:          sync.Mutex{},
+		openedTables:  map[storage.ShardID][]string{},
+	}
+	p = newProcedure(2, dispatch)
+	re.NoError(p.Start(ctx))
+	re.Equal(procedure.State(procedure.StateFinished), p.State())
+	re.Equal(map[storage.ShardID][]string{targetShardID: {test.TestTableName0, test.TestTableName1}}, dispatch.openedTables)
+	re.Equal(2, len(c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping[targetShardID].TableIDs))
+}
@@ -0,0 +1,498 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package scatter
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/looplab/fsm"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// Fsm state change: Begin -> OpenShards -> UpdateClusterView -> Finish
+// OpenShards will send open shard requests to the assigned nodes batch by batch, and the progress is persisted after every batch.
+// UpdateClusterView will persist the assignment into the cluster view and update the cluster state to stable.
+const (
+	eventOpenShards        = "EventOpenShards"
+	eventUpdateClusterView = "EventUpdateClusterView"
+	eventFinish            = "EventFinish"
+
+	stateBegin             = "StateBegin"
+	stateOpenShards        = "StateOpenShards"
+	stateUpdateClusterView = "StateUpdateClusterView"
+	stateFinish            = "StateFinish"
+)
+
+var (
+	scatterEvents = fsm.Events{
+		{Name: eventOpenShards, Src: []string{stateBegin}, Dst: stateOpenShards},
+		{Name: eventUpdateClusterView, Src: []string{stateOpenShards}, Dst: stateUpdateClusterView},
+		{Name: eventFinish, Src: []string{stateUpdateClusterView}, Dst: stateFinish},
+	}
+	scatterCallbacks = fsm.Callbacks{
+		eventOpenShards:        openShardsCallback,
+		eventUpdateClusterView: updateClusterViewCallback,
+		eventFinish:            finishCallback,
+	}
+)
+
+// ShardProgress describes whether the shard has been opened on the assigned node.
+type ShardProgress struct {
+	ShardID  storage.ShardID `json:"shardID"`
+	NodeName string          `json:"nodeName"`
+	Opened   bool            `json:"opened"`
+}
+
+type Procedure struct {
+	fsm                *fsm.FSM
+	params             ProcedureParams
+	relatedVersionInfo procedure.RelatedVersionInfo
+
+	// Protect the state and the progress.
+	lock  sync.RWMutex
+	state procedure.State
+	// ShardID -> whether the shard has been opened.
+	openedShards map[storage.ShardID]bool
+}
+
+type ProcedureParams struct {
+	ID uint64
+
+	Dispatch eventdispatch.Dispatch
+	Storage  procedure.Storage
+
+	ClusterMetadata *metadata.ClusterMetadata
+	ClusterSnapshot metadata.Snapshot
+
+	// ShardNodes is the assignment of all the shards in the cluster.
+	ShardNodes []storage.ShardNode
+	// OpenedShards contains the shards which have been opened, they will be skipped when the procedure is resumed.
+	OpenedShards []storage.ShardID
+	// BatchSize is the max number of shards to be opened concurrently, zero means no limit.
+	BatchSize uint32
+}
+
+func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	return newProcedure(params)
+}
+
+// DecodeProcedure rebuilds the procedure from the persisted meta, the fields of params persisted in the meta will be overwritten.
+// The opened shards will be skipped when the procedure is resumed.
+func DecodeProcedure(params ProcedureParams, meta *procedure.Meta) (procedure.Procedure, error) {
+	var data rawData
+	if err := json.Unmarshal(meta.RawData, &data); err != nil {
+		return nil, procedure.ErrDecodeRawData.WithCausef("unmarshal raw data, procedureID:%d, err:%v", meta.ID, err)
+	}
+
+	params.ID = data.ID
+	params.ShardNodes = data.ShardNodes
+	params.OpenedShards = data.OpenedShards
+	params.BatchSize = data.BatchSize
+	p, err := newProcedure(params)
+	if err != nil {
+		return nil, err
+	}
+	p.fsm.SetState(data.FsmState)
+
+	return p, nil
+}
+
+func newProcedure(params ProcedureParams) (*Procedure, error) {
+	if err := validateClusterTopology(params); err != nil {
+		return nil, err
+	}
+
+	relatedVersionInfo, err := buildRelatedVersionInfo(params)
+	if err != nil {
+		return nil, err
+	}
+
+	openedShards := make(map[storage.ShardID]bool, len(params.ShardNodes))
+	for _, shardNode := range params.ShardNodes {
+		openedShards[shardNode.ID] = false
+	}
+	for _, shardID := range params.OpenedShards {
+		openedShards[shardID] = true
+	}
+
+	scatterFsm := fsm.NewFSM(
+		stateBegin,
+		scatterEvents,
+		scatterCallbacks,
+	)
+
+	return &Procedure{
+		fsm:                scatterFsm,
+		params:             params,
+		relatedVersionInfo: relatedVersionInfo,
+		lock:               sync.RWMutex{},
+		state:              procedure.StateInit,
+		openedShards:       openedShards,
+	}, nil
+}
+
+func validateClusterTopology(params ProcedureParams) error {
+	topology := params.ClusterSnapshot.Topology
+	if topology.ClusterView.State != storage.ClusterStatePrepare {
+		log.Error("cluster state must be prepare", zap.Error(metadata.ErrClusterStateInvalid))
+		return metadata.ErrClusterStateInvalid
+	}
+
+	// Every shard must be assigned to exactly one leader.
+	assigned := make(map[storage.ShardID]struct{}, len(params.ShardNodes))
+	for _, shardNode := range params.ShardNodes {
+		if _, exists := topology.ShardViewsMapping[shardNode.ID]; !exists {
+			return errors.WithMessagef(metadata.ErrShardNotFound, "shard not found in topology, shardID:%d", shardNode.ID)
+		}
+		if _, exists := assigned[shardNode.ID]; exists {
+			return errors.WithMessagef(procedure.ErrInvalidScatterAssignment, "shard is assigned repeatedly, shardID:%d", shardNode.ID)
+		}
+		assigned[shardNode.ID] = struct{}{}
+	}
+	for shardID := range topology.ShardViewsMapping {
+		if _, exists := assigned[shardID]; !exists {
+			return errors.WithMessagef(procedure.ErrInvalidScatterAssignment, "shard is not assigned, shardID:%d", shardID)
+		}
+	}
+
+	return nil
+}
+
+func buildRelatedVersionInfo(params ProcedureParams) (procedure.RelatedVersionInfo, error) {
+	shardWithVersion := make(map[storage.ShardID]uint64, len(params.ShardNodes))
+	for _, shardNode := range params.ShardNodes {
+		shardView, exists := params.ClusterSnapshot.Topology.ShardViewsMapping[shardNode.ID]
+		if !exists {
+			return procedure.RelatedVersionInfo{}, errors.WithMessagef(metadata.ErrShardNotFound, "shard not found in topology, shardID:%d", shardNode.ID)
+		}
+		shardWithVersion[shardNode.ID] = shardView.Version
+	}
+
+	relatedVersionInfo := procedure.RelatedVersionInfo{
+		ClusterID:        params.ClusterSnapshot.Topology.ClusterView.ClusterID,
+		ShardWithVersion: shardWithVersion,
+		ClusterVersion:   params.ClusterSnapshot.Topology.ClusterView.Version,
+	}
+	return relatedVersionInfo, nil
+}
+
+type callbackRequest struct {
+	ctx context.Context
+	p   *Procedure
+}
+
+func (p *Procedure) ID() uint64 {
+	return p.params.ID
+}
+
+func (p *Procedure) Kind() procedure.Kind {
+	return procedure.Scatter
+}
+
+func (p *Procedure) RelatedVersionInfo() procedure.RelatedVersionInfo {
+	return p.relatedVersionInfo
+}
+
+func (p *Procedure) Priority() procedure.Priority {
+	return procedure.PriorityHigh
+}
+
+func (p *Procedure) Start(ctx context.Context) error {
+	p.updateStateWithLock(procedure.StateRunning)
+
+	// The procedure can't be interrupted once it starts, because the shards are opened by the first step.
+	sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "scatter procedure begin side effects")
+	}
+	ctx = sideEffectsCtx
+
+	scatterCallbackRequest := callbackRequest{
+		ctx: ctx,
+		p:   p,
+	}
+
+	for {
+		switch p.fsm.Current() {
+		case stateBegin:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "scatter procedure persist")
+			}
+			if err := p.fsm.Event(eventOpenShards, scatterCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				_ = p.persist(ctx)
+				return errors.WithMessage(err, "scatter procedure open shards")
+			}
+		case stateOpenShards:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "scatter procedure persist")
+			}
+			if err := p.fsm.Event(eventUpdateClusterView, scatterCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				_ = p.persist(ctx)
+				return errors.WithMessage(err, "scatter procedure update cluster view")
+			}
+		case stateUpdateClusterView:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "scatter procedure persist")
+			}
+			if err := p.fsm.Event(eventFinish, scatterCallbackRequest); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				_ = p.persist(ctx)
+				return errors.WithMessage(err, "scatter procedure finish")
+			}
+		case stateFinish:
+			p.updateStateWithLock(procedure.StateFinished)
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "scatter procedure persist")
+			}
+			return nil
+		}
+	}
+}
+
+func (p *Procedure) Cancel(_ context.Context) error {
+	p.updateStateWithLock(procedure.StateCancelled)
+	return nil
+}
+
+func (p *Procedure) State() procedure.State {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.state
+}
+
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
+// DedupKey makes all the scatters of the cluster equivalent, because the shards should be placed only once.
+func (p *Procedure) DedupKey() string {
+	return procedure.BuildDedupKey(procedure.Scatter)
+}
+
+// RelatedNodes returns the nodes which the shards are assigned to, sorted by name.
+func (p *Procedure) RelatedNodes() []string {
+	nodeNames := make([]string, 0, len(p.params.ShardNodes))
+	for _, shardNode := range p.params.ShardNodes {
+		nodeNames = append(nodeNames, shardNode.NodeName)
+	}
+	sort.Strings(nodeNames)
+	return slices.Compact(nodeNames)
+}
+
+// Progress returns the open progress of every shard, sorted by shard id.
+func (p *Procedure) Progress() []ShardProgress {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	progress := make([]ShardProgress, 0, len(p.params.ShardNodes))
+	for _, shardNode := range p.params.ShardNodes {
+		progress = append(progress, ShardProgress{
+			ShardID:  shardNode.ID,
+			NodeName: shardNode.NodeName,
+			Opened:   p.openedShards[shardNode.ID],
+		})
+	}
+	sort.Slice(progress, func(i, j int) bool {
+		return progress[i].ShardID < progress[j].ShardID
+	})
+	return progress
+}
+
+func (p *Procedure) updateStateWithLock(state procedure.State) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.state = state
+}
+
+func (p *Procedure) markShardOpened(shardID storage.ShardID) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.openedShards[shardID] = true
+}
+
+// unopenedShardNodes returns the shard nodes whose shard has not been opened yet, sorted by shard id.
+func (p *Procedure) unopenedShardNodes() []storage.ShardNode {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	shardNodes := make([]storage.ShardNode, 0, len(p.params.ShardNodes))
+	for _, shardNode := range p.params.ShardNodes {
+		if !p.openedShards[shardNode.ID] {
+			shardNodes = append(shardNodes, shardNode)
+		}
+	}
+	sort.Slice(shardNodes, func(i, j int) bool {
+		return shardNodes[i].ID < shardNodes[j].ID
+	})
+	return shardNodes
+}
+
+func openShardsCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+
+	shardNodes := p.unopenedShardNodes()
+	batchSize := int(p.params.BatchSize)
+	if batchSize == 0 {
+		batchSize = len(shardNodes)
+	}
+
+	for start := 0; start < len(shardNodes); start += batchSize {
+		end := start + batchSize
+		if end > len(shardNodes) {
+			end = len(shardNodes)
+		}
+
+		g, _ := errgroup.WithContext(req.ctx)
+		for _, shardNode := range shardNodes[start:end] {
+			shardNode := shardNode
+			g.Go(func() error {
+				if err := procedure.Retry(req.ctx, procedure.DispatchRetryPolicy, func() error {
+					return p.params.Dispatch.OpenShard(req.ctx, shardNode.NodeName, eventdispatch.OpenShardRequest{
+						Shard: metadata.ShardInfo{
+							ID:      shardNode.ID,
+							Role:    storage.ShardRoleLeader,
+							Version: p.relatedVersionInfo.ShardWithVersion[shardNode.ID],
+							Status:  storage.ShardStatusUnknown,
+						},
+					})
+				}); err != nil {
+					return errors.WithMessagef(err, "open shard, shardID:%d, node:%s", shardNode.ID, shardNode.NodeName)
+				}
+				p.markShardOpened(shardNode.ID)
+				log.Info("scatter procedure open shard finish", zap.Uint64("procedureID", p.ID()), zap.Uint32("shardID", uint32(shardNode.ID)), zap.String("node", shardNode.NodeName))
+				return nil
+			})
+		}
+		err := g.Wait()
+
+		// Persist the progress of this batch, so that the opened shards will be skipped after the procedure is resumed.
+		if persistErr := p.persist(req.ctx); persistErr != nil {
+			log.Warn("scatter procedure persist progress failed", zap.Uint64("procedureID", p.ID()), zap.Error(persistErr))
+		}
+		if err != nil {
+			procedure.CancelEventWithLog(event, err, "open shards")
+			return
+		}
+	}
+}
+
+func updateClusterViewCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	p := req.p
+
+	if err := p.params.ClusterMetadata.UpdateClusterView(req.ctx, storage.ClusterStateStable, p.params.ShardNodes); err != nil {
+		procedure.CancelEventWithLog(event, err, "update cluster view")
+		return
+	}
+}
+
+func finishCallback(event *fsm.Event) {
+	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
+	if err != nil {
+		procedure.CancelEventWithLog(event, err, "get request from event")
+		return
+	}
+	log.Info("scatter procedure finish", zap.Uint64("procedureID", req.p.ID()), zap.Int("shardNum", len(req.p.params.ShardNodes)))
+}
+
+func (p *Procedure) persist(ctx context.Context) error {
+	meta, err := p.convertToMeta()
+	if err != nil {
+		return errors.WithMessage(err, "convert to meta")
+	}
+	err = p.params.Storage.CreateOrUpdate(ctx, meta)
+	if err != nil {
+		return errors.WithMessage(err, "createOrUpdate procedure storage")
+	}
+	return nil
+}
+
+type rawData struct {
+	ID       uint64
+	FsmState string
+	State    procedure.State
+
+	ShardNodes   []storage.ShardNode
+	OpenedShards []storage.ShardID
+	BatchSize    uint32
+}
+
+func (p *Procedure) convertToMeta() (procedure.Meta, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	openedShards := make([]storage.ShardID, 0, len(p.openedShards))
+	for shardID, opened := range p.openedShards {
+		if opened {
+			openedShards = append(openedShards, shardID)
+		}
+	}
+	sort.Slice(openedShards, func(i, j int) bool {
+		return openedShards[i] < openedShards[j]
+	})
+
+	rawData := rawData{
+		ID:           p.params.ID,
+		FsmState:     p.fsm.Current(),
+		State:        p.state,
+		ShardNodes:   p.params.ShardNodes,
+		OpenedShards: openedShards,
+		BatchSize:    p.params.BatchSize,
+	}
+	rawDataBytes, err := json.Marshal(rawData)
+	if err != nil {
+		var emptyMeta procedure.Meta
+		return emptyMeta, procedure.ErrEncodeRawData.WithCausef("marshal raw data, procedureID:%d, err:%v", p.params.ID, err)
+	}
+
+	meta := procedure.Meta{
+		ID:    p.params.ID,
+		Kind:  procedure.Scatter,
+		State: p.state,
+
+		RawData: rawDataBytes,
+	}
+
+	return meta, nil
+}
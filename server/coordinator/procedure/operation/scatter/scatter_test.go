@@ -0,0 +1,137 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package scatter_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/scatter"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// recordDispatch records the opened shards, and fails to open the shard in failedShards.
+type recordDispatch struct {
+	test.MockDispatch
+
+	lock         sync.Mutex
+	openedShards []storage.ShardID
+	failedShards map[storage.ShardID]struct{}
+}
+
+func (d *recordDispatch) OpenShard(_ context.Context, _ string, request eventdispatch.OpenShardRequest) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, exists := d.failedShards[request.Shard.ID]; exists {
+		return errors.New("mock open shard failed")
+	}
+	d.openedShards = append(d.openedShards, request.Shard.ID)
+	return nil
+}
+
+func TestScatter(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	c := test.InitPrepareCluster(ctx, t)
+	s := test.NewTestStorage(t)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	shardNodes := make([]storage.ShardNode, 0, len(snapshot.Topology.ShardViewsMapping))
+	for shardID := range snapshot.Topology.ShardViewsMapping {
+		shardNodes = append(shardNodes, storage.ShardNode{
+			ID:        shardID,
+			ShardRole: storage.ShardRoleLeader,
+			NodeName:  snapshot.RegisteredNodes[int(shardID)%len(snapshot.RegisteredNodes)].Node.Name,
+		})
+	}
+	failedShardID := shardNodes[0].ID
+
+	// The assignment must contain all the shards.
+	_, err := scatter.NewProcedure(scatter.ProcedureParams{
+		ID:              0,
+		Dispatch:        test.MockDispatch{},
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: snapshot,
+		ShardNodes:      shardNodes[1:],
+		OpenedShards:    nil,
+		BatchSize:       1,
+	})
+	re.Error(err)
+
+	// Open shards failed on one shard, the other shards should be opened.
+	dispatch := &recordDispatch{failedShards: map[storage.ShardID]struct{}{failedShardID: {}}}
+	p, err := scatter.NewProcedure(scatter.ProcedureParams{
+		ID:              1,
+		Dispatch:        dispatch,
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: snapshot,
+		ShardNodes:      shardNodes,
+		OpenedShards:    nil,
+		BatchSize:       1,
+	})
+	re.NoError(err)
+	re.Error(p.Start(ctx))
+	re.Equal(procedure.State(procedure.StateFailed), p.State())
+	re.Equal(storage.ClusterStatePrepare, c.GetMetadata().GetClusterState())
+
+	openedShards := make([]storage.ShardID, 0, len(shardNodes))
+	for _, progress := range p.(*scatter.Procedure).Progress() {
+		if progress.Opened {
+			openedShards = append(openedShards, progress.ShardID)
+		}
+	}
+	re.Equal(len(dispatch.openedShards), len(openedShards))
+	re.NotContains(openedShards, failedShardID)
+
+	// Resume the procedure with the progress, only the unopened shards will be opened.
+	dispatch = &recordDispatch{failedShards: map[storage.ShardID]struct{}{}}
+	p, err = scatter.NewProcedure(scatter.ProcedureParams{
+		ID:              1,
+		Dispatch:        dispatch,
+		Storage:         s,
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: snapshot,
+		ShardNodes:      shardNodes,
+		OpenedShards:    openedShards,
+		BatchSize:       2,
+	})
+	re.NoError(err)
+	re.NoError(p.Start(ctx))
+	re.Equal(procedure.State(procedure.StateFinished), p.State())
+	re.Equal(len(shardNodes)-len(openedShards), len(dispatch.openedShards))
+	re.Contains(dispatch.openedShards, failedShardID)
+	for _, progress := range p.(*scatter.Procedure).Progress() {
+		re.True(progress.Opened)
+	}
+
+	snapshot = c.GetMetadata().GetClusterSnapshot()
+	re.Equal(storage.ClusterStateStable, snapshot.Topology.ClusterView.State)
+	re.Equal(len(shardNodes), len(snapshot.Topology.ClusterView.ShardNodes))
+	re.True(snapshot.Topology.IsStable())
+}
@@ -22,6 +22,8 @@ package split
 import (
 	"context"
 	"encoding/json"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -87,6 +89,9 @@ type ProcedureParams struct {
 
 	ShardID    storage.ShardID
 	NewShardID storage.ShardID
+	// AllocNewShardID tells the procedure to allocate the new shard by its first step instead of taking NewShardID, so no shard id is
+	// consumed by the procedure which is never started.
+	AllocNewShardID bool
 
 	SchemaName     string
 	TableNames     []string
@@ -94,6 +99,42 @@ type ProcedureParams struct {
 }
 
 func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
+	p, err := newProcedure(params)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DecodeProcedure rebuilds the persisted procedure with the current cluster snapshot in the params.
+func DecodeProcedure(params ProcedureParams, meta *procedure.Meta) (procedure.Procedure, error) {
+	var data rawData
+	if err := json.Unmarshal(meta.RawData, &data); err != nil {
+		return nil, procedure.ErrDecodeRawData.WithCausef("unmarshal raw data, procedureID:%d, err:%v", meta.ID, err)
+	}
+
+	params.ID = data.ID
+	params.SchemaName = data.SchemaName
+	params.TableNames = data.TableNames
+	params.ShardID = storage.ShardID(data.ShardID)
+	params.NewShardID = storage.ShardID(data.NewShardID)
+	params.TargetNodeName = data.TargetNodeName
+	p, err := newProcedure(params)
+	if err != nil {
+		return nil, err
+	}
+
+	fsmState := data.FsmState
+	// The tables may have been moved before the state is persisted.
+	if fsmState == stateCreateNewShardView && p.tablesMoved() {
+		fsmState = stateUpdateShardTables
+	}
+	p.fsm.SetState(fsmState)
+
+	return p, nil
+}
+
+func newProcedure(params ProcedureParams) (*Procedure, error) {
 	if err := validateClusterTopology(params.ClusterSnapshot.Topology, params.ShardID); err != nil {
 		return nil, err
 	}
@@ -126,7 +167,19 @@ func buildRelatedVersionInfo(params ProcedureParams) (procedure.RelatedVersionIn
 		return procedure.RelatedVersionInfo{}, errors.WithMessagef(metadata.ErrShardNotFound, "shard not found in topology, shardID:%d", params.ShardID)
 	}
 	shardWithVersion[params.ShardID] = shardView.Version
+	// The new shard to be allocated is unknown to others, so it needn't be locked.
+	if params.AllocNewShardID {
+		return procedure.RelatedVersionInfo{
+			ClusterID:        params.ClusterSnapshot.Topology.ClusterView.ClusterID,
+			ShardWithVersion: shardWithVersion,
+			ClusterVersion:   params.ClusterSnapshot.Topology.ClusterView.Version,
+		}, nil
+	}
 	shardWithVersion[params.NewShardID] = 0
+	// The new shard has been created if the procedure is recovered after the creation.
+	if newShardView, exists := params.ClusterSnapshot.Topology.ShardViewsMapping[params.NewShardID]; exists {
+		shardWithVersion[params.NewShardID] = newShardView.Version
+	}
 
 	relatedVersionInfo := procedure.RelatedVersionInfo{
 		ClusterID:        params.ClusterSnapshot.Topology.ClusterView.ClusterID,
@@ -188,6 +241,13 @@ func (p *Procedure) Priority() procedure.Priority {
 func (p *Procedure) Start(ctx context.Context) error {
 	p.updateStateWithLock(procedure.StateRunning)
 
+	// The procedure can't be interrupted once it starts, because the view of the new shard is created by the first step.
+	sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "split procedure begin side effects")
+	}
+	ctx = sideEffectsCtx
+
 	splitCallbackRequest := callbackRequest{
 		ctx: ctx,
 		p:   p,
@@ -196,6 +256,10 @@ func (p *Procedure) Start(ctx context.Context) error {
 	for {
 		switch p.fsm.Current() {
 		case stateBegin:
+			if err := p.allocNewShardID(ctx); err != nil {
+				p.updateStateWithLock(procedure.StateFailed)
+				return errors.WithMessage(err, "split procedure alloc new shard id")
+			}
 			if err := p.persist(ctx); err != nil {
 				return errors.WithMessage(err, "split procedure persist")
 			}
@@ -249,6 +313,67 @@ func (p *Procedure) State() procedure.State {
 	return p.state
 }
 
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
+// CreatedShardIDs returns the new shard, which is locked with version 0 before it is created.
+// The recovered procedure which has created the new shard and the procedure which hasn't allocated the new shard return nothing.
+func (p *Procedure) CreatedShardIDs() []storage.ShardID {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.fsm.Current() != stateBegin || p.params.AllocNewShardID {
+		return nil
+	}
+	return []storage.ShardID{p.params.NewShardID}
+}
+
+// allocNewShardID allocates the new shard if it is not given, and the allocated one is persisted along with the procedure before the
+// shard is created.
+func (p *Procedure) allocNewShardID(ctx context.Context) error {
+	if !p.params.AllocNewShardID {
+		return nil
+	}
+
+	id, err := p.params.ClusterMetadata.AllocShardID(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.params.NewShardID = storage.ShardID(id)
+	p.params.AllocNewShardID = false
+	return nil
+}
+
+// tablesMoved returns whether all the tables have been moved to the new shard in the topology.
+func (p *Procedure) tablesMoved() bool {
+	shardTables := p.params.ClusterMetadata.GetShardTables([]storage.ShardID{p.params.NewShardID})
+	newShardTables := make(map[string]struct{}, len(shardTables[p.params.NewShardID].Tables))
+	for _, table := range shardTables[p.params.NewShardID].Tables {
+		if table.SchemaName == p.params.SchemaName {
+			newShardTables[table.Name] = struct{}{}
+		}
+	}
+
+	for _, tableName := range p.params.TableNames {
+		if _, exists := newShardTables[tableName]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// DedupKey makes the splits of the same tables from the same shard to the same node equivalent, and the allocated new shard is ignored.
+func (p *Procedure) DedupKey() string {
+	tableNames := append([]string{}, p.params.TableNames...)
+	sort.Strings(tableNames)
+	return procedure.BuildDedupKey(procedure.Split, p.params.ShardID, p.params.TargetNodeName, p.params.SchemaName, strings.Join(tableNames, ","))
+}
+
 func (p *Procedure) updateStateWithLock(state procedure.State) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -298,14 +423,16 @@ func openShardCallback(event *fsm.Event) {
 	}
 	ctx := request.ctx
 
-	// Send open new shard request to CSE.
-	if err := request.p.params.Dispatch.OpenShard(ctx, request.p.params.TargetNodeName, eventdispatch.OpenShardRequest{
-		Shard: metadata.ShardInfo{
-			ID:      request.p.params.NewShardID,
-			Role:    storage.ShardRoleLeader,
-			Version: 0,
-			Status:  storage.ShardStatusUnknown,
-		},
+	// Send open new shard request to CSE, and it is retried because opening shard is idempotent.
+	if err := procedure.Retry(ctx, procedure.DispatchRetryPolicy, func() error {
+		return request.p.params.Dispatch.OpenShard(ctx, request.p.params.TargetNodeName, eventdispatch.OpenShardRequest{
+			Shard: metadata.ShardInfo{
+				ID:      request.p.params.NewShardID,
+				Role:    storage.ShardRoleLeader,
+				Version: 0,
+				Status:  storage.ShardStatusUnknown,
+			},
+		})
 	}); err != nil {
 		procedure.CancelEventWithLog(event, err, "open shard failed")
 		return
@@ -334,6 +461,10 @@ func (p *Procedure) persist(ctx context.Context) error {
 }
 
 type rawData struct {
+	ID       uint64
+	FsmState string
+	State    procedure.State
+
 	SchemaName     string
 	TableNames     []string
 	ShardID        uint32
@@ -346,6 +477,9 @@ func (p *Procedure) convertToMeta() (procedure.Meta, error) {
 	defer p.lock.RUnlock()
 
 	rawData := rawData{
+		ID:             p.params.ID,
+		FsmState:       p.fsm.Current(),
+		State:          p.state,
 		SchemaName:     p.params.SchemaName,
 		TableNames:     p.params.TableNames,
 		ShardID:        uint32(p.params.ShardID),
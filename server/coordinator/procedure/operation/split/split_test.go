@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/split"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -72,6 +73,7 @@ func TestSplit(t *testing.T) {
 		ClusterSnapshot: c.GetMetadata().GetClusterSnapshot(),
 		ShardID:         createTableNodeShard.ID,
 		NewShardID:      storage.ShardID(newShardID),
+		AllocNewShardID: false,
 		SchemaName:      test.TestSchemaName,
 		TableNames:      []string{test.TestTableName0},
 		TargetNodeName:  createTableNodeShard.NodeName,
@@ -98,3 +100,50 @@ func TestSplit(t *testing.T) {
 	re.NotNil(splitShardTables)
 	re.NotNil(newShardTables)
 }
+
+func TestSplitAllocNewShardID(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	c := test.InitStableCluster(ctx, t)
+
+	shardNode := c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes[0]
+	for _, tableName := range []string{test.TestTableName0, test.TestTableName1} {
+		_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+			ShardID:       shardNode.ID,
+			LatestVersion: c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping[shardNode.ID].Version,
+			SchemaName:    test.TestSchemaName,
+			TableName:     tableName,
+			PartitionInfo: storage.PartitionInfo{Info: nil},
+		})
+		re.NoError(err)
+	}
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	p, err := split.NewProcedure(split.ProcedureParams{
+		ID:              0,
+		Dispatch:        test.MockDispatch{},
+		Storage:         test.NewTestStorage(t),
+		ClusterMetadata: c.GetMetadata(),
+		ClusterSnapshot: snapshot,
+		ShardID:         shardNode.ID,
+		NewShardID:      0,
+		AllocNewShardID: true,
+		SchemaName:      test.TestSchemaName,
+		TableNames:      []string{test.TestTableName0},
+		TargetNodeName:  shardNode.NodeName,
+	})
+	re.NoError(err)
+	// The new shard is unknown before the procedure starts.
+	re.Len(p.RelatedVersionInfo().ShardWithVersion, 1)
+	re.Contains(p.RelatedVersionInfo().ShardWithVersion, shardNode.ID)
+	re.Empty(p.(procedure.CreatedShardsGetter).CreatedShardIDs())
+
+	re.NoError(p.Start(ctx))
+	newSnapshot := c.GetMetadata().GetClusterSnapshot()
+	re.Equal(len(snapshot.Topology.ShardViewsMapping)+1, len(newSnapshot.Topology.ShardViewsMapping))
+	for shardID, shardView := range newSnapshot.Topology.ShardViewsMapping {
+		if _, exists := snapshot.Topology.ShardViewsMapping[shardID]; !exists {
+			re.Len(shardView.TableIDs, 1)
+		}
+	}
+}
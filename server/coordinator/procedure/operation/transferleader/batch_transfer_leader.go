@@ -22,6 +22,9 @@ package transferleader
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -34,8 +37,10 @@ import (
 
 // BatchTransferLeaderProcedure is a proxy procedure contains a batch of TransferLeaderProcedure.
 // It is used to support concurrent execution of a batch of TransferLeaderProcedure with same version.
+// The batch may contain the procedures of other kinds, e.g. Migrate, and kind is reported as the kind of the whole batch.
 type BatchTransferLeaderProcedure struct {
 	id                 uint64
+	kind               procedure.Kind
 	batch              []procedure.Procedure
 	relatedVersionInfo procedure.RelatedVersionInfo
 
@@ -44,7 +49,7 @@ type BatchTransferLeaderProcedure struct {
 	state procedure.State
 }
 
-func NewBatchTransferLeaderProcedure(id uint64, batch []procedure.Procedure) (procedure.Procedure, error) {
+func NewBatchTransferLeaderProcedure(id uint64, kind procedure.Kind, batch []procedure.Procedure) (procedure.Procedure, error) {
 	if len(batch) == 0 {
 		return nil, procedure.ErrEmptyBatchProcedure
 	}
@@ -56,6 +61,7 @@ func NewBatchTransferLeaderProcedure(id uint64, batch []procedure.Procedure) (pr
 
 	return &BatchTransferLeaderProcedure{
 		id:                 id,
+		kind:               kind,
 		batch:              batch,
 		relatedVersionInfo: relateVersionInfo,
 		lock:               sync.RWMutex{},
@@ -103,10 +109,21 @@ func (p *BatchTransferLeaderProcedure) ID() uint64 {
 }
 
 func (p *BatchTransferLeaderProcedure) Kind() procedure.Kind {
-	return procedure.TransferLeader
+	return p.kind
+}
+
+func (p *BatchTransferLeaderProcedure) Batch() []procedure.Procedure {
+	return p.batch
 }
 
 func (p *BatchTransferLeaderProcedure) Start(ctx context.Context) error {
+	// The procedures in the batch are started concurrently, so the batch can't be interrupted once it starts.
+	sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "batch procedure begin side effects")
+	}
+	ctx = sideEffectsCtx
+
 	// Start procedures with multiple goroutine.
 	g, _ := errgroup.WithContext(ctx)
 	for _, p := range p.batch {
@@ -146,6 +163,43 @@ func (p *BatchTransferLeaderProcedure) Priority() procedure.Priority {
 	return p.batch[0].Priority()
 }
 
+// DedupKey makes the batches containing the same transfers equivalent, and empty key is returned if any transfer can't be deduplicated.
+func (p *BatchTransferLeaderProcedure) DedupKey() string {
+	keys := make([]string, 0, len(p.batch))
+	for _, subProcedure := range p.batch {
+		getter, ok := subProcedure.(procedure.DedupKeyGetter)
+		if !ok || len(getter.DedupKey()) == 0 {
+			return ""
+		}
+		keys = append(keys, getter.DedupKey())
+	}
+	sort.Strings(keys)
+	return procedure.BuildDedupKey(p.kind, "batch", strings.Join(keys, ";"))
+}
+
+// CreatedShardIDs returns the shards created by any procedure in the batch.
+func (p *BatchTransferLeaderProcedure) CreatedShardIDs() []storage.ShardID {
+	shardIDs := make([]storage.ShardID, 0)
+	for _, subProcedure := range p.batch {
+		if getter, ok := subProcedure.(procedure.CreatedShardsGetter); ok {
+			shardIDs = append(shardIDs, getter.CreatedShardIDs()...)
+		}
+	}
+	return shardIDs
+}
+
+// RelatedNodes returns the nodes related to any transfer in the batch.
+func (p *BatchTransferLeaderProcedure) RelatedNodes() []string {
+	nodeNames := make([]string, 0, len(p.batch))
+	for _, subProcedure := range p.batch {
+		if getter, ok := subProcedure.(procedure.RelatedNodesGetter); ok {
+			nodeNames = append(nodeNames, getter.RelatedNodes()...)
+		}
+	}
+	sort.Strings(nodeNames)
+	return slices.Compact(nodeNames)
+}
+
 func (p *BatchTransferLeaderProcedure) updateStateWithLock(state procedure.State) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -79,8 +79,10 @@ func TestBatchProcedure(t *testing.T) {
 		p := CreateMockProcedure(storage.ClusterID(0), 0, 0, shardWithVersion)
 		procedures = append(procedures, p)
 	}
-	_, err := transferleader.NewBatchTransferLeaderProcedure(0, procedures)
+	batchProcedure, err := transferleader.NewBatchTransferLeaderProcedure(0, procedure.Migrate, procedures)
 	re.NoError(err)
+	// The batch reports the kind it is created with.
+	re.Equal(procedure.Migrate, batchProcedure.Kind())
 
 	// Procedure with different clusterID.
 	for i := 0; i < 3; i++ {
@@ -89,7 +91,7 @@ func TestBatchProcedure(t *testing.T) {
 		p := CreateMockProcedure(storage.ClusterID(i), 0, procedure.TransferLeader, shardWithVersion)
 		procedures = append(procedures, p)
 	}
-	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures)
+	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedure.TransferLeader, procedures)
 	re.Error(err)
 
 	// Procedures with different type.
@@ -99,7 +101,7 @@ func TestBatchProcedure(t *testing.T) {
 		p := CreateMockProcedure(0, 0, procedure.Kind(i), shardWithVersion)
 		procedures = append(procedures, p)
 	}
-	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures)
+	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedure.TransferLeader, procedures)
 	re.Error(err)
 
 	// Procedures with different version.
@@ -109,7 +111,7 @@ func TestBatchProcedure(t *testing.T) {
 		p := CreateMockProcedure(0, 0, procedure.Kind(i), shardWithVersion)
 		procedures = append(procedures, p)
 	}
-	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedures)
+	_, err = transferleader.NewBatchTransferLeaderProcedure(0, procedure.TransferLeader, procedures)
 	re.Error(err)
 }
 
@@ -21,6 +21,7 @@ package transferleader
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -60,8 +61,6 @@ var (
 	}
 )
 
-// Procedure will not persist.
-// TODO: After supporting the expiration cleanup mechanism of Procedure, we can consider persisting it to facilitate tracing historical information.
 type Procedure struct {
 	fsm                *fsm.FSM
 	params             ProcedureParams
@@ -93,7 +92,40 @@ type ProcedureParams struct {
 }
 
 func NewProcedure(params ProcedureParams) (procedure.Procedure, error) {
-	if err := validateClusterTopology(params.ClusterSnapshot.Topology, params.ShardID, params.OldLeaderNodeName); err != nil {
+	p, err := newProcedure(params, params.OldLeaderNodeName)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DecodeProcedure rebuilds the persisted procedure with the current cluster snapshot in the params.
+func DecodeProcedure(params ProcedureParams, meta *procedure.Meta) (procedure.Procedure, error) {
+	var data rawData
+	if err := json.Unmarshal(meta.RawData, &data); err != nil {
+		return nil, procedure.ErrDecodeRawData.WithCausef("unmarshal raw data, procedureID:%d, err:%v", meta.ID, err)
+	}
+
+	params.ID = data.ID
+	params.ShardID = storage.ShardID(data.ShardID)
+	params.OldLeaderNodeName = data.OldLeaderNodeName
+	params.NewLeaderNodeName = data.NewLeaderNodeName
+	// The old leader may have been closed and removed from the topology, so it is validated only if nothing is done.
+	validatedOldLeaderNodeName := ""
+	if data.FsmState == stateBegin {
+		validatedOldLeaderNodeName = data.OldLeaderNodeName
+	}
+	p, err := newProcedure(params, validatedOldLeaderNodeName)
+	if err != nil {
+		return nil, err
+	}
+	p.fsm.SetState(data.FsmState)
+
+	return p, nil
+}
+
+func newProcedure(params ProcedureParams, validatedOldLeaderNodeName string) (*Procedure, error) {
+	if err := validateClusterTopology(params.ClusterSnapshot.Topology, params.ShardID, validatedOldLeaderNodeName); err != nil {
 		return nil, err
 	}
 
@@ -142,7 +174,7 @@ func validateClusterTopology(topology metadata.Topology, shardID storage.ShardID
 	if len(oldLeaderNodeName) == 0 {
 		return nil
 	}
-	shardNodes := topology.ClusterView.ShardNodes
+	shardNodes := topology.LeaderShardNodes()
 	if len(shardNodes) == 0 {
 		log.Error("shard not exist in any node", zap.Uint32("shardID", uint32(shardID)))
 		return metadata.ErrShardNotFound
@@ -178,6 +210,13 @@ func (p *Procedure) Priority() procedure.Priority {
 func (p *Procedure) Start(ctx context.Context) error {
 	p.updateStateWithLock(procedure.StateRunning)
 
+	// The procedure can't be interrupted once it starts, because the old leader is closed by the first step.
+	sideEffectsCtx, err := procedure.BeginSideEffects(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "transferLeader procedure begin side effects")
+	}
+	ctx = sideEffectsCtx
+
 	transferLeaderRequest := callbackRequest{
 		ctx: ctx,
 		p:   p,
@@ -186,16 +225,25 @@ func (p *Procedure) Start(ctx context.Context) error {
 	for {
 		switch p.fsm.Current() {
 		case stateBegin:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "transferLeader procedure persist")
+			}
 			if err := p.fsm.Event(eventCloseOldLeader, transferLeaderRequest); err != nil {
 				p.updateStateWithLock(procedure.StateFailed)
 				return errors.WithMessage(err, "transferLeader procedure close old leader")
 			}
 		case stateCloseOldLeader:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "transferLeader procedure persist")
+			}
 			if err := p.fsm.Event(eventOpenNewLeader, transferLeaderRequest); err != nil {
 				p.updateStateWithLock(procedure.StateFailed)
 				return errors.WithMessage(err, "transferLeader procedure open new leader")
 			}
 		case stateOpenNewLeader:
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "transferLeader procedure persist")
+			}
 			if err := p.fsm.Event(eventFinish, transferLeaderRequest); err != nil {
 				p.updateStateWithLock(procedure.StateFailed)
 				return errors.WithMessage(err, "transferLeader procedure finish")
@@ -203,6 +251,9 @@ func (p *Procedure) Start(ctx context.Context) error {
 		case stateFinish:
 			// TODO: The state update sequence here is inconsistent with the previous one. Consider reconstructing the state update logic of the state machine.
 			p.updateStateWithLock(procedure.StateFinished)
+			if err := p.persist(ctx); err != nil {
+				return errors.WithMessage(err, "transferLeader procedure persist")
+			}
 			return nil
 		}
 	}
@@ -219,6 +270,23 @@ func (p *Procedure) State() procedure.State {
 	return p.state
 }
 
+func (p *Procedure) FsmState() string {
+	return p.fsm.Current()
+}
+
+// DedupKey makes the transfers of the same shard to the same node equivalent.
+func (p *Procedure) DedupKey() string {
+	return procedure.BuildDedupKey(procedure.TransferLeader, p.params.ShardID, p.params.NewLeaderNodeName)
+}
+
+// RelatedNodes returns the old leader node, which is absent if the shard is not assigned, and the new leader node.
+func (p *Procedure) RelatedNodes() []string {
+	if len(p.params.OldLeaderNodeName) == 0 {
+		return []string{p.params.NewLeaderNodeName}
+	}
+	return []string{p.params.OldLeaderNodeName, p.params.NewLeaderNodeName}
+}
+
 func closeOldLeaderCallback(event *fsm.Event) {
 	req, err := procedure.GetRequestFromEvent[callbackRequest](event)
 	if err != nil {
@@ -236,7 +304,10 @@ func closeOldLeaderCallback(event *fsm.Event) {
 	closeShardRequest := eventdispatch.CloseShardRequest{
 		ShardID: uint32(req.p.params.ShardID),
 	}
-	if err := req.p.params.Dispatch.CloseShard(ctx, req.p.params.OldLeaderNodeName, closeShardRequest); err != nil {
+	// Closing shard is idempotent, so it can be retried.
+	if err := procedure.Retry(ctx, procedure.DispatchRetryPolicy, func() error {
+		return req.p.params.Dispatch.CloseShard(ctx, req.p.params.OldLeaderNodeName, closeShardRequest)
+	}); err != nil {
 		procedure.CancelEventWithLog(event, err, "close shard", zap.Uint32("shardID", uint32(req.p.params.ShardID)), zap.String("oldLeaderName", req.p.params.OldLeaderNodeName))
 		return
 	}
@@ -269,7 +340,10 @@ func openNewShardCallback(event *fsm.Event) {
 
 	log.Info("try to open shard", zap.Uint64("procedureID", req.p.ID()), zap.Uint64("shardID", uint64(req.p.params.ShardID)), zap.String("newLeader", req.p.params.NewLeaderNodeName))
 
-	if err := req.p.params.Dispatch.OpenShard(ctx, req.p.params.NewLeaderNodeName, openShardRequest); err != nil {
+	// Opening shard is idempotent, so it can be retried.
+	if err := procedure.Retry(ctx, procedure.DispatchRetryPolicy, func() error {
+		return req.p.params.Dispatch.OpenShard(ctx, req.p.params.NewLeaderNodeName, openShardRequest)
+	}); err != nil {
 		procedure.CancelEventWithLog(event, err, "open shard", zap.Uint32("shardID", uint32(req.p.params.ShardID)), zap.String("newLeaderNode", req.p.params.NewLeaderNodeName))
 		return
 	}
@@ -293,3 +367,54 @@ func (p *Procedure) updateStateWithLock(state procedure.State) {
 
 	p.state = state
 }
+
+func (p *Procedure) persist(ctx context.Context) error {
+	meta, err := p.convertToMeta()
+	if err != nil {
+		return errors.WithMessage(err, "convert to meta")
+	}
+	err = p.params.Storage.CreateOrUpdate(ctx, meta)
+	if err != nil {
+		return errors.WithMessage(err, "createOrUpdate procedure storage")
+	}
+	return nil
+}
+
+type rawData struct {
+	ID       uint64
+	FsmState string
+	State    procedure.State
+
+	ShardID           uint32
+	OldLeaderNodeName string
+	NewLeaderNodeName string
+}
+
+func (p *Procedure) convertToMeta() (procedure.Meta, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	rawData := rawData{
+		ID:                p.params.ID,
+		FsmState:          p.fsm.Current(),
+		State:             p.state,
+		ShardID:           uint32(p.params.ShardID),
+		OldLeaderNodeName: p.params.OldLeaderNodeName,
+		NewLeaderNodeName: p.params.NewLeaderNodeName,
+	}
+	rawDataBytes, err := json.Marshal(rawData)
+	if err != nil {
+		var emptyMeta procedure.Meta
+		return emptyMeta, procedure.ErrEncodeRawData.WithCausef("marshal raw data, procedureID:%d, err:%v", p.params.ID, err)
+	}
+
+	meta := procedure.Meta{
+		ID:    p.params.ID,
+		Kind:  procedure.TransferLeader,
+		State: p.state,
+
+		RawData: rawDataBytes,
+	}
+
+	return meta, nil
+}
@@ -22,13 +22,40 @@ package transferleader_test
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/operation/transferleader"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
+// slowCloseDispatch is the MockDispatch which takes the delay to close the shard, unless its ctx is done.
+type slowCloseDispatch struct {
+	test.MockDispatch
+	delay time.Duration
+}
+
+func (d slowCloseDispatch) CloseShard(ctx context.Context, _ string, _ eventdispatch.CloseShardRequest) error {
+	select {
+	case <-time.After(d.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type transferLeaderDecoder struct {
+	params transferleader.ProcedureParams
+}
+
+func (d transferLeaderDecoder) Decode(_ context.Context, meta *procedure.Meta) (procedure.Procedure, error) {
+	return transferleader.DecodeProcedure(d.params, meta)
+}
+
 func TestTransferLeader(t *testing.T) {
 	re := require.New(t)
 	ctx := context.Background()
@@ -59,3 +86,70 @@ func TestTransferLeader(t *testing.T) {
 	err = p.Start(ctx)
 	re.NoError(err)
 }
+
+// submitSlowTransferLeader submits the procedure transferring the leader of a shard, which takes a while to close the old leader.
+func submitSlowTransferLeader(t *testing.T, options procedure.ManagerOptions) procedure.Manager {
+	re := require.New(t)
+	ctx := context.Background()
+	c := test.InitStableCluster(ctx, t)
+	s := test.NewTestStorage(t)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	oldLeader := snapshot.Topology.ClusterView.ShardNodes[0]
+	newLeaderNodeName := snapshot.RegisteredNodes[0].Node.Name
+	if newLeaderNodeName == oldLeader.NodeName {
+		newLeaderNodeName = snapshot.RegisteredNodes[1].Node.Name
+	}
+	params := transferleader.ProcedureParams{
+		ID:                1,
+		Dispatch:          slowCloseDispatch{MockDispatch: test.MockDispatch{}, delay: time.Millisecond * 100},
+		Storage:           s,
+		ClusterSnapshot:   snapshot,
+		ShardID:           oldLeader.ID,
+		OldLeaderNodeName: oldLeader.NodeName,
+		NewLeaderNodeName: newLeaderNodeName,
+	}
+	manager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), s, transferLeaderDecoder{params: params}, options)
+	re.NoError(err)
+	re.NoError(manager.Start(ctx))
+
+	p, err := transferleader.NewProcedure(params)
+	re.NoError(err)
+	_, err = manager.Submit(ctx, p)
+	re.NoError(err)
+	re.Eventually(func() bool {
+		detail, err := manager.GetProcedure(ctx, 1)
+		return err == nil && detail.State == procedure.StateRunning
+	}, time.Second, time.Millisecond*5)
+	return manager
+}
+
+func TestTransferLeaderCancelAfterStart(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	manager := submitSlowTransferLeader(t, procedure.ManagerOptions{})
+
+	// The old leader is being closed, so the procedure can't be cancelled and will open the new leader in the end.
+	re.ErrorIs(manager.CancelProcedure(ctx, 1), procedure.ErrProcedureUninterruptible)
+	re.Eventually(func() bool {
+		detail, err := manager.GetProcedure(ctx, 1)
+		return err == nil && detail.State == procedure.StateFinished
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestTransferLeaderTimeoutAfterStart(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	manager := submitSlowTransferLeader(t, procedure.ManagerOptions{
+		Timeout: procedure.TimeoutOptions{
+			Default:      0,
+			KindTimeouts: map[procedure.Kind]time.Duration{procedure.TransferLeader: time.Millisecond * 20},
+		},
+	})
+
+	// The deadline is exceeded while the old leader is being closed, so the procedure keeps running until the new leader is opened.
+	re.Eventually(func() bool {
+		detail, err := manager.GetProcedure(ctx, 1)
+		return err == nil && detail.State == procedure.StateFinished && detail.LastError == ""
+	}, time.Second, time.Millisecond*10)
+}
@@ -21,6 +21,9 @@ package procedure
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 )
@@ -52,6 +55,11 @@ const (
 	DropTable
 	CreatePartitionTable
 	DropPartitionTable
+
+	// Replica Operation
+	OpenFollower
+	CloseFollower
+	PromoteFollower
 )
 
 type Priority uint32
@@ -63,6 +71,19 @@ const (
 	PriorityLow  Priority = 10
 )
 
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityMed:
+		return "med"
+	case PriorityLow:
+		return "low"
+	default:
+		return strconv.FormatUint(uint64(p), 10)
+	}
+}
+
 // Procedure is used to describe how to execute a set of operations from the scheduler, e.g. SwitchLeaderProcedure, MergeShardProcedure.
 type Procedure interface {
 	// ID of the procedure.
@@ -94,6 +115,99 @@ type Info struct {
 	State State
 }
 
+// HistoryInfo is used to describe the procedure which has been moved into the history.
+type HistoryInfo struct {
+	ID          uint64
+	Kind        Kind
+	State       State
+	ShardIDs    []storage.ShardID
+	CompletedAt time.Time
+	// LastError is the cause of the failure, and it is empty if the procedure doesn't fail.
+	LastError string
+}
+
+// HistoryFilter is used to filter and page the procedures in the history.
+type HistoryFilter struct {
+	// Kinds is empty means procedures of all kinds are accepted.
+	Kinds []Kind
+	// The range of the completion time, zero means unbounded.
+	StartTime time.Time
+	EndTime   time.Time
+	// ShardID is nil means procedures related to any shard are accepted.
+	ShardID *storage.ShardID
+	// The matched procedures are sorted by completion time in descending order, and Limit is zero means no limit.
+	Offset int
+	Limit  int
+}
+
+// FsmStateGetter is implemented by the procedures driven by the fsm.
+type FsmStateGetter interface {
+	// FsmState returns the current state of the fsm.
+	FsmState() string
+}
+
+// DedupKeyGetter is implemented by the procedures which can be deduplicated, and the procedures with the same key do the same work.
+type DedupKeyGetter interface {
+	// DedupKey returns the semantic key of the procedure, such as kind plus shard plus target node, and empty key means no deduplication.
+	DedupKey() string
+}
+
+// RelatedNodesGetter is implemented by the procedures which move the shards between the nodes.
+type RelatedNodesGetter interface {
+	// RelatedNodes returns the names of the nodes which the shards are moved from or to.
+	RelatedNodes() []string
+}
+
+// BatchGetter is implemented by the procedures running a batch of procedures, e.g. the batch transfer leader procedure.
+type BatchGetter interface {
+	// Batch returns the procedures in the batch, which are persisted on their own instead of the batch.
+	Batch() []Procedure
+}
+
+// CreatedShardsGetter is implemented by the procedures creating new shards, e.g. the split procedure.
+type CreatedShardsGetter interface {
+	// CreatedShardIDs returns the shards in the related version info which are created by the procedure. They must not exist in the
+	// topology before the procedure runs, and they are still locked, so the procedures creating the same shard won't run concurrently.
+	CreatedShardIDs() []storage.ShardID
+}
+
+// BuildDedupKey builds the dedup key from the kind and the parts describing the work of the procedure.
+func BuildDedupKey(kind Kind, parts ...any) string {
+	key := strconv.FormatUint(uint64(kind), 10)
+	for _, part := range parts {
+		key += "/" + fmt.Sprint(part)
+	}
+	return key
+}
+
+func dedupKey(p Procedure) string {
+	if getter, ok := p.(DedupKeyGetter); ok {
+		return getter.DedupKey()
+	}
+	return ""
+}
+
+func createdShardIDs(p Procedure) []storage.ShardID {
+	if getter, ok := p.(CreatedShardsGetter); ok {
+		return getter.CreatedShardIDs()
+	}
+	return nil
+}
+
+// Detail is used to provide the detailed description of a procedure.
+type Detail struct {
+	ID    uint64
+	Kind  Kind
+	State State
+	// FsmState is empty if the procedure is not driven by the fsm.
+	FsmState string
+	ShardIDs []storage.ShardID
+	// StartTime is zero if the procedure has not been started.
+	StartTime time.Time
+	// LastError is empty if no error occurs.
+	LastError string
+}
+
 type RelatedVersionInfo struct {
 	ClusterID storage.ClusterID
 	// shardWithVersion return the shardID associated with this procedure.
@@ -21,6 +21,8 @@ package procedure
 
 import (
 	"context"
+
+	"github.com/apache/incubator-horaedb-meta/server/storage"
 )
 
 type Write interface {
@@ -33,11 +35,29 @@ type Meta struct {
 	Kind    Kind
 	State   State
 	RawData []byte
+
+	// The following fields are set when the procedure is moved into the history.
+	ShardIDs []storage.ShardID
+	// CompletedAt is the unix time in milliseconds.
+	CompletedAt int64
+	LastError   string
+}
+
+// ArchiveInfo is recorded when the procedure is moved into the history.
+type ArchiveInfo struct {
+	// State overwrites the persisted state if it is not empty.
+	State    State
+	ShardIDs []storage.ShardID
+	// LastError is the cause of the failure, and it is empty if the procedure doesn't fail.
+	LastError string
 }
 
 type Storage interface {
 	Write
 	List(ctx context.Context, procedureType Kind, batchSize int) ([]*Meta, error)
+	// ListDeleted lists the procedures which have been moved into the history.
+	ListDeleted(ctx context.Context, procedureType Kind, batchSize int) ([]*Meta, error)
 	Delete(ctx context.Context, procedureType Kind, id uint64) error
-	MarkDeleted(ctx context.Context, procedureType Kind, id uint64) error
+	// MarkDeleted moves the procedure into the history with the archive info.
+	MarkDeleted(ctx context.Context, procedureType Kind, id uint64, info ArchiveInfo) error
 }
@@ -26,6 +26,7 @@ import (
 	"math"
 	"path"
 	"strconv"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
@@ -121,16 +122,35 @@ func (e EtcdStorageImpl) Delete(ctx context.Context, procedureType Kind, id uint
 
 // MarkDeleted Do a soft deletion, and the deleted key's format is:
 // /{rootPath}/v1/historyProcedure/{clusterID}/{procedureID}
-func (e EtcdStorageImpl) MarkDeleted(ctx context.Context, procedureType Kind, id uint64) error {
+// Nothing will be done if the procedure is not persisted.
+func (e EtcdStorageImpl) MarkDeleted(ctx context.Context, procedureType Kind, id uint64, info ArchiveInfo) error {
 	keyPath := e.generaNormalKeyPath(procedureType, id)
-	meta, err := etcdutil.Get(ctx, e.client, keyPath)
+	value, err := etcdutil.Get(ctx, e.client, keyPath)
+	if err == etcdutil.ErrEtcdKVGetNotFound {
+		return nil
+	}
 	if err != nil {
 		return errors.WithMessage(err, "get meta failed")
 	}
 
+	meta, err := decodeMeta(value)
+	if err != nil {
+		return errors.WithMessagef(err, "decode meta failed, key:%s", keyPath)
+	}
+	if len(info.State) != 0 {
+		meta.State = info.State
+	}
+	meta.ShardIDs = info.ShardIDs
+	meta.LastError = info.LastError
+	meta.CompletedAt = time.Now().UnixMilli()
+	deletedMeta, err := encode(meta)
+	if err != nil {
+		return errors.WithMessage(err, "encode meta failed")
+	}
+
 	deletedKeyPath := e.generaDeletedKeyPath(procedureType, id)
 	opDelete := clientv3.OpDelete(keyPath)
-	opPut := clientv3.OpPut(deletedKeyPath, meta)
+	opPut := clientv3.OpPut(deletedKeyPath, deletedMeta)
 
 	_, err = e.client.Txn(ctx).Then(opDelete, opPut).Commit()
 
@@ -159,6 +179,28 @@ func (e EtcdStorageImpl) List(ctx context.Context, procedureType Kind, batchSize
 	return metas, nil
 }
 
+func (e EtcdStorageImpl) ListDeleted(ctx context.Context, procedureType Kind, batchSize int) ([]*Meta, error) {
+	var metas []*Meta
+	do := func(key string, value []byte) error {
+		meta, err := decodeMeta(string(value))
+		if err != nil {
+			return errors.WithMessagef(err, "decode meta failed, key:%s, value:%v", key, value)
+		}
+
+		metas = append(metas, meta)
+		return nil
+	}
+
+	startKey := e.generaDeletedKeyPath(procedureType, uint64(0))
+	endKey := e.generaDeletedKeyPath(procedureType, math.MaxUint64)
+
+	err := etcdutil.Scan(ctx, e.client, startKey, endKey, batchSize, do)
+	if err != nil {
+		return nil, errors.WithMessage(err, "scan deleted procedure failed")
+	}
+	return metas, nil
+}
+
 func (e EtcdStorageImpl) generaNormalKeyPath(procedureType Kind, procedureID uint64) string {
 	return e.generateKeyPath(procedureID, procedureType, false)
 }
@@ -89,13 +89,28 @@ func testDelete(t *testing.T, storage Storage) {
 		State:   StateInit,
 		RawData: []byte("test"),
 	}
-	err := storage.MarkDeleted(ctx, TransferLeader, testMeta1.ID)
+	err := storage.MarkDeleted(ctx, TransferLeader, testMeta1.ID, ArchiveInfo{})
 	re.NoError(err)
 
 	metas, err := storage.List(ctx, TransferLeader, DefaultScanBatchSie)
 	re.NoError(err)
 	re.Equal(1, len(metas))
 
+	// The marked procedure is moved into the history.
+	deletedMetas, err := storage.ListDeleted(ctx, TransferLeader, DefaultScanBatchSie)
+	re.NoError(err)
+	re.Equal(1, len(deletedMetas))
+	re.Equal(testMeta1.ID, deletedMetas[0].ID)
+	re.Greater(deletedMetas[0].CompletedAt, int64(0))
+	re.Equal(State(StateInit), deletedMetas[0].State)
+
+	// Mark the procedure which is not persisted makes no difference.
+	err = storage.MarkDeleted(ctx, TransferLeader, testMeta1.ID, ArchiveInfo{})
+	re.NoError(err)
+	deletedMetas, err = storage.ListDeleted(ctx, TransferLeader, DefaultScanBatchSie)
+	re.NoError(err)
+	re.Equal(1, len(deletedMetas))
+
 	testMeta2 := Meta{
 		ID:      uint64(2),
 		Kind:    TransferLeader,
@@ -108,6 +123,13 @@ func testDelete(t *testing.T, storage Storage) {
 	metas, err = storage.List(ctx, TransferLeader, DefaultScanBatchSie)
 	re.NoError(err)
 	re.Equal(0, len(metas))
+
+	// Delete removes the procedure in the history too.
+	err = storage.Delete(ctx, TransferLeader, testMeta1.ID)
+	re.NoError(err)
+	deletedMetas, err = storage.ListDeleted(ctx, TransferLeader, DefaultScanBatchSie)
+	re.NoError(err)
+	re.Equal(0, len(deletedMetas))
 }
 
 func NewTestStorage(t *testing.T) Storage {
@@ -33,6 +33,7 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/eventdispatch"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -94,11 +95,15 @@ func (m MockStorage) List(_ context.Context, _ procedure.Kind, _ int) ([]*proced
 	return nil, nil
 }
 
+func (m MockStorage) ListDeleted(_ context.Context, _ procedure.Kind, _ int) ([]*procedure.Meta, error) {
+	return nil, nil
+}
+
 func (m MockStorage) Delete(_ context.Context, _ procedure.Kind, _ uint64) error {
 	return nil
 }
 
-func (m MockStorage) MarkDeleted(_ context.Context, _ procedure.Kind, _ uint64) error {
+func (m MockStorage) MarkDeleted(_ context.Context, _ procedure.Kind, _ uint64, _ procedure.ArchiveInfo) error {
 	return nil
 }
 
@@ -144,7 +149,7 @@ func InitEmptyCluster(ctx context.Context, t *testing.T) *cluster.Cluster {
 	err = clusterMetadata.Load(ctx)
 	re.NoError(err)
 
-	c, err := cluster.NewCluster(logger, clusterMetadata, client, TestRootPath)
+	c, err := cluster.NewCluster(logger, clusterMetadata, client, TestRootPath, procedure.ManagerOptions{}, manager.Options{})
 	re.NoError(err)
 
 	_, _, err = c.GetMetadata().GetOrCreateSchema(ctx, TestSchemaName)
@@ -195,7 +200,7 @@ func InitEmptyClusterWithConfig(ctx context.Context, t *testing.T, shardNumber i
 	err = clusterMetadata.Load(ctx)
 	re.NoError(err)
 
-	c, err := cluster.NewCluster(logger, clusterMetadata, client, TestRootPath)
+	c, err := cluster.NewCluster(logger, clusterMetadata, client, TestRootPath, procedure.ManagerOptions{}, manager.Options{})
 	re.NoError(err)
 
 	_, _, err = c.GetMetadata().GetOrCreateSchema(ctx, TestSchemaName)
@@ -20,12 +20,56 @@
 package procedure
 
 import (
+	"context"
+	"time"
+
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/looplab/fsm"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
+// RetryPolicy controls the retries of the idempotent steps of the procedures.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of attempts including the first one.
+	MaxAttempts int
+	// The backoff before the first retry, and it doubles after each retry until MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DispatchRetryPolicy is used to retry the idempotent events dispatched to the nodes, such as opening and closing shards.
+var DispatchRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond * 200,
+	MaxBackoff:     time.Second * 2,
+}
+
+// Retry calls f until it succeeds, the attempts run out or the ctx is done, and the last error will be returned.
+func Retry(ctx context.Context, policy RetryPolicy, f func() error) error {
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts {
+			return errors.WithMessagef(err, "failed after %d attempts", attempt)
+		}
+
+		log.Warn("retry after failure", zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errors.WithMessagef(err, "retry is stopped after %d attempts, ctxErr:%v", attempt, ctx.Err())
+		}
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
 // CancelEventWithLog Cancel event when error is not nil. If error is nil, do nothing.
 func CancelEventWithLog(event *fsm.Event, err error, msg string, fields ...zap.Field) {
 	if err == nil {
@@ -0,0 +1,69 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package procedure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond * 2,
+	}
+
+	attempts := 0
+	err := Retry(ctx, policy, func() error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("mock error")
+		}
+		return nil
+	})
+	re.NoError(err)
+	re.Equal(2, attempts)
+
+	attempts = 0
+	err = Retry(ctx, policy, func() error {
+		attempts++
+		return errors.New("mock error")
+	})
+	re.Error(err)
+	re.Equal(3, attempts)
+
+	// The retry is stopped once the ctx is done.
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	attempts = 0
+	err = Retry(cancelledCtx, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour}, func() error {
+		attempts++
+		return errors.New("mock error")
+	})
+	re.Error(err)
+	re.Equal(1, attempts)
+}
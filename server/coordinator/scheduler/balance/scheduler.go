@@ -0,0 +1,194 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package balance
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// Enable adds the table balance scheduler to the default scheduler list of the clusters, it only works in dynamic topology.
+	Enable bool
+	// Tolerance is the max difference of the table counts between the shards which is accepted without moving any table.
+	Tolerance uint32
+}
+
+// schedulerImpl evens out the number of tables of the leader shards by migrating the tables from the shard with the most tables to the one
+// with the fewest, and the pairs of shards are picked repeatedly until the difference falls within the tolerance or the batch is full.
+//
+// Every shard is migrated at most once in a batch, because the migrations of the same shard conflict with each other on the shard version.
+type schedulerImpl struct {
+	logger                      *zap.Logger
+	factory                     *coordinator.Factory
+	clusterMetadata             *metadata.ClusterMetadata
+	procedureExecutingBatchSize uint32
+	options                     Options
+
+	// Protect the following fields.
+	lock           sync.Mutex
+	enableSchedule bool
+}
+
+func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, clusterMetadata *metadata.ClusterMetadata, procedureExecutingBatchSize uint32, options Options) scheduler.Scheduler {
+	return &schedulerImpl{
+		logger:                      logger,
+		factory:                     factory,
+		clusterMetadata:             clusterMetadata,
+		procedureExecutingBatchSize: procedureExecutingBatchSize,
+		options:                     options,
+		lock:                        sync.Mutex{},
+		enableSchedule:              false,
+	}
+}
+
+func (s *schedulerImpl) Name() string {
+	return "table_balance_scheduler"
+}
+
+func (s *schedulerImpl) UpdateEnableSchedule(_ context.Context, enable bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.enableSchedule = enable
+}
+
+func (s *schedulerImpl) AddShardAffinityRule(_ context.Context, _ scheduler.ShardAffinityRule) error {
+	return nil
+}
+
+func (s *schedulerImpl) RemoveShardAffinityRule(_ context.Context, _ storage.ShardID) error {
+	return nil
+}
+
+func (s *schedulerImpl) ListShardAffinityRule(_ context.Context) (scheduler.ShardAffinityRule, error) {
+	return scheduler.ShardAffinityRule{Affinities: []scheduler.ShardAffinity{}}, nil
+}
+
+type shardTableCount struct {
+	shardID   storage.ShardID
+	numTables int
+}
+
+func (s *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	var emptySchedulerRes scheduler.ScheduleResult
+	// TableBalanceShardScheduler can only be scheduled when the cluster is stable.
+	if !clusterSnapshot.Topology.IsStable() {
+		return emptySchedulerRes, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The shard topology is locked.
+	if s.enableSchedule {
+		return emptySchedulerRes, nil
+	}
+
+	// The tables are closed and opened by the leaders of the shards, so only the shards whose leaders are alive are balanced.
+	now := time.Now()
+	aliveNodes := make(map[string]struct{}, len(clusterSnapshot.RegisteredNodes))
+	for _, registeredNode := range clusterSnapshot.RegisteredNodes {
+		if registeredNode.Node.State == storage.NodeStateOnline && !registeredNode.IsExpired(now) {
+			aliveNodes[registeredNode.Node.Name] = struct{}{}
+		}
+	}
+	shardCounts := make([]shardTableCount, 0, len(clusterSnapshot.Topology.ShardViewsMapping))
+	for _, shardNode := range clusterSnapshot.Topology.LeaderShardNodes() {
+		if _, alive := aliveNodes[shardNode.NodeName]; alive {
+			shardCounts = append(shardCounts, shardTableCount{
+				shardID:   shardNode.ID,
+				numTables: len(clusterSnapshot.Topology.ShardViewsMapping[shardNode.ID].TableIDs),
+			})
+		}
+	}
+	sort.Slice(shardCounts, func(i, j int) bool {
+		if shardCounts[i].numTables != shardCounts[j].numTables {
+			return shardCounts[i].numTables > shardCounts[j].numTables
+		}
+		return shardCounts[i].shardID < shardCounts[j].shardID
+	})
+
+	var procedures []procedure.Procedure
+	var reasons strings.Builder
+	// The shards are sorted by the table count in descending order, so the pairs are taken from both ends.
+	for i, j := 0, len(shardCounts)-1; i < j; i, j = i+1, j-1 {
+		if len(procedures) >= int(s.procedureExecutingBatchSize) {
+			s.logger.Warn("procedure length reached procedure executing batch size", zap.Uint32("procedureExecutingBatchSize", s.procedureExecutingBatchSize))
+			break
+		}
+		source, target := shardCounts[i], shardCounts[j]
+		diff := source.numTables - target.numTables
+		if diff <= int(s.options.Tolerance) {
+			break
+		}
+
+		schemaName, tableNames := s.pickTables(source.shardID, diff/2)
+		if len(tableNames) == 0 {
+			continue
+		}
+		p, err := s.factory.CreateMigrateProcedure(ctx, coordinator.MigrateRequest{
+			ClusterMetadata: s.clusterMetadata,
+			Snapshot:        clusterSnapshot,
+			SchemaName:      schemaName,
+			TableNames:      tableNames,
+			SourceShardID:   source.shardID,
+			TargetShardID:   target.shardID,
+		})
+		if err != nil {
+			return emptySchedulerRes, err
+		}
+
+		s.logger.Info("table balance shard scheduler try to migrate tables", zap.Uint32("sourceShardID", uint32(source.shardID)), zap.Int("numSourceTables", source.numTables), zap.Uint32("targetShardID", uint32(target.shardID)), zap.Int("numTargetTables", target.numTables), zap.Int("numMigratedTables", len(tableNames)))
+		procedures = append(procedures, p)
+		reasons.WriteString(fmt.Sprintf("tables are unbalanced, sourceShardID:%d, numSourceTables:%d, targetShardID:%d, numTargetTables:%d, numMigratedTables:%d. ", source.shardID, source.numTables, target.shardID, target.numTables, len(tableNames)))
+	}
+
+	if len(procedures) == 0 {
+		return emptySchedulerRes, nil
+	}
+
+	batchProcedure, err := s.factory.CreateBatchTransferLeaderProcedure(ctx, coordinator.BatchRequest{
+		Batch:     procedures,
+		BatchType: procedure.Migrate,
+	})
+	if err != nil {
+		return emptySchedulerRes, err
+	}
+
+	return scheduler.ScheduleResult{Procedure: batchProcedure, Reason: reasons.String()}, nil
+}
+
+// pickTables picks at most maxTables tables of the largest schema in the shard to migrate.
+func (s *schedulerImpl) pickTables(shardID storage.ShardID, maxTables int) (string, []string) {
+	schemaName, tableNames := scheduler.LargestSchemaTables(s.clusterMetadata.GetShardTables([]storage.ShardID{shardID})[shardID].Tables)
+	return schemaName, tableNames[:min(maxTables, len(tableNames))]
+}
@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package balance_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/balance"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTableBalanceShardScheduler(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata())
+	s := balance.NewShardScheduler(zap.NewNop(), procedureFactory, c.GetMetadata(), 10, balance.Options{Enable: true, Tolerance: 2})
+
+	// TableBalanceShardScheduler should not schedule when the shards are balanced.
+	result, err := s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	sourceShardID := snapshot.Topology.LeaderShardNodes()[0].ID
+	for i := 0; i < 6; i++ {
+		shardView := c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping[sourceShardID]
+		_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+			ShardID:       sourceShardID,
+			LatestVersion: shardView.Version,
+			SchemaName:    test.TestSchemaName,
+			TableName:     fmt.Sprintf("balanceTable%d", i),
+			PartitionInfo: storage.PartitionInfo{Info: nil},
+		})
+		re.NoError(err)
+	}
+
+	// Only one shard holds tables, so the tables are migrated to one of the empty shards.
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.Equal(procedure.Migrate, result.Procedure.Kind())
+	shardWithVersion := result.Procedure.RelatedVersionInfo().ShardWithVersion
+	re.Len(shardWithVersion, 2)
+	re.Contains(shardWithVersion, sourceShardID)
+
+	// The difference is accepted by a larger tolerance.
+	s = balance.NewShardScheduler(zap.NewNop(), procedureFactory, c.GetMetadata(), 10, balance.Options{Enable: true, Tolerance: 6})
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// TableBalanceShardScheduler should not schedule when the topology is locked.
+	s = balance.NewShardScheduler(zap.NewNop(), procedureFactory, c.GetMetadata(), 10, balance.Options{Enable: true, Tolerance: 2})
+	s.UpdateEnableSchedule(ctx, true)
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+}
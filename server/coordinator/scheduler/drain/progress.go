@@ -0,0 +1,202 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package drain
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Progress describes the progress of draining a node, and the drain is finished when no shard remains on the node.
+type Progress struct {
+	NodeName string `json:"nodeName"`
+	Drained  bool   `json:"drained"`
+	// NumShards is the number of the leader shards on the node when it starts to be drained.
+	NumShards         int               `json:"numShards"`
+	RemainingShardIDs []storage.ShardID `json:"remainingShardIDs"`
+	Finished          bool              `json:"finished"`
+	DrainedAt         uint64            `json:"drainedAt"`
+}
+
+// Tracker keeps the persisted drained nodes of the cluster, and shares their names with the schedulers through the DrainedNodes.
+type Tracker struct {
+	logger          *zap.Logger
+	clusterMetadata *metadata.ClusterMetadata
+	drainedNodes    *scheduler.DrainedNodes
+
+	// This lock is used to protect the following field.
+	lock            sync.RWMutex
+	drainedNodeList storage.DrainedNodeList
+}
+
+func NewTracker(logger *zap.Logger, clusterMetadata *metadata.ClusterMetadata, drainedNodes *scheduler.DrainedNodes) *Tracker {
+	return &Tracker{
+		logger:          logger,
+		clusterMetadata: clusterMetadata,
+		drainedNodes:    drainedNodes,
+		lock:            sync.RWMutex{},
+		drainedNodeList: storage.DrainedNodeList{Version: 0, Nodes: []storage.DrainedNode{}},
+	}
+}
+
+// Load reloads the persisted drained nodes, and it should be called before the schedulers start.
+func (t *Tracker) Load(ctx context.Context) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	drainedNodeList, err := metadata.LoadVersioned(ctx, t.clusterMetadata, storage.DrainedNodeList{Version: 0, Nodes: []storage.DrainedNode{}})
+	if err != nil {
+		return err
+	}
+
+	t.apply(drainedNodeList)
+	t.logger.Info("load drained nodes", zap.Uint64("version", drainedNodeList.Version), zap.Int("numDrainedNodes", len(drainedNodeList.Nodes)))
+	return nil
+}
+
+func (t *Tracker) apply(drainedNodeList storage.DrainedNodeList) {
+	drainedNodeNames := make([]string, 0, len(drainedNodeList.Nodes))
+	for _, drainedNode := range drainedNodeList.Nodes {
+		drainedNodeNames = append(drainedNodeNames, drainedNode.Name)
+	}
+	t.drainedNodes.Reset(drainedNodeNames)
+	t.drainedNodeList = drainedNodeList
+}
+
+// persist persists the drained nodes as the next version, and it fails if they have been updated by others, e.g. a new leader.
+func (t *Tracker) persist(ctx context.Context, nodes []storage.DrainedNode) error {
+	drainedNodeList := storage.DrainedNodeList{Version: t.drainedNodeList.Version + 1, Nodes: nodes}
+	if err := metadata.UpdateVersioned(ctx, t.clusterMetadata, drainedNodeList, t.drainedNodeList.Version); err != nil {
+		return errors.WithMessage(err, "persist drained nodes")
+	}
+
+	t.apply(drainedNodeList)
+	return nil
+}
+
+// Drain marks the node as drained, and it fails if no other alive node can take over the shards on it.
+func (t *Tracker) Drain(ctx context.Context, nodeName string) (Progress, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.clusterMetadata.GetRegisteredNodeByName(nodeName); !ok {
+		return Progress{}, metadata.ErrNodeNotFound.WithCausef("nodeName:%s", nodeName)
+	}
+
+	if _, drained := t.findDrainedNode(nodeName); !drained {
+		// Make sure there is still some node to take over the shards.
+		now := time.Now()
+		hasOtherNode := false
+		for _, registeredNode := range t.clusterMetadata.GetRegisteredNodes() {
+			name := registeredNode.Node.Name
+			if name != nodeName && !t.drainedNodes.Contains(name) && !registeredNode.IsExpired(now) {
+				hasOtherNode = true
+				break
+			}
+		}
+		if !hasOtherNode {
+			return Progress{}, ErrDrainNode.WithCausef("no other alive node can take over the shards, nodeName:%s", nodeName)
+		}
+
+		drainedNodes := append(slices.Clone(t.drainedNodeList.Nodes), storage.DrainedNode{
+			Name:      nodeName,
+			NumShards: len(leaderShardsOnNode(t.clusterMetadata.GetClusterSnapshot(), nodeName)),
+			DrainedAt: uint64(now.UnixMilli()),
+		})
+		if err := t.persist(ctx, drainedNodes); err != nil {
+			return Progress{}, err
+		}
+		t.logger.Info("node is drained", zap.String("nodeName", nodeName))
+	}
+
+	return t.progress(nodeName), nil
+}
+
+// Undrain makes the drained node available for the shard placement again, and nothing is done if the node is not drained.
+func (t *Tracker) Undrain(ctx context.Context, nodeName string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, drained := t.findDrainedNode(nodeName); !drained {
+		return nil
+	}
+
+	drainedNodes := make([]storage.DrainedNode, 0, len(t.drainedNodeList.Nodes))
+	for _, drainedNode := range t.drainedNodeList.Nodes {
+		if drainedNode.Name != nodeName {
+			drainedNodes = append(drainedNodes, drainedNode)
+		}
+	}
+	if err := t.persist(ctx, drainedNodes); err != nil {
+		return err
+	}
+	t.logger.Info("node is undrained", zap.String("nodeName", nodeName))
+	return nil
+}
+
+func (t *Tracker) Progress(nodeName string) Progress {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.progress(nodeName)
+}
+
+func (t *Tracker) progress(nodeName string) Progress {
+	remainingShardIDs := leaderShardsOnNode(t.clusterMetadata.GetClusterSnapshot(), nodeName)
+	drainedNode, drained := t.findDrainedNode(nodeName)
+	return Progress{
+		NodeName:          nodeName,
+		Drained:           drained,
+		NumShards:         drainedNode.NumShards,
+		RemainingShardIDs: remainingShardIDs,
+		Finished:          drained && len(remainingShardIDs) == 0,
+		DrainedAt:         drainedNode.DrainedAt,
+	}
+}
+
+func (t *Tracker) findDrainedNode(nodeName string) (storage.DrainedNode, bool) {
+	for _, drainedNode := range t.drainedNodeList.Nodes {
+		if drainedNode.Name == nodeName {
+			return drainedNode, true
+		}
+	}
+	return storage.DrainedNode{}, false
+}
+
+func leaderShardsOnNode(snapshot metadata.Snapshot, nodeName string) []storage.ShardID {
+	shardIDs := make([]storage.ShardID, 0)
+	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.NodeName == nodeName && shardNode.ShardRole == storage.ShardRoleLeader {
+			shardIDs = append(shardIDs, shardNode.ID)
+		}
+	}
+	sort.Slice(shardIDs, func(i, j int) bool {
+		return shardIDs[i] < shardIDs[j]
+	})
+	return shardIDs
+}
@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package drain
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"go.uber.org/zap"
+)
+
+// schedulerImpl moves the leader shards on the drained nodes to the other nodes in batches.
+//
+// The drain is requested explicitly, so it goes on even if the shard topology is locked.
+type schedulerImpl struct {
+	logger                      *zap.Logger
+	factory                     *coordinator.Factory
+	nodePicker                  nodepicker.NodePicker
+	drainedNodes                *scheduler.DrainedNodes
+	procedureExecutingBatchSize uint32
+
+	// The lock is used to protect following fields.
+	lock sync.Mutex
+	// shardAffinityRule and shardAntiAffinityRule are kept consistent with the rebalanced scheduler, so the shards are moved to where it expects.
+	shardAffinityRule     map[storage.ShardID]scheduler.ShardAffinity
+	shardAntiAffinityRule map[storage.ShardID]scheduler.ShardAntiAffinity
+}
+
+func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, nodePicker nodepicker.NodePicker, drainedNodes *scheduler.DrainedNodes, procedureExecutingBatchSize uint32) scheduler.Scheduler {
+	return &schedulerImpl{
+		logger:                      logger,
+		factory:                     factory,
+		nodePicker:                  nodePicker,
+		drainedNodes:                drainedNodes,
+		procedureExecutingBatchSize: procedureExecutingBatchSize,
+		lock:                        sync.Mutex{},
+		shardAffinityRule:           map[storage.ShardID]scheduler.ShardAffinity{},
+		shardAntiAffinityRule:       map[storage.ShardID]scheduler.ShardAntiAffinity{},
+	}
+}
+
+func (s *schedulerImpl) Name() string {
+	return "drain_scheduler"
+}
+
+func (s *schedulerImpl) UpdateEnableSchedule(_ context.Context, _ bool) {
+	// DrainShardScheduler do not need enableSchedule.
+}
+
+func (s *schedulerImpl) AddShardAffinityRule(_ context.Context, rule scheduler.ShardAffinityRule) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, shardAffinity := range rule.Affinities {
+		s.shardAffinityRule[shardAffinity.ShardID] = shardAffinity
+	}
+	for _, shardAntiAffinity := range rule.AntiAffinities {
+		s.shardAntiAffinityRule[shardAntiAffinity.ShardID] = shardAntiAffinity
+	}
+
+	return nil
+}
+
+func (s *schedulerImpl) RemoveShardAffinityRule(_ context.Context, shardID storage.ShardID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.shardAffinityRule, shardID)
+	delete(s.shardAntiAffinityRule, shardID)
+
+	return nil
+}
+
+func (s *schedulerImpl) ListShardAffinityRule(_ context.Context) (scheduler.ShardAffinityRule, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	affinities := make([]scheduler.ShardAffinity, 0, len(s.shardAffinityRule))
+	for _, affinity := range s.shardAffinityRule {
+		affinities = append(affinities, affinity)
+	}
+	antiAffinities := make([]scheduler.ShardAntiAffinity, 0, len(s.shardAntiAffinityRule))
+	for _, antiAffinity := range s.shardAntiAffinityRule {
+		antiAffinities = append(antiAffinities, antiAffinity)
+	}
+
+	return scheduler.ShardAffinityRule{Affinities: affinities, AntiAffinities: antiAffinities}, nil
+}
+
+func (s *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	var emptySchedulerRes scheduler.ScheduleResult
+	// DrainShardScheduler can only be scheduled when the cluster is stable.
+	if !clusterSnapshot.Topology.IsStable() || s.drainedNodes.Len() == 0 {
+		return emptySchedulerRes, nil
+	}
+
+	oldLeaderNodes := make(map[storage.ShardID]string)
+	shardIDs := make([]storage.ShardID, 0)
+	for _, shardNode := range clusterSnapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.ShardRole == storage.ShardRoleLeader && s.drainedNodes.Contains(shardNode.NodeName) {
+			oldLeaderNodes[shardNode.ID] = shardNode.NodeName
+			shardIDs = append(shardIDs, shardNode.ID)
+		}
+	}
+	if len(shardIDs) == 0 {
+		return emptySchedulerRes, nil
+	}
+	sort.Slice(shardIDs, func(i, j int) bool {
+		return shardIDs[i] < shardIDs[j]
+	})
+	if len(shardIDs) > int(s.procedureExecutingBatchSize) {
+		shardIDs = shardIDs[:s.procedureExecutingBatchSize]
+	}
+
+	candidateNodes := make([]metadata.RegisteredNode, 0, len(clusterSnapshot.RegisteredNodes))
+	for _, registeredNode := range clusterSnapshot.RegisteredNodes {
+		if !s.drainedNodes.Contains(registeredNode.Node.Name) {
+			candidateNodes = append(candidateNodes, registeredNode)
+		}
+	}
+	s.lock.Lock()
+	pickConfig := nodepicker.Config{
+		NumTotalShards:        clusterSnapshot.Topology.ShardIDBound(),
+		ShardAffinityRule:     maps.Clone(s.shardAffinityRule),
+		ShardAntiAffinityRule: maps.Clone(s.shardAntiAffinityRule),
+	}
+	s.lock.Unlock()
+	shardNodeMapping, err := s.nodePicker.PickNode(ctx, pickConfig, shardIDs, candidateNodes)
+	if err != nil {
+		return emptySchedulerRes, err
+	}
+
+	procedures := make([]procedure.Procedure, 0, len(shardIDs))
+	var reasons strings.Builder
+	for _, shardID := range shardIDs {
+		newLeaderNode := shardNodeMapping[shardID]
+		s.logger.Info("drain shard scheduler try to move shard out of the drained node", zap.Uint32("shardID", uint32(shardID)), zap.String("oldNode", oldLeaderNodes[shardID]), zap.String("newNode", newLeaderNode.Node.Name))
+		p, err := s.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
+			Snapshot:          clusterSnapshot,
+			ShardID:           shardID,
+			OldLeaderNodeName: oldLeaderNodes[shardID],
+			NewLeaderNodeName: newLeaderNode.Node.Name,
+		})
+		if err != nil {
+			return emptySchedulerRes, err
+		}
+
+		procedures = append(procedures, p)
+		reasons.WriteString(fmt.Sprintf("node is drained, shardID:%d, oldNode:%s, newNode:%s\n", shardID, oldLeaderNodes[shardID], newLeaderNode.Node.Name))
+	}
+
+	batchProcedure, err := s.factory.CreateBatchTransferLeaderProcedure(ctx, coordinator.BatchRequest{
+		Batch:     procedures,
+		BatchType: procedure.TransferLeader,
+	})
+	if err != nil {
+		return emptySchedulerRes, err
+	}
+
+	return scheduler.ScheduleResult{Procedure: batchProcedure, Reason: reasons.String()}, nil
+}
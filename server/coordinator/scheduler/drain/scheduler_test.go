@@ -0,0 +1,76 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package drain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/drain"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDrainShardScheduler(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata())
+	drainedNodes := scheduler.NewDrainedNodes()
+	s := drain.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), drainedNodes, 1)
+
+	// DrainShardScheduler should not schedule when no node is drained.
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	result, err := s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	nodeShards := make(map[string][]storage.ShardID)
+	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+		nodeShards[shardNode.NodeName] = append(nodeShards[shardNode.NodeName], shardNode.ID)
+	}
+	drainedNode := "node0"
+	if len(nodeShards[drainedNode]) == 0 {
+		drainedNode = "node1"
+	}
+	drainedNodes.Reset([]string{drainedNode})
+
+	// Only one shard is moved in a round because of the batch size, and it must be the one on the drained node.
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	shardWithVersion := result.Procedure.RelatedVersionInfo().ShardWithVersion
+	re.Len(shardWithVersion, 1)
+	for shardID := range shardWithVersion {
+		re.Contains(nodeShards[drainedNode], shardID)
+	}
+
+	// Nothing should be scheduled after the node is undrained.
+	drainedNodes.Reset([]string{})
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
+}
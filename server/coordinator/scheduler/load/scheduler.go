@@ -0,0 +1,351 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package load
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"go.uber.org/zap"
+)
+
+// The load reported before loadTTL is considered stale, and the node won't be scheduled until it reports again.
+const loadTTL = time.Minute
+
+type Options struct {
+	// Enable adds the load scheduler to the default scheduler list of the clusters, it only works in dynamic topology.
+	Enable bool
+	// Threshold is the ratio by which the load of a node exceeds the mean of the cluster to be considered overloaded.
+	Threshold float64
+	// Hysteresis is subtracted from the threshold to get the low watermark.
+	// An overloaded node keeps being unloaded until its load falls below the low watermark, and a shard is only moved to the node whose load stays below it.
+	Hysteresis float64
+	// Cooldown is the min interval between two moves of the same shard.
+	Cooldown time.Duration
+}
+
+// schedulerImpl moves the shard leaders from the overloaded nodes to the least loaded ones, one shard per round.
+//
+// The load of a node is scored as the mean of its usages normalized by the mean of the cluster, so the mean score of the cluster is always 1.
+type schedulerImpl struct {
+	logger          *zap.Logger
+	factory         *coordinator.Factory
+	leaderOverrides *scheduler.LeaderOverrides
+	// drainedNodes are neither unloaded nor loaded by this scheduler, and it can be nil.
+	drainedNodes *scheduler.DrainedNodes
+	options      Options
+
+	// Protect the following fields.
+	lock              sync.Mutex
+	enableSchedule    bool
+	overloadedNodes   map[string]struct{}
+	lastMovedAt       map[storage.ShardID]time.Time
+	shardAffinityRule map[storage.ShardID]scheduler.ShardAffinity
+	// shardAntiAffinityRule prevents the shard from being moved to the node holding the shards anti-affine with it.
+	shardAntiAffinityRule map[storage.ShardID]scheduler.ShardAntiAffinity
+}
+
+func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, leaderOverrides *scheduler.LeaderOverrides, drainedNodes *scheduler.DrainedNodes, options Options) scheduler.Scheduler {
+	return &schedulerImpl{
+		logger:                logger,
+		factory:               factory,
+		leaderOverrides:       leaderOverrides,
+		drainedNodes:          drainedNodes,
+		options:               options,
+		lock:                  sync.Mutex{},
+		enableSchedule:        false,
+		overloadedNodes:       map[string]struct{}{},
+		lastMovedAt:           map[storage.ShardID]time.Time{},
+		shardAffinityRule:     map[storage.ShardID]scheduler.ShardAffinity{},
+		shardAntiAffinityRule: map[storage.ShardID]scheduler.ShardAntiAffinity{},
+	}
+}
+
+func (s *schedulerImpl) Name() string {
+	return "load_scheduler"
+}
+
+func (s *schedulerImpl) UpdateEnableSchedule(_ context.Context, enable bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.enableSchedule = enable
+}
+
+func (s *schedulerImpl) AddShardAffinityRule(_ context.Context, rule scheduler.ShardAffinityRule) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, shardAffinity := range rule.Affinities {
+		s.shardAffinityRule[shardAffinity.ShardID] = shardAffinity
+	}
+	for _, shardAntiAffinity := range rule.AntiAffinities {
+		s.shardAntiAffinityRule[shardAntiAffinity.ShardID] = shardAntiAffinity
+	}
+
+	return nil
+}
+
+func (s *schedulerImpl) RemoveShardAffinityRule(_ context.Context, shardID storage.ShardID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.shardAffinityRule, shardID)
+	delete(s.shardAntiAffinityRule, shardID)
+
+	return nil
+}
+
+func (s *schedulerImpl) ListShardAffinityRule(_ context.Context) (scheduler.ShardAffinityRule, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	affinities := make([]scheduler.ShardAffinity, 0, len(s.shardAffinityRule))
+	for _, affinity := range s.shardAffinityRule {
+		affinities = append(affinities, affinity)
+	}
+	antiAffinities := make([]scheduler.ShardAntiAffinity, 0, len(s.shardAntiAffinityRule))
+	for _, antiAffinity := range s.shardAntiAffinityRule {
+		antiAffinities = append(antiAffinities, antiAffinity)
+	}
+
+	return scheduler.ShardAffinityRule{Affinities: affinities, AntiAffinities: antiAffinities}, nil
+}
+
+type nodeLoad struct {
+	name  string
+	load  storage.NodeLoad
+	score float64
+}
+
+func (s *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	var emptySchedulerRes scheduler.ScheduleResult
+	// LoadShardScheduler can only be scheduled when the cluster is stable.
+	if !clusterSnapshot.Topology.IsStable() {
+		return emptySchedulerRes, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The shard topology is locked.
+	if s.enableSchedule {
+		return emptySchedulerRes, nil
+	}
+
+	now := time.Now()
+	nodeLoads := collectNodeLoads(clusterSnapshot, s.drainedNodes, now)
+	if len(nodeLoads) < 2 {
+		return emptySchedulerRes, nil
+	}
+	computeScores(nodeLoads)
+
+	highWatermark := 1 + s.options.Threshold
+	lowWatermark := highWatermark - s.options.Hysteresis
+	s.updateOverloadedNodes(nodeLoads, highWatermark, lowWatermark)
+
+	// The most loaded node is unloaded first, and the shard is moved to the least loaded node.
+	sort.Slice(nodeLoads, func(i, j int) bool {
+		return nodeLoads[i].score > nodeLoads[j].score
+	})
+	nodeShards := make(map[string][]storage.ShardID, len(nodeLoads))
+	for _, shardNode := range clusterSnapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.ShardRole == storage.ShardRoleLeader {
+			nodeShards[shardNode.NodeName] = append(nodeShards[shardNode.NodeName], shardNode.ID)
+		}
+	}
+
+	for _, source := range nodeLoads {
+		if _, overloaded := s.overloadedNodes[source.name]; !overloaded {
+			continue
+		}
+		for i := len(nodeLoads) - 1; i >= 0; i-- {
+			target := nodeLoads[i]
+			if target.name == source.name || target.score >= lowWatermark || !s.allowMoreShards(nodeShards[target.name]) {
+				continue
+			}
+
+			shardID, projectedScore, ok := s.pickShard(source, target, nodeShards[source.name], nodeShards[target.name], lowWatermark, now)
+			if !ok {
+				continue
+			}
+
+			p, err := s.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
+				Snapshot:          clusterSnapshot,
+				ShardID:           shardID,
+				OldLeaderNodeName: source.name,
+				NewLeaderNodeName: target.name,
+			})
+			if err != nil {
+				return emptySchedulerRes, err
+			}
+
+			s.lastMovedAt[shardID] = now
+			if s.leaderOverrides != nil {
+				s.leaderOverrides.Set(shardID, target.name)
+			}
+			s.logger.Info("load shard scheduler try to move shard from overloaded node", zap.Uint32("shardID", uint32(shardID)), zap.String("sourceNode", source.name), zap.Float64("sourceScore", source.score), zap.String("targetNode", target.name), zap.Float64("targetScore", target.score), zap.Float64("projectedTargetScore", projectedScore))
+
+			return scheduler.ScheduleResult{
+				Procedure: p,
+				Reason:    fmt.Sprintf("node is overloaded, shardID:%d, oldNode:%s, oldNodeLoadScore:%.2f, newNode:%s, newNodeLoadScore:%.2f", shardID, source.name, source.score, target.name, target.score),
+			}, nil
+		}
+	}
+
+	return emptySchedulerRes, nil
+}
+
+// pickShard picks the leader shard of source with the largest load which can be moved to target without overloading it, and the shards
+// anti-affine with any shard on target are skipped.
+func (s *schedulerImpl) pickShard(source, target nodeLoad, shardIDs, targetShardIDs []storage.ShardID, lowWatermark float64, now time.Time) (storage.ShardID, float64, bool) {
+	candidates := make([]storage.ShardID, 0, len(shardIDs))
+	for _, shardID := range shardIDs {
+		if _, hasAffinity := s.shardAffinityRule[shardID]; hasAffinity {
+			continue
+		}
+		if slices.ContainsFunc(targetShardIDs, func(targetShardID storage.ShardID) bool {
+			return scheduler.IsAntiAffine(s.shardAntiAffinityRule, shardID, targetShardID)
+		}) {
+			continue
+		}
+		if movedAt, ok := s.lastMovedAt[shardID]; ok && now.Sub(movedAt) < s.options.Cooldown {
+			continue
+		}
+		candidates = append(candidates, shardID)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return shardShare(source.load, candidates[i]) > shardShare(source.load, candidates[j])
+	})
+
+	for _, shardID := range candidates {
+		movedScore := source.score * shardShare(source.load, shardID)
+		if movedScore <= 0 {
+			continue
+		}
+		projectedScore := target.score + movedScore
+		// Make sure the shard won't be moved back, which happens if the target becomes more loaded than the source.
+		if projectedScore < lowWatermark && projectedScore < source.score-movedScore {
+			return shardID, projectedScore, true
+		}
+	}
+	return 0, 0, false
+}
+
+// allowMoreShards checks whether the shard affinity rules of the shards on the node allow another shard to be moved in.
+func (s *schedulerImpl) allowMoreShards(shardIDs []storage.ShardID) bool {
+	for _, shardID := range shardIDs {
+		affinity, ok := s.shardAffinityRule[shardID]
+		if ok && uint(len(shardIDs)) > affinity.NumAllowedOtherShards {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *schedulerImpl) updateOverloadedNodes(nodeLoads []nodeLoad, highWatermark, lowWatermark float64) {
+	overloadedNodes := make(map[string]struct{}, len(s.overloadedNodes))
+	for _, node := range nodeLoads {
+		_, overloaded := s.overloadedNodes[node.name]
+		if node.score > highWatermark || (overloaded && node.score >= lowWatermark) {
+			overloadedNodes[node.name] = struct{}{}
+		}
+	}
+	s.overloadedNodes = overloadedNodes
+}
+
+func collectNodeLoads(clusterSnapshot metadata.Snapshot, drainedNodes *scheduler.DrainedNodes, now time.Time) []nodeLoad {
+	nodeLoads := make([]nodeLoad, 0, len(clusterSnapshot.RegisteredNodes))
+	for _, registeredNode := range clusterSnapshot.RegisteredNodes {
+		if drainedNodes != nil && drainedNodes.Contains(registeredNode.Node.Name) {
+			continue
+		}
+		load := registeredNode.Node.NodeStats.Load
+		if registeredNode.IsExpired(now) || load.ReportedAt == 0 || now.Sub(time.UnixMilli(int64(load.ReportedAt))) > loadTTL {
+			continue
+		}
+		nodeLoads = append(nodeLoads, nodeLoad{name: registeredNode.Node.Name, load: load, score: 0})
+	}
+	return nodeLoads
+}
+
+var loadMetrics = []func(storage.NodeLoad) float64{
+	func(load storage.NodeLoad) float64 { return load.CPUUsage },
+	func(load storage.NodeLoad) float64 { return load.MemoryUsage },
+	func(load storage.NodeLoad) float64 { return load.DiskUsage },
+	func(load storage.NodeLoad) float64 { return load.WriteQPS },
+}
+
+func computeScores(nodeLoads []nodeLoad) {
+	numMetrics := 0
+	for _, metric := range loadMetrics {
+		var sum float64
+		for _, node := range nodeLoads {
+			sum += metric(node.load)
+		}
+		mean := sum / float64(len(nodeLoads))
+		// The metric is not reported by any node.
+		if mean <= 0 {
+			continue
+		}
+
+		numMetrics++
+		for i := range nodeLoads {
+			nodeLoads[i].score += metric(nodeLoads[i].load) / mean
+		}
+	}
+
+	if numMetrics == 0 {
+		return
+	}
+	for i := range nodeLoads {
+		nodeLoads[i].score /= float64(numMetrics)
+	}
+}
+
+// shardShare estimates the share of the shard in the load of the node by the write qps, or by the disk usage if there is no write.
+func shardShare(load storage.NodeLoad, shardID storage.ShardID) float64 {
+	shardLoad, ok := load.ShardLoads[shardID]
+	if !ok {
+		return 0
+	}
+
+	var totalWriteQPS float64
+	var totalDiskBytes uint64
+	for _, l := range load.ShardLoads {
+		totalWriteQPS += l.WriteQPS
+		totalDiskBytes += l.DiskBytes
+	}
+	if totalWriteQPS > 0 {
+		return shardLoad.WriteQPS / totalWriteQPS
+	}
+	if totalDiskBytes > 0 {
+		return float64(shardLoad.DiskBytes) / float64(totalDiskBytes)
+	}
+	return 0
+}
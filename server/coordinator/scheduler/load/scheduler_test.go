@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package load_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/load"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLoadShardScheduler(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata())
+	leaderOverrides := scheduler.NewLeaderOverrides()
+	s := load.NewShardScheduler(zap.NewNop(), procedureFactory, leaderOverrides, nil, load.Options{
+		Enable:     true,
+		Threshold:  0.25,
+		Hysteresis: 0.1,
+		Cooldown:   time.Hour,
+	})
+
+	// LoadShardScheduler should not schedule when no load is reported.
+	result, err := s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	nodeShards := make(map[string][]storage.ShardID)
+	for _, shardNode := range c.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes {
+		nodeShards[shardNode.NodeName] = append(nodeShards[shardNode.NodeName], shardNode.ID)
+	}
+	sourceNode, targetNode := "node0", "node1"
+	if len(nodeShards[sourceNode]) < len(nodeShards[targetNode]) {
+		sourceNode, targetNode = targetNode, sourceNode
+	}
+	re.GreaterOrEqual(len(nodeShards[sourceNode]), 2)
+
+	// The heavy shard can't be moved, which will make the target overloaded, and the others without write can't relieve the source.
+	shardLoads := make(map[storage.ShardID]storage.ShardLoad)
+	for _, shardID := range nodeShards[sourceNode] {
+		shardLoads[shardID] = storage.ShardLoad{WriteQPS: 0, DiskBytes: 0}
+	}
+	heavyShard, lightShard := nodeShards[sourceNode][0], nodeShards[sourceNode][1]
+	shardLoads[heavyShard] = storage.ShardLoad{WriteQPS: 900, DiskBytes: 0}
+	shardLoads[lightShard] = storage.ShardLoad{WriteQPS: 100, DiskBytes: 0}
+	re.NoError(c.GetMetadata().UpdateNodeLoad(sourceNode, storage.NodeLoad{
+		CPUUsage:    0.9,
+		MemoryUsage: 0.8,
+		DiskUsage:   0.5,
+		WriteQPS:    1000,
+		ShardLoads:  shardLoads,
+		ReportedAt:  uint64(time.Now().UnixMilli()),
+	}))
+	re.NoError(c.GetMetadata().UpdateNodeLoad(targetNode, storage.NodeLoad{
+		CPUUsage:    0.1,
+		MemoryUsage: 0.2,
+		DiskUsage:   0.5,
+		WriteQPS:    100,
+		ShardLoads:  map[storage.ShardID]storage.ShardLoad{},
+		ReportedAt:  uint64(time.Now().UnixMilli()),
+	}))
+
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.Contains(result.Procedure.RelatedVersionInfo().ShardWithVersion, lightShard)
+	nodeName, ok := leaderOverrides.Get(lightShard)
+	re.True(ok)
+	re.Equal(targetNode, nodeName)
+
+	// The moved shard is cooling down and the heavy one can't be moved, so nothing should be scheduled.
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// LoadShardScheduler should not schedule when the topology is locked.
+	s.UpdateEnableSchedule(ctx, true)
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+}
@@ -0,0 +1,248 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package manager
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/pkg/log"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker/hash"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// loadShardAffinityRules loads the persisted rules, and applies them to the registered schedulers.
+func (m *schedulerManagerImpl) loadShardAffinityRules(ctx context.Context) error {
+	rules, err := metadata.LoadVersioned(ctx, m.clusterMetadata, storage.ShardAffinityRules{Version: 0, Affinities: []storage.ShardAffinity{}, AntiAffinities: []storage.ShardAntiAffinity{}})
+	if err != nil {
+		return err
+	}
+
+	m.shardAffinities = make(map[storage.ShardID]scheduler.ShardAffinity, len(rules.Affinities))
+	for _, affinity := range rules.Affinities {
+		m.shardAffinities[affinity.ShardID] = scheduler.ShardAffinity{
+			ShardID:               affinity.ShardID,
+			NumAllowedOtherShards: affinity.NumAllowedOtherShards,
+		}
+	}
+	m.shardAntiAffinities = make(map[storage.ShardID]scheduler.ShardAntiAffinity, len(rules.AntiAffinities))
+	for _, antiAffinity := range rules.AntiAffinities {
+		m.shardAntiAffinities[antiAffinity.ShardID] = scheduler.ShardAntiAffinity{
+			ShardID:              antiAffinity.ShardID,
+			AntiAffinityShardIDs: antiAffinity.AntiAffinityShardIDs,
+		}
+	}
+	m.shardAffinitiesVersion = rules.Version
+	m.logger.Info("load shard affinity rules", zap.Uint64("version", rules.Version), zap.Int("numAffinities", len(rules.Affinities)), zap.Int("numAntiAffinities", len(rules.AntiAffinities)))
+
+	m.applyShardAffinityRules(ctx, m.registerSchedulers)
+	return nil
+}
+
+// applyShardAffinityRules applies all the shard affinity rules of the manager to the schedulers.
+func (m *schedulerManagerImpl) applyShardAffinityRules(ctx context.Context, schedulers []scheduler.Scheduler) {
+	// Only the schedulers of dynamic topology support shard affinity.
+	if (len(m.shardAffinities) == 0 && len(m.shardAntiAffinities) == 0) || m.topologyType != storage.TopologyTypeDynamic {
+		return
+	}
+	rule := scheduler.ShardAffinityRule{Affinities: sortedShardAffinities(m.shardAffinities), AntiAffinities: sortedShardAntiAffinities(m.shardAntiAffinities)}
+	for _, scheduler := range schedulers {
+		if err := scheduler.AddShardAffinityRule(ctx, rule); err != nil {
+			m.logger.Error("failed to apply the shard affinity rule to a scheduler", zap.String("scheduler", scheduler.Name()), zap.Error(err))
+		}
+	}
+}
+
+// persistShardAffinities persists the affinities and anti-affinities as the next version of the rules, and it fails if the rules have
+// been updated by others, e.g. a new leader.
+func (m *schedulerManagerImpl) persistShardAffinities(ctx context.Context, affinities map[storage.ShardID]scheduler.ShardAffinity, antiAffinities map[storage.ShardID]scheduler.ShardAntiAffinity) error {
+	storageAffinities := make([]storage.ShardAffinity, 0, len(affinities))
+	for _, affinity := range sortedShardAffinities(affinities) {
+		storageAffinities = append(storageAffinities, storage.ShardAffinity{
+			ShardID:               affinity.ShardID,
+			NumAllowedOtherShards: affinity.NumAllowedOtherShards,
+		})
+	}
+
+	storageAntiAffinities := make([]storage.ShardAntiAffinity, 0, len(antiAffinities))
+	for _, antiAffinity := range sortedShardAntiAffinities(antiAffinities) {
+		storageAntiAffinities = append(storageAntiAffinities, storage.ShardAntiAffinity{
+			ShardID:              antiAffinity.ShardID,
+			AntiAffinityShardIDs: antiAffinity.AntiAffinityShardIDs,
+		})
+	}
+
+	rules := storage.ShardAffinityRules{
+		Version:        m.shardAffinitiesVersion + 1,
+		Affinities:     storageAffinities,
+		AntiAffinities: storageAntiAffinities,
+	}
+	if err := metadata.UpdateVersioned(ctx, m.clusterMetadata, rules, m.shardAffinitiesVersion); err != nil {
+		return err
+	}
+
+	m.shardAffinities = affinities
+	m.shardAntiAffinities = antiAffinities
+	m.shardAffinitiesVersion = rules.Version
+	return nil
+}
+
+func sortedShardAffinities(affinities map[storage.ShardID]scheduler.ShardAffinity) []scheduler.ShardAffinity {
+	sorted := make([]scheduler.ShardAffinity, 0, len(affinities))
+	for _, affinity := range affinities {
+		sorted = append(sorted, affinity)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ShardID < sorted[j].ShardID
+	})
+	return sorted
+}
+
+func sortedShardAntiAffinities(antiAffinities map[storage.ShardID]scheduler.ShardAntiAffinity) []scheduler.ShardAntiAffinity {
+	sorted := make([]scheduler.ShardAntiAffinity, 0, len(antiAffinities))
+	for _, antiAffinity := range antiAffinities {
+		sorted = append(sorted, antiAffinity)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ShardID < sorted[j].ShardID
+	})
+	return sorted
+}
+
+// checkShardAntiAffinities checks whether the anti-affinities refer to the existing shards only, and whether all the rules can be
+// satisfied by the registered nodes.
+func (m *schedulerManagerImpl) checkShardAntiAffinities(ctx context.Context, antiAffinities []scheduler.ShardAntiAffinity, allAffinities map[storage.ShardID]scheduler.ShardAffinity, allAntiAffinities map[storage.ShardID]scheduler.ShardAntiAffinity) error {
+	numTotalShards := m.clusterMetadata.GetTotalShardNum()
+	for _, antiAffinity := range antiAffinities {
+		for _, shardID := range append([]storage.ShardID{antiAffinity.ShardID}, antiAffinity.AntiAffinityShardIDs...) {
+			if uint32(shardID) >= numTotalShards {
+				return ErrInvalidShardAffinity.WithCausef("shard not found, shardID:%d, numTotalShards:%d", shardID, numTotalShards)
+			}
+		}
+		if slices.Contains(antiAffinity.AntiAffinityShardIDs, antiAffinity.ShardID) {
+			return ErrInvalidShardAffinity.WithCausef("shard can't be anti-affine with itself, shardID:%d", antiAffinity.ShardID)
+		}
+	}
+
+	// Only the schedulers of dynamic topology support shard affinity.
+	if len(antiAffinities) == 0 || m.topologyType != storage.TopologyTypeDynamic {
+		return nil
+	}
+	shardIDs := make([]storage.ShardID, 0, numTotalShards)
+	for shardID := uint32(0); shardID < numTotalShards; shardID++ {
+		shardIDs = append(shardIDs, storage.ShardID(shardID))
+	}
+	config := nodepicker.Config{
+		NumTotalShards:        numTotalShards,
+		ShardAffinityRule:     allAffinities,
+		ShardAntiAffinityRule: allAntiAffinities,
+	}
+	registeredNodes := m.clusterMetadata.GetRegisteredNodes()
+	if _, err := m.nodePicker.PickNode(ctx, config, shardIDs, registeredNodes); errors.Is(err, hash.ErrUnsatisfiableAntiAffinity) {
+		return ErrUnsatisfiableShardAffinity.WithCausef("numRegisteredNodes:%d, err:%v", len(registeredNodes), err)
+	}
+	return nil
+}
+
+func (m *schedulerManagerImpl) AddShardAffinityRule(ctx context.Context, rule scheduler.ShardAffinityRule) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	affinities := maps.Clone(m.shardAffinities)
+	for _, affinity := range rule.Affinities {
+		affinities[affinity.ShardID] = affinity
+	}
+	antiAffinities := maps.Clone(m.shardAntiAffinities)
+	newAntiAffinities := make([]scheduler.ShardAntiAffinity, 0, len(rule.AntiAffinities))
+	for _, antiAffinity := range rule.AntiAffinities {
+		antiAffinityShardIDs := slices.Clone(antiAffinity.AntiAffinityShardIDs)
+		slices.Sort(antiAffinityShardIDs)
+		antiAffinity.AntiAffinityShardIDs = slices.Compact(antiAffinityShardIDs)
+		antiAffinities[antiAffinity.ShardID] = antiAffinity
+		newAntiAffinities = append(newAntiAffinities, antiAffinity)
+	}
+	rule.AntiAffinities = newAntiAffinities
+	if err := m.checkShardAntiAffinities(ctx, rule.AntiAffinities, affinities, antiAffinities); err != nil {
+		return err
+	}
+	if err := m.persistShardAffinities(ctx, affinities, antiAffinities); err != nil {
+		return errors.WithMessage(err, "persist shard affinity rules")
+	}
+
+	var lastErr error
+	for _, scheduler := range m.registerSchedulers {
+		if err := scheduler.AddShardAffinityRule(ctx, rule); err != nil {
+			log.Error("failed to add shard affinity rule of a scheduler", zap.String("scheduler", scheduler.Name()), zap.Error(err))
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (m *schedulerManagerImpl) RemoveShardAffinityRule(ctx context.Context, shardID storage.ShardID) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, affinityExists := m.shardAffinities[shardID]
+	_, antiAffinityExists := m.shardAntiAffinities[shardID]
+	if affinityExists || antiAffinityExists {
+		affinities := maps.Clone(m.shardAffinities)
+		delete(affinities, shardID)
+		antiAffinities := maps.Clone(m.shardAntiAffinities)
+		delete(antiAffinities, shardID)
+		if err := m.persistShardAffinities(ctx, affinities, antiAffinities); err != nil {
+			return errors.WithMessage(err, "persist shard affinity rules")
+		}
+	}
+
+	var lastErr error
+	for _, scheduler := range m.registerSchedulers {
+		if err := scheduler.RemoveShardAffinityRule(ctx, shardID); err != nil {
+			log.Error("failed to remove shard affinity rule of a scheduler", zap.String("scheduler", scheduler.Name()), zap.Error(err))
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (m *schedulerManagerImpl) ListShardAffinityRules(ctx context.Context) (map[string]scheduler.ShardAffinityRule, error) {
+	rules := make(map[string]scheduler.ShardAffinityRule, len(m.registerSchedulers))
+	var lastErr error
+
+	for _, scheduler := range m.registerSchedulers {
+		rule, err := scheduler.ListShardAffinityRule(ctx)
+		if err != nil {
+			log.Error("failed to list shard affinity rule of a scheduler", zap.String("scheduler", scheduler.Name()), zap.Error(err))
+			lastErr = err
+		}
+
+		rules[scheduler.Name()] = rule
+	}
+
+	return rules, lastErr
+}
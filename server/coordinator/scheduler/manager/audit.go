@@ -0,0 +1,242 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manager
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"go.uber.org/zap"
+)
+
+// ScheduleAuditFilter is used to filter the schedule audit records.
+type ScheduleAuditFilter struct {
+	// ShardID is nil means records related to any shard are accepted.
+	ShardID *storage.ShardID
+	// NodeName is empty means records related to any node are accepted.
+	NodeName string
+	// The matched records are sorted by ID in descending order, and Limit is zero means no limit.
+	Limit int
+}
+
+// scheduleAuditLog records the procedures submitted by the schedulers into a ring buffer persisted in the storage, so that why the shards
+// are moved can be reconstructed afterwards. The records are updated with the final states of the procedures once they are completed.
+type scheduleAuditLog struct {
+	logger          *zap.Logger
+	clusterMetadata *metadata.ClusterMetadata
+	// capacity is the number of the slots in the ring buffer, zero means nothing is recorded.
+	capacity uint64
+
+	// This lock is used to protect the following fields.
+	lock     sync.Mutex
+	latestID uint64
+	// ProcedureID -> the record of the submitted procedure which hasn't been completed, and it is nil before the record is appended.
+	submittedRecords map[uint64]*storage.ScheduleAuditRecord
+	// ProcedureID -> the final state of the procedure completed before its record is appended.
+	earlyCompletions map[uint64]procedure.ArchiveInfo
+}
+
+func newScheduleAuditLog(logger *zap.Logger, clusterMetadata *metadata.ClusterMetadata, capacity uint64) *scheduleAuditLog {
+	return &scheduleAuditLog{
+		logger:           logger,
+		clusterMetadata:  clusterMetadata,
+		capacity:         capacity,
+		lock:             sync.Mutex{},
+		latestID:         0,
+		submittedRecords: map[uint64]*storage.ScheduleAuditRecord{},
+		earlyCompletions: map[uint64]procedure.ArchiveInfo{},
+	}
+}
+
+// load reloads the ID of the latest record, and the next record will follow it.
+func (l *scheduleAuditLog) load(ctx context.Context) error {
+	if l.capacity == 0 {
+		return nil
+	}
+
+	_, latestID, err := l.clusterMetadata.ListScheduleAuditRecords(ctx)
+	if err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.latestID = latestID
+	return nil
+}
+
+// prepare must be called before the result is submitted, so that the final state of the procedure completed before the result is recorded
+// won't be missed.
+func (l *scheduleAuditLog) prepare(result scheduler.ScheduleResult) {
+	if l.capacity == 0 || result.Procedure == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.submittedRecords[result.Procedure.ID()] = nil
+}
+
+// record appends the submitted result with the outcome of the submission, and the result dropped as a duplicate is not recorded because the
+// equivalent procedure has been recorded when it was submitted.
+func (l *scheduleAuditLog) record(ctx context.Context, result scheduler.ScheduleResult, procedureID uint64, submitErr error) {
+	if l.capacity == 0 || result.Procedure == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.submittedRecords, result.Procedure.ID())
+	completion, completed := l.earlyCompletions[result.Procedure.ID()]
+	delete(l.earlyCompletions, result.Procedure.ID())
+	if submitErr == nil && procedureID != result.Procedure.ID() {
+		return
+	}
+
+	record := storage.ScheduleAuditRecord{
+		ID:            0,
+		Timestamp:     uint64(time.Now().UnixMilli()),
+		Scheduler:     result.Scheduler,
+		Reason:        result.Reason,
+		ProcedureID:   result.Procedure.ID(),
+		ProcedureKind: uint(result.Procedure.Kind()),
+		ShardIDs:      relatedShardIDs(result.Procedure),
+		NodeNames:     []string{},
+		Outcome:       storage.ScheduleAuditOutcomeSubmitted,
+		Error:         "",
+	}
+	if getter, ok := result.Procedure.(procedure.RelatedNodesGetter); ok {
+		record.NodeNames = getter.RelatedNodes()
+	}
+	if submitErr != nil {
+		record.Outcome = storage.ScheduleAuditOutcomeFailed
+		record.Error = submitErr.Error()
+	} else if completed {
+		setCompletion(&record, completion)
+	}
+
+	record.ID = l.latestID + 1
+	if err := l.clusterMetadata.AppendScheduleAuditRecord(ctx, record, l.capacity); err != nil {
+		l.logger.Warn("append schedule audit record failed", zap.Uint64("procedureID", record.ProcedureID), zap.Error(err))
+		// The records may have been appended by others, e.g. the previous leader, so the latest ID is reloaded for the next record.
+		if _, latestID, err := l.clusterMetadata.ListScheduleAuditRecords(ctx); err == nil {
+			l.latestID = latestID
+		}
+		return
+	}
+	l.latestID = record.ID
+	if record.Outcome == storage.ScheduleAuditOutcomeSubmitted {
+		l.submittedRecords[record.ProcedureID] = &record
+	}
+	// The records overwritten in the ring buffer can't be updated any more.
+	for submittedID, submittedRecord := range l.submittedRecords {
+		if submittedRecord != nil && submittedRecord.ID+l.capacity <= l.latestID {
+			delete(l.submittedRecords, submittedID)
+		}
+	}
+}
+
+// complete updates the record of the submitted procedure with its final state, and it is registered as the archive listener of the
+// procedure manager. The procedures submitted before the leader changes are not updated because their records are unknown.
+func (l *scheduleAuditLog) complete(ctx context.Context, procedureID uint64, info procedure.ArchiveInfo) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	record, exists := l.submittedRecords[procedureID]
+	if !exists {
+		return
+	}
+	if record == nil {
+		l.earlyCompletions[procedureID] = info
+		return
+	}
+	delete(l.submittedRecords, procedureID)
+
+	setCompletion(record, info)
+	if err := l.clusterMetadata.UpdateScheduleAuditRecord(ctx, *record, l.capacity); err != nil {
+		l.logger.Warn("update schedule audit record failed", zap.Uint64("procedureID", procedureID), zap.Error(err))
+	}
+}
+
+func setCompletion(record *storage.ScheduleAuditRecord, info procedure.ArchiveInfo) {
+	switch info.State {
+	case procedure.StateFinished:
+		record.Outcome = storage.ScheduleAuditOutcomeFinished
+	case procedure.StateCancelled:
+		record.Outcome = storage.ScheduleAuditOutcomeCancelled
+	default:
+		record.Outcome = storage.ScheduleAuditOutcomeFailed
+	}
+	record.Error = info.LastError
+}
+
+// list returns the records matching the filter, and the records overwritten before the capacity is reduced are skipped.
+func (l *scheduleAuditLog) list(ctx context.Context, filter ScheduleAuditFilter) ([]storage.ScheduleAuditRecord, error) {
+	if l.capacity == 0 {
+		return []storage.ScheduleAuditRecord{}, nil
+	}
+
+	records, latestID, err := l.clusterMetadata.ListScheduleAuditRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var oldestID uint64
+	if latestID > l.capacity {
+		oldestID = latestID - l.capacity + 1
+	}
+
+	matchedRecords := make([]storage.ScheduleAuditRecord, 0, len(records))
+	for _, record := range records {
+		if record.ID < oldestID {
+			continue
+		}
+		if filter.ShardID != nil && !slices.Contains(record.ShardIDs, *filter.ShardID) {
+			continue
+		}
+		if len(filter.NodeName) != 0 && !slices.Contains(record.NodeNames, filter.NodeName) {
+			continue
+		}
+		matchedRecords = append(matchedRecords, record)
+	}
+	sort.Slice(matchedRecords, func(i, j int) bool {
+		return matchedRecords[i].ID > matchedRecords[j].ID
+	})
+	if filter.Limit > 0 && filter.Limit < len(matchedRecords) {
+		matchedRecords = matchedRecords[:filter.Limit]
+	}
+	return matchedRecords, nil
+}
+
+func relatedShardIDs(p procedure.Procedure) []storage.ShardID {
+	shardIDs := make([]storage.ShardID, 0, len(p.RelatedVersionInfo().ShardWithVersion))
+	for shardID := range p.RelatedVersionInfo().ShardWithVersion {
+		shardIDs = append(shardIDs, shardID)
+	}
+	slices.Sort(shardIDs)
+	return shardIDs
+}
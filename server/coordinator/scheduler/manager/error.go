@@ -21,4 +21,16 @@ package manager
 
 import "github.com/apache/incubator-horaedb-meta/pkg/coderr"
 
-var ErrInvalidTopologyType = coderr.NewCodeError(coderr.InvalidParams, "invalid topology type")
+var (
+	ErrInvalidTopologyType        = coderr.NewCodeError(coderr.InvalidParams, "invalid topology type")
+	ErrInvalidShardGroup          = coderr.NewCodeError(coderr.InvalidParams, "invalid shard group")
+	ErrUnknownScheduler           = coderr.NewCodeError(coderr.InvalidParams, "unknown scheduler")
+	ErrInvalidMaintenanceWindow   = coderr.NewCodeError(coderr.InvalidParams, "invalid maintenance window")
+	ErrInvalidShardAffinity       = coderr.NewCodeError(coderr.InvalidParams, "invalid shard affinity")
+	ErrUnsatisfiableShardAffinity = coderr.NewCodeError(coderr.InvalidParams, "unsatisfiable shard affinity")
+	ErrInvalidNodeWeight          = coderr.NewCodeError(coderr.InvalidParams, "invalid node weight")
+	ErrInvalidReplicaNum          = coderr.NewCodeError(coderr.InvalidParams, "invalid replica num")
+	ErrExpandShards               = coderr.NewCodeError(coderr.InvalidParams, "expand shards")
+	ErrSchedulerRegistered        = coderr.NewCodeError(coderr.Internal, "scheduler registered")
+	ErrInvalidSchedulerConfig     = coderr.NewCodeError(coderr.InvalidParams, "invalid scheduler config")
+)
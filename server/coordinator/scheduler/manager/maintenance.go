@@ -0,0 +1,141 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/window"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type MaintenanceWindows struct {
+	Windows []storage.MaintenanceWindow `json:"windows"`
+	// Open tells whether the schedulers changing the topology can run now.
+	Open bool `json:"open"`
+}
+
+func parseMaintenanceWindows(windows []storage.MaintenanceWindow) ([]*window.Window, error) {
+	parsedWindows := make([]*window.Window, 0, len(windows))
+	for _, w := range windows {
+		parsedWindow, err := window.Parse(w.Cron, w.TimeZone)
+		if err != nil {
+			return nil, ErrInvalidMaintenanceWindow.WithCausef("name:%s, err:%v", w.Name, err)
+		}
+		parsedWindows = append(parsedWindows, parsedWindow)
+	}
+	return parsedWindows, nil
+}
+
+// inMaintenanceWindow returns true if no window is given or any window is open at the time.
+func (m *schedulerManagerImpl) inMaintenanceWindow(t time.Time) bool {
+	if len(m.maintenanceWindows) == 0 {
+		return true
+	}
+	for _, w := range m.maintenanceWindows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// runnableSchedulers filters out the schedulers changing the topology if no maintenance window is open at the time, and only the
+// corrective work of the partially corrective schedulers is kept.
+func (m *schedulerManagerImpl) runnableSchedulers(schedulers []scheduler.Scheduler, t time.Time) []scheduler.Scheduler {
+	if m.inMaintenanceWindow(t) {
+		return schedulers
+	}
+
+	runnable := make([]scheduler.Scheduler, 0, len(schedulers))
+	for _, s := range schedulers {
+		if corrective, ok := s.(scheduler.CorrectiveScheduler); ok && corrective.IsCorrective() {
+			runnable = append(runnable, s)
+			continue
+		}
+		if partiallyCorrective, ok := s.(scheduler.PartiallyCorrectiveScheduler); ok {
+			runnable = append(runnable, correctiveWorkScheduler{Scheduler: s, partiallyCorrective: partiallyCorrective})
+		}
+	}
+	return runnable
+}
+
+// correctiveWorkScheduler schedules only the corrective work of the wrapped scheduler.
+type correctiveWorkScheduler struct {
+	scheduler.Scheduler
+	partiallyCorrective scheduler.PartiallyCorrectiveScheduler
+}
+
+func (s correctiveWorkScheduler) Schedule(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	return s.partiallyCorrective.ScheduleCorrective(ctx, clusterSnapshot)
+}
+
+func (m *schedulerManagerImpl) loadMaintenanceWindows(ctx context.Context) error {
+	maintenanceWindowList, err := metadata.LoadVersioned(ctx, m.clusterMetadata, storage.MaintenanceWindowList{Version: 0, Windows: []storage.MaintenanceWindow{}})
+	if err != nil {
+		return err
+	}
+
+	maintenanceWindows, err := parseMaintenanceWindows(maintenanceWindowList.Windows)
+	if err != nil {
+		return err
+	}
+	m.maintenanceWindowList = maintenanceWindowList
+	m.maintenanceWindows = maintenanceWindows
+	m.logger.Info("load maintenance windows", zap.Uint64("version", maintenanceWindowList.Version), zap.Int("numWindows", len(maintenanceWindowList.Windows)))
+	return nil
+}
+
+func (m *schedulerManagerImpl) GetMaintenanceWindows(_ context.Context) (MaintenanceWindows, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return MaintenanceWindows{
+		Windows: m.maintenanceWindowList.Windows,
+		Open:    m.inMaintenanceWindow(time.Now()),
+	}, nil
+}
+
+func (m *schedulerManagerImpl) UpdateMaintenanceWindows(ctx context.Context, windows []storage.MaintenanceWindow) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if windows == nil {
+		windows = []storage.MaintenanceWindow{}
+	}
+	maintenanceWindows, err := parseMaintenanceWindows(windows)
+	if err != nil {
+		return err
+	}
+	maintenanceWindowList := storage.MaintenanceWindowList{Version: m.maintenanceWindowList.Version + 1, Windows: windows}
+	if err := metadata.UpdateVersioned(ctx, m.clusterMetadata, maintenanceWindowList, m.maintenanceWindowList.Version); err != nil {
+		return errors.WithMessage(err, "persist maintenance windows")
+	}
+	m.maintenanceWindowList = maintenanceWindowList
+	m.maintenanceWindows = maintenanceWindows
+
+	m.logger.Info("update maintenance windows", zap.Int("numWindows", len(windows)))
+	return nil
+}
@@ -0,0 +1,374 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker/hash"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// subTableNamePrefix is the prefix of the sub table names of a partitioned table, which are named as `__{table}_{partition}` by HoraeDB.
+// There is no other relation between the partitioned table and its sub tables in the metadata.
+const subTableNamePrefix = "__"
+
+// placementNodePicker picks nodes with the node picker selected by the shard placement rules of the cluster, and the node picker can
+// be switched at runtime without rebuilding the schedulers. The drained nodes are never picked.
+type placementNodePicker struct {
+	logger          *zap.Logger
+	clusterMetadata *metadata.ClusterMetadata
+	drainedNodes    *scheduler.DrainedNodes
+
+	// This lock is used to protect the following fields.
+	lock        sync.RWMutex
+	name        string
+	picker      nodepicker.NodePicker
+	shardGroups [][]storage.ShardID
+	nodeWeights map[string]uint32
+}
+
+func newPlacementNodePicker(logger *zap.Logger, clusterMetadata *metadata.ClusterMetadata, drainedNodes *scheduler.DrainedNodes) *placementNodePicker {
+	return &placementNodePicker{
+		logger:          logger,
+		clusterMetadata: clusterMetadata,
+		drainedNodes:    drainedNodes,
+		lock:            sync.RWMutex{},
+		name:            nodepicker.ConsistentUniformHashNodePickerName,
+		picker:          nodepicker.NewConsistentUniformHashNodePicker(logger),
+		shardGroups:     [][]storage.ShardID{},
+		nodeWeights:     map[string]uint32{},
+	}
+}
+
+// apply switches to the node picker and the shard groups specified by the rules.
+func (p *placementNodePicker) apply(rules storage.ShardPlacementRules) error {
+	picker, err := nodepicker.NewNodePicker(p.logger, rules.NodePicker)
+	if err != nil {
+		return err
+	}
+
+	shardGroups := make([][]storage.ShardID, 0, len(rules.ShardGroups))
+	for _, group := range rules.ShardGroups {
+		shardGroups = append(shardGroups, group.ShardIDs)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.name = rules.NodePicker
+	p.picker = picker
+	p.shardGroups = shardGroups
+	return nil
+}
+
+// applyNodeWeights replaces the weights of the nodes used to pick nodes.
+func (p *placementNodePicker) applyNodeWeights(weights []storage.NodeWeight) {
+	nodeWeights := make(map[string]uint32, len(weights))
+	for _, nodeWeight := range weights {
+		nodeWeights[nodeWeight.Name] = nodeWeight.Weight
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.nodeWeights = nodeWeights
+}
+
+func (p *placementNodePicker) PickNode(ctx context.Context, config nodepicker.Config, shardIDs []storage.ShardID, registerNodes []metadata.RegisteredNode) (map[storage.ShardID]metadata.RegisteredNode, error) {
+	p.lock.RLock()
+	name, picker := p.name, p.picker
+	shardGroups := append([][]storage.ShardID{}, p.shardGroups...)
+	nodeWeights := p.nodeWeights
+	p.lock.RUnlock()
+
+	if config.NodeWeights == nil {
+		config.NodeWeights = nodeWeights
+	}
+
+	// Only the zone aware node picker cares about the shard groups, so skip collecting the groups of partitioned tables for others.
+	if name == nodepicker.ZoneAwareNodePickerName {
+		config.ShardGroups = append(append(shardGroups, config.ShardGroups...), partitionedTableShardGroups(p.clusterMetadata.GetClusterSnapshot(), p.clusterMetadata)...)
+	}
+
+	candidateNodes := make([]metadata.RegisteredNode, 0, len(registerNodes))
+	for _, registerNode := range registerNodes {
+		if !p.drainedNodes.Contains(registerNode.Node.Name) {
+			candidateNodes = append(candidateNodes, registerNode)
+		}
+	}
+	return picker.PickNode(ctx, config, shardIDs, candidateNodes)
+}
+
+type schemaTableName struct {
+	schemaID  storage.SchemaID
+	tableName string
+}
+
+// partitionedTableShardGroups returns the shards holding the sub tables of every partitioned table as a group.
+// The partitioned tables are not assigned to any shard, so they are enumerated from the table manager rather than the shard views.
+func partitionedTableShardGroups(snapshot metadata.Snapshot, clusterMetadata *metadata.ClusterMetadata) [][]storage.ShardID {
+	tableShards := make(map[storage.TableID]storage.ShardID)
+	tableIDs := make([]storage.TableID, 0)
+	for shardID, shardView := range snapshot.Topology.ShardViewsMapping {
+		for _, tableID := range shardView.TableIDs {
+			tableShards[tableID] = shardID
+			tableIDs = append(tableIDs, tableID)
+		}
+	}
+
+	subTableShards := make(map[schemaTableName]storage.ShardID, len(tableIDs))
+	for _, table := range clusterMetadata.GetTablesByIDs(tableIDs) {
+		subTableShards[schemaTableName{schemaID: table.SchemaID, tableName: table.Name}] = tableShards[table.ID]
+	}
+
+	partitionedTables := clusterMetadata.GetPartitionedTables()
+	sort.Slice(partitionedTables, func(i, j int) bool {
+		return partitionedTables[i].ID < partitionedTables[j].ID
+	})
+	groups := make([][]storage.ShardID, 0)
+	for _, table := range partitionedTables {
+		group := make([]storage.ShardID, 0)
+		groupShards := make(map[storage.ShardID]struct{})
+		for _, definition := range partitionDefinitions(table.PartitionInfo.Info) {
+			subTableName := schemaTableName{schemaID: table.SchemaID, tableName: subTableNamePrefix + table.Name + "_" + definition.GetName()}
+			shardID, ok := subTableShards[subTableName]
+			if !ok {
+				continue
+			}
+			if _, ok := groupShards[shardID]; !ok {
+				groupShards[shardID] = struct{}{}
+				group = append(group, shardID)
+			}
+		}
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func partitionDefinitions(info *clusterpb.PartitionInfo) []*clusterpb.PartitionDefinition {
+	switch {
+	case info.GetHash() != nil:
+		return info.GetHash().GetDefinitions()
+	case info.GetKey() != nil:
+		return info.GetKey().GetDefinitions()
+	case info.GetRandom() != nil:
+		return info.GetRandom().GetDefinitions()
+	default:
+		return nil
+	}
+}
+
+func (m *schedulerManagerImpl) loadShardPlacementRules(ctx context.Context) error {
+	rules, err := metadata.LoadVersioned(ctx, m.clusterMetadata, storage.ShardPlacementRules{Version: 0, NodePicker: "", ShardGroups: []storage.ShardGroup{}})
+	if err != nil {
+		return err
+	}
+
+	if err := m.applyShardPlacementRules(rules); err != nil {
+		return err
+	}
+	m.logger.Info("load shard placement rules", zap.Uint64("version", rules.Version), zap.String("nodePicker", rules.NodePicker), zap.Int("numShardGroups", len(rules.ShardGroups)))
+	return nil
+}
+
+func (m *schedulerManagerImpl) applyShardPlacementRules(rules storage.ShardPlacementRules) error {
+	if err := m.nodePicker.apply(rules); err != nil {
+		return err
+	}
+
+	m.shardPlacementRules = rules
+	return nil
+}
+
+// persistShardPlacementRules persists the rules as the next version, and it fails if the rules have been updated by others, e.g. a new leader.
+func (m *schedulerManagerImpl) persistShardPlacementRules(ctx context.Context, rules storage.ShardPlacementRules) error {
+	rules.Version = m.shardPlacementRules.Version + 1
+	if err := metadata.UpdateVersioned(ctx, m.clusterMetadata, rules, m.shardPlacementRules.Version); err != nil {
+		return errors.WithMessage(err, "persist shard placement rules")
+	}
+
+	return m.applyShardPlacementRules(rules)
+}
+
+func (m *schedulerManagerImpl) GetShardPlacementRules(_ context.Context) (storage.ShardPlacementRules, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.shardPlacementRules, nil
+}
+
+func (m *schedulerManagerImpl) UpdateShardPlacementRules(ctx context.Context, nodePicker string, shardGroups []storage.ShardGroup) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// Make sure the node picker is valid before persisting it.
+	if _, err := nodepicker.NewNodePicker(m.logger, nodePicker); err != nil {
+		return err
+	}
+	if shardGroups == nil {
+		shardGroups = []storage.ShardGroup{}
+	}
+	if err := m.validateShardGroups(shardGroups); err != nil {
+		return err
+	}
+
+	rules := m.shardPlacementRules
+	rules.NodePicker = nodePicker
+	rules.ShardGroups = shardGroups
+	return m.persistShardPlacementRules(ctx, rules)
+}
+
+func (m *schedulerManagerImpl) validateShardGroups(shardGroups []storage.ShardGroup) error {
+	numTotalShards := m.clusterMetadata.GetTotalShardNum()
+	groupNames := make(map[string]struct{}, len(shardGroups))
+	for _, group := range shardGroups {
+		if len(group.Name) == 0 {
+			return ErrInvalidShardGroup.WithCausef("the name of shard group is empty")
+		}
+		if _, ok := groupNames[group.Name]; ok {
+			return ErrInvalidShardGroup.WithCausef("duplicate shard group, name:%s", group.Name)
+		}
+		groupNames[group.Name] = struct{}{}
+
+		for _, shardID := range group.ShardIDs {
+			if uint32(shardID) >= numTotalShards {
+				return ErrInvalidShardGroup.WithCausef("shard not found, name:%s, shardID:%d, numTotalShards:%d", group.Name, shardID, numTotalShards)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *schedulerManagerImpl) loadNodeWeights(ctx context.Context) error {
+	nodeWeightList, err := metadata.LoadVersioned(ctx, m.clusterMetadata, storage.NodeWeightList{Version: 0, Weights: []storage.NodeWeight{}})
+	if err != nil {
+		return err
+	}
+
+	m.applyNodeWeights(nodeWeightList)
+	m.logger.Info("load node weights", zap.Uint64("version", nodeWeightList.Version), zap.Int("numNodeWeights", len(nodeWeightList.Weights)))
+	return nil
+}
+
+func (m *schedulerManagerImpl) applyNodeWeights(nodeWeightList storage.NodeWeightList) {
+	m.nodePicker.applyNodeWeights(nodeWeightList.Weights)
+	m.nodeWeightList = nodeWeightList
+}
+
+func (m *schedulerManagerImpl) GetNodeWeights(_ context.Context) ([]storage.NodeWeight, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.nodeWeightList.Weights, nil
+}
+
+func (m *schedulerManagerImpl) UpdateNodeWeights(ctx context.Context, nodeWeights []storage.NodeWeight) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	nodeNames := make(map[string]struct{}, len(nodeWeights))
+	for _, nodeWeight := range nodeWeights {
+		if len(nodeWeight.Name) == 0 {
+			return ErrInvalidNodeWeight.WithCausef("the name of node is empty")
+		}
+		if _, ok := nodeNames[nodeWeight.Name]; ok {
+			return ErrInvalidNodeWeight.WithCausef("duplicate node, name:%s", nodeWeight.Name)
+		}
+		nodeNames[nodeWeight.Name] = struct{}{}
+
+		if nodeWeight.Weight == 0 || nodeWeight.Weight > hash.MaxMemberWeight {
+			return ErrInvalidNodeWeight.WithCausef("weight should be in [1, %d], name:%s, weight:%d", hash.MaxMemberWeight, nodeWeight.Name, nodeWeight.Weight)
+		}
+	}
+
+	sortedNodeWeights := slices.Clone(nodeWeights)
+	if sortedNodeWeights == nil {
+		sortedNodeWeights = []storage.NodeWeight{}
+	}
+	sort.Slice(sortedNodeWeights, func(i, j int) bool {
+		return sortedNodeWeights[i].Name < sortedNodeWeights[j].Name
+	})
+
+	nodeWeightList := storage.NodeWeightList{Version: m.nodeWeightList.Version + 1, Weights: sortedNodeWeights}
+	if err := metadata.UpdateVersioned(ctx, m.clusterMetadata, nodeWeightList, m.nodeWeightList.Version); err != nil {
+		return errors.WithMessage(err, "persist node weights")
+	}
+	m.applyNodeWeights(nodeWeightList)
+	m.logger.Info("update node weights", zap.String("nodeWeights", fmt.Sprintf("%+v", sortedNodeWeights)))
+	return nil
+}
+
+func (m *schedulerManagerImpl) loadReplicaSettings(ctx context.Context) error {
+	settings, err := metadata.LoadVersioned(ctx, m.clusterMetadata, storage.ReplicaSettings{Version: 0, ReplicaNum: 0})
+	if err != nil {
+		return err
+	}
+
+	m.applyReplicaSettings(settings)
+	m.logger.Info("load replica settings", zap.Uint64("version", settings.Version), zap.Uint32("replicaNum", settings.ReplicaNum))
+	return nil
+}
+
+func (m *schedulerManagerImpl) applyReplicaSettings(settings storage.ReplicaSettings) {
+	m.replicaNum.Set(settings.ReplicaNum)
+	m.replicaSettings = settings
+}
+
+func (m *schedulerManagerImpl) GetReplicaNum(_ context.Context) (uint32, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.replicaSettings.ReplicaNum, nil
+}
+
+func (m *schedulerManagerImpl) UpdateReplicaNum(ctx context.Context, replicaNum uint32) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.topologyType != storage.TopologyTypeDynamic {
+		return ErrInvalidTopologyType.WithCausef("replicas could only be updated when topology type is dynamic")
+	}
+	// Every replica of a shard needs a distinct node.
+	numNodes := uint32(len(m.clusterMetadata.GetRegisteredNodes()))
+	if replicaNum == 0 || replicaNum > numNodes {
+		return ErrInvalidReplicaNum.WithCausef("replicaNum should be in [1, %d], replicaNum:%d", numNodes, replicaNum)
+	}
+
+	settings := storage.ReplicaSettings{Version: m.replicaSettings.Version + 1, ReplicaNum: replicaNum}
+	if err := metadata.UpdateVersioned(ctx, m.clusterMetadata, settings, m.replicaSettings.Version); err != nil {
+		return errors.WithMessage(err, "persist replica settings")
+	}
+	m.applyReplicaSettings(settings)
+	m.logger.Info("update replica num", zap.Uint32("replicaNum", replicaNum))
+	return nil
+}
@@ -0,0 +1,312 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/balance"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/drain"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/load"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/rebalanced"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/reopen"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/replica"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/split"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/static"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	StaticSchedulerName       = "static_scheduler"
+	ReopenSchedulerName       = "reopen_scheduler"
+	RebalancedSchedulerName   = "rebalanced_scheduler"
+	DrainSchedulerName        = "drain_scheduler"
+	ReplicaSchedulerName      = "replica_scheduler"
+	LoadSchedulerName         = "load_scheduler"
+	SplitSchedulerName        = "split_scheduler"
+	TableBalanceSchedulerName = "table_balance_scheduler"
+)
+
+// SchedulerDeps are what the scheduler manager of a cluster provides to create the schedulers.
+type SchedulerDeps struct {
+	Logger          *zap.Logger
+	Factory         *coordinator.Factory
+	ClusterMetadata *metadata.ClusterMetadata
+	TopologyType    storage.TopologyType
+	// NodePicker picks the nodes with the shard placement rules of the cluster.
+	NodePicker nodepicker.NodePicker
+	// LeaderOverrides, DrainedNodes and ReplicaNum are shared by the schedulers of the cluster, and they are cloned in the dry run.
+	LeaderOverrides             *scheduler.LeaderOverrides
+	DrainedNodes                *scheduler.DrainedNodes
+	ReplicaNum                  *scheduler.ReplicaNum
+	ProcedureExecutingBatchSize uint32
+	// Options are the options of the optional schedulers given in the config file, and the built-in schedulers use them as the defaults
+	// of their configs.
+	Options Options
+}
+
+// SchedulerConstructor creates a scheduler with the config given in the scheduler list of a cluster, and the config is empty if it is
+// not given. The name of the created scheduler must be the one which it is registered with.
+type SchedulerConstructor func(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error)
+
+var (
+	registryLock          sync.RWMutex
+	schedulerConstructors = make(map[string]SchedulerConstructor)
+)
+
+// RegisterScheduler registers the constructor of a scheduler by name, so that the scheduler can be enabled in the scheduler list of any
+// cluster afterwards. It is usually called in the init function of the package providing the scheduler.
+func RegisterScheduler(name string, constructor SchedulerConstructor) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := schedulerConstructors[name]; ok {
+		return ErrSchedulerRegistered.WithCausef("name:%s", name)
+	}
+	schedulerConstructors[name] = constructor
+	return nil
+}
+
+// RegisteredSchedulers returns the sorted names of all the registered schedulers.
+func RegisteredSchedulers() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(schedulerConstructors))
+	for name := range schedulerConstructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getSchedulerConstructor(name string) (SchedulerConstructor, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	constructor, ok := schedulerConstructors[name]
+	return constructor, ok
+}
+
+func init() {
+	builtinSchedulers := []struct {
+		name        string
+		constructor SchedulerConstructor
+	}{
+		{StaticSchedulerName, newStaticScheduler},
+		{ReopenSchedulerName, newReopenScheduler},
+		{RebalancedSchedulerName, newRebalancedScheduler},
+		{DrainSchedulerName, newDrainScheduler},
+		{ReplicaSchedulerName, newReplicaScheduler},
+		{LoadSchedulerName, newLoadScheduler},
+		{SplitSchedulerName, newSplitScheduler},
+		{TableBalanceSchedulerName, newTableBalanceScheduler},
+	}
+	for _, builtin := range builtinSchedulers {
+		if err := RegisterScheduler(builtin.name, builtin.constructor); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// defaultSchedulerConfigs returns the schedulers run by the cluster without a scheduler list, and the optional schedulers are included if
+// they are enabled in the options.
+func defaultSchedulerConfigs(topologyType storage.TopologyType, options Options) []storage.SchedulerConfig {
+	var names []string
+	switch topologyType {
+	case storage.TopologyTypeStatic:
+		names = []string{StaticSchedulerName, ReopenSchedulerName}
+	case storage.TopologyTypeDynamic:
+		names = []string{RebalancedSchedulerName, ReopenSchedulerName, DrainSchedulerName, ReplicaSchedulerName}
+		if options.Load.Enable {
+			names = append(names, LoadSchedulerName)
+		}
+		if options.Split.Enable {
+			names = append(names, SplitSchedulerName)
+		}
+		if options.TableBalance.Enable {
+			names = append(names, TableBalanceSchedulerName)
+		}
+	}
+
+	configs := make([]storage.SchedulerConfig, 0, len(names))
+	for _, name := range names {
+		configs = append(configs, storage.SchedulerConfig{Name: name, Config: nil})
+	}
+	return configs
+}
+
+// decodeSchedulerConfig decodes the config onto the defaults held by v, so the fields not given keep the defaults.
+func decodeSchedulerConfig(config json.RawMessage, v any) error {
+	if len(config) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return errors.WithMessage(err, "decode scheduler config")
+	}
+	return nil
+}
+
+func checkTopologyType(deps SchedulerDeps, name string, topologyType storage.TopologyType) error {
+	if deps.TopologyType != topologyType {
+		return ErrInvalidTopologyType.WithCausef("scheduler only works in %s topology, name:%s, topologyType:%s", topologyType, name, deps.TopologyType)
+	}
+	return nil
+}
+
+func newStaticScheduler(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error) {
+	if err := checkTopologyType(deps, StaticSchedulerName, storage.TopologyTypeStatic); err != nil {
+		return nil, err
+	}
+	if err := decodeSchedulerConfig(config, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return static.NewShardScheduler(deps.Factory, deps.NodePicker, deps.ClusterMetadata, deps.ProcedureExecutingBatchSize), nil
+}
+
+func newReopenScheduler(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error) {
+	if err := decodeSchedulerConfig(config, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return reopen.NewShardScheduler(deps.Factory, deps.ProcedureExecutingBatchSize), nil
+}
+
+func newRebalancedScheduler(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error) {
+	if err := checkTopologyType(deps, RebalancedSchedulerName, storage.TopologyTypeDynamic); err != nil {
+		return nil, err
+	}
+	if err := decodeSchedulerConfig(config, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return rebalanced.NewShardScheduler(deps.Logger, deps.Factory, deps.NodePicker, deps.LeaderOverrides, deps.DrainedNodes, deps.ProcedureExecutingBatchSize), nil
+}
+
+func newDrainScheduler(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error) {
+	if err := checkTopologyType(deps, DrainSchedulerName, storage.TopologyTypeDynamic); err != nil {
+		return nil, err
+	}
+	if err := decodeSchedulerConfig(config, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return drain.NewShardScheduler(deps.Logger, deps.Factory, deps.NodePicker, deps.DrainedNodes, deps.ProcedureExecutingBatchSize), nil
+}
+
+func newReplicaScheduler(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error) {
+	if err := checkTopologyType(deps, ReplicaSchedulerName, storage.TopologyTypeDynamic); err != nil {
+		return nil, err
+	}
+	if err := decodeSchedulerConfig(config, &struct{}{}); err != nil {
+		return nil, err
+	}
+	// The replica scheduler only reads the replicaNum, so it is shared even in the dry run.
+	return replica.NewShardScheduler(deps.Logger, deps.Factory, deps.ReplicaNum, deps.DrainedNodes, deps.ProcedureExecutingBatchSize), nil
+}
+
+type loadSchedulerConfig struct {
+	Threshold   float64 `json:"threshold"`
+	Hysteresis  float64 `json:"hysteresis"`
+	CooldownSec int64   `json:"cooldownSec"`
+}
+
+func newLoadScheduler(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error) {
+	if err := checkTopologyType(deps, LoadSchedulerName, storage.TopologyTypeDynamic); err != nil {
+		return nil, err
+	}
+	loadConfig := loadSchedulerConfig{
+		Threshold:   deps.Options.Load.Threshold,
+		Hysteresis:  deps.Options.Load.Hysteresis,
+		CooldownSec: int64(deps.Options.Load.Cooldown / time.Second),
+	}
+	if err := decodeSchedulerConfig(config, &loadConfig); err != nil {
+		return nil, err
+	}
+	if loadConfig.Threshold < 0 || loadConfig.Hysteresis < 0 || loadConfig.CooldownSec < 0 {
+		return nil, errors.Errorf("invalid load scheduler config, threshold:%v, hysteresis:%v, cooldownSec:%d", loadConfig.Threshold, loadConfig.Hysteresis, loadConfig.CooldownSec)
+	}
+
+	return load.NewShardScheduler(deps.Logger, deps.Factory, deps.LeaderOverrides, deps.DrainedNodes, load.Options{
+		Enable:     true,
+		Threshold:  loadConfig.Threshold,
+		Hysteresis: loadConfig.Hysteresis,
+		Cooldown:   time.Duration(loadConfig.CooldownSec) * time.Second,
+	}), nil
+}
+
+type splitSchedulerConfig struct {
+	MaxTablesPerShard uint32  `json:"maxTablesPerShard"`
+	MaxShardWriteQPS  float64 `json:"maxShardWriteQPS"`
+	CooldownSec       int64   `json:"cooldownSec"`
+}
+
+func newSplitScheduler(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error) {
+	if err := checkTopologyType(deps, SplitSchedulerName, storage.TopologyTypeDynamic); err != nil {
+		return nil, err
+	}
+	splitConfig := splitSchedulerConfig{
+		MaxTablesPerShard: deps.Options.Split.MaxTablesPerShard,
+		MaxShardWriteQPS:  deps.Options.Split.MaxShardWriteQPS,
+		CooldownSec:       int64(deps.Options.Split.Cooldown / time.Second),
+	}
+	if err := decodeSchedulerConfig(config, &splitConfig); err != nil {
+		return nil, err
+	}
+	if splitConfig.MaxShardWriteQPS < 0 || splitConfig.CooldownSec < 0 {
+		return nil, errors.Errorf("invalid split scheduler config, maxShardWriteQPS:%v, cooldownSec:%d", splitConfig.MaxShardWriteQPS, splitConfig.CooldownSec)
+	}
+
+	return split.NewShardScheduler(deps.Logger, deps.Factory, deps.ClusterMetadata, deps.DrainedNodes, split.Options{
+		Enable:            true,
+		MaxTablesPerShard: splitConfig.MaxTablesPerShard,
+		MaxShardWriteQPS:  splitConfig.MaxShardWriteQPS,
+		Cooldown:          time.Duration(splitConfig.CooldownSec) * time.Second,
+	}), nil
+}
+
+type tableBalanceSchedulerConfig struct {
+	Tolerance uint32 `json:"tolerance"`
+}
+
+func newTableBalanceScheduler(deps SchedulerDeps, config json.RawMessage) (scheduler.Scheduler, error) {
+	if err := checkTopologyType(deps, TableBalanceSchedulerName, storage.TopologyTypeDynamic); err != nil {
+		return nil, err
+	}
+	balanceConfig := tableBalanceSchedulerConfig{Tolerance: deps.Options.TableBalance.Tolerance}
+	if err := decodeSchedulerConfig(config, &balanceConfig); err != nil {
+		return nil, err
+	}
+
+	return balance.NewShardScheduler(deps.Logger, deps.Factory, deps.ClusterMetadata, deps.ProcedureExecutingBatchSize, balance.Options{
+		Enable:    true,
+		Tolerance: balanceConfig.Tolerance,
+	}), nil
+}
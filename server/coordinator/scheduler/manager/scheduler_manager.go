@@ -22,20 +22,23 @@ package manager
 import (
 	"context"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/balance"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/drain"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/load"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
-	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/rebalanced"
-	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/reopen"
-	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/static"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/split"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/window"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/watch"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/pkg/errors"
@@ -47,7 +50,7 @@ const (
 	schedulerInterval = time.Second * 5
 )
 
-// SchedulerManager used to manage schedulers, it will register all schedulers when it starts.
+// SchedulerManager used to manage schedulers, it will register all schedulers in the scheduler list of the cluster when it starts.
 //
 // Each registered scheduler will generate procedures if the cluster topology matches the scheduling condition.
 type SchedulerManager interface {
@@ -59,33 +62,164 @@ type SchedulerManager interface {
 
 	// UpdateEnableSchedule can only be used in dynamic mode, it will throw error when topology type is static.
 	// when enableSchedule is true, shard topology will not be updated, it is usually used in scenarios such as cluster deploy.
+	// The value is persisted, and will be reloaded when the manager starts.
 	UpdateEnableSchedule(ctx context.Context, enable bool) error
 
 	// GetEnableSchedule can only be used in dynamic mode, it will throw error when topology type is static.
 	GetEnableSchedule(ctx context.Context) (bool, error)
 
 	// AddShardAffinityRule adds a shard affinity rule to the manager, and then apply it to the underlying schedulers.
+	// The rules are persisted, and will be reloaded when the manager starts.
 	AddShardAffinityRule(ctx context.Context, rule scheduler.ShardAffinityRule) error
 
-	// Remove the shard rules applied to some specific rule.
+	// Remove the shard rules applied to some specific rule, including both the affinity and the anti-affinity of the shard.
 	RemoveShardAffinityRule(ctx context.Context, shardID storage.ShardID) error
 
 	// ListShardAffinityRules lists all the rules about shard affinity of all the registered schedulers.
 	ListShardAffinityRules(ctx context.Context) (map[string]scheduler.ShardAffinityRule, error)
 
+	// GetShardPlacementRules returns the rules deciding how the shards are placed onto the nodes.
+	GetShardPlacementRules(ctx context.Context) (storage.ShardPlacementRules, error)
+
+	// UpdateShardPlacementRules persists the node picker and the shard groups, and the schedulers pick nodes with them afterwards.
+	UpdateShardPlacementRules(ctx context.Context, nodePicker string, shardGroups []storage.ShardGroup) error
+
+	// GetNodeWeights returns the weights of the nodes, and the nodes not included have the default weight.
+	GetNodeWeights(ctx context.Context) ([]storage.NodeWeight, error)
+
+	// UpdateNodeWeights replaces the weights of the nodes, and the nodes not included get the default weight.
+	// The share of shards of a node is proportional to its weight.
+	UpdateNodeWeights(ctx context.Context, nodeWeights []storage.NodeWeight) error
+
+	// GetReplicaNum returns the number of the replicas of every shard including the leader, and zero means that it is never set.
+	GetReplicaNum(ctx context.Context) (uint32, error)
+
+	// UpdateReplicaNum sets the number of the replicas of every shard including the leader, and the followers are opened or closed
+	// by the replica scheduler afterwards. It can only be used in dynamic mode.
+	UpdateReplicaNum(ctx context.Context, replicaNum uint32) error
+
+	// ExpandShards adds new shards to the stable cluster and places them onto the nodes picked by the node picker, and the new shards are
+	// either created empty or split from the existing shards with the most tables. It can only be used in dynamic mode.
+	ExpandShards(ctx context.Context, req ExpandShardsRequest) (ExpandShardsResult, error)
+
+	// DrainNode excludes the node from the shard placement, and the shards on it will be moved to other nodes in batches.
+	// It can only be used in dynamic mode.
+	DrainNode(ctx context.Context, nodeName string) (drain.Progress, error)
+
+	// UndrainNode makes the drained node available for the shard placement again.
+	UndrainNode(ctx context.Context, nodeName string) error
+
+	// GetDrainProgress returns how many shards are still on the drained node.
+	GetDrainProgress(ctx context.Context, nodeName string) (drain.Progress, error)
+
+	// GetSchedulerSettings returns the enableSchedule and whether each registered scheduler is enabled.
+	GetSchedulerSettings(ctx context.Context) (SchedulerSettings, error)
+
+	// UpdateSchedulerSettings persists the given settings, and the ones not given are kept unchanged.
+	UpdateSchedulerSettings(ctx context.Context, req UpdateSchedulerSettingsRequest) (SchedulerSettings, error)
+
+	// GetSchedulerList returns the ordered list of the schedulers run by the cluster with their configs, and the names of all the
+	// registered schedulers which can be put into the list.
+	GetSchedulerList(ctx context.Context) (SchedulerList, error)
+
+	// UpdateSchedulerList replaces the scheduler list of the cluster, and the schedulers are recreated with the new list, so the states
+	// kept across rounds by the schedulers, e.g. the cooldown of the moved shards, are reset.
+	// The default schedulers of the topology type are restored if the list is empty.
+	UpdateSchedulerList(ctx context.Context, configs []storage.SchedulerConfig) (SchedulerList, error)
+
+	// GetMaintenanceWindows returns the maintenance windows of the cluster, and whether the schedulers changing the topology can run now.
+	GetMaintenanceWindows(ctx context.Context) (MaintenanceWindows, error)
+
+	// UpdateMaintenanceWindows replaces the maintenance windows, and the schedulers changing the topology can only run when any window
+	// is open afterwards, while the corrective ones, e.g. the reopen scheduler, are not restricted.
+	// The schedulers can run at any time if the windows are empty.
+	UpdateMaintenanceWindows(ctx context.Context, windows []storage.MaintenanceWindow) error
+
+	// DryRun runs a fresh copy of every enabled scheduler against the current cluster snapshot modified by the request, and returns
+	// the procedures which would be generated without submitting them.
+	// The schedulers run as if the shard topology is not locked, and the states kept across rounds, e.g. the cooldown of the moved
+	// shards, are not considered.
+	DryRun(ctx context.Context, req DryRunRequest) ([]DryRunResult, error)
+
+	// ListScheduleAuditRecords returns the recorded procedures submitted by the schedulers matching the filter.
+	ListScheduleAuditRecords(ctx context.Context, filter ScheduleAuditFilter) ([]storage.ScheduleAuditRecord, error)
+
 	// Scheduler will be called when received new heartbeat, every scheduler registered in schedulerManager will be called to generate procedures.
 	// Scheduler cloud be schedule with fix time interval or heartbeat.
 	Scheduler(ctx context.Context, clusterSnapshot metadata.Snapshot) []scheduler.ScheduleResult
 }
 
+type SchedulerSettings struct {
+	EnableSchedule bool `json:"enableSchedule"`
+	// Schedulers maps the name of every registered scheduler to whether it is enabled.
+	Schedulers map[string]bool `json:"schedulers"`
+}
+
+type UpdateSchedulerSettingsRequest struct {
+	// EnableSchedule is kept unchanged if it is nil, and it can only be set in dynamic mode.
+	EnableSchedule *bool
+	// Schedulers enables or disables the schedulers by name, and the schedulers not mentioned are kept unchanged.
+	Schedulers map[string]bool
+}
+
+type SchedulerList struct {
+	Schedulers []storage.SchedulerConfig `json:"schedulers"`
+	// IsDefault means that no list is given for the cluster, and the default schedulers of the topology type are run.
+	IsDefault  bool     `json:"isDefault"`
+	Registered []string `json:"registered"`
+}
+
+type ExpandShardsRequest struct {
+	NumShards uint32
+	// SplitTables moves half of the tables of the largest schema in an existing shard onto each new shard, and every existing shard is
+	// split at most once. The new shards are created empty if it is false or no shard has enough tables to split.
+	SplitTables bool
+}
+
+type ExpandShardsResult struct {
+	ShardIDs    []storage.ShardID `json:"shardIDs"`
+	ProcedureID uint64            `json:"procedureID"`
+}
+
+// DryRunRequest describes the hypothetical changes applied to the current cluster snapshot.
+type DryRunRequest struct {
+	AddNodes    []storage.Node
+	RemoveNodes []string
+	// ShardAffinities replaces the current shard affinity rules if it is not nil.
+	ShardAffinities []scheduler.ShardAffinity
+}
+
+type DryRunResult struct {
+	Scheduler string            `json:"scheduler"`
+	Kind      procedure.Kind    `json:"kind"`
+	ShardIDs  []storage.ShardID `json:"shardIDs"`
+	Reason    string            `json:"reason"`
+}
+
+// Options is used to configure the optional schedulers.
+type Options struct {
+	Load         load.Options
+	Split        split.Options
+	TableBalance balance.Options
+	// AuditCapacity is the max number of the submitted procedures kept in the audit log, zero means nothing is recorded.
+	AuditCapacity uint64
+}
+
 type schedulerManagerImpl struct {
 	logger           *zap.Logger
 	procedureManager procedure.Manager
 	factory          *coordinator.Factory
-	nodePicker       nodepicker.NodePicker
+	nodePicker       *placementNodePicker
 	client           *clientv3.Client
 	clusterMetadata  *metadata.ClusterMetadata
 	rootPath         string
+	options          Options
+	// leaderOverrides is shared by the schedulers, so the shards moved by the load scheduler won't be moved back by the rebalanced scheduler.
+	leaderOverrides *scheduler.LeaderOverrides
+	drainedNodes    *scheduler.DrainedNodes
+	drainTracker    *drain.Tracker
+	replicaNum      *scheduler.ReplicaNum
+	auditLog        *scheduleAuditLog
 
 	// This lock is used to protect the following field.
 	lock                        sync.RWMutex
@@ -95,10 +229,21 @@ type schedulerManagerImpl struct {
 	topologyType                storage.TopologyType
 	procedureExecutingBatchSize uint32
 	enableSchedule              bool
-	shardAffinities             map[storage.ShardID]scheduler.ShardAffinityRule
+	shardAffinities             map[storage.ShardID]scheduler.ShardAffinity
+	shardAntiAffinities         map[storage.ShardID]scheduler.ShardAntiAffinity
+	// shardAffinitiesVersion is the version of the persisted rules which shardAffinities and shardAntiAffinities are consistent with.
+	shardAffinitiesVersion uint64
+	shardPlacementRules    storage.ShardPlacementRules
+	nodeWeightList         storage.NodeWeightList
+	replicaSettings        storage.ReplicaSettings
+	schedulerSettings      storage.SchedulerSettings
+	schedulerList          storage.SchedulerList
+	maintenanceWindowList  storage.MaintenanceWindowList
+	// maintenanceWindows are parsed from the maintenanceWindowList.
+	maintenanceWindows []*window.Window
 }
 
-func NewManager(logger *zap.Logger, procedureManager procedure.Manager, factory *coordinator.Factory, clusterMetadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath string, topologyType storage.TopologyType, procedureExecutingBatchSize uint32) SchedulerManager {
+func NewManager(logger *zap.Logger, procedureManager procedure.Manager, factory *coordinator.Factory, clusterMetadata *metadata.ClusterMetadata, client *clientv3.Client, rootPath string, topologyType storage.TopologyType, procedureExecutingBatchSize uint32, options Options) SchedulerManager {
 	var shardWatch watch.ShardWatch
 	switch topologyType {
 	case storage.TopologyTypeDynamic:
@@ -108,14 +253,23 @@ func NewManager(logger *zap.Logger, procedureManager procedure.Manager, factory
 		shardWatch = watch.NewNoopShardWatch()
 	}
 
+	drainedNodes := scheduler.NewDrainedNodes()
+	auditLog := newScheduleAuditLog(logger, clusterMetadata, options.AuditCapacity)
+	procedureManager.AddArchiveListener(auditLog.complete)
 	return &schedulerManagerImpl{
 		logger:                      logger,
 		procedureManager:            procedureManager,
 		factory:                     factory,
-		nodePicker:                  nodepicker.NewConsistentUniformHashNodePicker(logger),
+		nodePicker:                  newPlacementNodePicker(logger, clusterMetadata, drainedNodes),
 		client:                      client,
 		clusterMetadata:             clusterMetadata,
 		rootPath:                    rootPath,
+		options:                     options,
+		leaderOverrides:             scheduler.NewLeaderOverrides(),
+		drainedNodes:                drainedNodes,
+		drainTracker:                drain.NewTracker(logger, clusterMetadata, drainedNodes),
+		replicaNum:                  scheduler.NewReplicaNum(),
+		auditLog:                    auditLog,
 		lock:                        sync.RWMutex{},
 		registerSchedulers:          []scheduler.Scheduler{},
 		shardWatch:                  shardWatch,
@@ -123,7 +277,16 @@ func NewManager(logger *zap.Logger, procedureManager procedure.Manager, factory
 		topologyType:                topologyType,
 		procedureExecutingBatchSize: procedureExecutingBatchSize,
 		enableSchedule:              false,
-		shardAffinities:             make(map[storage.ShardID]scheduler.ShardAffinityRule),
+		shardAffinities:             make(map[storage.ShardID]scheduler.ShardAffinity),
+		shardAntiAffinities:         make(map[storage.ShardID]scheduler.ShardAntiAffinity),
+		shardAffinitiesVersion:      0,
+		shardPlacementRules:         storage.ShardPlacementRules{Version: 0, NodePicker: "", ShardGroups: []storage.ShardGroup{}},
+		nodeWeightList:              storage.NodeWeightList{Version: 0, Weights: []storage.NodeWeight{}},
+		replicaSettings:             storage.ReplicaSettings{Version: 0, ReplicaNum: 0},
+		schedulerSettings:           storage.SchedulerSettings{Version: 0, EnableSchedule: false, DisabledSchedulers: []string{}},
+		schedulerList:               storage.SchedulerList{Version: 0, Schedulers: []storage.SchedulerConfig{}},
+		maintenanceWindowList:       storage.MaintenanceWindowList{Version: 0, Windows: []storage.MaintenanceWindow{}},
+		maintenanceWindows:          []*window.Window{},
 	}
 }
 
@@ -150,7 +313,20 @@ func (m *schedulerManagerImpl) Start(ctx context.Context) error {
 		return nil
 	}
 
-	m.initRegister()
+	// The setting which fails to be loaded keeps the current value, which is the default at the first start, so that a broken setting
+	// won't stop the scheduling, just like the scheduler list which fails to be created.
+	m.loadOrKeep(ctx, "shard placement rules", m.loadShardPlacementRules)
+	m.loadOrKeep(ctx, "drained nodes", m.drainTracker.Load)
+	m.loadOrKeep(ctx, "node weights", m.loadNodeWeights)
+	m.loadOrKeep(ctx, "replica settings", m.loadReplicaSettings)
+	m.loadOrKeep(ctx, "scheduler settings", m.loadSchedulerSettings)
+	m.loadOrKeep(ctx, "maintenance windows", m.loadMaintenanceWindows)
+	m.loadOrKeep(ctx, "scheduler list", m.loadSchedulerList)
+
+	m.initRegister(ctx)
+
+	m.loadOrKeep(ctx, "shard affinity rules", m.loadShardAffinityRules)
+	m.loadOrKeep(ctx, "schedule audit log", m.auditLog.load)
 
 	if err := m.shardWatch.Start(ctx); err != nil {
 		return errors.WithMessage(err, "start shard watch failed")
@@ -181,9 +357,16 @@ func (m *schedulerManagerImpl) Start(ctx context.Context) error {
 			for _, result := range results {
 				if result.Procedure != nil {
 					m.logger.Info("scheduler submit new procedure", zap.Uint64("ProcedureID", result.Procedure.ID()), zap.String("Reason", result.Reason))
-					if err := m.procedureManager.Submit(ctx, result.Procedure); err != nil {
+					m.auditLog.prepare(result)
+					// The procedure regenerated for the same work will be dropped by the procedure manager.
+					procedureID, err := m.procedureManager.Submit(ctx, result.Procedure)
+					if err != nil {
 						m.logger.Error("scheduler submit new procedure failed", zap.Uint64("ProcedureID", result.Procedure.ID()), zap.Error(err))
 					}
+					if err == nil && procedureID == result.Procedure.ID() && result.OnSubmitted != nil {
+						result.OnSubmitted()
+					}
+					m.auditLog.record(ctx, result, procedureID, err)
 				}
 			}
 		}
@@ -213,33 +396,85 @@ func (callback *schedulerWatchCallback) OnShardExpired(ctx context.Context, even
 }
 
 // Schedulers should to be initialized and registered here.
-func (m *schedulerManagerImpl) initRegister() {
-	var schedulers []scheduler.Scheduler
-	switch m.topologyType {
-	case storage.TopologyTypeDynamic:
-		schedulers = m.createDynamicTopologySchedulers()
-	case storage.TopologyTypeStatic:
-		schedulers = m.createStaticTopologySchedulers()
+//
+// The schedulers are created from the scheduler list of the cluster, and the default schedulers of the topology type are created instead
+// if the list can't be created, so that a broken list won't stop the cluster from being scheduled.
+func (m *schedulerManagerImpl) loadOrKeep(ctx context.Context, name string, load func(ctx context.Context) error) {
+	if err := load(ctx); err != nil {
+		m.logger.Error("failed to load the setting, keep the current one", zap.String("name", name), zap.Error(err))
+	}
+}
+
+func (m *schedulerManagerImpl) initRegister(ctx context.Context) {
+	schedulers, err := m.createSchedulers(m.factory, m.schedulerConfigs(), m.leaderOverrides, m.drainedNodes)
+	if err != nil {
+		m.logger.Error("failed to create the schedulers of the scheduler list, fall back to the default schedulers", zap.Error(err))
+		schedulers, err = m.createSchedulers(m.factory, defaultSchedulerConfigs(m.topologyType, m.options), m.leaderOverrides, m.drainedNodes)
+		if err != nil {
+			m.logger.Error("failed to create the default schedulers", zap.Error(err))
+		}
 	}
+	m.registerSchedulers = m.registerSchedulers[:0]
 	for i := 0; i < len(schedulers); i++ {
-		m.registerScheduler(schedulers[i])
+		m.registerScheduler(ctx, schedulers[i])
 	}
 }
 
-func (m *schedulerManagerImpl) createStaticTopologySchedulers() []scheduler.Scheduler {
-	staticTopologyShardScheduler := static.NewShardScheduler(m.factory, m.nodePicker, m.procedureExecutingBatchSize)
-	reopenShardScheduler := reopen.NewShardScheduler(m.factory, m.procedureExecutingBatchSize)
-	return []scheduler.Scheduler{staticTopologyShardScheduler, reopenShardScheduler}
+// schedulerConfigs returns the scheduler list of the cluster, and the default one of the topology type if no list is given.
+func (m *schedulerManagerImpl) schedulerConfigs() []storage.SchedulerConfig {
+	if len(m.schedulerList.Schedulers) > 0 {
+		return m.schedulerList.Schedulers
+	}
+	return defaultSchedulerConfigs(m.topologyType, m.options)
 }
 
-func (m *schedulerManagerImpl) createDynamicTopologySchedulers() []scheduler.Scheduler {
-	rebalancedShardScheduler := rebalanced.NewShardScheduler(m.logger, m.factory, m.nodePicker, m.procedureExecutingBatchSize)
-	reopenShardScheduler := reopen.NewShardScheduler(m.factory, m.procedureExecutingBatchSize)
-	return []scheduler.Scheduler{rebalancedShardScheduler, reopenShardScheduler}
+// createSchedulers creates the schedulers in the list with the registered constructors, and the schedulers share the leaderOverrides
+// and the drainedNodes.
+func (m *schedulerManagerImpl) createSchedulers(factory *coordinator.Factory, configs []storage.SchedulerConfig, leaderOverrides *scheduler.LeaderOverrides, drainedNodes *scheduler.DrainedNodes) ([]scheduler.Scheduler, error) {
+	deps := SchedulerDeps{
+		Logger:                      m.logger,
+		Factory:                     factory,
+		ClusterMetadata:             m.clusterMetadata,
+		TopologyType:                m.topologyType,
+		NodePicker:                  m.nodePicker,
+		LeaderOverrides:             leaderOverrides,
+		DrainedNodes:                drainedNodes,
+		ReplicaNum:                  m.replicaNum,
+		ProcedureExecutingBatchSize: m.procedureExecutingBatchSize,
+		Options:                     m.options,
+	}
+
+	schedulers := make([]scheduler.Scheduler, 0, len(configs))
+	for i, config := range configs {
+		constructor, ok := getSchedulerConstructor(config.Name)
+		if !ok {
+			return nil, ErrUnknownScheduler.WithCausef("scheduler is not registered, name:%s", config.Name)
+		}
+		duplicated := slices.ContainsFunc(configs[:i], func(other storage.SchedulerConfig) bool {
+			return other.Name == config.Name
+		})
+		if duplicated {
+			return nil, ErrInvalidSchedulerConfig.WithCausef("scheduler is duplicated, name:%s", config.Name)
+		}
+
+		scheduler, err := constructor(deps, config.Config)
+		if err != nil {
+			return nil, ErrInvalidSchedulerConfig.WithCausef("create scheduler, name:%s, err:%v", config.Name, err)
+		}
+		if scheduler.Name() != config.Name {
+			return nil, ErrInvalidSchedulerConfig.WithCausef("scheduler is registered with another name, name:%s, registeredName:%s", scheduler.Name(), config.Name)
+		}
+		schedulers = append(schedulers, scheduler)
+	}
+	return schedulers, nil
 }
 
-func (m *schedulerManagerImpl) registerScheduler(scheduler scheduler.Scheduler) {
+func (m *schedulerManagerImpl) registerScheduler(ctx context.Context, scheduler scheduler.Scheduler) {
 	m.logger.Info("register new scheduler", zap.String("schedulerName", reflect.TypeOf(scheduler).String()), zap.Int("totalSchedulerLen", len(m.registerSchedulers)))
+	// The topology of the static mode is never locked.
+	if m.topologyType == storage.TopologyTypeDynamic {
+		scheduler.UpdateEnableSchedule(ctx, m.enableSchedule)
+	}
 	m.registerSchedulers = append(m.registerSchedulers, scheduler)
 }
 
@@ -251,14 +486,19 @@ func (m *schedulerManagerImpl) ListScheduler() []scheduler.Scheduler {
 }
 
 func (m *schedulerManagerImpl) Scheduler(ctx context.Context, clusterSnapshot metadata.Snapshot) []scheduler.ScheduleResult {
+	m.lock.RLock()
+	schedulers := m.runnableSchedulers(m.enabledSchedulers(m.registerSchedulers), time.Now())
+	m.lock.RUnlock()
+
 	// TODO: Every scheduler should run in an independent goroutine.
-	results := make([]scheduler.ScheduleResult, 0, len(m.registerSchedulers))
-	for _, scheduler := range m.registerSchedulers {
+	results := make([]scheduler.ScheduleResult, 0, len(schedulers))
+	for _, scheduler := range schedulers {
 		result, err := scheduler.Schedule(ctx, clusterSnapshot)
 		if err != nil {
 			m.logger.Error("scheduler failed", zap.Error(err))
 			continue
 		}
+		result.Scheduler = scheduler.Name()
 		results = append(results, result)
 	}
 	return results
@@ -272,12 +512,9 @@ func (m *schedulerManagerImpl) UpdateEnableSchedule(ctx context.Context, enable
 		return ErrInvalidTopologyType.WithCausef("deploy mode could only update when topology type is dynamic")
 	}
 
-	m.enableSchedule = enable
-	for _, scheduler := range m.registerSchedulers {
-		scheduler.UpdateEnableSchedule(ctx, enable)
-	}
-
-	return nil
+	settings := m.schedulerSettings
+	settings.EnableSchedule = enable
+	return m.persistSchedulerSettings(ctx, settings)
 }
 
 func (m *schedulerManagerImpl) GetEnableSchedule(_ context.Context) (bool, error) {
@@ -291,43 +528,334 @@ func (m *schedulerManagerImpl) GetEnableSchedule(_ context.Context) (bool, error
 	return m.enableSchedule, nil
 }
 
-func (m *schedulerManagerImpl) AddShardAffinityRule(ctx context.Context, rule scheduler.ShardAffinityRule) error {
-	var lastErr error
-	for _, scheduler := range m.registerSchedulers {
-		if err := scheduler.AddShardAffinityRule(ctx, rule); err != nil {
-			log.Error("failed to add shard affinity rule of a scheduler", zap.String("scheduler", scheduler.Name()), zap.Error(err))
-			lastErr = err
+// loadSchedulerSettings loads the persisted settings, and they are applied to the schedulers registered afterwards.
+func (m *schedulerManagerImpl) loadSchedulerSettings(ctx context.Context) error {
+	settings, err := metadata.LoadVersioned(ctx, m.clusterMetadata, storage.SchedulerSettings{Version: 0, EnableSchedule: false, DisabledSchedulers: []string{}})
+	if err != nil {
+		return err
+	}
+
+	m.applySchedulerSettings(ctx, settings)
+	m.logger.Info("load scheduler settings", zap.Uint64("version", settings.Version), zap.Bool("enableSchedule", settings.EnableSchedule), zap.Strings("disabledSchedulers", settings.DisabledSchedulers))
+	return nil
+}
+
+func (m *schedulerManagerImpl) applySchedulerSettings(ctx context.Context, settings storage.SchedulerSettings) {
+	// The topology of the static mode is never locked.
+	if m.topologyType == storage.TopologyTypeDynamic {
+		m.enableSchedule = settings.EnableSchedule
+		for _, scheduler := range m.registerSchedulers {
+			scheduler.UpdateEnableSchedule(ctx, settings.EnableSchedule)
+		}
+	}
+	m.schedulerSettings = settings
+}
+
+// persistSchedulerSettings persists the settings as the next version, and it fails if the settings have been updated by others, e.g. a new leader.
+func (m *schedulerManagerImpl) persistSchedulerSettings(ctx context.Context, settings storage.SchedulerSettings) error {
+	settings.Version = m.schedulerSettings.Version + 1
+	if err := metadata.UpdateVersioned(ctx, m.clusterMetadata, settings, m.schedulerSettings.Version); err != nil {
+		return errors.WithMessage(err, "persist scheduler settings")
+	}
+
+	m.applySchedulerSettings(ctx, settings)
+	return nil
+}
+
+// enabledSchedulers filters out the schedulers disabled by the settings.
+func (m *schedulerManagerImpl) enabledSchedulers(schedulers []scheduler.Scheduler) []scheduler.Scheduler {
+	if len(m.schedulerSettings.DisabledSchedulers) == 0 {
+		return schedulers
+	}
+
+	enabled := make([]scheduler.Scheduler, 0, len(schedulers))
+	for _, scheduler := range schedulers {
+		if !slices.Contains(m.schedulerSettings.DisabledSchedulers, scheduler.Name()) {
+			enabled = append(enabled, scheduler)
 		}
 	}
+	return enabled
+}
+
+func (m *schedulerManagerImpl) GetSchedulerSettings(_ context.Context) (SchedulerSettings, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
 
-	return lastErr
+	return m.getSchedulerSettings(), nil
 }
 
-func (m *schedulerManagerImpl) RemoveShardAffinityRule(ctx context.Context, shardID storage.ShardID) error {
-	var lastErr error
+func (m *schedulerManagerImpl) getSchedulerSettings() SchedulerSettings {
+	schedulers := make(map[string]bool, len(m.registerSchedulers))
 	for _, scheduler := range m.registerSchedulers {
-		if err := scheduler.RemoveShardAffinityRule(ctx, shardID); err != nil {
-			log.Error("failed to remove shard affinity rule of a scheduler", zap.String("scheduler", scheduler.Name()), zap.Error(err))
-			lastErr = err
+		schedulers[scheduler.Name()] = !slices.Contains(m.schedulerSettings.DisabledSchedulers, scheduler.Name())
+	}
+	return SchedulerSettings{
+		EnableSchedule: m.enableSchedule,
+		Schedulers:     schedulers,
+	}
+}
+
+func (m *schedulerManagerImpl) UpdateSchedulerSettings(ctx context.Context, req UpdateSchedulerSettingsRequest) (SchedulerSettings, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	settings := storage.SchedulerSettings{
+		Version:            m.schedulerSettings.Version,
+		EnableSchedule:     m.schedulerSettings.EnableSchedule,
+		DisabledSchedulers: slices.Clone(m.schedulerSettings.DisabledSchedulers),
+	}
+	if req.EnableSchedule != nil {
+		if m.topologyType != storage.TopologyTypeDynamic {
+			return SchedulerSettings{}, ErrInvalidTopologyType.WithCausef("enableSchedule could only update when topology type is dynamic")
+		}
+		settings.EnableSchedule = *req.EnableSchedule
+	}
+
+	for name, enable := range req.Schedulers {
+		registered := slices.ContainsFunc(m.registerSchedulers, func(scheduler scheduler.Scheduler) bool {
+			return scheduler.Name() == name
+		})
+		if !registered {
+			return SchedulerSettings{}, ErrUnknownScheduler.WithCausef("scheduler is not registered, name:%s", name)
 		}
+
+		settings.DisabledSchedulers = slices.DeleteFunc(settings.DisabledSchedulers, func(disabled string) bool {
+			return disabled == name
+		})
+		if !enable {
+			settings.DisabledSchedulers = append(settings.DisabledSchedulers, name)
+		}
+	}
+	slices.Sort(settings.DisabledSchedulers)
+
+	if err := m.persistSchedulerSettings(ctx, settings); err != nil {
+		return SchedulerSettings{}, err
 	}
 
-	return lastErr
+	m.logger.Info("update scheduler settings", zap.Bool("enableSchedule", settings.EnableSchedule), zap.Strings("disabledSchedulers", settings.DisabledSchedulers))
+	return m.getSchedulerSettings(), nil
 }
 
-func (m *schedulerManagerImpl) ListShardAffinityRules(ctx context.Context) (map[string]scheduler.ShardAffinityRule, error) {
-	rules := make(map[string]scheduler.ShardAffinityRule, len(m.registerSchedulers))
-	var lastErr error
+// loadSchedulerList loads the persisted scheduler list, and the schedulers in the list are registered afterwards.
+func (m *schedulerManagerImpl) loadSchedulerList(ctx context.Context) error {
+	schedulerList, err := metadata.LoadVersioned(ctx, m.clusterMetadata, storage.SchedulerList{Version: 0, Schedulers: []storage.SchedulerConfig{}})
+	if err != nil {
+		return err
+	}
 
-	for _, scheduler := range m.registerSchedulers {
-		rule, err := scheduler.ListShardAffinityRule(ctx)
+	m.schedulerList = schedulerList
+	m.logger.Info("load scheduler list", zap.Uint64("version", schedulerList.Version), zap.Int("numSchedulers", len(schedulerList.Schedulers)))
+	return nil
+}
+
+func (m *schedulerManagerImpl) GetSchedulerList(_ context.Context) (SchedulerList, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.getSchedulerList(), nil
+}
+
+func (m *schedulerManagerImpl) getSchedulerList() SchedulerList {
+	return SchedulerList{
+		Schedulers: slices.Clone(m.schedulerConfigs()),
+		IsDefault:  len(m.schedulerList.Schedulers) == 0,
+		Registered: RegisteredSchedulers(),
+	}
+}
+
+func (m *schedulerManagerImpl) UpdateSchedulerList(ctx context.Context, configs []storage.SchedulerConfig) (SchedulerList, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// Make sure all the schedulers can be created before persisting the list.
+	createConfigs := configs
+	if len(configs) == 0 {
+		createConfigs = defaultSchedulerConfigs(m.topologyType, m.options)
+	}
+	schedulers, err := m.createSchedulers(m.factory, createConfigs, m.leaderOverrides, m.drainedNodes)
+	if err != nil {
+		return SchedulerList{}, err
+	}
+
+	schedulerList := storage.SchedulerList{Version: m.schedulerList.Version + 1, Schedulers: slices.Clone(configs)}
+	if schedulerList.Schedulers == nil {
+		schedulerList.Schedulers = []storage.SchedulerConfig{}
+	}
+	if err := metadata.UpdateVersioned(ctx, m.clusterMetadata, schedulerList, m.schedulerList.Version); err != nil {
+		return SchedulerList{}, errors.WithMessage(err, "persist scheduler list")
+	}
+	m.schedulerList = schedulerList
+
+	m.registerSchedulers = m.registerSchedulers[:0]
+	for _, scheduler := range schedulers {
+		m.registerScheduler(ctx, scheduler)
+	}
+	m.applyShardAffinityRules(ctx, m.registerSchedulers)
+
+	names := make([]string, 0, len(createConfigs))
+	for _, config := range createConfigs {
+		names = append(names, config.Name)
+	}
+	m.logger.Info("update scheduler list", zap.Strings("schedulers", names), zap.Bool("isDefault", len(configs) == 0))
+	return m.getSchedulerList(), nil
+}
+
+func (m *schedulerManagerImpl) ExpandShards(ctx context.Context, req ExpandShardsRequest) (ExpandShardsResult, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// The placement of the new shards is learned from the heartbeats, which are ignored by the stable static cluster.
+	if m.topologyType != storage.TopologyTypeDynamic {
+		return ExpandShardsResult{}, ErrInvalidTopologyType.WithCausef("shards could only be expanded when topology type is dynamic")
+	}
+	if req.NumShards == 0 {
+		return ExpandShardsResult{}, ErrExpandShards.WithCausef("numShards should be positive")
+	}
+	snapshot := m.clusterMetadata.GetClusterSnapshot()
+	if snapshot.Topology.ClusterView.State != storage.ClusterStateStable {
+		return ExpandShardsResult{}, ErrExpandShards.WithCausef("cluster is not stable, state:%v", snapshot.Topology.ClusterView.State)
+	}
+	now := time.Now()
+	aliveNodes := make([]metadata.RegisteredNode, 0, len(snapshot.RegisteredNodes))
+	for _, registeredNode := range snapshot.RegisteredNodes {
+		if !registeredNode.IsExpired(now) && !m.drainedNodes.Contains(registeredNode.Node.Name) {
+			aliveNodes = append(aliveNodes, registeredNode)
+		}
+	}
+	if len(aliveNodes) == 0 {
+		return ExpandShardsResult{}, ErrExpandShards.WithCausef("no alive node can hold the new shards")
+	}
+
+	shardIDs, err := m.clusterMetadata.ExpandShardTotal(ctx, req.NumShards)
+	if err != nil {
+		return ExpandShardsResult{}, errors.WithMessage(err, "expand shard total")
+	}
+	m.logger.Info("shard total is expanded", zap.Uint32("numShards", req.NumShards), zap.String("shardIDs", fmt.Sprintf("%v", shardIDs)))
+
+	// The shard ids may not be contiguous because of the dropped shards, and the node picker requires every id to be less than the total.
+	numTotalShards := max(m.clusterMetadata.GetTotalShardNum(), snapshot.Topology.ShardIDBound())
+	for _, shardID := range shardIDs {
+		numTotalShards = max(numTotalShards, uint32(shardID)+1)
+	}
+	pickConfig := nodepicker.Config{
+		NumTotalShards:        numTotalShards,
+		ShardAffinityRule:     maps.Clone(m.shardAffinities),
+		ShardAntiAffinityRule: maps.Clone(m.shardAntiAffinities),
+	}
+	shardNodeMapping, err := m.nodePicker.PickNode(ctx, pickConfig, shardIDs, aliveNodes)
+	if err != nil {
+		return ExpandShardsResult{}, errors.WithMessage(err, "pick nodes for the new shards")
+	}
+
+	shardNodes := make(map[storage.ShardID]string, len(shardIDs))
+	for _, shardID := range shardIDs {
+		shardNodes[shardID] = shardNodeMapping[shardID].Node.Name
+	}
+	batchProcedure, err := m.factory.CreateExpandShardsProcedure(ctx, coordinator.ExpandShardsRequest{
+		ClusterMetadata: m.clusterMetadata,
+		Snapshot:        snapshot,
+		NewShardIDs:     shardIDs,
+		ShardNodes:      shardNodes,
+		SplitTables:     req.SplitTables,
+	})
+	if err != nil {
+		return ExpandShardsResult{}, errors.WithMessage(err, "create procedure for the new shards")
+	}
+	procedureID, err := m.procedureManager.Submit(ctx, batchProcedure)
+	if err != nil {
+		return ExpandShardsResult{}, errors.WithMessage(err, "submit procedure")
+	}
+	m.logger.Info("new shards are submitted to be placed", zap.Uint64("procedureID", procedureID))
+
+	return ExpandShardsResult{ShardIDs: shardIDs, ProcedureID: procedureID}, nil
+}
+
+func (m *schedulerManagerImpl) DrainNode(ctx context.Context, nodeName string) (drain.Progress, error) {
+	if m.topologyType != storage.TopologyTypeDynamic {
+		return drain.Progress{}, ErrInvalidTopologyType.WithCausef("node could only be drained when topology type is dynamic")
+	}
+
+	return m.drainTracker.Drain(ctx, nodeName)
+}
+
+func (m *schedulerManagerImpl) UndrainNode(ctx context.Context, nodeName string) error {
+	return m.drainTracker.Undrain(ctx, nodeName)
+}
+
+func (m *schedulerManagerImpl) GetDrainProgress(_ context.Context, nodeName string) (drain.Progress, error) {
+	return m.drainTracker.Progress(nodeName), nil
+}
+
+func (m *schedulerManagerImpl) DryRun(ctx context.Context, req DryRunRequest) ([]DryRunResult, error) {
+	m.lock.RLock()
+	affinities := req.ShardAffinities
+	if affinities == nil {
+		affinities = sortedShardAffinities(m.shardAffinities)
+	}
+	antiAffinities := sortedShardAntiAffinities(m.shardAntiAffinities)
+	// The shared states are cloned and the procedure ids are not allocated, so the dry run won't affect the registered schedulers.
+	schedulers, err := m.createSchedulers(m.factory.DryRunFactory(), m.schedulerConfigs(), m.leaderOverrides.Clone(), m.drainedNodes.Clone())
+	if err != nil {
+		m.lock.RUnlock()
+		return nil, err
+	}
+	schedulers = m.enabledSchedulers(schedulers)
+	m.lock.RUnlock()
+
+	if (len(affinities) > 0 || len(antiAffinities) > 0) && m.topologyType == storage.TopologyTypeDynamic {
+		rule := scheduler.ShardAffinityRule{Affinities: affinities, AntiAffinities: antiAffinities}
+		for _, scheduler := range schedulers {
+			if err := scheduler.AddShardAffinityRule(ctx, rule); err != nil {
+				return nil, errors.WithMessagef(err, "apply shard affinity rule to scheduler, scheduler:%s", scheduler.Name())
+			}
+		}
+	}
+
+	snapshot := buildDryRunSnapshot(m.clusterMetadata.GetClusterSnapshot(), req)
+	results := make([]DryRunResult, 0, len(schedulers))
+	for _, scheduler := range schedulers {
+		result, err := scheduler.Schedule(ctx, snapshot)
 		if err != nil {
-			log.Error("failed to list shard affinity rule of a scheduler", zap.String("scheduler", scheduler.Name()), zap.Error(err))
-			lastErr = err
+			return nil, errors.WithMessagef(err, "dry run scheduler, scheduler:%s", scheduler.Name())
+		}
+		if result.Procedure == nil {
+			continue
 		}
 
-		rules[scheduler.Name()] = rule
+		results = append(results, DryRunResult{
+			Scheduler: scheduler.Name(),
+			Kind:      result.Procedure.Kind(),
+			ShardIDs:  relatedShardIDs(result.Procedure),
+			Reason:    result.Reason,
+		})
+	}
+	return results, nil
+}
+
+// buildDryRunSnapshot applies the node changes of the request to the snapshot, and the shards on the removed nodes are kept in the
+// cluster view as if the nodes are down.
+func buildDryRunSnapshot(snapshot metadata.Snapshot, req DryRunRequest) metadata.Snapshot {
+	removedNodes := make(map[string]struct{}, len(req.RemoveNodes))
+	for _, nodeName := range req.RemoveNodes {
+		removedNodes[nodeName] = struct{}{}
 	}
 
-	return rules, lastErr
+	registeredNodes := make([]metadata.RegisteredNode, 0, len(snapshot.RegisteredNodes)+len(req.AddNodes))
+	for _, registeredNode := range snapshot.RegisteredNodes {
+		if _, removed := removedNodes[registeredNode.Node.Name]; !removed {
+			registeredNodes = append(registeredNodes, registeredNode)
+		}
+	}
+	for _, node := range req.AddNodes {
+		registeredNodes = append(registeredNodes, metadata.NewRegisteredNode(node, []metadata.ShardInfo{}))
+	}
+
+	return metadata.Snapshot{
+		Topology:        snapshot.Topology,
+		RegisteredNodes: registeredNodes,
+	}
+}
+
+func (m *schedulerManagerImpl) ListScheduleAuditRecords(ctx context.Context, filter ScheduleAuditFilter) ([]storage.ScheduleAuditRecord, error) {
+	return m.auditLog.list(ctx, filter)
 }
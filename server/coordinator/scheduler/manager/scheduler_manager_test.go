@@ -21,41 +21,94 @@ package manager_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/apache/incubator-horaedb-meta/server/cluster"
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/reopen"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
 	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 )
 
-func TestSchedulerManager(t *testing.T) {
+// countingIDAllocator is the MockIDAllocator which counts the allocated ids.
+type countingIDAllocator struct {
+	test.MockIDAllocator
+	count *atomic.Int64
+}
+
+func (a countingIDAllocator) Alloc(ctx context.Context) (uint64, error) {
+	a.count.Add(1)
+	return a.MockIDAllocator.Alloc(ctx)
+}
+
+// schedulerManagerDeps holds the dependencies of the scheduler managers in a test, and the managers created with them share the cluster and
+// the storage, like the ones restarted or running on another leader.
+type schedulerManagerDeps struct {
+	cluster          *cluster.Cluster
+	allocator        countingIDAllocator
+	procedureManager procedure.Manager
+	factory          *coordinator.Factory
+	client           *clientv3.Client
+}
+
+// newTestSchedulerManager creates the scheduler manager of the dynamic topology with the default options on a stable cluster.
+func newTestSchedulerManager(t *testing.T) (manager.SchedulerManager, schedulerManagerDeps) {
 	ctx := context.Background()
 	re := require.New(t)
 
-	// Init dependencies for scheduler manager.
 	c := test.InitStableCluster(ctx, t)
-	procedureManager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata())
-	re.NoError(err)
 	dispatch := test.MockDispatch{}
-	allocator := test.MockIDAllocator{}
+	allocator := countingIDAllocator{MockIDAllocator: test.MockIDAllocator{}, count: &atomic.Int64{}}
 	s := test.NewTestStorage(t)
 	f := coordinator.NewFactory(zap.NewNop(), allocator, dispatch, s, c.GetMetadata())
+	procedureManager, err := procedure.NewManagerImpl(zap.NewNop(), c.GetMetadata(), s, f, procedure.ManagerOptions{})
+	re.NoError(err)
 	_, client, _ := etcdutil.PrepareEtcdServerAndClient(t)
 
+	deps := schedulerManagerDeps{
+		cluster:          c,
+		allocator:        allocator,
+		procedureManager: procedureManager,
+		factory:          f,
+		client:           client,
+	}
+	return deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{}), deps
+}
+
+func (d schedulerManagerDeps) newSchedulerManager(topologyType storage.TopologyType, options manager.Options) manager.SchedulerManager {
+	return manager.NewManager(zap.NewNop(), d.procedureManager, d.factory, d.cluster.GetMetadata(), d.client, "/rootPath", topologyType, 1, options)
+}
+
+func TestSchedulerManager(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	// Init dependencies for scheduler manager.
+	_, deps := newTestSchedulerManager(t)
+
 	// Create scheduler manager with enableScheduler equal to false.
-	schedulerManager := manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeStatic, 1)
-	err = schedulerManager.Start(ctx)
+	schedulerManager := deps.newSchedulerManager(storage.TopologyTypeStatic, manager.Options{})
+	err := schedulerManager.Start(ctx)
 	re.NoError(err)
 	err = schedulerManager.Stop(ctx)
 	re.NoError(err)
 
 	// Create scheduler manager with static topology.
-	schedulerManager = manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeStatic, 1)
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeStatic, manager.Options{})
 	err = schedulerManager.Start(ctx)
 	re.NoError(err)
 	schedulers := schedulerManager.ListScheduler()
@@ -64,11 +117,584 @@ func TestSchedulerManager(t *testing.T) {
 	re.NoError(err)
 
 	// Create scheduler manager with dynamic topology.
-	schedulerManager = manager.NewManager(zap.NewNop(), procedureManager, f, c.GetMetadata(), client, "/rootPath", storage.TopologyTypeDynamic, 1)
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
 	err = schedulerManager.Start(ctx)
 	re.NoError(err)
 	schedulers = schedulerManager.ListScheduler()
-	re.Equal(2, len(schedulers))
+	re.Equal(4, len(schedulers))
 	err = schedulerManager.Stop(ctx)
 	re.NoError(err)
 }
+
+func TestSchedulerManagerShardAffinity(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+	re.NoError(schedulerManager.Start(ctx))
+	// The manager of a stale leader, which loads the rules before they are updated by the new leader.
+	staleSchedulerManager := deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
+	re.NoError(staleSchedulerManager.Start(ctx))
+
+	affinity := scheduler.ShardAffinity{ShardID: 0, NumAllowedOtherShards: 1}
+	re.NoError(schedulerManager.AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{Affinities: []scheduler.ShardAffinity{affinity}}))
+	re.NoError(schedulerManager.Stop(ctx))
+
+	// The update of the stale leader should be rejected.
+	re.Error(staleSchedulerManager.AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{Affinities: []scheduler.ShardAffinity{{ShardID: 1, NumAllowedOtherShards: 0}}}))
+	re.NoError(staleSchedulerManager.Stop(ctx))
+
+	// The rules should be reloaded after restart.
+	re.NoError(schedulerManager.Start(ctx))
+	rules, err := schedulerManager.ListShardAffinityRules(ctx)
+	re.NoError(err)
+	re.Equal([]scheduler.ShardAffinity{affinity}, rules["rebalanced_scheduler"].Affinities)
+
+	re.NoError(schedulerManager.RemoveShardAffinityRule(ctx, 0))
+	re.NoError(schedulerManager.Stop(ctx))
+	re.NoError(schedulerManager.Start(ctx))
+	rules, err = schedulerManager.ListShardAffinityRules(ctx)
+	re.NoError(err)
+	re.Empty(rules["rebalanced_scheduler"].Affinities)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerShardAntiAffinity(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, _ := newTestSchedulerManager(t)
+	re.NoError(schedulerManager.Start(ctx))
+
+	antiAffinity := scheduler.ShardAntiAffinity{ShardID: 0, AntiAffinityShardIDs: []storage.ShardID{1}}
+	re.NoError(schedulerManager.AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{AntiAffinities: []scheduler.ShardAntiAffinity{antiAffinity}}))
+
+	// The invalid rules should be rejected.
+	err := schedulerManager.AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{AntiAffinities: []scheduler.ShardAntiAffinity{{ShardID: 2, AntiAffinityShardIDs: []storage.ShardID{2}}}})
+	re.ErrorContains(err, "invalid shard affinity")
+	err = schedulerManager.AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{AntiAffinities: []scheduler.ShardAntiAffinity{{ShardID: 2, AntiAffinityShardIDs: []storage.ShardID{test.DefaultShardTotal}}}})
+	re.ErrorContains(err, "invalid shard affinity")
+	// Three shards can't be placed on two nodes without sharing.
+	err = schedulerManager.AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{AntiAffinities: []scheduler.ShardAntiAffinity{{ShardID: 2, AntiAffinityShardIDs: []storage.ShardID{0, 1}}}})
+	re.ErrorContains(err, "unsatisfiable shard affinity")
+
+	// The rules should be reloaded after restart.
+	re.NoError(schedulerManager.Stop(ctx))
+	re.NoError(schedulerManager.Start(ctx))
+	rules, err := schedulerManager.ListShardAffinityRules(ctx)
+	re.NoError(err)
+	re.Equal([]scheduler.ShardAntiAffinity{antiAffinity}, rules["rebalanced_scheduler"].AntiAffinities)
+
+	re.NoError(schedulerManager.RemoveShardAffinityRule(ctx, 0))
+	rules, err = schedulerManager.ListShardAffinityRules(ctx)
+	re.NoError(err)
+	re.Empty(rules["rebalanced_scheduler"].AntiAffinities)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerShardPlacement(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+	re.NoError(schedulerManager.Start(ctx))
+	rules, err := schedulerManager.GetShardPlacementRules(ctx)
+	re.NoError(err)
+	re.Empty(rules.NodePicker)
+
+	// Invalid node picker and shard groups should be rejected.
+	re.Error(schedulerManager.UpdateShardPlacementRules(ctx, "unknown", nil))
+	re.Error(schedulerManager.UpdateShardPlacementRules(ctx, nodepicker.ZoneAwareNodePickerName, []storage.ShardGroup{{Name: "group0", ShardIDs: []storage.ShardID{0, 100}}}))
+	re.Error(schedulerManager.UpdateShardPlacementRules(ctx, nodepicker.ZoneAwareNodePickerName, []storage.ShardGroup{{Name: "", ShardIDs: []storage.ShardID{0, 1}}}))
+
+	groups := []storage.ShardGroup{{Name: "group0", ShardIDs: []storage.ShardID{0, 1}}}
+	re.NoError(schedulerManager.UpdateShardPlacementRules(ctx, nodepicker.ZoneAwareNodePickerName, groups))
+	re.NoError(schedulerManager.Stop(ctx))
+
+	// The rules should be reloaded after restart.
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
+	re.NoError(schedulerManager.Start(ctx))
+	rules, err = schedulerManager.GetShardPlacementRules(ctx)
+	re.NoError(err)
+	re.Equal(uint64(1), rules.Version)
+	re.Equal(nodepicker.ZoneAwareNodePickerName, rules.NodePicker)
+	re.Equal(groups, rules.ShardGroups)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerNodeWeights(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+	re.NoError(schedulerManager.Start(ctx))
+	nodeWeights, err := schedulerManager.GetNodeWeights(ctx)
+	re.NoError(err)
+	re.Empty(nodeWeights)
+
+	// Invalid node weights should be rejected.
+	re.Error(schedulerManager.UpdateNodeWeights(ctx, []storage.NodeWeight{{Name: "node0", Weight: 0}}))
+	re.Error(schedulerManager.UpdateNodeWeights(ctx, []storage.NodeWeight{{Name: "node0", Weight: 200}, {Name: "node0", Weight: 100}}))
+	nodeWeights = []storage.NodeWeight{{Name: "node0", Weight: 200}, {Name: "node1", Weight: 50}}
+	re.NoError(schedulerManager.UpdateNodeWeights(ctx, []storage.NodeWeight{nodeWeights[1], nodeWeights[0]}))
+	re.NoError(schedulerManager.Stop(ctx))
+
+	// The node weights should be reloaded after restart, and they are persisted separately without bumping the version of the placement rules.
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
+	re.NoError(schedulerManager.Start(ctx))
+	reloadedNodeWeights, err := schedulerManager.GetNodeWeights(ctx)
+	re.NoError(err)
+	re.Equal(nodeWeights, reloadedNodeWeights)
+	rules, err := schedulerManager.GetShardPlacementRules(ctx)
+	re.NoError(err)
+	re.Equal(uint64(0), rules.Version)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerReplicaNum(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+	re.NoError(schedulerManager.Start(ctx))
+	replicaNum, err := schedulerManager.GetReplicaNum(ctx)
+	re.NoError(err)
+	re.Equal(uint32(0), replicaNum)
+
+	// The replicas of a shard should be on distinct nodes.
+	re.Error(schedulerManager.UpdateReplicaNum(ctx, 0))
+	re.Error(schedulerManager.UpdateReplicaNum(ctx, 3))
+	re.NoError(schedulerManager.UpdateReplicaNum(ctx, 2))
+	re.NoError(schedulerManager.Stop(ctx))
+
+	// The replicas should be reloaded after restart, and they are persisted separately without bumping the version of the placement rules.
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
+	re.NoError(schedulerManager.Start(ctx))
+	replicaNum, err = schedulerManager.GetReplicaNum(ctx)
+	re.NoError(err)
+	re.Equal(uint32(2), replicaNum)
+	rules, err := schedulerManager.GetShardPlacementRules(ctx)
+	re.NoError(err)
+	re.Equal(uint64(0), rules.Version)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerPartitionedTableShardGroups(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	c := test.InitStableCluster(ctx, t)
+	partitionInfo := &clusterpb.PartitionInfo{
+		Info: &clusterpb.PartitionInfo_Hash{Hash: &clusterpb.HashPartitionInfo{
+			Definitions: []*clusterpb.PartitionDefinition{{Name: "0"}, {Name: "1"}},
+		}},
+	}
+	_, err := c.GetMetadata().CreateTableMetadata(ctx, metadata.CreateTableMetadataRequest{
+		SchemaName:    test.TestSchemaName,
+		TableName:     "partitioned",
+		PartitionInfo: storage.PartitionInfo{Info: partitionInfo},
+	})
+	re.NoError(err)
+
+	// The sub tables are created on distinct shards, while the partitioned table is not assigned to any shard.
+	for i, subTableName := range []string{"__partitioned_0", "__partitioned_1"} {
+		shardID := storage.ShardID(i)
+		_, err = c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+			ShardID:       shardID,
+			LatestVersion: c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping[shardID].Version,
+			SchemaName:    test.TestSchemaName,
+			TableName:     subTableName,
+			PartitionInfo: storage.PartitionInfo{Info: nil},
+		})
+		re.NoError(err)
+	}
+
+	re.Equal([][]storage.ShardID{{0, 1}}, manager.PartitionedTableShardGroups(c.GetMetadata()))
+}
+
+func TestSchedulerManagerSettings(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+	re.NoError(schedulerManager.Start(ctx))
+	settings, err := schedulerManager.GetSchedulerSettings(ctx)
+	re.NoError(err)
+	re.False(settings.EnableSchedule)
+	re.Equal(map[string]bool{"rebalanced_scheduler": true, "reopen_scheduler": true, "drain_scheduler": true, "replica_scheduler": true}, settings.Schedulers)
+
+	// Unknown scheduler should be rejected.
+	_, err = schedulerManager.UpdateSchedulerSettings(ctx, manager.UpdateSchedulerSettingsRequest{Schedulers: map[string]bool{"unknown": false}})
+	re.Error(err)
+
+	re.NoError(schedulerManager.UpdateEnableSchedule(ctx, true))
+	settings, err = schedulerManager.UpdateSchedulerSettings(ctx, manager.UpdateSchedulerSettingsRequest{Schedulers: map[string]bool{"reopen_scheduler": false}})
+	re.NoError(err)
+	re.True(settings.EnableSchedule)
+	re.False(settings.Schedulers["reopen_scheduler"])
+	re.True(settings.Schedulers["rebalanced_scheduler"])
+	// Only the enabled schedulers are called.
+	re.Len(schedulerManager.Scheduler(ctx, deps.cluster.GetMetadata().GetClusterSnapshot()), 3)
+	re.NoError(schedulerManager.Stop(ctx))
+
+	// The settings should be reloaded after restart.
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
+	re.NoError(schedulerManager.Start(ctx))
+	enableSchedule, err := schedulerManager.GetEnableSchedule(ctx)
+	re.NoError(err)
+	re.True(enableSchedule)
+	settings, err = schedulerManager.GetSchedulerSettings(ctx)
+	re.NoError(err)
+	re.False(settings.Schedulers["reopen_scheduler"])
+
+	enable := false
+	settings, err = schedulerManager.UpdateSchedulerSettings(ctx, manager.UpdateSchedulerSettingsRequest{EnableSchedule: &enable, Schedulers: map[string]bool{"reopen_scheduler": true}})
+	re.NoError(err)
+	re.False(settings.EnableSchedule)
+	re.True(settings.Schedulers["reopen_scheduler"])
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+// renamedScheduler is a custom scheduler reusing the reopen scheduler under another name.
+type renamedScheduler struct {
+	scheduler.Scheduler
+	name string
+}
+
+func (s renamedScheduler) Name() string {
+	return s.name
+}
+
+func init() {
+	err := manager.RegisterScheduler("custom_scheduler", func(deps manager.SchedulerDeps, _ json.RawMessage) (scheduler.Scheduler, error) {
+		return renamedScheduler{Scheduler: reopen.NewShardScheduler(deps.Factory, deps.ProcedureExecutingBatchSize), name: "custom_scheduler"}, nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestSchedulerManagerSchedulerList(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+
+	schedulerNames := func(schedulers []scheduler.Scheduler) []string {
+		names := make([]string, 0, len(schedulers))
+		for _, scheduler := range schedulers {
+			names = append(names, scheduler.Name())
+		}
+		return names
+	}
+
+	re.NoError(schedulerManager.Start(ctx))
+	list, err := schedulerManager.GetSchedulerList(ctx)
+	re.NoError(err)
+	re.True(list.IsDefault)
+	re.Equal([]storage.SchedulerConfig{{Name: "rebalanced_scheduler"}, {Name: "reopen_scheduler"}, {Name: "drain_scheduler"}, {Name: "replica_scheduler"}}, list.Schedulers)
+	re.Contains(list.Registered, "custom_scheduler")
+	re.Contains(list.Registered, "table_balance_scheduler")
+
+	// The invalid lists should be rejected.
+	invalidLists := [][]storage.SchedulerConfig{
+		{{Name: "unknown"}},
+		{{Name: "reopen_scheduler"}, {Name: "reopen_scheduler"}},
+		// The static scheduler only works in static topology.
+		{{Name: "static_scheduler"}},
+		{{Name: "load_scheduler", Config: json.RawMessage(`{"unknownField":1}`)}},
+		{{Name: "load_scheduler", Config: json.RawMessage(`{"threshold":-1}`)}},
+	}
+	for _, invalidList := range invalidLists {
+		_, err = schedulerManager.UpdateSchedulerList(ctx, invalidList)
+		re.Error(err)
+	}
+	re.Equal([]string{"rebalanced_scheduler", "reopen_scheduler", "drain_scheduler", "replica_scheduler"}, schedulerNames(schedulerManager.ListScheduler()))
+
+	re.NoError(schedulerManager.AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{Affinities: []scheduler.ShardAffinity{{ShardID: 0, NumAllowedOtherShards: 1}}}))
+	configs := []storage.SchedulerConfig{
+		{Name: "custom_scheduler"},
+		{Name: "load_scheduler", Config: json.RawMessage(`{"threshold":0.5,"cooldownSec":60}`)},
+		{Name: "rebalanced_scheduler"},
+	}
+	list, err = schedulerManager.UpdateSchedulerList(ctx, configs)
+	re.NoError(err)
+	re.False(list.IsDefault)
+	re.Equal(configs, list.Schedulers)
+	re.Equal([]string{"custom_scheduler", "load_scheduler", "rebalanced_scheduler"}, schedulerNames(schedulerManager.ListScheduler()))
+	// The shard affinity rules should be applied to the recreated schedulers.
+	rules, err := schedulerManager.ListShardAffinityRules(ctx)
+	re.NoError(err)
+	re.Len(rules["rebalanced_scheduler"].Affinities, 1)
+	re.NoError(schedulerManager.Stop(ctx))
+
+	// The list should be reloaded after restart.
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
+	re.NoError(schedulerManager.Start(ctx))
+	re.Equal([]string{"custom_scheduler", "load_scheduler", "rebalanced_scheduler"}, schedulerNames(schedulerManager.ListScheduler()))
+
+	// The default schedulers should be restored with an empty list.
+	list, err = schedulerManager.UpdateSchedulerList(ctx, nil)
+	re.NoError(err)
+	re.True(list.IsDefault)
+	re.Equal([]string{"rebalanced_scheduler", "reopen_scheduler", "drain_scheduler", "replica_scheduler"}, schedulerNames(schedulerManager.ListScheduler()))
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerStartWithLoadFailures(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	_, deps := newTestSchedulerManager(t)
+
+	// None of the settings can be loaded with the cancelled ctx, and the manager is started with the default ones.
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	schedulerManager := deps.newSchedulerManager(storage.TopologyTypeStatic, manager.Options{})
+	re.NoError(schedulerManager.Start(cancelledCtx))
+	schedulers := schedulerManager.ListScheduler()
+	re.Len(schedulers, 2)
+	re.Equal("static_scheduler", schedulers[0].Name())
+	re.Equal("reopen_scheduler", schedulers[1].Name())
+	nodeWeights, err := schedulerManager.GetNodeWeights(ctx)
+	re.NoError(err)
+	re.Empty(nodeWeights)
+	windows, err := schedulerManager.GetMaintenanceWindows(ctx)
+	re.NoError(err)
+	re.True(windows.Open)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerMaintenanceWindows(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+	re.NoError(schedulerManager.Start(ctx))
+	windows, err := schedulerManager.GetMaintenanceWindows(ctx)
+	re.NoError(err)
+	re.Empty(windows.Windows)
+	re.True(windows.Open)
+
+	re.Error(schedulerManager.UpdateMaintenanceWindows(ctx, []storage.MaintenanceWindow{{Name: "invalid", Cron: "* 24 * * *", TimeZone: ""}}))
+
+	// Only the reopen scheduler and the corrective work of the rebalanced and replica schedulers can run outside the maintenance window.
+	closedHour := (time.Now().UTC().Hour() + 12) % 24
+	closedWindows := []storage.MaintenanceWindow{{Name: "night", Cron: fmt.Sprintf("* %d * * *", closedHour), TimeZone: "UTC"}}
+	re.NoError(schedulerManager.UpdateMaintenanceWindows(ctx, closedWindows))
+	windows, err = schedulerManager.GetMaintenanceWindows(ctx)
+	re.NoError(err)
+	re.False(windows.Open)
+	results := schedulerManager.Scheduler(ctx, deps.cluster.GetMetadata().GetClusterSnapshot())
+	re.Len(results, 3)
+	re.Equal("rebalanced_scheduler", results[0].Scheduler)
+	re.Nil(results[0].Procedure)
+	re.Equal("reopen_scheduler", results[1].Scheduler)
+	re.Equal("replica_scheduler", results[2].Scheduler)
+	re.Nil(results[2].Procedure)
+
+	// The shards on the expired node are still moved to the alive node.
+	snapshot := deps.cluster.GetMetadata().GetClusterSnapshot()
+	expiredNodeName := snapshot.Topology.ClusterView.ShardNodes[0].NodeName
+	for i := range snapshot.RegisteredNodes {
+		if snapshot.RegisteredNodes[i].Node.Name == expiredNodeName {
+			snapshot.RegisteredNodes[i].Node.LastTouchTime = uint64(time.Now().Add(-time.Hour).UnixMilli())
+		}
+	}
+	results = schedulerManager.Scheduler(ctx, snapshot)
+	re.Len(results, 3)
+	re.NotNil(results[0].Procedure)
+	re.Contains(results[0].Reason, fmt.Sprintf("oldNode:%s", expiredNodeName))
+
+	// Updating the other settings should keep the windows.
+	_, err = schedulerManager.UpdateSchedulerSettings(ctx, manager.UpdateSchedulerSettingsRequest{Schedulers: map[string]bool{"drain_scheduler": true}})
+	re.NoError(err)
+	re.NoError(schedulerManager.Stop(ctx))
+
+	// The windows should be reloaded after restart.
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
+	re.NoError(schedulerManager.Start(ctx))
+	windows, err = schedulerManager.GetMaintenanceWindows(ctx)
+	re.NoError(err)
+	re.Equal(closedWindows, windows.Windows)
+	re.False(windows.Open)
+
+	re.NoError(schedulerManager.UpdateMaintenanceWindows(ctx, []storage.MaintenanceWindow{{Name: "always", Cron: "* * * * *", TimeZone: ""}}))
+	re.Len(schedulerManager.Scheduler(ctx, deps.cluster.GetMetadata().GetClusterSnapshot()), 4)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerDrainNode(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+
+	// Node can't be drained in static topology.
+	staticSchedulerManager := deps.newSchedulerManager(storage.TopologyTypeStatic, manager.Options{})
+	re.NoError(staticSchedulerManager.Start(ctx))
+	_, err := staticSchedulerManager.DrainNode(ctx, "node0")
+	re.Error(err)
+	re.NoError(staticSchedulerManager.Stop(ctx))
+
+	re.NoError(schedulerManager.Start(ctx))
+	_, err = schedulerManager.DrainNode(ctx, "unknown")
+	re.Error(err)
+
+	progress, err := schedulerManager.DrainNode(ctx, "node0")
+	re.NoError(err)
+	re.True(progress.Drained)
+	re.Equal(progress.NumShards, len(progress.RemainingShardIDs))
+	re.Equal(progress.NumShards == 0, progress.Finished)
+
+	// The last node can't be drained, otherwise no node can take over the shards.
+	_, err = schedulerManager.DrainNode(ctx, "node1")
+	re.Error(err)
+	re.NoError(schedulerManager.Stop(ctx))
+
+	// The drained node should be reloaded after restart.
+	schedulerManager = deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{})
+	re.NoError(schedulerManager.Start(ctx))
+	progress, err = schedulerManager.GetDrainProgress(ctx, "node0")
+	re.NoError(err)
+	re.True(progress.Drained)
+
+	re.NoError(schedulerManager.UndrainNode(ctx, "node0"))
+	progress, err = schedulerManager.GetDrainProgress(ctx, "node0")
+	re.NoError(err)
+	re.False(progress.Drained)
+	re.False(progress.Finished)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerExpandShards(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+
+	// Shards can't be expanded in static topology.
+	staticSchedulerManager := deps.newSchedulerManager(storage.TopologyTypeStatic, manager.Options{})
+	re.NoError(staticSchedulerManager.Start(ctx))
+	_, err := staticSchedulerManager.ExpandShards(ctx, manager.ExpandShardsRequest{NumShards: 1, SplitTables: false})
+	re.Error(err)
+	re.NoError(staticSchedulerManager.Stop(ctx))
+
+	re.NoError(schedulerManager.Start(ctx))
+	_, err = schedulerManager.ExpandShards(ctx, manager.ExpandShardsRequest{NumShards: 0, SplitTables: false})
+	re.Error(err)
+
+	// Shards can't be expanded before the cluster is stable.
+	snapshot := deps.cluster.GetMetadata().GetClusterSnapshot()
+	re.NoError(deps.cluster.GetMetadata().UpdateClusterView(ctx, storage.ClusterStatePrepare, snapshot.Topology.ClusterView.ShardNodes))
+	_, err = schedulerManager.ExpandShards(ctx, manager.ExpandShardsRequest{NumShards: 1, SplitTables: false})
+	re.Error(err)
+	re.Equal(uint32(test.DefaultShardTotal), deps.cluster.GetMetadata().GetTotalShardNum())
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerScheduleAudit(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	_, deps := newTestSchedulerManager(t)
+	schedulerManager := deps.newSchedulerManager(storage.TopologyTypeDynamic, manager.Options{AuditCapacity: 10})
+	re.NoError(schedulerManager.Start(ctx))
+	records, err := schedulerManager.ListScheduleAuditRecords(ctx, manager.ScheduleAuditFilter{})
+	re.NoError(err)
+	re.Empty(records)
+
+	// The shards on the drained node will be moved by the drain scheduler, and the submitted procedure should be recorded.
+	shardNode := deps.cluster.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes[0]
+	_, err = schedulerManager.DrainNode(ctx, shardNode.NodeName)
+	re.NoError(err)
+	re.Eventually(func() bool {
+		records, err = schedulerManager.ListScheduleAuditRecords(ctx, manager.ScheduleAuditFilter{NodeName: shardNode.NodeName, Limit: 1})
+		return err == nil && len(records) == 1
+	}, time.Second*15, time.Millisecond*100)
+	re.Equal("drain_scheduler", records[0].Scheduler)
+	re.Equal(storage.ScheduleAuditOutcomeSubmitted, records[0].Outcome)
+	procedureID := records[0].ProcedureID
+	re.NotEmpty(records[0].Reason)
+	re.Contains(records[0].NodeNames, shardNode.NodeName)
+	re.Len(records[0].ShardIDs, 1)
+
+	records, err = schedulerManager.ListScheduleAuditRecords(ctx, manager.ScheduleAuditFilter{ShardID: &records[0].ShardIDs[0]})
+	re.NoError(err)
+	re.Len(records, 1)
+
+	records, err = schedulerManager.ListScheduleAuditRecords(ctx, manager.ScheduleAuditFilter{NodeName: "unknown"})
+	re.NoError(err)
+	re.Empty(records)
+
+	// The record is updated with the final state once the procedure is completed.
+	re.NoError(deps.procedureManager.Start(ctx))
+	re.Eventually(func() bool {
+		records, err = schedulerManager.ListScheduleAuditRecords(ctx, manager.ScheduleAuditFilter{NodeName: shardNode.NodeName})
+		if err != nil {
+			return false
+		}
+		for _, record := range records {
+			if record.ProcedureID == procedureID {
+				return record.Outcome == storage.ScheduleAuditOutcomeFinished
+			}
+		}
+		return false
+	}, time.Second*15, time.Millisecond*100)
+	re.NoError(schedulerManager.Stop(ctx))
+}
+
+func TestSchedulerManagerDryRun(t *testing.T) {
+	ctx := context.Background()
+	re := require.New(t)
+
+	schedulerManager, deps := newTestSchedulerManager(t)
+	re.NoError(schedulerManager.Start(ctx))
+	defer func() {
+		re.NoError(schedulerManager.Stop(ctx))
+	}()
+
+	nodeShards := make(map[string][]storage.ShardID)
+	for _, shardNode := range deps.cluster.GetMetadata().GetClusterSnapshot().Topology.ClusterView.ShardNodes {
+		nodeShards[shardNode.NodeName] = append(nodeShards[shardNode.NodeName], shardNode.ID)
+	}
+	removedNode := "node0"
+	if len(nodeShards[removedNode]) == 0 {
+		removedNode = "node1"
+	}
+
+	// The shards on the removed node should be moved to the remaining node.
+	numAllocatedIDs := deps.allocator.count.Load()
+	results, err := schedulerManager.DryRun(ctx, manager.DryRunRequest{
+		AddNodes:        nil,
+		RemoveNodes:     []string{removedNode},
+		ShardAffinities: nil,
+	})
+	re.NoError(err)
+	re.NotEmpty(results)
+	re.Equal("rebalanced_scheduler", results[0].Scheduler)
+	re.Equal(procedure.TransferLeader, results[0].Kind)
+	re.Len(results[0].ShardIDs, 1)
+	re.Contains(nodeShards[removedNode], results[0].ShardIDs[0])
+
+	// Nothing should be submitted or changed by the dry run, and no procedure id is consumed.
+	re.Equal(numAllocatedIDs, deps.allocator.count.Load())
+	procedures, err := deps.procedureManager.ListRunningProcedure(ctx)
+	re.NoError(err)
+	re.Empty(procedures)
+	rules, err := schedulerManager.ListShardAffinityRules(ctx)
+	re.NoError(err)
+	re.Empty(rules["rebalanced_scheduler"].Affinities)
+
+	_, err = schedulerManager.DryRun(ctx, manager.DryRunRequest{
+		AddNodes: []storage.Node{{
+			Name:          "node2",
+			NodeStats:     storage.NewEmptyNodeStats(),
+			LastTouchTime: uint64(time.Now().UnixMilli()),
+			State:         storage.NodeStateOnline,
+		}},
+		RemoveNodes:     nil,
+		ShardAffinities: []scheduler.ShardAffinity{{ShardID: 0, NumAllowedOtherShards: 0}},
+	})
+	re.NoError(err)
+}
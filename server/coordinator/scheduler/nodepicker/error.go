@@ -21,4 +21,7 @@ package nodepicker
 
 import "github.com/apache/incubator-horaedb-meta/pkg/coderr"
 
-var ErrNoAliveNodes = coderr.NewCodeError(coderr.InvalidParams, "no alive nodes is found")
+var (
+	ErrNoAliveNodes      = coderr.NewCodeError(coderr.InvalidParams, "no alive nodes is found")
+	ErrUnknownNodePicker = coderr.NewCodeError(coderr.InvalidParams, "unknown node picker")
+)
@@ -62,12 +62,26 @@ var (
 
 	// ErrEmptyMembers will be thrown if no member is provided.
 	ErrEmptyMembers = errors.New("at least one member is required")
+
+	// ErrInvalidAntiAffinity will be thrown if the anti-affinity refers to the partition itself or an unknown partition.
+	ErrInvalidAntiAffinity = errors.New("invalid partition anti-affinity")
+
+	// ErrUnsatisfiableAntiAffinity will be thrown if some partitions can't be moved away from the partitions they conflict with.
+	ErrUnsatisfiableAntiAffinity = errors.New("partition anti-affinity can't be satisfied")
 )
 
 // hashSeparator is used to building the virtual node name for member.
 // With this special separator, it will be hard to generate duplicate virtual node names.
 const hashSeparator = "@$"
 
+const (
+	// DefaultMemberWeight is the weight of the member whose weight is not specified, so a member with weight 200 is expected to hold
+	// twice as many partitions as the member with the default weight.
+	DefaultMemberWeight uint32 = 100
+	// MaxMemberWeight limits the weight to avoid creating too many virtual nodes for a member.
+	MaxMemberWeight uint32 = 100 * DefaultMemberWeight
+)
+
 type Hasher interface {
 	Sum64([]byte) uint64
 }
@@ -82,6 +96,12 @@ type PartitionAffinity struct {
 	NumAllowedOtherPartitions uint
 }
 
+// PartitionAntiAffinity describes the partitions which must not be allocated to the same member as the partition.
+type PartitionAntiAffinity struct {
+	PartitionID              int
+	AntiAffinityPartitionIDs []int
+}
+
 // Config represents a structure to control consistent package.
 type Config struct {
 	// Hasher is responsible for generating unsigned, 64 bit hash of provided byte slice.
@@ -94,6 +114,26 @@ type Config struct {
 
 	// The rule describes the partition affinity.
 	PartitionAffinities []PartitionAffinity
+
+	// The rule describes the partition anti-affinity, and it is ensured after the partition affinity.
+	PartitionAntiAffinities []PartitionAntiAffinity
+
+	// MemberWeights scales the share of partitions of the members, and DefaultMemberWeight is used for the members not included.
+	MemberWeights map[string]uint32
+}
+
+func (c *Config) memberWeight(mem string) uint32 {
+	weight, ok := c.MemberWeights[mem]
+	if !ok || weight == 0 {
+		return DefaultMemberWeight
+	}
+	return min(weight, MaxMemberWeight)
+}
+
+// numVirtualNodes returns the number of the virtual nodes of the member which is proportional to its weight. The virtual nodes of
+// the smaller weight are a prefix of the ones of the larger weight, so few partitions are moved when the weight changes.
+func (c *Config) numVirtualNodes(mem string) int {
+	return max(1, c.ReplicationFactor*int(c.memberWeight(mem))/int(DefaultMemberWeight))
 }
 
 type virtualNode uint64
@@ -101,10 +141,14 @@ type virtualNode uint64
 // ConsistentUniformHash generates a uniform distribution of partitions over the members, and this distribution will keep as
 // consistent as possible while the members has some tiny changes.
 type ConsistentUniformHash struct {
-	config        Config
+	config Config
+	// The minLoad and maxLoad are the bounds of the load of all the members.
 	minLoad       int
 	maxLoad       int
 	numPartitions uint32
+	// Member name => The load bounds decided by the weight of the member
+	memMinLoads map[string]int
+	memMaxLoads map[string]int
 	// Member name => Member
 	members map[string]Member
 	// Member name => Partitions allocated to this member
@@ -140,14 +184,27 @@ func BuildConsistentUniformHash(numPartitions int, members []Member, config Conf
 		return nil, ErrEmptyMembers
 	}
 
-	numReplicatedNodes := len(members) * config.ReplicationFactor
-	avgLoad := float64(numPartitions) / float64(len(members))
-	minLoad := int(math.Floor(avgLoad))
-	maxLoad := int(math.Ceil(avgLoad))
+	numReplicatedNodes := 0
+	totalWeight := uint64(0)
+	for _, mem := range members {
+		numReplicatedNodes += config.numVirtualNodes(mem.String())
+		totalWeight += uint64(config.memberWeight(mem.String()))
+	}
 
+	// The load of every member is bounded by its share of the partitions according to the weight.
+	minLoad, maxLoad := math.MaxInt, 0
+	memMinLoads := make(map[string]int, len(members))
+	memMaxLoads := make(map[string]int, len(members))
 	memPartitions := make(map[string]map[int]struct{}, len(members))
 	for _, mem := range members {
-		memPartitions[mem.String()] = make(map[int]struct{}, maxLoad)
+		weightedLoad := uint64(numPartitions) * uint64(config.memberWeight(mem.String()))
+		memMinLoad := int(weightedLoad / totalWeight)
+		memMaxLoad := int((weightedLoad + totalWeight - 1) / totalWeight)
+		memMinLoads[mem.String()] = memMinLoad
+		memMaxLoads[mem.String()] = memMaxLoad
+		minLoad = min(minLoad, memMinLoad)
+		maxLoad = max(maxLoad, memMaxLoad)
+		memPartitions[mem.String()] = make(map[int]struct{}, memMaxLoad)
 	}
 
 	// Sort the affinity rule to ensure consistency.
@@ -159,6 +216,8 @@ func BuildConsistentUniformHash(numPartitions int, members []Member, config Conf
 		minLoad:       minLoad,
 		maxLoad:       maxLoad,
 		numPartitions: uint32(numPartitions),
+		memMinLoads:   memMinLoads,
+		memMaxLoads:   memMaxLoads,
 		sortedRing:    make([]virtualNode, 0, numReplicatedNodes),
 		memPartitions: memPartitions,
 		members:       make(map[string]Member, len(members)),
@@ -169,15 +228,13 @@ func BuildConsistentUniformHash(numPartitions int, members []Member, config Conf
 	c.initializeVirtualNodes(members)
 	c.distributePartitions()
 	c.ensureAffinity()
+	if err := c.ensureAntiAffinity(); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
-func (c *ConsistentUniformHash) distributePartitionWithLoad(partID, virtualNodeIdx int, allowedLoad int) bool {
-	// A fast path to avoid unnecessary loop.
-	if allowedLoad == 0 {
-		return false
-	}
-
+func (c *ConsistentUniformHash) distributePartitionWithLoad(partID, virtualNodeIdx int, allowedLoads map[string]int) bool {
 	var count int
 	for {
 		count++
@@ -189,7 +246,7 @@ func (c *ConsistentUniformHash) distributePartitionWithLoad(partID, virtualNodeI
 		partitions, ok := c.memPartitions[member.String()]
 		assert.Assert(ok)
 
-		if len(partitions)+1 <= allowedLoad {
+		if len(partitions)+1 <= allowedLoads[member.String()] {
 			c.partitionDist[partID] = virtualNodeIdx
 			partitions[partID] = struct{}{}
 			return true
@@ -202,12 +259,12 @@ func (c *ConsistentUniformHash) distributePartitionWithLoad(partID, virtualNodeI
 }
 
 func (c *ConsistentUniformHash) distributePartition(partID, virtualNodeIdx int) {
-	ok := c.distributePartitionWithLoad(partID, virtualNodeIdx, c.minLoad)
+	ok := c.distributePartitionWithLoad(partID, virtualNodeIdx, c.memMinLoads)
 	if ok {
 		return
 	}
 
-	ok = c.distributePartitionWithLoad(partID, virtualNodeIdx, c.maxLoad)
+	ok = c.distributePartitionWithLoad(partID, virtualNodeIdx, c.memMaxLoads)
 	assert.Assertf(ok, "not enough room to distribute partitions")
 }
 
@@ -262,7 +319,7 @@ func (c *ConsistentUniformHash) initializeVirtualNodes(members []Member) {
 	})
 
 	for _, mem := range members {
-		for i := 0; i < c.config.ReplicationFactor; i++ {
+		for i := 0; i < c.config.numVirtualNodes(mem.String()); i++ {
 			// TODO: Shall use a more generic hasher which receives multiple slices or string?
 			key := []byte(fmt.Sprintf("%s%s%d", mem.String(), hashSeparator, i))
 			h := virtualNode(c.config.Hasher.Sum64(key))
@@ -308,9 +365,15 @@ func (c *ConsistentUniformHash) ensureAffinity() {
 func (c *ConsistentUniformHash) offloadMember(mem Member, memPartitions map[int]struct{}, retainedPartID, numAllowedParts int, offloadedMems map[string]struct{}) {
 	assert.Assertf(numAllowedParts >= 1, "At least the partition itself should be allowed")
 	partIDsToOffload := make([]int, 0, len(memPartitions)-numAllowedParts)
+	// Sort the partitions to ensure the retained ones are consistent.
+	partIDs := make([]int, 0, len(memPartitions))
+	for partID := range memPartitions {
+		partIDs = append(partIDs, partID)
+	}
+	slices.Sort(partIDs)
 	// The `retainedPartID` must be retained.
 	numRetainedParts := 1
-	for partID := range memPartitions {
+	for _, partID := range partIDs {
 		if partID == retainedPartID {
 			continue
 		}
@@ -323,17 +386,16 @@ func (c *ConsistentUniformHash) offloadMember(mem Member, memPartitions map[int]
 		partIDsToOffload = append(partIDsToOffload, partID)
 	}
 
-	slices.Sort(partIDsToOffload)
 	for _, partID := range partIDsToOffload {
 		c.offloadPartition(partID, mem, offloadedMems)
 	}
 }
 
 func (c *ConsistentUniformHash) offloadPartition(sourcePartID int, sourceMem Member, blackedMembers map[string]struct{}) {
-	// Ensure all members' load smaller than the max load as much as possible.
+	// Ensure all members' load smaller than their max load as much as possible.
 	loadUpperBound := c.numPartitions
-	for load := c.maxLoad; load < int(loadUpperBound); load++ {
-		if done := c.offloadPartitionWithAllowedLoad(sourcePartID, sourceMem, load, blackedMembers); done {
+	for extraLoad := 0; c.maxLoad+extraLoad < int(loadUpperBound); extraLoad++ {
+		if done := c.offloadPartitionWithExtraLoad(sourcePartID, sourceMem, extraLoad, blackedMembers); done {
 			return
 		}
 	}
@@ -341,7 +403,8 @@ func (c *ConsistentUniformHash) offloadPartition(sourcePartID int, sourceMem Mem
 	log.Warn("failed to offload partition")
 }
 
-func (c *ConsistentUniformHash) offloadPartitionWithAllowedLoad(sourcePartID int, sourceMem Member, allowedMaxLoad int, blackedMembers map[string]struct{}) bool {
+// offloadPartitionWithExtraLoad moves the partition to the member whose load won't exceed its max load by more than the extraLoad.
+func (c *ConsistentUniformHash) offloadPartitionWithExtraLoad(sourcePartID int, sourceMem Member, extraLoad int, blackedMembers map[string]struct{}) bool {
 	vNodeIdx := c.partitionDist[sourcePartID]
 	// Skip the first member which must not be the target to move.
 	for loopCnt := 1; loopCnt < len(c.sortedRing); loopCnt++ {
@@ -363,7 +426,7 @@ func (c *ConsistentUniformHash) offloadPartitionWithAllowedLoad(sourcePartID int
 		assert.Assert(ok)
 		memLoad := len(memPartitions)
 		// Check whether the member's load is too allowed.
-		if memLoad+1 > allowedMaxLoad {
+		if memLoad+1 > c.memMaxLoads[mem.String()]+extraLoad {
 			continue
 		}
 
@@ -378,3 +441,159 @@ func (c *ConsistentUniformHash) offloadPartitionWithAllowedLoad(sourcePartID int
 
 	return false
 }
+
+// buildAntiAffinityConflicts returns the conflicting partitions of every partition, and the conflicts are symmetric.
+func (c *ConsistentUniformHash) buildAntiAffinityConflicts() (map[int]map[int]struct{}, error) {
+	conflicts := make(map[int]map[int]struct{}, len(c.config.PartitionAntiAffinities))
+	addConflict := func(partID, otherPartID int) {
+		if _, ok := conflicts[partID]; !ok {
+			conflicts[partID] = make(map[int]struct{})
+		}
+		conflicts[partID][otherPartID] = struct{}{}
+	}
+
+	for _, antiAffinity := range c.config.PartitionAntiAffinities {
+		partID := antiAffinity.PartitionID
+		if partID < 0 || partID >= int(c.numPartitions) {
+			return nil, fmt.Errorf("%w: unknown partition:%d", ErrInvalidAntiAffinity, partID)
+		}
+		for _, otherPartID := range antiAffinity.AntiAffinityPartitionIDs {
+			if otherPartID < 0 || otherPartID >= int(c.numPartitions) {
+				return nil, fmt.Errorf("%w: unknown partition:%d", ErrInvalidAntiAffinity, otherPartID)
+			}
+			if otherPartID == partID {
+				return nil, fmt.Errorf("%w: partition:%d conflicts with itself", ErrInvalidAntiAffinity, partID)
+			}
+			addConflict(partID, otherPartID)
+			addConflict(otherPartID, partID)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// ensureAntiAffinity moves the partitions sharing the same member with their conflicting partitions to other members, and the
+// partition with affinity or the smaller id is kept in place. The load limits set by the partition affinities are respected during the
+// moving.
+func (c *ConsistentUniformHash) ensureAntiAffinity() error {
+	conflicts, err := c.buildAntiAffinityConflicts()
+	if err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	affinityLoads := make(map[int]int, len(c.config.PartitionAffinities))
+	for _, affinity := range c.config.PartitionAffinities {
+		affinityLoads[affinity.PartitionID] = int(affinity.NumAllowedOtherPartitions) + 1
+	}
+
+	partIDs := make([]int, 0, len(conflicts))
+	for partID := range conflicts {
+		partIDs = append(partIDs, partID)
+	}
+	slices.Sort(partIDs)
+
+	for _, partID := range partIDs {
+		mem := c.GetPartitionOwner(partID)
+		for _, otherPartID := range c.conflictingPartitions(mem.String(), conflicts[partID]) {
+			// The partition without affinity is preferred to move because it can be placed on the member with any load.
+			partIDToMove := max(partID, otherPartID)
+			_, hasAffinity := affinityLoads[partID]
+			_, otherHasAffinity := affinityLoads[otherPartID]
+			if hasAffinity != otherHasAffinity {
+				partIDToMove = partID
+				if hasAffinity {
+					partIDToMove = otherPartID
+				}
+			}
+
+			if !c.relocatePartition(partIDToMove, mem, conflicts[partIDToMove], affinityLoads) {
+				return fmt.Errorf("%w: no member is available for partition:%d, numMembers:%d", ErrUnsatisfiableAntiAffinity, partIDToMove, len(c.members))
+			}
+			if partIDToMove == partID {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// conflictingPartitions returns the sorted partitions on the member which conflict with the given partition.
+func (c *ConsistentUniformHash) conflictingPartitions(mem string, conflictingPartIDs map[int]struct{}) []int {
+	partIDs := make([]int, 0)
+	for partID := range c.memPartitions[mem] {
+		if _, ok := conflictingPartIDs[partID]; ok {
+			partIDs = append(partIDs, partID)
+		}
+	}
+	slices.Sort(partIDs)
+	return partIDs
+}
+
+func (c *ConsistentUniformHash) hasConflictingPartition(mem string, conflictingPartIDs map[int]struct{}) bool {
+	return len(c.conflictingPartitions(mem, conflictingPartIDs)) > 0
+}
+
+// relocatePartition moves the partition to the member holding none of its conflicting partitions, and the members' load is kept as
+// small as possible.
+func (c *ConsistentUniformHash) relocatePartition(sourcePartID int, sourceMem Member, conflictingPartIDs map[int]struct{}, affinityLoads map[int]int) bool {
+	for extraLoad := 0; extraLoad <= int(c.numPartitions); extraLoad++ {
+		if done := c.relocatePartitionWithExtraLoad(sourcePartID, sourceMem, extraLoad, conflictingPartIDs, affinityLoads); done {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *ConsistentUniformHash) relocatePartitionWithExtraLoad(sourcePartID int, sourceMem Member, extraLoad int, conflictingPartIDs map[int]struct{}, affinityLoads map[int]int) bool {
+	vNodeIdx := c.partitionDist[sourcePartID]
+	for loopCnt := 1; loopCnt < len(c.sortedRing); loopCnt++ {
+		vNodeIdx++
+		if vNodeIdx == len(c.sortedRing) {
+			vNodeIdx = 0
+		}
+
+		vNode := c.sortedRing[vNodeIdx]
+		mem, ok := c.nodeToMems[vNode]
+		assert.Assert(ok)
+		if mem.String() == sourceMem.String() || c.hasConflictingPartition(mem.String(), conflictingPartIDs) {
+			continue
+		}
+
+		memPartitions, ok := c.memPartitions[mem.String()]
+		assert.Assert(ok)
+		memLoad := len(memPartitions)
+		allowedMaxLoad := c.memMaxLoads[mem.String()] + extraLoad
+		// The load allowed by the affinity of the partition itself.
+		if load, ok := affinityLoads[sourcePartID]; ok && load < allowedMaxLoad {
+			allowedMaxLoad = load
+		}
+		if memLoad+1 > allowedMaxLoad {
+			continue
+		}
+		// The load allowed by the affinities of the partitions on the member.
+		allowedByAffinity := true
+		for partID := range memPartitions {
+			if load, ok := affinityLoads[partID]; ok && memLoad+1 > load {
+				allowedByAffinity = false
+				break
+			}
+		}
+		if !allowedByAffinity {
+			continue
+		}
+
+		memPartitions[sourcePartID] = struct{}{}
+		c.partitionDist[sourcePartID] = vNodeIdx
+		sourceMemPartitions, ok := c.memPartitions[sourceMem.String()]
+		assert.Assert(ok)
+		delete(sourceMemPartitions, sourcePartID)
+		return true
+	}
+
+	return false
+}
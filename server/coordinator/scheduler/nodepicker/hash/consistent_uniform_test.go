@@ -309,3 +309,120 @@ func TestInvalidAffinity(t *testing.T) {
 	_, err := BuildConsistentUniformHash(4, members, cfg)
 	assert.NoError(t, err)
 }
+
+func checkAntiAffinity(t *testing.T, numPartitions, numMembers int, affinities []PartitionAffinity, antiAffinities []PartitionAntiAffinity) {
+	members := buildTestMembers(numMembers)
+	cfg := Config{
+		ReplicationFactor:       127,
+		Hasher:                  testHasher{},
+		PartitionAffinities:     affinities,
+		PartitionAntiAffinities: antiAffinities,
+	}
+	c, err := BuildConsistentUniformHash(numPartitions, members, cfg)
+	assert.NoError(t, err)
+
+	for _, antiAffinity := range antiAffinities {
+		mem := c.GetPartitionOwner(antiAffinity.PartitionID)
+		for _, otherPartID := range antiAffinity.AntiAffinityPartitionIDs {
+			assert.NotEqual(t, mem.String(), c.GetPartitionOwner(otherPartID).String(), "partition:%d, otherPartition:%d", antiAffinity.PartitionID, otherPartID)
+		}
+	}
+
+	loadDistribution := c.LoadDistribution()
+	for _, affinity := range affinities {
+		mem := c.GetPartitionOwner(affinity.PartitionID)
+		assert.LessOrEqual(t, loadDistribution[mem.String()], affinity.NumAllowedOtherPartitions+1)
+	}
+
+	// The distribution should be stable regardless of the order of the members.
+	newMembers := make([]Member, 0, numMembers)
+	for i := numMembers - 1; i >= 0; i-- {
+		newMembers = append(newMembers, members[i])
+	}
+	newC, err := BuildConsistentUniformHash(numPartitions, newMembers, cfg)
+	assert.NoError(t, err)
+	for partID := 0; partID < numPartitions; partID++ {
+		assert.Equal(t, c.GetPartitionOwner(partID).String(), newC.GetPartitionOwner(partID).String())
+	}
+}
+
+func TestAntiAffinity(t *testing.T) {
+	checkAntiAffinity(t, 8, 2, nil, []PartitionAntiAffinity{
+		{PartitionID: 3, AntiAffinityPartitionIDs: []int{7}},
+	})
+
+	checkAntiAffinity(t, 16, 4, nil, []PartitionAntiAffinity{
+		{PartitionID: 0, AntiAffinityPartitionIDs: []int{1, 2, 3}},
+		{PartitionID: 1, AntiAffinityPartitionIDs: []int{2, 3}},
+		{PartitionID: 2, AntiAffinityPartitionIDs: []int{3}},
+	})
+
+	checkAntiAffinity(t, 64, 8, []PartitionAffinity{
+		{PartitionID: 0, NumAllowedOtherPartitions: 0},
+		{PartitionID: 5, NumAllowedOtherPartitions: 3},
+	}, []PartitionAntiAffinity{
+		{PartitionID: 5, AntiAffinityPartitionIDs: []int{6, 7, 8}},
+		{PartitionID: 10, AntiAffinityPartitionIDs: []int{11, 12, 13, 14, 15}},
+	})
+}
+
+func TestUnsatisfiableAntiAffinity(t *testing.T) {
+	cfg := Config{
+		ReplicationFactor: 127,
+		Hasher:            testHasher{},
+		PartitionAntiAffinities: []PartitionAntiAffinity{
+			{PartitionID: 0, AntiAffinityPartitionIDs: []int{1, 2, 3}},
+			{PartitionID: 1, AntiAffinityPartitionIDs: []int{2, 3}},
+			{PartitionID: 2, AntiAffinityPartitionIDs: []int{3}},
+		},
+	}
+	_, err := BuildConsistentUniformHash(8, buildTestMembers(3), cfg)
+	assert.ErrorIs(t, err, ErrUnsatisfiableAntiAffinity)
+
+	// The member holding partition 0 is exclusive, so partition 1 and 2 have to share the other member.
+	cfg = Config{
+		ReplicationFactor:       127,
+		Hasher:                  testHasher{},
+		PartitionAffinities:     []PartitionAffinity{{PartitionID: 0, NumAllowedOtherPartitions: 0}},
+		PartitionAntiAffinities: []PartitionAntiAffinity{{PartitionID: 1, AntiAffinityPartitionIDs: []int{2}}},
+	}
+	_, err = BuildConsistentUniformHash(4, buildTestMembers(2), cfg)
+	assert.ErrorIs(t, err, ErrUnsatisfiableAntiAffinity)
+
+	cfg.PartitionAntiAffinities = []PartitionAntiAffinity{{PartitionID: 1, AntiAffinityPartitionIDs: []int{1}}}
+	_, err = BuildConsistentUniformHash(4, buildTestMembers(2), cfg)
+	assert.ErrorIs(t, err, ErrInvalidAntiAffinity)
+
+	cfg.PartitionAntiAffinities = []PartitionAntiAffinity{{PartitionID: 1, AntiAffinityPartitionIDs: []int{4}}}
+	_, err = BuildConsistentUniformHash(4, buildTestMembers(2), cfg)
+	assert.ErrorIs(t, err, ErrInvalidAntiAffinity)
+}
+
+func TestWeightedUniform(t *testing.T) {
+	numPartitions := 100
+	members := buildTestMembers(5)
+	weights := map[string]uint32{"node-0": 3 * DefaultMemberWeight, "node-1": DefaultMemberWeight / 2, "node-2": 0}
+	cfg := Config{
+		ReplicationFactor: 127,
+		Hasher:            testHasher{},
+		MemberWeights:     weights,
+	}
+	c, err := BuildConsistentUniformHash(numPartitions, members, cfg)
+	assert.NoError(t, err)
+
+	// The total weight is 300 + 50 + 100 * 3 = 650, and the zero weight is regarded as the default one.
+	expectLoads := map[string][2]uint{
+		"node-0": {46, 47},
+		"node-1": {7, 8},
+		"node-2": {15, 16},
+		"node-3": {15, 16},
+		"node-4": {15, 16},
+	}
+	loadDistribution := c.LoadDistribution()
+	for mem, expectLoad := range expectLoads {
+		assert.GreaterOrEqual(t, loadDistribution[mem], expectLoad[0], "member:%s", mem)
+		assert.LessOrEqual(t, loadDistribution[mem], expectLoad[1], "member:%s", mem)
+	}
+	assert.Equal(t, uint(7), c.MinLoad())
+	assert.Equal(t, uint(47), c.MaxLoad())
+}
@@ -35,6 +35,12 @@ import (
 type Config struct {
 	NumTotalShards    uint32
 	ShardAffinityRule map[storage.ShardID]scheduler.ShardAffinity
+	// ShardAntiAffinityRule describes the shards which must not share the same node.
+	ShardAntiAffinityRule map[storage.ShardID]scheduler.ShardAntiAffinity
+	// NodeWeights scales the share of shards of the nodes, and hash.DefaultMemberWeight is used for the nodes not included.
+	NodeWeights map[string]uint32
+	// ShardGroups are the groups of shards which should be spread across zones, and it is only respected by the zone aware node picker.
+	ShardGroups [][]storage.ShardID
 }
 
 func (c Config) genPartitionAffinities() []hash.PartitionAffinity {
@@ -50,10 +56,43 @@ func (c Config) genPartitionAffinities() []hash.PartitionAffinity {
 	return affinities
 }
 
+func (c Config) genPartitionAntiAffinities() []hash.PartitionAntiAffinity {
+	antiAffinities := make([]hash.PartitionAntiAffinity, 0, len(c.ShardAntiAffinityRule))
+	for shardID, antiAffinity := range c.ShardAntiAffinityRule {
+		partitionIDs := make([]int, 0, len(antiAffinity.AntiAffinityShardIDs))
+		for _, antiAffinityShardID := range antiAffinity.AntiAffinityShardIDs {
+			partitionIDs = append(partitionIDs, int(antiAffinityShardID))
+		}
+		antiAffinities = append(antiAffinities, hash.PartitionAntiAffinity{
+			PartitionID:              int(shardID),
+			AntiAffinityPartitionIDs: partitionIDs,
+		})
+	}
+
+	return antiAffinities
+}
+
 type NodePicker interface {
 	PickNode(ctx context.Context, config Config, shardIDs []storage.ShardID, registerNodes []metadata.RegisteredNode) (map[storage.ShardID]metadata.RegisteredNode, error)
 }
 
+const (
+	ConsistentUniformHashNodePickerName = "consistent_uniform_hash"
+	ZoneAwareNodePickerName             = "zone_aware"
+)
+
+// NewNodePicker creates the node picker by its name, and the consistent uniform hash node picker is created if the name is empty.
+func NewNodePicker(logger *zap.Logger, name string) (NodePicker, error) {
+	switch name {
+	case "", ConsistentUniformHashNodePickerName:
+		return NewConsistentUniformHashNodePicker(logger), nil
+	case ZoneAwareNodePickerName:
+		return NewZoneAwareNodePicker(logger), nil
+	default:
+		return nil, ErrUnknownNodePicker.WithCausef("name:%s", name)
+	}
+}
+
 type ConsistentUniformHashNodePicker struct {
 	logger *zap.Logger
 }
@@ -106,9 +145,11 @@ func (p *ConsistentUniformHashNodePicker) PickNode(_ context.Context, config Con
 	}
 
 	hashConf := hash.Config{
-		ReplicationFactor:   uniformHashReplicationFactor,
-		Hasher:              hasher{},
-		PartitionAffinities: config.genPartitionAffinities(),
+		ReplicationFactor:       uniformHashReplicationFactor,
+		Hasher:                  hasher{},
+		PartitionAffinities:     config.genPartitionAffinities(),
+		PartitionAntiAffinities: config.genPartitionAntiAffinities(),
+		MemberWeights:           config.NodeWeights,
 	}
 	h, err := hash.BuildConsistentUniformHash(int(config.NumTotalShards), mems, hashConf)
 	if err != nil {
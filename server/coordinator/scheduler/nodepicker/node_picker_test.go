@@ -27,7 +27,9 @@ import (
 	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker/hash"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -158,6 +160,182 @@ func TestUniformity(t *testing.T) {
 	}
 }
 
+func TestZoneAwareNodePicker(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	zones := []string{"zone0", "zone1", "zone2"}
+	var nodes []metadata.RegisteredNode
+	for i := 0; i < 6; i++ {
+		stats := storage.NewEmptyNodeStats()
+		stats.Zone = zones[i%len(zones)]
+		nodes = append(nodes, metadata.RegisteredNode{
+			Node: storage.Node{
+				Name:          strconv.Itoa(i),
+				NodeStats:     stats,
+				LastTouchTime: generateLastTouchTime(0),
+				State:         storage.NodeStateOnline,
+			},
+			ShardInfos: nil,
+		})
+	}
+
+	shardIDs := make([]storage.ShardID, 0, defaultTotalShardNum)
+	for i := 0; i < defaultTotalShardNum; i++ {
+		shardIDs = append(shardIDs, storage.ShardID(i))
+	}
+	groups := [][]storage.ShardID{{0, 1, 2}, {3, 4, 5, 6, 7, 8}}
+	config := nodepicker.Config{
+		NumTotalShards:    defaultTotalShardNum,
+		ShardAffinityRule: nil,
+		ShardGroups:       groups,
+	}
+
+	zoneAwarePicker := nodepicker.NewZoneAwareNodePicker(zap.NewNop())
+	shardNodes, err := zoneAwarePicker.PickNode(ctx, config, shardIDs, nodes)
+	re.NoError(err)
+	re.Len(shardNodes, defaultTotalShardNum)
+	for _, group := range groups {
+		zoneShardCount := make(map[string]int, len(zones))
+		for _, shardID := range group {
+			zoneShardCount[shardNodes[shardID].Node.NodeStats.Zone]++
+		}
+		for _, zone := range zones {
+			re.Equal(len(group)/len(zones), zoneShardCount[zone])
+		}
+	}
+
+	// The result of a part of the shards should be consistent with that of all the shards.
+	partShardNodes, err := zoneAwarePicker.PickNode(ctx, config, []storage.ShardID{1, 4}, nodes)
+	re.NoError(err)
+	re.Len(partShardNodes, 2)
+	re.Equal(shardNodes[1].Node.Name, partShardNodes[1].Node.Name)
+	re.Equal(shardNodes[4].Node.Name, partShardNodes[4].Node.Name)
+
+	// The shards out of groups are placed as the consistent uniform hash node picker does.
+	hashShardNodes, err := nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()).PickNode(ctx, config, shardIDs, nodes)
+	re.NoError(err)
+	re.Equal(hashShardNodes[9].Node.Name, shardNodes[9].Node.Name)
+}
+
+func TestShardAntiAffinity(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	zones := []string{"zone0", "zone1"}
+	var nodes []metadata.RegisteredNode
+	for i := 0; i < nodeLength; i++ {
+		stats := storage.NewEmptyNodeStats()
+		stats.Zone = zones[i%len(zones)]
+		nodes = append(nodes, metadata.RegisteredNode{
+			Node: storage.Node{
+				Name:          strconv.Itoa(i),
+				NodeStats:     stats,
+				LastTouchTime: generateLastTouchTime(0),
+				State:         storage.NodeStateOnline,
+			},
+			ShardInfos: nil,
+		})
+	}
+
+	shardIDs := make([]storage.ShardID, 0, defaultTotalShardNum)
+	for i := 0; i < defaultTotalShardNum; i++ {
+		shardIDs = append(shardIDs, storage.ShardID(i))
+	}
+	antiAffinities := map[storage.ShardID]scheduler.ShardAntiAffinity{
+		3: {ShardID: 3, AntiAffinityShardIDs: []storage.ShardID{7}},
+		0: {ShardID: 0, AntiAffinityShardIDs: []storage.ShardID{1, 2}},
+		1: {ShardID: 1, AntiAffinityShardIDs: []storage.ShardID{2}},
+	}
+	config := nodepicker.Config{
+		NumTotalShards:        defaultTotalShardNum,
+		ShardAffinityRule:     nil,
+		ShardAntiAffinityRule: antiAffinities,
+		ShardGroups:           [][]storage.ShardID{{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	}
+
+	pickers := []nodepicker.NodePicker{nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), nodepicker.NewZoneAwareNodePicker(zap.NewNop())}
+	for _, picker := range pickers {
+		shardNodes, err := picker.PickNode(ctx, config, shardIDs, nodes)
+		re.NoError(err)
+		for shardID, antiAffinity := range antiAffinities {
+			for _, otherShardID := range antiAffinity.AntiAffinityShardIDs {
+				re.NotEqual(shardNodes[shardID].Node.Name, shardNodes[otherShardID].Node.Name)
+			}
+		}
+	}
+
+	// Four shards can't be placed on three nodes without sharing.
+	config.ShardAntiAffinityRule[3] = scheduler.ShardAntiAffinity{ShardID: 3, AntiAffinityShardIDs: []storage.ShardID{0, 1, 2}}
+	for _, picker := range pickers {
+		_, err := picker.PickNode(ctx, config, shardIDs, nodes)
+		re.ErrorIs(err, hash.ErrUnsatisfiableAntiAffinity)
+	}
+}
+
+func TestWeightedNodes(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	var nodes []metadata.RegisteredNode
+	for i := 0; i < 8; i++ {
+		nodes = append(nodes, metadata.RegisteredNode{
+			Node: storage.Node{
+				Name:          strconv.Itoa(i),
+				NodeStats:     storage.NewEmptyNodeStats(),
+				LastTouchTime: generateLastTouchTime(0),
+				State:         storage.NodeStateOnline,
+			},
+			ShardInfos: nil,
+		})
+	}
+	shardNum := 128
+	shardIDs := make([]storage.ShardID, 0, shardNum)
+	for i := 0; i < shardNum; i++ {
+		shardIDs = append(shardIDs, storage.ShardID(i))
+	}
+
+	nodePicker := nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop())
+	pickNodes := func(nodeWeights map[string]uint32) (map[storage.ShardID]string, map[string]int) {
+		config := nodepicker.Config{
+			NumTotalShards:    uint32(shardNum),
+			ShardAffinityRule: nil,
+			NodeWeights:       nodeWeights,
+		}
+		shardNodes, err := nodePicker.PickNode(ctx, config, shardIDs, nodes)
+		re.NoError(err)
+
+		shardNodeNames := make(map[storage.ShardID]string, len(shardNodes))
+		nodeLoads := make(map[string]int, len(nodes))
+		for shardID, node := range shardNodes {
+			shardNodeNames[shardID] = node.Node.Name
+			nodeLoads[node.Node.Name]++
+		}
+		return shardNodeNames, nodeLoads
+	}
+
+	oldShardNodes, oldNodeLoads := pickNodes(nil)
+	re.Equal(shardNum/len(nodes), oldNodeLoads["0"])
+
+	// The node with double weight should hold about twice as many shards: 128 * 200 / 900 = 28.4.
+	newShardNodes, newNodeLoads := pickNodes(map[string]uint32{"0": 2 * hash.DefaultMemberWeight})
+	re.GreaterOrEqual(newNodeLoads["0"], 28)
+	re.LessOrEqual(newNodeLoads["0"], 29)
+	for i := 1; i < len(nodes); i++ {
+		re.GreaterOrEqual(newNodeLoads[strconv.Itoa(i)], 14)
+		re.LessOrEqual(newNodeLoads[strconv.Itoa(i)], 15)
+	}
+
+	// Only a few more shards than the ones moved to the node with double weight should be moved.
+	numMovedShards := 0
+	for shardID, nodeName := range oldShardNodes {
+		if newShardNodes[shardID] != nodeName {
+			numMovedShards++
+		}
+	}
+	re.LessOrEqual(numMovedShards, 2*(newNodeLoads["0"]-oldNodeLoads["0"]))
+}
+
 func allocShards(ctx context.Context, nodePicker nodepicker.NodePicker, nodeNum int, shardNum int, re *require.Assertions) map[string][]int {
 	var nodes []metadata.RegisteredNode
 	for i := 0; i < nodeNum; i++ {
@@ -0,0 +1,203 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nodepicker
+
+import (
+	"context"
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"go.uber.org/zap"
+)
+
+// ZoneAwareNodePicker places the shards by the consistent uniform hash first, and then moves the shards of the same group out of
+// the zones holding more than their share, so losing one zone won't take out all the shards of a group.
+//
+// The nodes without zone are regarded as in the same zone.
+type ZoneAwareNodePicker struct {
+	logger     *zap.Logger
+	hashPicker NodePicker
+}
+
+func NewZoneAwareNodePicker(logger *zap.Logger) NodePicker {
+	return &ZoneAwareNodePicker{
+		logger:     logger,
+		hashPicker: NewConsistentUniformHashNodePicker(logger),
+	}
+}
+
+func (p *ZoneAwareNodePicker) PickNode(ctx context.Context, config Config, shardIDs []storage.ShardID, registerNodes []metadata.RegisteredNode) (map[storage.ShardID]metadata.RegisteredNode, error) {
+	// The requested shards beyond the total are counted too, otherwise they would be missing from the result.
+	for _, shardID := range shardIDs {
+		config.NumTotalShards = max(config.NumTotalShards, uint32(shardID)+1)
+	}
+	// All the shards are picked, so the result of the requested shards won't depend on which shards are requested.
+	allShardIDs := make([]storage.ShardID, 0, config.NumTotalShards)
+	for i := uint32(0); i < config.NumTotalShards; i++ {
+		allShardIDs = append(allShardIDs, storage.ShardID(i))
+	}
+	allShardNodes, err := p.hashPicker.PickNode(ctx, config, allShardIDs, registerNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := newZoneLayout(filterExpiredNodes(registerNodes), allShardNodes)
+	if len(zones.zoneNames) > 1 {
+		for _, group := range config.ShardGroups {
+			p.spreadShardGroup(config, group, zones, allShardNodes)
+		}
+	}
+
+	shardNodes := make(map[storage.ShardID]metadata.RegisteredNode, len(shardIDs))
+	for _, shardID := range shardIDs {
+		shardNodes[shardID] = allShardNodes[shardID]
+	}
+	return shardNodes, nil
+}
+
+// spreadShardGroup moves the shards of the group to make every zone hold at most ceil(len(group)/numZones) of them.
+func (p *ZoneAwareNodePicker) spreadShardGroup(config Config, group []storage.ShardID, zones *zoneLayout, shardNodes map[storage.ShardID]metadata.RegisteredNode) {
+	groupShardIDs := make([]storage.ShardID, 0, len(group))
+	for _, shardID := range group {
+		if _, ok := shardNodes[shardID]; ok {
+			groupShardIDs = append(groupShardIDs, shardID)
+		}
+	}
+	sort.Slice(groupShardIDs, func(i, j int) bool {
+		return groupShardIDs[i] < groupShardIDs[j]
+	})
+
+	numZones := len(zones.zoneNames)
+	maxShardsPerZone := (len(groupShardIDs) + numZones - 1) / numZones
+	zoneShardCount := make(map[string]int, numZones)
+	for _, shardID := range groupShardIDs {
+		zoneShardCount[shardNodes[shardID].Node.NodeStats.Zone]++
+	}
+
+	for _, shardID := range groupShardIDs {
+		oldNode := shardNodes[shardID]
+		oldZone := oldNode.Node.NodeStats.Zone
+		if zoneShardCount[oldZone] <= maxShardsPerZone {
+			continue
+		}
+		// The shard with affinity rule is placed by the hash ring carefully, so keep it untouched.
+		if _, ok := config.ShardAffinityRule[shardID]; ok {
+			continue
+		}
+
+		newZone := zones.leastUsedZone(zoneShardCount)
+		newNode, ok := zones.pickNodeInZone(newZone, config, shardID)
+		if !ok {
+			continue
+		}
+
+		shardNodes[shardID] = newNode
+		zones.moveShard(shardID, oldNode.Node.Name, newNode.Node.Name)
+		zoneShardCount[oldZone]--
+		zoneShardCount[newZone]++
+		p.logger.Debug("shard is moved to another zone", zap.Uint32("shardID", uint32(shardID)), zap.String("oldNode", oldNode.Node.Name), zap.String("newNode", newNode.Node.Name), zap.String("zone", newZone))
+	}
+}
+
+// zoneLayout describes the alive nodes in every zone and the shards on every node.
+type zoneLayout struct {
+	zoneNames  []string
+	zoneNodes  map[string][]metadata.RegisteredNode
+	nodeShards map[string]map[storage.ShardID]struct{}
+}
+
+func newZoneLayout(aliveNodes map[string]metadata.RegisteredNode, shardNodes map[storage.ShardID]metadata.RegisteredNode) *zoneLayout {
+	layout := &zoneLayout{
+		zoneNames:  []string{},
+		zoneNodes:  make(map[string][]metadata.RegisteredNode),
+		nodeShards: make(map[string]map[storage.ShardID]struct{}, len(aliveNodes)),
+	}
+	for _, node := range aliveNodes {
+		zone := node.Node.NodeStats.Zone
+		if _, ok := layout.zoneNodes[zone]; !ok {
+			layout.zoneNames = append(layout.zoneNames, zone)
+		}
+		layout.zoneNodes[zone] = append(layout.zoneNodes[zone], node)
+		layout.nodeShards[node.Node.Name] = make(map[storage.ShardID]struct{})
+	}
+	sort.Strings(layout.zoneNames)
+	for _, nodes := range layout.zoneNodes {
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].Node.Name < nodes[j].Node.Name
+		})
+	}
+	for shardID, node := range shardNodes {
+		layout.nodeShards[node.Node.Name][shardID] = struct{}{}
+	}
+	return layout
+}
+
+func (l *zoneLayout) leastUsedZone(zoneShardCount map[string]int) string {
+	leastUsed := l.zoneNames[0]
+	for _, zone := range l.zoneNames[1:] {
+		if zoneShardCount[zone] < zoneShardCount[leastUsed] {
+			leastUsed = zone
+		}
+	}
+	return leastUsed
+}
+
+// pickNodeInZone picks the node holding the fewest shards in the zone for the shard, and the nodes holding shards with affinity rule
+// or the shards which are anti-affine with the shard are skipped.
+func (l *zoneLayout) pickNodeInZone(zone string, config Config, shardID storage.ShardID) (metadata.RegisteredNode, bool) {
+	var picked metadata.RegisteredNode
+	found := false
+	for _, node := range l.zoneNodes[zone] {
+		shards := l.nodeShards[node.Node.Name]
+		if hasAffinityShard(shards, config) || hasAntiAffineShard(shards, config, shardID) {
+			continue
+		}
+		if !found || len(shards) < len(l.nodeShards[picked.Node.Name]) {
+			picked = node
+			found = true
+		}
+	}
+	return picked, found
+}
+
+func (l *zoneLayout) moveShard(shardID storage.ShardID, oldNodeName, newNodeName string) {
+	delete(l.nodeShards[oldNodeName], shardID)
+	l.nodeShards[newNodeName][shardID] = struct{}{}
+}
+
+func hasAffinityShard(shards map[storage.ShardID]struct{}, config Config) bool {
+	for shardID := range shards {
+		if _, ok := config.ShardAffinityRule[shardID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAntiAffineShard(shards map[storage.ShardID]struct{}, config Config, shardID storage.ShardID) bool {
+	for otherShardID := range shards {
+		if scheduler.IsAntiAffine(config.ShardAntiAffinityRule, shardID, otherShardID) {
+			return true
+		}
+	}
+	return false
+}
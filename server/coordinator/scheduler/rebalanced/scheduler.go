@@ -23,8 +23,11 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/assert"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
@@ -51,9 +54,15 @@ type schedulerImpl struct {
 	enableSchedule bool
 	// shardAffinityRule is used to control the shard distribution.
 	shardAffinityRule map[storage.ShardID]scheduler.ShardAffinity
+	// shardAntiAffinityRule is used to keep the shards apart.
+	shardAntiAffinityRule map[storage.ShardID]scheduler.ShardAntiAffinity
+	// leaderOverrides is used to keep the shards on the nodes chosen by other schedulers, and it can be nil.
+	leaderOverrides *scheduler.LeaderOverrides
+	// drainedNodes are left to the drain scheduler, and it can be nil.
+	drainedNodes *scheduler.DrainedNodes
 }
 
-func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, nodePicker nodepicker.NodePicker, procedureExecutingBatchSize uint32) scheduler.Scheduler {
+func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, nodePicker nodepicker.NodePicker, leaderOverrides *scheduler.LeaderOverrides, drainedNodes *scheduler.DrainedNodes, procedureExecutingBatchSize uint32) scheduler.Scheduler {
 	return &schedulerImpl{
 		logger:                      logger,
 		factory:                     factory,
@@ -63,6 +72,9 @@ func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, nodePic
 		latestShardNodeMapping:      map[storage.ShardID]metadata.RegisteredNode{},
 		enableSchedule:              false,
 		shardAffinityRule:           map[storage.ShardID]scheduler.ShardAffinity{},
+		shardAntiAffinityRule:       map[storage.ShardID]scheduler.ShardAntiAffinity{},
+		leaderOverrides:             leaderOverrides,
+		drainedNodes:                drainedNodes,
 	}
 }
 
@@ -81,6 +93,9 @@ func (r *schedulerImpl) AddShardAffinityRule(_ context.Context, rule scheduler.S
 	for _, shardAffinity := range rule.Affinities {
 		r.shardAffinityRule[shardAffinity.ShardID] = shardAffinity
 	}
+	for _, shardAntiAffinity := range rule.AntiAffinities {
+		r.shardAntiAffinityRule[shardAntiAffinity.ShardID] = shardAntiAffinity
+	}
 
 	return nil
 }
@@ -90,6 +105,7 @@ func (r *schedulerImpl) RemoveShardAffinityRule(_ context.Context, shardID stora
 	defer r.lock.Unlock()
 
 	delete(r.shardAffinityRule, shardID)
+	delete(r.shardAntiAffinityRule, shardID)
 
 	return nil
 }
@@ -102,11 +118,25 @@ func (r *schedulerImpl) ListShardAffinityRule(_ context.Context) (scheduler.Shar
 	for _, affinity := range r.shardAffinityRule {
 		affinities = append(affinities, affinity)
 	}
+	antiAffinities := make([]scheduler.ShardAntiAffinity, 0, len(r.shardAntiAffinityRule))
+	for _, antiAffinity := range r.shardAntiAffinityRule {
+		antiAffinities = append(antiAffinities, antiAffinity)
+	}
 
-	return scheduler.ShardAffinityRule{Affinities: affinities}, nil
+	return scheduler.ShardAffinityRule{Affinities: affinities, AntiAffinities: antiAffinities}, nil
 }
 
 func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	return r.schedule(ctx, clusterSnapshot, false)
+}
+
+// ScheduleCorrective only assigns the unassigned shards and the shards on the expired nodes, and the shards on the alive nodes are never
+// moved.
+func (r *schedulerImpl) ScheduleCorrective(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	return r.schedule(ctx, clusterSnapshot, true)
+}
+
+func (r *schedulerImpl) schedule(ctx context.Context, clusterSnapshot metadata.Snapshot, correctiveOnly bool) (scheduler.ScheduleResult, error) {
 	var emptySchedulerRes scheduler.ScheduleResult
 	// RebalancedShardScheduler can only be scheduled when the cluster is not empty.
 	if clusterSnapshot.Topology.ClusterView.State == storage.ClusterStateEmpty {
@@ -122,10 +152,10 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 		return emptySchedulerRes, nil
 	}
 
-	numShards := uint32(len(clusterSnapshot.Topology.ShardViewsMapping))
+	aliveNodes, followerNodes := r.aliveNodesAndFollowers(clusterSnapshot)
 	// Generate assigned shards mapping and transfer leader if node is changed.
-	assignedShardIDs := make(map[storage.ShardID]struct{}, numShards)
-	for _, shardNode := range clusterSnapshot.Topology.ClusterView.ShardNodes {
+	assignedShardIDs := make(map[storage.ShardID]struct{}, len(clusterSnapshot.Topology.ShardViewsMapping))
+	for _, shardNode := range clusterSnapshot.Topology.LeaderShardNodes() {
 		if len(procedures) >= int(r.procedureExecutingBatchSize) {
 			r.logger.Warn("procedure length reached procedure executing batch size", zap.Uint32("procedureExecutingBatchSize", r.procedureExecutingBatchSize))
 			break
@@ -135,7 +165,30 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 		assignedShardIDs[shardNode.ID] = struct{}{}
 		newLeaderNode, ok := shardNodeMapping[shardNode.ID]
 		assert.Assert(ok)
+		newLeaderNode = r.applyLeaderOverride(shardNode.ID, newLeaderNode, clusterSnapshot)
+		// The shards on the drained nodes are moved by the drain scheduler, and the latest mapping recorded before the node is drained
+		// may still point to it when the topology is locked.
+		if r.isDrained(shardNode.NodeName) || r.isDrained(newLeaderNode.Node.Name) {
+			continue
+		}
 		if newLeaderNode.Node.Name != shardNode.NodeName {
+			_, alive := aliveNodes[shardNode.NodeName]
+			if alive && correctiveOnly {
+				continue
+			}
+			// The follower takes over the shard if the node of the leader expires, which is much faster than opening the shard elsewhere.
+			if !alive {
+				promoteProcedure, err := r.promoteFollower(ctx, clusterSnapshot, shardNode.ID, shardNode.NodeName, newLeaderNode.Node.Name, followerNodes)
+				if err != nil {
+					return emptySchedulerRes, err
+				}
+				if promoteProcedure != nil {
+					procedures = append(procedures, promoteProcedure)
+					reasons.WriteString(fmt.Sprintf("follower is promoted because the leader node expires, shardID:%d, oldNode:%s\n", shardNode.ID, shardNode.NodeName))
+					continue
+				}
+			}
+
 			r.logger.Info("rebalanced shard scheduler try to assign shard to another node", zap.Uint64("shardID", uint64(shardNode.ID)), zap.String("originNode", shardNode.NodeName), zap.String("newNode", newLeaderNode.Node.Name))
 			p, err := r.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
 				Snapshot:          clusterSnapshot,
@@ -152,19 +205,33 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 		}
 	}
 
-	// Check whether the assigned shard needs to be reopened.
-	for id := uint32(0); id < numShards; id++ {
+	// Check whether the assigned shard needs to be reopened, and the shard ids may not be contiguous after the shards are merged.
+	shardIDs := make([]storage.ShardID, 0, len(clusterSnapshot.Topology.ShardViewsMapping))
+	for shardID := range clusterSnapshot.Topology.ShardViewsMapping {
+		shardIDs = append(shardIDs, shardID)
+	}
+	slices.Sort(shardIDs)
+	for _, shardID := range shardIDs {
 		if len(procedures) >= int(r.procedureExecutingBatchSize) {
 			r.logger.Warn("procedure length reached procedure executing batch size", zap.Uint32("procedureExecutingBatchSize", r.procedureExecutingBatchSize))
 			break
 		}
 
-		shardID := storage.ShardID(id)
 		if _, assigned := assignedShardIDs[shardID]; !assigned {
 			node, ok := r.latestShardNodeMapping[shardID]
 			assert.Assert(ok)
 
-			r.logger.Info("rebalanced shard scheduler try to assign unassigned shard to node", zap.Uint32("shardID", id), zap.String("node", node.Node.Name))
+			promoteProcedure, err := r.promoteFollower(ctx, clusterSnapshot, shardID, "", node.Node.Name, followerNodes)
+			if err != nil {
+				return emptySchedulerRes, err
+			}
+			if promoteProcedure != nil {
+				procedures = append(procedures, promoteProcedure)
+				reasons.WriteString(fmt.Sprintf("follower is promoted because the shard is unassigned, shardID:%d\n", shardID))
+				continue
+			}
+
+			r.logger.Info("rebalanced shard scheduler try to assign unassigned shard to node", zap.Uint32("shardID", uint32(shardID)), zap.String("node", node.Node.Name))
 			p, err := r.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
 				Snapshot:          clusterSnapshot,
 				ShardID:           shardID,
@@ -196,30 +263,124 @@ func (r *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.S
 }
 
 func (r *schedulerImpl) generateLatestShardNodeMapping(ctx context.Context, snapshot metadata.Snapshot) (map[storage.ShardID]metadata.RegisteredNode, error) {
-	numShards := uint32(len(snapshot.Topology.ShardViewsMapping))
 	// TODO: Improve scheduling efficiency and verify whether the topology changes.
-	shardIDs := make([]storage.ShardID, 0, numShards)
+	shardIDs := make([]storage.ShardID, 0, len(snapshot.Topology.ShardViewsMapping))
 	for shardID := range snapshot.Topology.ShardViewsMapping {
 		shardIDs = append(shardIDs, shardID)
 	}
 
 	r.lock.Lock()
 	defer r.lock.Unlock()
-	var err error
-	shardNodeMapping := r.latestShardNodeMapping
-	if !r.enableSchedule {
-		pickConfig := nodepicker.Config{
-			NumTotalShards:    numShards,
-			ShardAffinityRule: maps.Clone(r.shardAffinityRule),
+	if r.enableSchedule && len(r.latestShardNodeMapping) >= len(shardIDs) {
+		return r.latestShardNodeMapping, nil
+	}
+
+	pickConfig := nodepicker.Config{
+		NumTotalShards:        snapshot.Topology.ShardIDBound(),
+		ShardAffinityRule:     maps.Clone(r.shardAffinityRule),
+		ShardAntiAffinityRule: maps.Clone(r.shardAntiAffinityRule),
+	}
+	shardNodeMapping, err := r.nodePicker.PickNode(ctx, pickConfig, shardIDs, snapshot.RegisteredNodes)
+	if err != nil {
+		return nil, err
+	}
+	// The locked mapping is not generated yet, e.g. the persisted enableSchedule is loaded after restarting, so the current topology is
+	// locked and only the unassigned shards are placed by the node picker.
+	if r.enableSchedule {
+		lockShardNodeMapping(shardNodeMapping, snapshot)
+	}
+	r.latestShardNodeMapping = shardNodeMapping
+
+	return shardNodeMapping, nil
+}
+
+// lockShardNodeMapping keeps the shards on the nodes they are currently assigned to if the nodes are registered.
+func lockShardNodeMapping(shardNodeMapping map[storage.ShardID]metadata.RegisteredNode, snapshot metadata.Snapshot) {
+	registeredNodes := make(map[string]metadata.RegisteredNode, len(snapshot.RegisteredNodes))
+	for _, registeredNode := range snapshot.RegisteredNodes {
+		registeredNodes[registeredNode.Node.Name] = registeredNode
+	}
+	for _, shardNode := range snapshot.Topology.LeaderShardNodes() {
+		if registeredNode, ok := registeredNodes[shardNode.NodeName]; ok {
+			shardNodeMapping[shardNode.ID] = registeredNode
 		}
-		shardNodeMapping, err = r.nodePicker.PickNode(ctx, pickConfig, shardIDs, snapshot.RegisteredNodes)
-		if err != nil {
-			return nil, err
+	}
+}
+
+// applyLeaderOverride returns the node chosen by other schedulers if it is still online, and the override is dropped otherwise.
+func (r *schedulerImpl) applyLeaderOverride(shardID storage.ShardID, node metadata.RegisteredNode, snapshot metadata.Snapshot) metadata.RegisteredNode {
+	if r.leaderOverrides == nil {
+		return node
+	}
+	nodeName, ok := r.leaderOverrides.Get(shardID)
+	if !ok {
+		return node
+	}
+
+	for _, registeredNode := range snapshot.RegisteredNodes {
+		if registeredNode.Node.Name == nodeName && registeredNode.Node.State == storage.NodeStateOnline && !registeredNode.IsExpired(time.Now()) {
+			return registeredNode
 		}
-		r.latestShardNodeMapping = shardNodeMapping
 	}
+	r.logger.Info("drop the leader override of shard because the node is offline", zap.Uint32("shardID", uint32(shardID)), zap.String("node", nodeName))
+	r.leaderOverrides.Remove(shardID)
+	return node
+}
 
-	return shardNodeMapping, nil
+// aliveNodesAndFollowers returns the online nodes which are not expired, and the nodes of the followers which can be promoted, i.e. the
+// ones on the alive and undrained nodes, grouped by the shards and sorted by the node names.
+func (r *schedulerImpl) aliveNodesAndFollowers(snapshot metadata.Snapshot) (map[string]struct{}, map[storage.ShardID][]string) {
+	now := time.Now()
+	aliveNodes := make(map[string]struct{}, len(snapshot.RegisteredNodes))
+	for _, registeredNode := range snapshot.RegisteredNodes {
+		if registeredNode.Node.State == storage.NodeStateOnline && !registeredNode.IsExpired(now) {
+			aliveNodes[registeredNode.Node.Name] = struct{}{}
+		}
+	}
+
+	followerNodes := make(map[storage.ShardID][]string)
+	for _, shardNode := range snapshot.Topology.FollowerShardNodes() {
+		if _, alive := aliveNodes[shardNode.NodeName]; alive && !r.isDrained(shardNode.NodeName) {
+			followerNodes[shardNode.ID] = append(followerNodes[shardNode.ID], shardNode.NodeName)
+		}
+	}
+	for _, nodeNames := range followerNodes {
+		sort.Strings(nodeNames)
+	}
+	return aliveNodes, followerNodes
+}
+
+// promoteFollower creates the procedure promoting a follower of the shard whose leader is lost, and nil is returned if no follower can be
+// promoted. The follower on the expected node is preferred, otherwise the leader is kept on the promoted one by the override to avoid
+// moving the shard again right after the failover.
+func (r *schedulerImpl) promoteFollower(ctx context.Context, snapshot metadata.Snapshot, shardID storage.ShardID, oldLeaderNodeName, expectedNodeName string, followerNodes map[storage.ShardID][]string) (procedure.Procedure, error) {
+	nodeNames := followerNodes[shardID]
+	if len(nodeNames) == 0 {
+		return nil, nil
+	}
+	followerNodeName := nodeNames[0]
+	if slices.Contains(nodeNames, expectedNodeName) {
+		followerNodeName = expectedNodeName
+	}
+
+	r.logger.Info("rebalanced shard scheduler try to promote follower", zap.Uint32("shardID", uint32(shardID)), zap.String("oldLeaderNode", oldLeaderNodeName), zap.String("followerNode", followerNodeName))
+	p, err := r.factory.CreatePromoteFollowerProcedure(ctx, coordinator.PromoteFollowerRequest{
+		Snapshot:          snapshot,
+		ShardID:           shardID,
+		OldLeaderNodeName: oldLeaderNodeName,
+		FollowerNodeName:  followerNodeName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if followerNodeName != expectedNodeName && r.leaderOverrides != nil {
+		r.leaderOverrides.Set(shardID, followerNodeName)
+	}
+	return p, nil
+}
+
+func (r *schedulerImpl) isDrained(nodeName string) bool {
+	return r.drainedNodes != nil && r.drainedNodes.Contains(nodeName)
 }
 
 func (r *schedulerImpl) updateEnableSchedule(enableSchedule bool) {
@@ -21,12 +21,16 @@ package rebalanced_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/nodepicker"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/rebalanced"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -38,7 +42,7 @@ func TestRebalancedScheduler(t *testing.T) {
 	// EmptyCluster would be scheduled an empty procedure.
 	emptyCluster := test.InitEmptyCluster(ctx, t)
 	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), emptyCluster.GetMetadata())
-	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), nil, nil, 1)
 	result, err := s.Schedule(ctx, emptyCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
 	re.Empty(result)
@@ -46,14 +50,102 @@ func TestRebalancedScheduler(t *testing.T) {
 	// PrepareCluster would be scheduled an empty procedure.
 	prepareCluster := test.InitPrepareCluster(ctx, t)
 	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), prepareCluster.GetMetadata())
-	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), nil, nil, 1)
 	_, err = s.Schedule(ctx, prepareCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
 
 	// StableCluster with all shards assigned would be scheduled a load balance procedure.
 	stableCluster := test.InitStableCluster(ctx, t)
 	procedureFactory = coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), stableCluster.GetMetadata())
-	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), 1)
+	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), nil, nil, 1)
 	_, err = s.Schedule(ctx, stableCluster.GetMetadata().GetClusterSnapshot())
 	re.NoError(err)
+
+	// The topology locked before the first schedule, e.g. after restarting, would be kept as it is.
+	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), nil, nil, 1)
+	s.UpdateEnableSchedule(ctx, true)
+	result, err = s.Schedule(ctx, stableCluster.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+}
+
+func TestRebalancedSchedulerAfterMerge(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata())
+
+	// The merged shard is dropped, so the shard ids are not contiguous anymore.
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	mergedShardID := storage.ShardID(1)
+	re.NoError(c.GetMetadata().DropShardView(ctx, mergedShardID, snapshot.Topology.ShardViewsMapping[mergedShardID].Version))
+	shardNodes := make([]storage.ShardNode, 0, len(snapshot.Topology.ClusterView.ShardNodes))
+	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+		if shardNode.ID != mergedShardID {
+			shardNodes = append(shardNodes, shardNode)
+		}
+	}
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+
+	snapshot = c.GetMetadata().GetClusterSnapshot()
+	re.Equal(uint32(len(snapshot.Topology.ShardViewsMapping)+1), snapshot.Topology.ShardIDBound())
+	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), nil, nil, 10)
+	result, err := s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotContains(result.Reason, fmt.Sprintf("shardID:%d,", mergedShardID))
+}
+
+func TestRebalancedSchedulerPromoteFollower(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata())
+
+	// Every shard has a follower on the other node.
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	leaderShardNodes := snapshot.Topology.LeaderShardNodes()
+	shardNodes := make([]storage.ShardNode, 0, 2*len(leaderShardNodes))
+	for _, leader := range leaderShardNodes {
+		followerNodeName := "node0"
+		if leader.NodeName == followerNodeName {
+			followerNodeName = "node1"
+		}
+		shardNodes = append(shardNodes, leader, storage.ShardNode{ID: leader.ID, ShardRole: storage.ShardRoleFollower, NodeName: followerNodeName})
+	}
+
+	// The follower is promoted if the leader is dropped after the shard lock expires, instead of opening the shard on the picked node.
+	lostLeader := shardNodes[0]
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes[1:]))
+	leaderOverrides := scheduler.NewLeaderOverrides()
+	s := rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), leaderOverrides, nil, 10)
+	result, err := s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.Contains(result.Reason, fmt.Sprintf("follower is promoted because the shard is unassigned, shardID:%d", lostLeader.ID))
+	re.NotContains(result.Reason, fmt.Sprintf("shard is assigned to a node, shardID:%d", lostLeader.ID))
+	// The promoted follower is kept as the leader if it is not on the node picked by the consistent hash.
+	if nodeName, ok := leaderOverrides.Get(lostLeader.ID); ok {
+		re.Equal(shardNodes[1].NodeName, nodeName)
+	}
+
+	// The followers are promoted if the node of the leaders expires.
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+	snapshot = c.GetMetadata().GetClusterSnapshot()
+	for i := range snapshot.RegisteredNodes {
+		if snapshot.RegisteredNodes[i].Node.Name == lostLeader.NodeName {
+			snapshot.RegisteredNodes[i].Node.LastTouchTime = uint64(time.Now().Add(-time.Hour).UnixMilli())
+		}
+	}
+	s = rebalanced.NewShardScheduler(zap.NewNop(), procedureFactory, nodepicker.NewConsistentUniformHashNodePicker(zap.NewNop()), nil, nil, 10)
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	for _, leader := range leaderShardNodes {
+		if leader.NodeName == lostLeader.NodeName {
+			re.Contains(result.Reason, fmt.Sprintf("follower is promoted because the leader node expires, shardID:%d, oldNode:%s", leader.ID, leader.NodeName))
+		}
+	}
+	re.NotContains(result.Reason, "shard is transferred to another node")
 }
@@ -49,6 +49,11 @@ func (r schedulerImpl) Name() string {
 	return "reopen_scheduler"
 }
 
+// IsCorrective returns true because the reopened shards stay on the same nodes.
+func (r schedulerImpl) IsCorrective() bool {
+	return true
+}
+
 func (r schedulerImpl) UpdateEnableSchedule(_ context.Context, _ bool) {
 	// ReopenShardScheduler do not need enableSchedule.
 }
@@ -122,6 +127,7 @@ func (r schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 	return scheduleRes, nil
 }
 
+// needReopen only considers the leaders because reopening a shard by transferring the leader would promote the follower.
 func needReopen(shardInfo metadata.ShardInfo) bool {
-	return shardInfo.Status == storage.ShardStatusPartialOpen
+	return shardInfo.Role == storage.ShardRoleLeader && shardInfo.Status == storage.ShardStatusPartialOpen
 }
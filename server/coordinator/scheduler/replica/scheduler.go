@@ -0,0 +1,236 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package replica
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"go.uber.org/zap"
+)
+
+// schedulerImpl opens and closes the followers to make every shard have the configured number of replicas. A follower is placed on a node
+// holding no replica of the shard, and the nodes in the zones with fewer replicas of the shard are preferred, then the less loaded ones.
+//
+// The number of replicas is set explicitly, so the followers are placed even if the shard topology is locked.
+type schedulerImpl struct {
+	logger                      *zap.Logger
+	factory                     *coordinator.Factory
+	replicaNum                  *scheduler.ReplicaNum
+	drainedNodes                *scheduler.DrainedNodes
+	procedureExecutingBatchSize uint32
+}
+
+func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, replicaNum *scheduler.ReplicaNum, drainedNodes *scheduler.DrainedNodes, procedureExecutingBatchSize uint32) scheduler.Scheduler {
+	return &schedulerImpl{
+		logger:                      logger,
+		factory:                     factory,
+		replicaNum:                  replicaNum,
+		drainedNodes:                drainedNodes,
+		procedureExecutingBatchSize: procedureExecutingBatchSize,
+	}
+}
+
+func (s *schedulerImpl) Name() string {
+	return "replica_scheduler"
+}
+
+func (s *schedulerImpl) UpdateEnableSchedule(_ context.Context, _ bool) {
+	// ReplicaShardScheduler do not need enableSchedule.
+}
+
+func (s *schedulerImpl) AddShardAffinityRule(_ context.Context, _ scheduler.ShardAffinityRule) error {
+	return nil
+}
+
+func (s *schedulerImpl) RemoveShardAffinityRule(_ context.Context, _ storage.ShardID) error {
+	return nil
+}
+
+func (s *schedulerImpl) ListShardAffinityRule(_ context.Context) (scheduler.ShardAffinityRule, error) {
+	return scheduler.ShardAffinityRule{Affinities: []scheduler.ShardAffinity{}}, nil
+}
+
+func (s *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	return s.schedule(ctx, clusterSnapshot, false)
+}
+
+// ScheduleCorrective only opens the followers of the shards lacking replicas, and the followers on the alive nodes are never closed even
+// if they are on the drained nodes or more than needed.
+func (s *schedulerImpl) ScheduleCorrective(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	return s.schedule(ctx, clusterSnapshot, true)
+}
+
+func (s *schedulerImpl) schedule(ctx context.Context, clusterSnapshot metadata.Snapshot, correctiveOnly bool) (scheduler.ScheduleResult, error) {
+	var emptySchedulerRes scheduler.ScheduleResult
+	// ReplicaShardScheduler can only be scheduled when the cluster is stable.
+	if !clusterSnapshot.Topology.IsStable() {
+		return emptySchedulerRes, nil
+	}
+
+	now := time.Now()
+	aliveNodes := make(map[string]metadata.RegisteredNode, len(clusterSnapshot.RegisteredNodes))
+	for _, registeredNode := range clusterSnapshot.RegisteredNodes {
+		if registeredNode.Node.State == storage.NodeStateOnline && !registeredNode.IsExpired(now) {
+			aliveNodes[registeredNode.Node.Name] = registeredNode
+		}
+	}
+
+	// The load of a node is the number of the replicas on it, including both the leaders and the followers.
+	nodeLoads := make(map[string]int, len(aliveNodes))
+	followerNodes := make(map[storage.ShardID][]string)
+	for _, shardNode := range clusterSnapshot.Topology.ClusterView.ShardNodes {
+		nodeLoads[shardNode.NodeName]++
+		if shardNode.ShardRole == storage.ShardRoleFollower {
+			followerNodes[shardNode.ID] = append(followerNodes[shardNode.ID], shardNode.NodeName)
+		}
+	}
+	leaderShardNodes := clusterSnapshot.Topology.LeaderShardNodes()
+	sort.Slice(leaderShardNodes, func(i, j int) bool {
+		return leaderShardNodes[i].ID < leaderShardNodes[j].ID
+	})
+
+	numFollowers := int(s.replicaNum.Get()) - 1
+	var procedures []procedure.Procedure
+	var reasons strings.Builder
+	batchFull := func() bool {
+		if len(procedures) >= int(s.procedureExecutingBatchSize) {
+			s.logger.Warn("procedure length reached procedure executing batch size", zap.Uint32("procedureExecutingBatchSize", s.procedureExecutingBatchSize))
+			return true
+		}
+		return false
+	}
+	for _, leader := range leaderShardNodes {
+		// The shard whose leader is not alive is failed over by the rebalanced scheduler first.
+		if _, alive := aliveNodes[leader.NodeName]; !alive {
+			continue
+		}
+
+		followers := followerNodes[leader.ID]
+		sort.Strings(followers)
+		keptFollowers := make([]string, 0, len(followers))
+		for _, nodeName := range followers {
+			// The followers on the nodes which are not alive are not counted, and they are closed after the nodes come back if needed.
+			if _, alive := aliveNodes[nodeName]; !alive {
+				continue
+			}
+			if correctiveOnly || (!s.drainedNodes.Contains(nodeName) && len(keptFollowers) < numFollowers) {
+				keptFollowers = append(keptFollowers, nodeName)
+				continue
+			}
+			if batchFull() {
+				break
+			}
+
+			s.logger.Info("replica shard scheduler try to close follower", zap.Uint32("shardID", uint32(leader.ID)), zap.String("node", nodeName))
+			p, err := s.factory.CreateCloseFollowerProcedure(ctx, coordinator.FollowerRequest{
+				Snapshot: clusterSnapshot,
+				ShardID:  leader.ID,
+				NodeName: nodeName,
+			})
+			if err != nil {
+				return emptySchedulerRes, err
+			}
+			procedures = append(procedures, p)
+			reasons.WriteString(fmt.Sprintf("follower is closed, shardID:%d, node:%s, numFollowers:%d\n", leader.ID, nodeName, numFollowers))
+		}
+
+		for len(keptFollowers) < numFollowers && !batchFull() {
+			nodeName, ok := s.pickFollowerNode(leader, followers, keptFollowers, aliveNodes, nodeLoads)
+			if !ok {
+				break
+			}
+
+			s.logger.Info("replica shard scheduler try to open follower", zap.Uint32("shardID", uint32(leader.ID)), zap.String("node", nodeName))
+			p, err := s.factory.CreateOpenFollowerProcedure(ctx, coordinator.FollowerRequest{
+				Snapshot: clusterSnapshot,
+				ShardID:  leader.ID,
+				NodeName: nodeName,
+			})
+			if err != nil {
+				return emptySchedulerRes, err
+			}
+			procedures = append(procedures, p)
+			reasons.WriteString(fmt.Sprintf("follower is opened, shardID:%d, node:%s, numFollowers:%d\n", leader.ID, nodeName, numFollowers))
+			keptFollowers = append(keptFollowers, nodeName)
+			nodeLoads[nodeName]++
+		}
+	}
+
+	if len(procedures) == 0 {
+		return emptySchedulerRes, nil
+	}
+
+	batchProcedure, err := s.factory.CreateBatchTransferLeaderProcedure(ctx, coordinator.BatchRequest{
+		Batch:     procedures,
+		BatchType: procedure.OpenFollower,
+	})
+	if err != nil {
+		return emptySchedulerRes, err
+	}
+
+	return scheduler.ScheduleResult{Procedure: batchProcedure, Reason: reasons.String()}, nil
+}
+
+// pickFollowerNode picks the node for a new follower of the shard, and false is returned if all the candidate nodes hold a replica of it.
+func (s *schedulerImpl) pickFollowerNode(leader storage.ShardNode, followers []string, keptFollowers []string, aliveNodes map[string]metadata.RegisteredNode, nodeLoads map[string]int) (string, bool) {
+	usedNodes := make(map[string]struct{}, len(followers)+len(keptFollowers)+1)
+	usedNodes[leader.NodeName] = struct{}{}
+	for _, nodeName := range followers {
+		usedNodes[nodeName] = struct{}{}
+	}
+	zoneReplicas := make(map[string]int)
+	for _, nodeName := range append([]string{leader.NodeName}, keptFollowers...) {
+		usedNodes[nodeName] = struct{}{}
+		zoneReplicas[aliveNodes[nodeName].Node.NodeStats.Zone]++
+	}
+
+	candidates := make([]metadata.RegisteredNode, 0, len(aliveNodes))
+	for nodeName, node := range aliveNodes {
+		if _, used := usedNodes[nodeName]; used || s.drainedNodes.Contains(nodeName) {
+			continue
+		}
+		candidates = append(candidates, node)
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		zoneI, zoneJ := candidates[i].Node.NodeStats.Zone, candidates[j].Node.NodeStats.Zone
+		if zoneReplicas[zoneI] != zoneReplicas[zoneJ] {
+			return zoneReplicas[zoneI] < zoneReplicas[zoneJ]
+		}
+		nameI, nameJ := candidates[i].Node.Name, candidates[j].Node.Name
+		if nodeLoads[nameI] != nodeLoads[nameJ] {
+			return nodeLoads[nameI] < nodeLoads[nameJ]
+		}
+		return nameI < nameJ
+	})
+	return candidates[0].Node.Name, true
+}
@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package replica_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/replica"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReplicaShardScheduler(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata())
+	replicaNum := scheduler.NewReplicaNum()
+	drainedNodes := scheduler.NewDrainedNodes()
+	s := replica.NewShardScheduler(zap.NewNop(), procedureFactory, replicaNum, drainedNodes, 10)
+
+	// Only the leaders are kept by default.
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	result, err := s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// Every shard gets a follower on the other node.
+	replicaNum.Set(2)
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.Equal(procedure.OpenFollower, result.Procedure.Kind())
+	re.Len(result.Procedure.RelatedVersionInfo().ShardWithVersion, test.DefaultShardTotal)
+	shardNodes := snapshot.Topology.ClusterView.ShardNodes
+	for _, leader := range snapshot.Topology.LeaderShardNodes() {
+		followerNodeName := "node0"
+		if leader.NodeName == followerNodeName {
+			followerNodeName = "node1"
+		}
+		re.Contains(result.Reason, fmt.Sprintf("follower is opened, shardID:%d, node:%s", leader.ID, followerNodeName))
+		shardNodes = append(shardNodes, storage.ShardNode{ID: leader.ID, ShardRole: storage.ShardRoleFollower, NodeName: followerNodeName})
+	}
+	// The followers are replenished outside the maintenance windows too.
+	corrective := s.(scheduler.PartiallyCorrectiveScheduler)
+	correctiveResult, err := corrective.ScheduleCorrective(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(correctiveResult.Procedure)
+	re.Equal(result.Reason, correctiveResult.Reason)
+
+	// Nothing is scheduled after the followers are opened, and the cluster is still stable.
+	re.NoError(c.GetMetadata().UpdateClusterView(ctx, storage.ClusterStateStable, shardNodes))
+	snapshot = c.GetMetadata().GetClusterSnapshot()
+	re.True(snapshot.Topology.IsStable())
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// The followers can't exceed the number of the nodes.
+	replicaNum.Set(3)
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// The followers on the drained node are closed, and no other node can take them over.
+	replicaNum.Set(2)
+	drainedNode := shardNodes[len(shardNodes)-1].NodeName
+	drainedNodes.Reset([]string{drainedNode})
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.Contains(result.Reason, fmt.Sprintf("follower is closed, shardID:%d, node:%s", shardNodes[len(shardNodes)-1].ID, drainedNode))
+	re.NotContains(result.Reason, "follower is opened")
+	correctiveResult, err = corrective.ScheduleCorrective(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(correctiveResult.Procedure)
+
+	// All the followers are closed if only the leaders are needed.
+	drainedNodes.Reset([]string{})
+	replicaNum.Set(1)
+	result, err = s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.Len(result.Procedure.RelatedVersionInfo().ShardWithVersion, test.DefaultShardTotal)
+	re.NotContains(result.Reason, "follower is opened")
+	correctiveResult, err = corrective.ScheduleCorrective(ctx, snapshot)
+	re.NoError(err)
+	re.Nil(correctiveResult.Procedure)
+}
+
+func TestReplicaShardSchedulerZone(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableClusterWithConfig(ctx, t, 4, 1)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata())
+	replicaNum := scheduler.NewReplicaNum()
+	replicaNum.Set(3)
+	s := replica.NewShardScheduler(zap.NewNop(), procedureFactory, replicaNum, scheduler.NewDrainedNodes(), 10)
+
+	// Two nodes are in each zone, so the first follower is placed in the zone without the leader.
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	leader := snapshot.Topology.LeaderShardNodes()[0]
+	nodeZones := make(map[string]string, len(snapshot.RegisteredNodes))
+	leaderZone := ""
+	for i := range snapshot.RegisteredNodes {
+		zone := fmt.Sprintf("zone%d", i%2)
+		snapshot.RegisteredNodes[i].Node.NodeStats.Zone = zone
+		nodeZones[snapshot.RegisteredNodes[i].Node.Name] = zone
+		if snapshot.RegisteredNodes[i].Node.Name == leader.NodeName {
+			leaderZone = zone
+		}
+	}
+
+	result, err := s.Schedule(ctx, snapshot)
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	numFollowers, numOtherZoneFollowers := 0, 0
+	for nodeName, zone := range nodeZones {
+		if nodeName != leader.NodeName && strings.Contains(result.Reason, fmt.Sprintf("node:%s,", nodeName)) {
+			numFollowers++
+			if zone != leaderZone {
+				numOtherZoneFollowers++
+			}
+		}
+	}
+	re.Equal(2, numFollowers)
+	re.GreaterOrEqual(numOtherZoneFollowers, 1)
+}
@@ -21,6 +21,11 @@ package scheduler
 
 import (
 	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
 
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
@@ -31,6 +36,10 @@ type ScheduleResult struct {
 	Procedure procedure.Procedure
 	// The reason that the procedure is generated for.
 	Reason string
+	// Scheduler is the name of the scheduler generating the result, and it is filled by the scheduler manager.
+	Scheduler string
+	// OnSubmitted is called by the scheduler manager once the procedure is accepted by the procedure manager, and it is optional.
+	OnSubmitted func()
 }
 
 type ShardAffinity struct {
@@ -38,8 +47,174 @@ type ShardAffinity struct {
 	NumAllowedOtherShards uint            `json:"numAllowedOtherShards"`
 }
 
+// ShardAntiAffinity forbids the shard to share the same node with any of the AntiAffinityShardIDs, and the constraint works in both
+// directions.
+type ShardAntiAffinity struct {
+	ShardID              storage.ShardID   `json:"shardID"`
+	AntiAffinityShardIDs []storage.ShardID `json:"antiAffinityShardIDs"`
+}
+
 type ShardAffinityRule struct {
-	Affinities []ShardAffinity
+	Affinities     []ShardAffinity
+	AntiAffinities []ShardAntiAffinity
+}
+
+// IsAntiAffine checks whether the two shards are forbidden to share the same node by the anti-affinities.
+func IsAntiAffine(antiAffinities map[storage.ShardID]ShardAntiAffinity, shardID, otherShardID storage.ShardID) bool {
+	return slices.Contains(antiAffinities[shardID].AntiAffinityShardIDs, otherShardID) || slices.Contains(antiAffinities[otherShardID].AntiAffinityShardIDs, shardID)
+}
+
+// LargestSchemaTables returns the sorted names of the tables in the schema with the most tables, because the tables moved by a split or a
+// migration must belong to the same schema.
+func LargestSchemaTables(tables []metadata.TableInfo) (string, []string) {
+	tablesBySchema := make(map[string][]string)
+	for _, table := range tables {
+		tablesBySchema[table.SchemaName] = append(tablesBySchema[table.SchemaName], table.Name)
+	}
+	schemaName, tableNames := "", []string{}
+	for name, names := range tablesBySchema {
+		if len(names) > len(tableNames) || (len(names) == len(tableNames) && name < schemaName) {
+			schemaName, tableNames = name, names
+		}
+	}
+
+	sort.Strings(tableNames)
+	return schemaName, tableNames
+}
+
+// PickSplitTables picks the latter half of the tables returned by LargestSchemaTables, which are moved to the new shard when the shard
+// holding the tables is split, and false is returned if the schema has less than two tables.
+func PickSplitTables(tables []metadata.TableInfo) (string, []string, bool) {
+	schemaName, tableNames := LargestSchemaTables(tables)
+	if len(tableNames) < 2 {
+		return "", nil, false
+	}
+	return schemaName, tableNames[len(tableNames)/2:], true
+}
+
+// LeaderOverrides records the shard leaders which are not decided by the consistent hash, e.g. the ones moved because of the load.
+// The rebalanced scheduler respects them instead of moving the shards back.
+type LeaderOverrides struct {
+	lock      sync.RWMutex
+	overrides map[storage.ShardID]string
+}
+
+func NewLeaderOverrides() *LeaderOverrides {
+	return &LeaderOverrides{
+		lock:      sync.RWMutex{},
+		overrides: make(map[storage.ShardID]string),
+	}
+}
+
+func (o *LeaderOverrides) Set(shardID storage.ShardID, nodeName string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.overrides[shardID] = nodeName
+}
+
+func (o *LeaderOverrides) Get(shardID storage.ShardID) (string, bool) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	nodeName, ok := o.overrides[shardID]
+	return nodeName, ok
+}
+
+func (o *LeaderOverrides) Remove(shardID storage.ShardID) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	delete(o.overrides, shardID)
+}
+
+func (o *LeaderOverrides) Clone() *LeaderOverrides {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	return &LeaderOverrides{
+		lock:      sync.RWMutex{},
+		overrides: maps.Clone(o.overrides),
+	}
+}
+
+// DrainedNodes records the nodes being drained, no shard should be placed onto them and the shards on them are moved away.
+type DrainedNodes struct {
+	lock  sync.RWMutex
+	nodes map[string]struct{}
+}
+
+func NewDrainedNodes() *DrainedNodes {
+	return &DrainedNodes{
+		lock:  sync.RWMutex{},
+		nodes: make(map[string]struct{}),
+	}
+}
+
+// Reset replaces all the drained nodes with the nodeNames.
+func (d *DrainedNodes) Reset(nodeNames []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.nodes = make(map[string]struct{}, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		d.nodes[nodeName] = struct{}{}
+	}
+}
+
+func (d *DrainedNodes) Contains(nodeName string) bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	_, ok := d.nodes[nodeName]
+	return ok
+}
+
+func (d *DrainedNodes) Len() int {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return len(d.nodes)
+}
+
+func (d *DrainedNodes) Clone() *DrainedNodes {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return &DrainedNodes{
+		lock:  sync.RWMutex{},
+		nodes: maps.Clone(d.nodes),
+	}
+}
+
+// ReplicaNum records the number of the replicas of every shard including the leader, which is updated along with the replica settings.
+type ReplicaNum struct {
+	num atomic.Uint32
+}
+
+func NewReplicaNum() *ReplicaNum {
+	return &ReplicaNum{num: atomic.Uint32{}}
+}
+
+func (r *ReplicaNum) Set(num uint32) {
+	r.num.Store(num)
+}
+
+// Get returns at least one because the leader is always kept.
+func (r *ReplicaNum) Get() uint32 {
+	return max(r.num.Load(), 1)
+}
+
+// CorrectiveScheduler is implemented by the schedulers which only bring the shards back to the expected topology, e.g. reopen the shards
+// closed unexpectedly, so they can run outside the maintenance windows.
+type CorrectiveScheduler interface {
+	IsCorrective() bool
+}
+
+// PartiallyCorrectiveScheduler is implemented by the schedulers doing both the corrective and the voluntary work, e.g. the failover of
+// the expired nodes and the rebalance, and only the corrective work is scheduled outside the maintenance windows.
+type PartiallyCorrectiveScheduler interface {
+	ScheduleCorrective(ctx context.Context, clusterSnapshot metadata.Snapshot) (ScheduleResult, error)
 }
 
 type Scheduler interface {
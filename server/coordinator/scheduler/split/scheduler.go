@@ -0,0 +1,313 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package split
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"go.uber.org/zap"
+)
+
+// The shard load reported before loadTTL is ignored, and the shard is only split for its tables then.
+const loadTTL = time.Minute
+
+type Options struct {
+	// Enable adds the split scheduler to the default scheduler list of the clusters, it only works in dynamic topology.
+	Enable bool
+	// MaxTablesPerShard is the number of tables above which a shard is split, and zero means the shards are never split for the tables.
+	MaxTablesPerShard uint32
+	// MaxShardWriteQPS is the write qps reported by the leader node above which a shard is split, and zero means the shards are never
+	// split for the load.
+	MaxShardWriteQPS float64
+	// Cooldown is the min interval between two splits of the same shard.
+	Cooldown time.Duration
+}
+
+// schedulerImpl splits the hottest shard whose tables or write qps exceed the thresholds, one shard per round. The tables picked by
+// scheduler.PickSplitTables are moved to a new shard, which is opened on the least loaded node.
+//
+// The hotness of a shard is the max ratio of its table count and write qps to the thresholds, and a shard is hot if it is above one.
+type schedulerImpl struct {
+	logger          *zap.Logger
+	factory         *coordinator.Factory
+	clusterMetadata *metadata.ClusterMetadata
+	// drainedNodes never hold the new shards, and it can be nil.
+	drainedNodes *scheduler.DrainedNodes
+	options      Options
+
+	// Protect the following fields.
+	lock              sync.Mutex
+	enableSchedule    bool
+	lastSplitAt       map[storage.ShardID]time.Time
+	shardAffinityRule map[storage.ShardID]scheduler.ShardAffinity
+}
+
+func NewShardScheduler(logger *zap.Logger, factory *coordinator.Factory, clusterMetadata *metadata.ClusterMetadata, drainedNodes *scheduler.DrainedNodes, options Options) scheduler.Scheduler {
+	return &schedulerImpl{
+		logger:            logger,
+		factory:           factory,
+		clusterMetadata:   clusterMetadata,
+		drainedNodes:      drainedNodes,
+		options:           options,
+		lock:              sync.Mutex{},
+		enableSchedule:    false,
+		lastSplitAt:       map[storage.ShardID]time.Time{},
+		shardAffinityRule: map[storage.ShardID]scheduler.ShardAffinity{},
+	}
+}
+
+func (s *schedulerImpl) Name() string {
+	return "split_scheduler"
+}
+
+func (s *schedulerImpl) UpdateEnableSchedule(_ context.Context, enable bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.enableSchedule = enable
+}
+
+// AddShardAffinityRule only keeps the affinities, because the new shard is not anti-affine with any shard.
+func (s *schedulerImpl) AddShardAffinityRule(_ context.Context, rule scheduler.ShardAffinityRule) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, shardAffinity := range rule.Affinities {
+		s.shardAffinityRule[shardAffinity.ShardID] = shardAffinity
+	}
+
+	return nil
+}
+
+func (s *schedulerImpl) RemoveShardAffinityRule(_ context.Context, shardID storage.ShardID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.shardAffinityRule, shardID)
+
+	return nil
+}
+
+func (s *schedulerImpl) ListShardAffinityRule(_ context.Context) (scheduler.ShardAffinityRule, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	affinities := make([]scheduler.ShardAffinity, 0, len(s.shardAffinityRule))
+	for _, affinity := range s.shardAffinityRule {
+		affinities = append(affinities, affinity)
+	}
+
+	return scheduler.ShardAffinityRule{Affinities: affinities, AntiAffinities: []scheduler.ShardAntiAffinity{}}, nil
+}
+
+type hotShard struct {
+	shardID   storage.ShardID
+	nodeName  string
+	numTables int
+	writeQPS  float64
+	hotness   float64
+}
+
+type candidateNode struct {
+	name string
+	// hasLoad tells whether the node reports its load recently.
+	hasLoad   bool
+	writeQPS  float64
+	numShards int
+}
+
+func (s *schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	var emptySchedulerRes scheduler.ScheduleResult
+	// SplitShardScheduler can only be scheduled when the cluster is stable.
+	if !clusterSnapshot.Topology.IsStable() {
+		return emptySchedulerRes, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The shard topology is locked.
+	if s.enableSchedule {
+		return emptySchedulerRes, nil
+	}
+
+	now := time.Now()
+	hotShards := s.findHotShards(clusterSnapshot, now)
+	if len(hotShards) == 0 {
+		return emptySchedulerRes, nil
+	}
+	candidateNodes := s.collectCandidateNodes(clusterSnapshot, now)
+	if len(candidateNodes) == 0 {
+		return emptySchedulerRes, nil
+	}
+	target := candidateNodes[0]
+
+	leaderShardIDs := make([]storage.ShardID, 0, len(hotShards))
+	for _, shard := range hotShards {
+		leaderShardIDs = append(leaderShardIDs, shard.shardID)
+	}
+	shardTables := s.clusterMetadata.GetShardTables(leaderShardIDs)
+	for _, shard := range hotShards {
+		schemaName, tableNames, ok := scheduler.PickSplitTables(shardTables[shard.shardID].Tables)
+		if !ok {
+			continue
+		}
+
+		// The new shard is allocated when the procedure starts, so no shard id is consumed by the procedure which is dropped or outdated.
+		p, err := s.factory.CreateSplitProcedure(ctx, coordinator.SplitRequest{
+			ClusterMetadata: s.clusterMetadata,
+			SchemaName:      schemaName,
+			TableNames:      tableNames,
+			Snapshot:        clusterSnapshot,
+			ShardID:         shard.shardID,
+			NewShardID:      0,
+			AllocNewShardID: true,
+			TargetNodeName:  target.name,
+		})
+		if err != nil {
+			return emptySchedulerRes, err
+		}
+
+		s.logger.Info("split shard scheduler try to split hot shard", zap.Uint32("shardID", uint32(shard.shardID)), zap.Int("numTables", shard.numTables), zap.Float64("writeQPS", shard.writeQPS), zap.Int("numSplitTables", len(tableNames)), zap.String("targetNode", target.name))
+
+		shardID := shard.shardID
+		return scheduler.ScheduleResult{
+			Procedure: p,
+			Reason:    fmt.Sprintf("shard is hot, shardID:%d, numTables:%d, writeQPS:%.2f, numSplitTables:%d, newNode:%s", shard.shardID, shard.numTables, shard.writeQPS, len(tableNames), target.name),
+			// The shard cools down only if the split is submitted, otherwise it is retried by the next schedule.
+			OnSubmitted: func() {
+				s.lock.Lock()
+				defer s.lock.Unlock()
+
+				s.lastSplitAt[shardID] = now
+			},
+		}, nil
+	}
+
+	return emptySchedulerRes, nil
+}
+
+// findHotShards returns the hot leader shards which are not split recently, and the hottest one comes first.
+func (s *schedulerImpl) findHotShards(clusterSnapshot metadata.Snapshot, now time.Time) []hotShard {
+	nodeLoads := make(map[string]storage.NodeLoad, len(clusterSnapshot.RegisteredNodes))
+	for _, registeredNode := range clusterSnapshot.RegisteredNodes {
+		load := registeredNode.Node.NodeStats.Load
+		if load.ReportedAt != 0 && now.Sub(time.UnixMilli(int64(load.ReportedAt))) <= loadTTL {
+			nodeLoads[registeredNode.Node.Name] = load
+		}
+	}
+
+	hotShards := make([]hotShard, 0)
+	for _, shardNode := range clusterSnapshot.Topology.LeaderShardNodes() {
+		if splitAt, ok := s.lastSplitAt[shardNode.ID]; ok && now.Sub(splitAt) < s.options.Cooldown {
+			continue
+		}
+
+		shard := hotShard{
+			shardID:   shardNode.ID,
+			nodeName:  shardNode.NodeName,
+			numTables: len(clusterSnapshot.Topology.ShardViewsMapping[shardNode.ID].TableIDs),
+			writeQPS:  nodeLoads[shardNode.NodeName].ShardLoads[shardNode.ID].WriteQPS,
+			hotness:   0,
+		}
+		if s.options.MaxTablesPerShard > 0 {
+			shard.hotness = float64(shard.numTables) / float64(s.options.MaxTablesPerShard)
+		}
+		if s.options.MaxShardWriteQPS > 0 {
+			shard.hotness = max(shard.hotness, shard.writeQPS/s.options.MaxShardWriteQPS)
+		}
+		if shard.hotness > 1 {
+			hotShards = append(hotShards, shard)
+		}
+	}
+
+	sort.Slice(hotShards, func(i, j int) bool {
+		if hotShards[i].hotness != hotShards[j].hotness {
+			return hotShards[i].hotness > hotShards[j].hotness
+		}
+		return hotShards[i].shardID < hotShards[j].shardID
+	})
+	return hotShards
+}
+
+// collectCandidateNodes returns the nodes which can hold the new shard, and the least loaded one comes first. The nodes reporting their
+// load are preferred and compared by the write qps, then the number of the leader shards.
+func (s *schedulerImpl) collectCandidateNodes(clusterSnapshot metadata.Snapshot, now time.Time) []candidateNode {
+	nodeShards := make(map[string][]storage.ShardID, len(clusterSnapshot.RegisteredNodes))
+	for _, shardNode := range clusterSnapshot.Topology.LeaderShardNodes() {
+		nodeShards[shardNode.NodeName] = append(nodeShards[shardNode.NodeName], shardNode.ID)
+	}
+
+	candidateNodes := make([]candidateNode, 0, len(clusterSnapshot.RegisteredNodes))
+	for _, registeredNode := range clusterSnapshot.RegisteredNodes {
+		name := registeredNode.Node.Name
+		if registeredNode.Node.State != storage.NodeStateOnline || registeredNode.IsExpired(now) {
+			continue
+		}
+		if s.drainedNodes != nil && s.drainedNodes.Contains(name) {
+			continue
+		}
+		if !s.allowMoreShards(nodeShards[name]) {
+			continue
+		}
+
+		load := registeredNode.Node.NodeStats.Load
+		hasLoad := load.ReportedAt != 0 && now.Sub(time.UnixMilli(int64(load.ReportedAt))) <= loadTTL
+		node := candidateNode{name: name, hasLoad: hasLoad, writeQPS: 0, numShards: len(nodeShards[name])}
+		if hasLoad {
+			node.writeQPS = load.WriteQPS
+		}
+		candidateNodes = append(candidateNodes, node)
+	}
+
+	sort.Slice(candidateNodes, func(i, j int) bool {
+		a, b := candidateNodes[i], candidateNodes[j]
+		if a.hasLoad != b.hasLoad {
+			return a.hasLoad
+		}
+		if a.writeQPS != b.writeQPS {
+			return a.writeQPS < b.writeQPS
+		}
+		if a.numShards != b.numShards {
+			return a.numShards < b.numShards
+		}
+		return a.name < b.name
+	})
+	return candidateNodes
+}
+
+// allowMoreShards checks whether the shard affinity rules of the shards on the node allow the new shard to be placed.
+func (s *schedulerImpl) allowMoreShards(shardIDs []storage.ShardID) bool {
+	for _, shardID := range shardIDs {
+		affinity, ok := s.shardAffinityRule[shardID]
+		if ok && uint(len(shardIDs)) > affinity.NumAllowedOtherShards {
+			return false
+		}
+	}
+	return true
+}
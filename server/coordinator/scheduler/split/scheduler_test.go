@@ -0,0 +1,116 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package split_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure/test"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/split"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSplitShardScheduler(t *testing.T) {
+	re := require.New(t)
+	ctx := context.Background()
+
+	c := test.InitStableCluster(ctx, t)
+	procedureFactory := coordinator.NewFactory(zap.NewNop(), test.MockIDAllocator{}, test.MockDispatch{}, test.NewTestStorage(t), c.GetMetadata())
+	s := split.NewShardScheduler(zap.NewNop(), procedureFactory, c.GetMetadata(), nil, split.Options{
+		Enable:            true,
+		MaxTablesPerShard: 2,
+		MaxShardWriteQPS:  1000,
+		Cooldown:          time.Hour,
+	})
+
+	// SplitShardScheduler should not schedule when no shard is hot.
+	result, err := s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	snapshot := c.GetMetadata().GetClusterSnapshot()
+	hotShardNode := snapshot.Topology.LeaderShardNodes()[0]
+	for i := 0; i < 3; i++ {
+		shardView := c.GetMetadata().GetClusterSnapshot().Topology.ShardViewsMapping[hotShardNode.ID]
+		_, err := c.GetMetadata().CreateTable(ctx, metadata.CreateTableRequest{
+			ShardID:       hotShardNode.ID,
+			LatestVersion: shardView.Version,
+			SchemaName:    test.TestSchemaName,
+			TableName:     fmt.Sprintf("splitTable%d", i),
+			PartitionInfo: storage.PartitionInfo{Info: nil},
+		})
+		re.NoError(err)
+	}
+
+	// The shard holding too many tables should be split.
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.Equal(procedure.Split, result.Procedure.Kind())
+	re.Contains(result.Procedure.RelatedVersionInfo().ShardWithVersion, hotShardNode.ID)
+	// The new shard is not allocated until the procedure starts.
+	re.Len(result.Procedure.RelatedVersionInfo().ShardWithVersion, 1)
+
+	// The shard doesn't cool down until the split is submitted.
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.NotNil(result.Procedure)
+	re.NotNil(result.OnSubmitted)
+	result.OnSubmitted()
+
+	// The split shard is cooling down.
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// The shard with too much write load can't be split if it has less than two tables.
+	var loadedShardNode storage.ShardNode
+	for _, shardNode := range snapshot.Topology.LeaderShardNodes() {
+		if shardNode.ID != hotShardNode.ID {
+			loadedShardNode = shardNode
+			break
+		}
+	}
+	re.NoError(c.GetMetadata().UpdateNodeLoad(loadedShardNode.NodeName, storage.NodeLoad{
+		CPUUsage:    0.9,
+		MemoryUsage: 0.8,
+		DiskUsage:   0.5,
+		WriteQPS:    2000,
+		ShardLoads:  map[storage.ShardID]storage.ShardLoad{loadedShardNode.ID: {WriteQPS: 2000, DiskBytes: 0}},
+		ReportedAt:  uint64(time.Now().UnixMilli()),
+	}))
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+
+	// SplitShardScheduler should not schedule when the topology is locked.
+	s.UpdateEnableSchedule(ctx, true)
+	result, err = s.Schedule(ctx, c.GetMetadata().GetClusterSnapshot())
+	re.NoError(err)
+	re.Nil(result.Procedure)
+}
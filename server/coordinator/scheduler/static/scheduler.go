@@ -39,17 +39,23 @@ import (
 type schedulerImpl struct {
 	factory                     *coordinator.Factory
 	nodePicker                  nodepicker.NodePicker
+	clusterMetadata             *metadata.ClusterMetadata
 	procedureExecutingBatchSize uint32
 }
 
-func NewShardScheduler(factory *coordinator.Factory, nodePicker nodepicker.NodePicker, procedureExecutingBatchSize uint32) scheduler.Scheduler {
-	return schedulerImpl{factory: factory, nodePicker: nodePicker, procedureExecutingBatchSize: procedureExecutingBatchSize}
+func NewShardScheduler(factory *coordinator.Factory, nodePicker nodepicker.NodePicker, clusterMetadata *metadata.ClusterMetadata, procedureExecutingBatchSize uint32) scheduler.Scheduler {
+	return schedulerImpl{factory: factory, nodePicker: nodePicker, clusterMetadata: clusterMetadata, procedureExecutingBatchSize: procedureExecutingBatchSize}
 }
 
 func (s schedulerImpl) Name() string {
 	return "static_scheduler"
 }
 
+// IsCorrective returns true because the shards are always opened on the nodes assigned statically.
+func (s schedulerImpl) IsCorrective() bool {
+	return true
+}
+
 func (s schedulerImpl) UpdateEnableSchedule(_ context.Context, _ bool) {
 	// StaticTopologyShardScheduler do not need EnableSchedule.
 }
@@ -76,43 +82,9 @@ func (s schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 	case storage.ClusterStateEmpty:
 		return emptyScheduleRes, nil
 	case storage.ClusterStatePrepare:
-		unassignedShardIds := make([]storage.ShardID, 0, len(clusterSnapshot.Topology.ShardViewsMapping))
-		for _, shardView := range clusterSnapshot.Topology.ShardViewsMapping {
-			_, exists := findNodeByShard(shardView.ShardID, clusterSnapshot.Topology.ClusterView.ShardNodes)
-			if exists {
-				continue
-			}
-			unassignedShardIds = append(unassignedShardIds, shardView.ShardID)
-		}
-		pickConfig := nodepicker.Config{
-			NumTotalShards:    uint32(len(clusterSnapshot.Topology.ShardViewsMapping)),
-			ShardAffinityRule: map[storage.ShardID]scheduler.ShardAffinity{},
-		}
-		// Assign shards
-		shardNodeMapping, err := s.nodePicker.PickNode(ctx, pickConfig, unassignedShardIds, clusterSnapshot.RegisteredNodes)
-		if err != nil {
-			return emptyScheduleRes, err
-		}
-		for shardID, node := range shardNodeMapping {
-			// Shard exists and ShardNode not exists.
-			p, err := s.factory.CreateTransferLeaderProcedure(ctx, coordinator.TransferLeaderRequest{
-				Snapshot:          clusterSnapshot,
-				ShardID:           shardID,
-				OldLeaderNodeName: "",
-				NewLeaderNodeName: node.Node.Name,
-			})
-			if err != nil {
-				return emptyScheduleRes, err
-			}
-			procedures = append(procedures, p)
-			reasons.WriteString(fmt.Sprintf("Cluster initialization, assign shard to node, shardID:%d, nodeName:%s. ", shardID, node.Node.Name))
-			if len(procedures) >= int(s.procedureExecutingBatchSize) {
-				break
-			}
-		}
+		return s.scheduleScatter(ctx, clusterSnapshot)
 	case storage.ClusterStateStable:
-		for i := 0; i < len(clusterSnapshot.Topology.ClusterView.ShardNodes); i++ {
-			shardNode := clusterSnapshot.Topology.ClusterView.ShardNodes[i]
+		for _, shardNode := range clusterSnapshot.Topology.LeaderShardNodes() {
 			node, err := findOnlineNodeByName(shardNode.NodeName, clusterSnapshot.RegisteredNodes)
 			if err != nil {
 				continue
@@ -152,6 +124,61 @@ func (s schedulerImpl) Schedule(ctx context.Context, clusterSnapshot metadata.Sn
 	return scheduler.ScheduleResult{Procedure: batchProcedure, Reason: reasons.String()}, nil
 }
 
+// scheduleScatter assigns all the unassigned shards of the cluster in one scatter procedure, which will make the cluster stable when it finishes.
+func (s schedulerImpl) scheduleScatter(ctx context.Context, clusterSnapshot metadata.Snapshot) (scheduler.ScheduleResult, error) {
+	var emptyScheduleRes scheduler.ScheduleResult
+	var reasons strings.Builder
+
+	shardNodes := make([]storage.ShardNode, 0, len(clusterSnapshot.Topology.ShardViewsMapping))
+	leaderShardNodes := clusterSnapshot.Topology.LeaderShardNodes()
+	openedShards := make([]storage.ShardID, 0, len(leaderShardNodes))
+	unassignedShardIds := make([]storage.ShardID, 0, len(clusterSnapshot.Topology.ShardViewsMapping))
+	for _, shardView := range clusterSnapshot.Topology.ShardViewsMapping {
+		// The shards reported by nodes have been opened already.
+		shardNode, exists := findNodeByShard(shardView.ShardID, leaderShardNodes)
+		if exists {
+			shardNodes = append(shardNodes, shardNode)
+			openedShards = append(openedShards, shardNode.ID)
+			continue
+		}
+		unassignedShardIds = append(unassignedShardIds, shardView.ShardID)
+	}
+	if len(unassignedShardIds) == 0 {
+		return emptyScheduleRes, nil
+	}
+
+	pickConfig := nodepicker.Config{
+		NumTotalShards:    clusterSnapshot.Topology.ShardIDBound(),
+		ShardAffinityRule: map[storage.ShardID]scheduler.ShardAffinity{},
+	}
+	// Assign shards
+	shardNodeMapping, err := s.nodePicker.PickNode(ctx, pickConfig, unassignedShardIds, clusterSnapshot.RegisteredNodes)
+	if err != nil {
+		return emptyScheduleRes, err
+	}
+	for shardID, node := range shardNodeMapping {
+		shardNodes = append(shardNodes, storage.ShardNode{
+			ID:        shardID,
+			ShardRole: storage.ShardRoleLeader,
+			NodeName:  node.Node.Name,
+		})
+		reasons.WriteString(fmt.Sprintf("Cluster initialization, assign shard to node, shardID:%d, nodeName:%s. ", shardID, node.Node.Name))
+	}
+
+	p, err := s.factory.CreateScatterProcedure(ctx, coordinator.ScatterRequest{
+		ClusterMetadata: s.clusterMetadata,
+		Snapshot:        clusterSnapshot,
+		ShardNodes:      shardNodes,
+		OpenedShards:    openedShards,
+		BatchSize:       s.procedureExecutingBatchSize,
+	})
+	if err != nil {
+		return emptyScheduleRes, err
+	}
+
+	return scheduler.ScheduleResult{Procedure: p, Reason: reasons.String()}, nil
+}
+
 func findOnlineNodeByName(nodeName string, nodes []metadata.RegisteredNode) (metadata.RegisteredNode, error) {
 	now := time.Now()
 	for i := 0; i < len(nodes); i++ {
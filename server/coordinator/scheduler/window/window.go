@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package window provides the maintenance windows described by cron-like expressions.
+package window
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type field struct {
+	name string
+	min  int
+	max  int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// Both 0 and 7 are Sunday.
+	{name: "day of week", min: 0, max: 7},
+}
+
+// Window is open during the minutes matching the expression, which consists of five fields: minute, hour, day of month, month and
+// day of week. Each field is `*` or a comma separated list of values, ranges like `1-5` and steps like `*/2` or `0-30/10`.
+// The window matches the day if either day field matches when both of them are restricted, which is the same as cron.
+// e.g. `* 0-5 * * *` is open from 00:00 to 05:59 every day, and `* 22-23 * * 6,0` is open from 22:00 to 23:59 on weekends.
+type Window struct {
+	location *time.Location
+	// The allowed values of each field.
+	values [][]bool
+	// Whether the day of month and the day of week are restricted.
+	domRestricted bool
+	dowRestricted bool
+}
+
+// Parse parses the expression in the time zone, and UTC is used if the time zone is empty.
+func Parse(expr string, timeZone string) (*Window, error) {
+	location := time.UTC
+	if len(timeZone) != 0 {
+		var err error
+		location, err = time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid time zone:%s", timeZone)
+		}
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, errors.Errorf("expression should have %d fields, expr:%s", len(fields), expr)
+	}
+
+	values := make([][]bool, 0, len(fields))
+	for i, part := range parts {
+		fieldValues, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid %s, expr:%s", fields[i].name, expr)
+		}
+		values = append(values, fieldValues)
+	}
+	// Sunday can be either 0 or 7.
+	values[4][0] = values[4][0] || values[4][7]
+
+	return &Window{
+		location:      location,
+		values:        values,
+		domRestricted: parts[2] != "*",
+		dowRestricted: parts[4] != "*",
+	}, nil
+}
+
+func parseField(part string, f field) ([]bool, error) {
+	values := make([]bool, f.max+1)
+	for _, item := range strings.Split(part, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangePart = item[:i]
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return nil, errors.Errorf("invalid step:%s", item)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.Errorf("invalid value:%s", item)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.Errorf("invalid value:%s", item)
+				}
+			} else if step > 1 {
+				// `a/n` means from a to the max value.
+				end = f.max
+			}
+		}
+		if start < f.min || end > f.max || start > end {
+			return nil, errors.Errorf("value out of range [%d, %d]:%s", f.min, f.max, item)
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Contains returns whether the window is open at the time.
+func (w *Window) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	if !w.values[0][t.Minute()] || !w.values[1][t.Hour()] || !w.values[3][int(t.Month())] {
+		return false
+	}
+
+	domMatched := w.values[2][t.Day()]
+	dowMatched := w.values[4][int(t.Weekday())]
+	if w.domRestricted && w.dowRestricted {
+		return domMatched || dowMatched
+	}
+	return domMatched && dowMatched
+}
@@ -0,0 +1,68 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package window
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindow(t *testing.T) {
+	re := require.New(t)
+
+	// 2024-01-06 is Saturday.
+	saturdayNight := time.Date(2024, 1, 6, 2, 30, 0, 0, time.UTC)
+	saturdayNoon := time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC)
+	mondayNight := time.Date(2024, 1, 8, 2, 30, 0, 0, time.UTC)
+
+	w, err := Parse("* 0-5 * * *", "")
+	re.NoError(err)
+	re.True(w.Contains(saturdayNight))
+	re.False(w.Contains(saturdayNoon))
+
+	w, err = Parse("*/15 * * * 6,7", "")
+	re.NoError(err)
+	re.True(w.Contains(saturdayNight.Add(-30 * time.Minute)))
+	re.False(w.Contains(saturdayNight.Add(-29 * time.Minute)))
+	// Both 0 and 7 are Sunday.
+	re.True(w.Contains(saturdayNight.Add(24 * time.Hour)))
+	re.False(w.Contains(mondayNight))
+
+	// Either day field matches if both of them are restricted.
+	w, err = Parse("* * 8 * 6", "")
+	re.NoError(err)
+	re.True(w.Contains(saturdayNoon))
+	re.True(w.Contains(mondayNight))
+	re.False(w.Contains(saturdayNoon.Add(24 * time.Hour)))
+
+	// The time is converted into the time zone of the window.
+	w, err = Parse("* 10 * * *", "Asia/Shanghai")
+	re.NoError(err)
+	re.True(w.Contains(time.Date(2024, 1, 6, 2, 0, 0, 0, time.UTC)))
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 5-1 * * *", "*/0 * * * *", "a * * * *", "* * 0 * *"} {
+		_, err = Parse(expr, "")
+		re.Error(err, expr)
+	}
+	_, err = Parse("* * * * *", "unknown")
+	re.Error(err)
+}
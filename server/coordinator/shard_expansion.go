@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package coordinator
+
+import (
+	"context"
+	"sort"
+
+	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ExpandShardsRequest describes the new shards allocated by expanding the shard total, and the nodes picked to hold them.
+type ExpandShardsRequest struct {
+	ClusterMetadata *metadata.ClusterMetadata
+	// Snapshot is taken before the shard total is expanded, so the new shards are not included.
+	Snapshot    metadata.Snapshot
+	NewShardIDs []storage.ShardID
+	// ShardNodes maps every new shard to the name of the node picked to hold it.
+	ShardNodes map[storage.ShardID]string
+	// SplitTables moves half of the tables of the largest schema in an existing shard onto each new shard, and every existing shard is
+	// split at most once. The new shards are created empty if it is false or no shard has enough tables to split.
+	SplitTables bool
+}
+
+type shardSplit struct {
+	shardID    storage.ShardID
+	schemaName string
+	tableNames []string
+}
+
+// CreateExpandShardsProcedure creates the views of the new shards which are not split from the existing shards, and returns the
+// procedure placing all the new shards onto their nodes.
+func (f *Factory) CreateExpandShardsProcedure(ctx context.Context, request ExpandShardsRequest) (procedure.Procedure, error) {
+	splits := map[storage.ShardID]shardSplit{}
+	if request.SplitTables {
+		splits = planShardSplits(request.Snapshot, request.ClusterMetadata, request.NewShardIDs)
+	}
+	// The split procedure creates the view of the new shard by itself.
+	emptyShardViews := make([]metadata.CreateShardView, 0, len(request.NewShardIDs))
+	for _, shardID := range request.NewShardIDs {
+		if _, ok := splits[shardID]; !ok {
+			emptyShardViews = append(emptyShardViews, metadata.CreateShardView{ShardID: shardID, Tables: []storage.TableID{}})
+		}
+	}
+	if len(emptyShardViews) > 0 {
+		if err := request.ClusterMetadata.CreateShardViews(ctx, emptyShardViews); err != nil {
+			return nil, errors.WithMessage(err, "create shard views")
+		}
+	}
+
+	snapshot := request.ClusterMetadata.GetClusterSnapshot()
+	procedures := make([]procedure.Procedure, 0, len(request.NewShardIDs))
+	for _, shardID := range request.NewShardIDs {
+		var p procedure.Procedure
+		var err error
+		if split, ok := splits[shardID]; ok {
+			p, err = f.CreateSplitProcedure(ctx, SplitRequest{
+				ClusterMetadata: request.ClusterMetadata,
+				SchemaName:      split.schemaName,
+				TableNames:      split.tableNames,
+				Snapshot:        snapshot,
+				ShardID:         split.shardID,
+				NewShardID:      shardID,
+				AllocNewShardID: false,
+				TargetNodeName:  request.ShardNodes[shardID],
+			})
+		} else {
+			p, err = f.CreateTransferLeaderProcedure(ctx, TransferLeaderRequest{
+				Snapshot:          snapshot,
+				ShardID:           shardID,
+				OldLeaderNodeName: "",
+				NewLeaderNodeName: request.ShardNodes[shardID],
+			})
+		}
+		if err != nil {
+			return nil, errors.WithMessagef(err, "create procedure for the new shard, shardID:%d", shardID)
+		}
+		procedures = append(procedures, p)
+	}
+	f.logger.Info("create procedures for the new shards", zap.Int("numShards", len(request.NewShardIDs)), zap.Int("numSplits", len(splits)))
+
+	// All the new shards are placed in one batch, otherwise the procedures submitted later may be outdated by the ones finished earlier.
+	return f.CreateBatchTransferLeaderProcedure(ctx, BatchRequest{
+		Batch:     procedures,
+		BatchType: procedure.TransferLeader,
+	})
+}
+
+// planShardSplits picks the leader shard to split for every new shard, and the shards with more tables are split first.
+func planShardSplits(snapshot metadata.Snapshot, clusterMetadata *metadata.ClusterMetadata, newShardIDs []storage.ShardID) map[storage.ShardID]shardSplit {
+	leaderShardIDs := make([]storage.ShardID, 0, len(snapshot.Topology.ShardViewsMapping))
+	for _, shardNode := range snapshot.Topology.LeaderShardNodes() {
+		leaderShardIDs = append(leaderShardIDs, shardNode.ID)
+	}
+	shardTables := clusterMetadata.GetShardTables(leaderShardIDs)
+	sort.Slice(leaderShardIDs, func(i, j int) bool {
+		numTablesI, numTablesJ := len(shardTables[leaderShardIDs[i]].Tables), len(shardTables[leaderShardIDs[j]].Tables)
+		if numTablesI != numTablesJ {
+			return numTablesI > numTablesJ
+		}
+		return leaderShardIDs[i] < leaderShardIDs[j]
+	})
+
+	splits := make(map[storage.ShardID]shardSplit, len(newShardIDs))
+	for i, newShardID := range newShardIDs {
+		if i >= len(leaderShardIDs) {
+			break
+		}
+		shardID := leaderShardIDs[i]
+		schemaName, tableNames, ok := scheduler.PickSplitTables(shardTables[shardID].Tables)
+		if !ok {
+			continue
+		}
+		splits[newShardID] = shardSplit{
+			shardID:    shardID,
+			schemaName: schemaName,
+			tableNames: tableNames,
+		}
+	}
+	return splits
+}
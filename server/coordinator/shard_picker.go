@@ -45,13 +45,14 @@ func NewLeastTableShardPicker() ShardPicker {
 }
 
 func (l leastTableShardPicker) PickShards(_ context.Context, snapshot metadata.Snapshot, expectShardNum int) ([]storage.ShardNode, error) {
-	if len(snapshot.Topology.ClusterView.ShardNodes) == 0 {
+	leaderShardNodes := snapshot.Topology.LeaderShardNodes()
+	if len(leaderShardNodes) == 0 {
 		return nil, errors.WithMessage(ErrNodeNumberNotEnough, "no shard is assigned")
 	}
 
 	shardNodeMapping := make(map[storage.ShardID]storage.ShardNode, len(snapshot.Topology.ShardViewsMapping))
 	sortedShardsByTableCount := make([]storage.ShardID, 0, len(snapshot.Topology.ShardViewsMapping))
-	for _, shardNode := range snapshot.Topology.ClusterView.ShardNodes {
+	for _, shardNode := range leaderShardNodes {
 		shardNodeMapping[shardNode.ID] = shardNode
 		// Only collect the shards witch has been allocated to a node.
 		sortedShardsByTableCount = append(sortedShardsByTableCount, shardNode.ID)
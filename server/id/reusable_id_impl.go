@@ -78,6 +78,10 @@ func (l *OrderedList) Remove(v uint64) int {
 			removeIndex = i
 		}
 	}
+	// The value is not found, nothing to remove.
+	if removeIndex == -1 {
+		return removeIndex
+	}
 	l.sorted = append(l.sorted[:removeIndex], l.sorted[removeIndex+1:]...)
 	return removeIndex
 }
@@ -33,6 +33,11 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/cluster"
 	"github.com/apache/incubator-horaedb-meta/server/cluster/metadata"
 	"github.com/apache/incubator-horaedb-meta/server/config"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/balance"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/load"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/split"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-meta/server/limiter"
 	"github.com/apache/incubator-horaedb-meta/server/member"
@@ -259,7 +264,44 @@ func (srv *Server) startServer(_ context.Context) error {
 		return err
 	}
 
-	manager, err := cluster.NewManagerImpl(storage, srv.etcdCli, srv.etcdCli, srv.cfg.StorageRootPath, srv.cfg.IDAllocatorStep, topologyType)
+	timeoutCfg := srv.cfg.ProcedureTimeout
+	procedureOptions := procedure.ManagerOptions{
+		History: procedure.HistoryOptions{
+			RetentionCount: srv.cfg.ProcedureHistory.RetentionCount,
+			RetentionAge:   time.Duration(srv.cfg.ProcedureHistory.RetentionSec) * time.Second,
+			GCInterval:     time.Duration(srv.cfg.ProcedureHistory.GCIntervalSec) * time.Second,
+		},
+		Timeout: procedure.TimeoutOptions{
+			Default: time.Duration(timeoutCfg.DefaultSec) * time.Second,
+			KindTimeouts: map[procedure.Kind]time.Duration{
+				procedure.TransferLeader: time.Duration(timeoutCfg.TransferLeaderSec) * time.Second,
+				procedure.Migrate:        time.Duration(timeoutCfg.MigrateSec) * time.Second,
+				procedure.Split:          time.Duration(timeoutCfg.SplitSec) * time.Second,
+				procedure.Merge:          time.Duration(timeoutCfg.MergeSec) * time.Second,
+				procedure.Scatter:        time.Duration(timeoutCfg.ScatterSec) * time.Second,
+			},
+		},
+	}
+	schedulerOptions := manager.Options{
+		Load: load.Options{
+			Enable:     srv.cfg.LoadSchedule.Enable,
+			Threshold:  srv.cfg.LoadSchedule.Threshold,
+			Hysteresis: srv.cfg.LoadSchedule.Hysteresis,
+			Cooldown:   time.Duration(srv.cfg.LoadSchedule.CooldownSec) * time.Second,
+		},
+		Split: split.Options{
+			Enable:            srv.cfg.SplitSchedule.Enable,
+			MaxTablesPerShard: srv.cfg.SplitSchedule.MaxTablesPerShard,
+			MaxShardWriteQPS:  srv.cfg.SplitSchedule.MaxShardWriteQPS,
+			Cooldown:          time.Duration(srv.cfg.SplitSchedule.CooldownSec) * time.Second,
+		},
+		TableBalance: balance.Options{
+			Enable:    srv.cfg.TableBalanceSchedule.Enable,
+			Tolerance: srv.cfg.TableBalanceSchedule.Tolerance,
+		},
+		AuditCapacity: srv.cfg.ScheduleAudit.Capacity,
+	}
+	manager, err := cluster.NewManagerImpl(storage, srv.etcdCli, srv.etcdCli, srv.cfg.StorageRootPath, srv.cfg.IDAllocatorStep, topologyType, procedureOptions, schedulerOptions)
 	if err != nil {
 		return err
 	}
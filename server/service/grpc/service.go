@@ -214,7 +214,7 @@ func (s *Service) CreateTable(ctx context.Context, req *metaservicepb.CreateTabl
 		return &metaservicepb.CreateTableResponse{Header: responseHeader(err, err.Error())}, nil
 	}
 
-	err = c.GetProcedureManager().Submit(ctx, p)
+	_, err = c.GetProcedureManager().Submit(ctx, p)
 	if err != nil {
 		log.Error("fail to create table, manager submit procedure", zap.Error(err))
 		return &metaservicepb.CreateTableResponse{Header: responseHeader(err, err.Error())}, nil
@@ -297,7 +297,7 @@ func (s *Service) DropTable(ctx context.Context, req *metaservicepb.DropTableReq
 		return &metaservicepb.DropTableResponse{Header: okResponseHeader()}, nil
 	}
 
-	err = c.GetProcedureManager().Submit(ctx, procedure)
+	_, err = c.GetProcedureManager().Submit(ctx, procedure)
 	if err != nil {
 		log.Error("fail to drop table, manager submit procedure", zap.Error(err), zap.Int64("costTime", time.Since(start).Milliseconds()))
 		return &metaservicepb.DropTableResponse{Header: responseHeader(err, "drop table")}, nil
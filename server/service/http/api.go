@@ -27,6 +27,9 @@ import (
 	"io"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
@@ -36,10 +39,12 @@ import (
 	"github.com/apache/incubator-horaedb-meta/server/coordinator"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
 	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler/manager"
 	"github.com/apache/incubator-horaedb-meta/server/limiter"
 	"github.com/apache/incubator-horaedb-meta/server/member"
 	"github.com/apache/incubator-horaedb-meta/server/status"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
+	"github.com/pkg/errors"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 )
@@ -61,6 +66,8 @@ func (a *API) NewAPIRouter() *Router {
 	router.Post("/getShardTables", wrap(a.getShardTables, true, a.forwardClient))
 	router.Post("/transferLeader", wrap(a.transferLeader, true, a.forwardClient))
 	router.Post("/split", wrap(a.split, true, a.forwardClient))
+	router.Post("/migrate", wrap(a.migrate, true, a.forwardClient))
+	router.Post("/merge", wrap(a.merge, true, a.forwardClient))
 	router.Post("/route", wrap(a.route, true, a.forwardClient))
 	router.Del("/table", wrap(a.dropTable, true, a.forwardClient))
 	router.Post("/getNodeShards", wrap(a.getNodeShards, true, a.forwardClient))
@@ -74,9 +81,32 @@ func (a *API) NewAPIRouter() *Router {
 	router.Post("/clusters", wrap(a.createCluster, true, a.forwardClient))
 	router.Put(fmt.Sprintf("/clusters/:%s", clusterNameParam), wrap(a.updateCluster, true, a.forwardClient))
 	router.Get(fmt.Sprintf("/clusters/:%s/procedure", clusterNameParam), wrap(a.listProcedures, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/procedure/:%s", clusterNameParam, procedureIDParam), wrap(a.getProcedure, true, a.forwardClient))
+	router.Del(fmt.Sprintf("/clusters/:%s/procedure/:%s", clusterNameParam, procedureIDParam), wrap(a.cancelProcedure, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/procedureHistory", clusterNameParam), wrap(a.listProcedureHistory, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/scheduleAudit", clusterNameParam), wrap(a.listScheduleAudit, true, a.forwardClient))
 	router.Get(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), wrap(a.listShardAffinities, true, a.forwardClient))
 	router.Post(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), wrap(a.addShardAffinities, true, a.forwardClient))
 	router.Del(fmt.Sprintf("/clusters/:%s/shardAffinities", clusterNameParam), wrap(a.removeShardAffinities, true, a.forwardClient))
+	router.Post(fmt.Sprintf("/clusters/:%s/shardAntiAffinities", clusterNameParam), wrap(a.addShardAntiAffinities, true, a.forwardClient))
+	router.Post(fmt.Sprintf("/clusters/:%s/nodeLoad", clusterNameParam), wrap(a.reportNodeLoad, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/shardPlacement", clusterNameParam), wrap(a.getShardPlacement, true, a.forwardClient))
+	router.Post(fmt.Sprintf("/clusters/:%s/shardPlacement", clusterNameParam), wrap(a.updateShardPlacement, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/nodeWeights", clusterNameParam), wrap(a.getNodeWeights, true, a.forwardClient))
+	router.Put(fmt.Sprintf("/clusters/:%s/nodeWeights", clusterNameParam), wrap(a.updateNodeWeights, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/replicas", clusterNameParam), wrap(a.getReplicas, true, a.forwardClient))
+	router.Put(fmt.Sprintf("/clusters/:%s/replicas", clusterNameParam), wrap(a.updateReplicas, true, a.forwardClient))
+	router.Post(fmt.Sprintf("/clusters/:%s/expandShards", clusterNameParam), wrap(a.expandShards, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/nodes/:%s/drain", clusterNameParam, nodeNameParam), wrap(a.getDrainProgress, true, a.forwardClient))
+	router.Post(fmt.Sprintf("/clusters/:%s/dryRunSchedule", clusterNameParam), wrap(a.dryRunSchedule, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/settings", clusterNameParam), wrap(a.getClusterSettings, true, a.forwardClient))
+	router.Put(fmt.Sprintf("/clusters/:%s/settings", clusterNameParam), wrap(a.updateClusterSettings, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/maintenanceWindows", clusterNameParam), wrap(a.getMaintenanceWindows, true, a.forwardClient))
+	router.Put(fmt.Sprintf("/clusters/:%s/maintenanceWindows", clusterNameParam), wrap(a.updateMaintenanceWindows, true, a.forwardClient))
+	router.Get(fmt.Sprintf("/clusters/:%s/schedulers", clusterNameParam), wrap(a.getSchedulers, true, a.forwardClient))
+	router.Put(fmt.Sprintf("/clusters/:%s/schedulers", clusterNameParam), wrap(a.updateSchedulers, true, a.forwardClient))
+	router.Post(fmt.Sprintf("/clusters/:%s/nodes/:%s/drain", clusterNameParam, nodeNameParam), wrap(a.drainNode, true, a.forwardClient))
+	router.Del(fmt.Sprintf("/clusters/:%s/nodes/:%s/drain", clusterNameParam, nodeNameParam), wrap(a.undrainNode, true, a.forwardClient))
 	router.Post("/table/query", wrap(a.queryTable, true, a.forwardClient))
 
 	// Register debug API.
@@ -166,7 +196,7 @@ func (a *API) transferLeader(req *http.Request) apiFuncResult {
 		log.Error("create transfer leader procedure failed", zap.Error(err))
 		return errResult(ErrCreateProcedure, err.Error())
 	}
-	err = c.GetProcedureManager().Submit(req.Context(), transferLeaderProcedure)
+	_, err = c.GetProcedureManager().Submit(req.Context(), transferLeaderProcedure)
 	if err != nil {
 		log.Error("submit transfer leader procedure failed", zap.Error(err))
 		return errResult(ErrSubmitProcedure, err.Error())
@@ -284,6 +314,7 @@ func (a *API) split(req *http.Request) apiFuncResult {
 		Snapshot:        c.GetMetadata().GetClusterSnapshot(),
 		ShardID:         storage.ShardID(splitRequest.ShardID),
 		NewShardID:      storage.ShardID(newShardID),
+		AllocNewShardID: false,
 		TargetNodeName:  splitRequest.NodeName,
 	})
 	if err != nil {
@@ -291,14 +322,95 @@ func (a *API) split(req *http.Request) apiFuncResult {
 		return errResult(ErrCreateProcedure, err.Error())
 	}
 
-	if err := c.GetProcedureManager().Submit(ctx, splitProcedure); err != nil {
+	procedureID, err := c.GetProcedureManager().Submit(ctx, splitProcedure)
+	if err != nil {
 		log.Error("submit split procedure failed", zap.Error(err))
 		return errResult(ErrSubmitProcedure, err.Error())
 	}
+	// The new shard of the existing equivalent procedure is unknown, so reject the duplicate split.
+	if procedureID != splitProcedure.ID() {
+		return errResult(ErrSubmitProcedure, fmt.Sprintf("an equivalent split procedure is running, procedureID: %d", procedureID))
+	}
 
 	return okResult(newShardID)
 }
 
+func (a *API) migrate(req *http.Request) apiFuncResult {
+	var migrateRequest MigrateRequest
+	err := json.NewDecoder(req.Body).Decode(&migrateRequest)
+	if err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	log.Info("migrate request", zap.String("request", fmt.Sprintf("%+v", migrateRequest)))
+
+	ctx := context.Background()
+
+	c, err := a.clusterManager.GetCluster(ctx, migrateRequest.ClusterName)
+	if err != nil {
+		log.Error("get cluster failed", zap.String("clusterName", migrateRequest.ClusterName), zap.Error(err))
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", migrateRequest.ClusterName, err.Error()))
+	}
+
+	migrateProcedure, err := c.GetProcedureFactory().CreateMigrateProcedure(ctx, coordinator.MigrateRequest{
+		ClusterMetadata: c.GetMetadata(),
+		Snapshot:        c.GetMetadata().GetClusterSnapshot(),
+		SchemaName:      migrateRequest.SchemaName,
+		TableNames:      migrateRequest.TableNames,
+		SourceShardID:   storage.ShardID(migrateRequest.SourceShardID),
+		TargetShardID:   storage.ShardID(migrateRequest.TargetShardID),
+	})
+	if err != nil {
+		log.Error("create migrate procedure failed", zap.Error(err))
+		return errResult(ErrCreateProcedure, err.Error())
+	}
+
+	procedureID, err := c.GetProcedureManager().Submit(ctx, migrateProcedure)
+	if err != nil {
+		log.Error("submit migrate procedure failed", zap.Error(err))
+		return errResult(ErrSubmitProcedure, err.Error())
+	}
+
+	return okResult(procedureID)
+}
+
+func (a *API) merge(req *http.Request) apiFuncResult {
+	var mergeRequest MergeRequest
+	err := json.NewDecoder(req.Body).Decode(&mergeRequest)
+	if err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	log.Info("merge request", zap.String("request", fmt.Sprintf("%+v", mergeRequest)))
+
+	ctx := context.Background()
+
+	c, err := a.clusterManager.GetCluster(ctx, mergeRequest.ClusterName)
+	if err != nil {
+		log.Error("get cluster failed", zap.String("clusterName", mergeRequest.ClusterName), zap.Error(err))
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", mergeRequest.ClusterName, err.Error()))
+	}
+
+	mergeProcedure, err := c.GetProcedureFactory().CreateMergeProcedure(ctx, coordinator.MergeRequest{
+		ClusterMetadata: c.GetMetadata(),
+		Snapshot:        c.GetMetadata().GetClusterSnapshot(),
+		SourceShardID:   storage.ShardID(mergeRequest.SourceShardID),
+		TargetShardID:   storage.ShardID(mergeRequest.TargetShardID),
+	})
+	if err != nil {
+		log.Error("create merge procedure failed", zap.Error(err))
+		return errResult(ErrCreateProcedure, err.Error())
+	}
+
+	procedureID, err := c.GetProcedureManager().Submit(ctx, mergeProcedure)
+	if err != nil {
+		log.Error("submit merge procedure failed", zap.Error(err))
+		return errResult(ErrSubmitProcedure, err.Error())
+	}
+
+	return okResult(procedureID)
+}
+
 func (a *API) listClusters(req *http.Request) apiFuncResult {
 	clusters, err := a.clusterManager.ListClusters(req.Context())
 	if err != nil {
@@ -438,8 +550,191 @@ func (a *API) listProcedures(req *http.Request) apiFuncResult {
 		log.Error("list running procedure failed", zap.Error(err))
 		return errResult(procedure.ErrListRunningProcedure, fmt.Sprintf("clusterName: %s", clusterName))
 	}
+	waitingDepth, err := c.GetProcedureManager().ListWaitingDepth(ctx)
+	if err != nil {
+		log.Error("list waiting depth failed", zap.Error(err))
+		return errResult(procedure.ErrListRunningProcedure, fmt.Sprintf("clusterName: %s", clusterName))
+	}
+
+	result := ListProceduresResult{
+		Procedures:   infos,
+		WaitingDepth: make(map[string]int, len(waitingDepth)),
+	}
+	for priority, depth := range waitingDepth {
+		result.WaitingDepth[priority.String()] = depth
+	}
+	return okResult(result)
+}
+
+func (a *API) getProcedure(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	procedureID, err := strconv.ParseUint(Param(ctx, procedureIDParam), 10, 64)
+	if err != nil {
+		return errResult(ErrParseRequest, fmt.Sprintf("invalid procedureID, err: %s", err.Error()))
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	detail, err := c.GetProcedureManager().GetProcedure(ctx, procedureID)
+	if err != nil {
+		return errResult(ErrGetProcedure, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(detail)
+}
+
+func (a *API) cancelProcedure(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	procedureID, err := strconv.ParseUint(Param(ctx, procedureIDParam), 10, 64)
+	if err != nil {
+		return errResult(ErrParseRequest, fmt.Sprintf("invalid procedureID, err: %s", err.Error()))
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to cancel procedure", zap.String("clusterName", clusterName), zap.Uint64("procedureID", procedureID))
+	if err := c.GetProcedureManager().CancelProcedure(ctx, procedureID); err != nil {
+		log.Error("cancel procedure failed", zap.String("clusterName", clusterName), zap.Uint64("procedureID", procedureID), zap.Error(err))
+		return errResult(ErrCancelProcedure, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(statusSuccess)
+}
+
+// listProcedureHistory lists the completed procedures, and the optional query params are:
+// kind (repeatable), startTime and endTime (unix milliseconds of the completion time), shardID, offset and limit.
+func (a *API) listProcedureHistory(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	filter, err := parseHistoryFilter(req.URL.Query())
+	if err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	historyInfos, err := c.GetProcedureManager().ListHistoryProcedure(ctx, filter)
+	if err != nil {
+		log.Error("list procedure history failed", zap.String("clusterName", clusterName), zap.Error(err))
+		return errResult(ErrListProcedureHistory, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(historyInfos)
+}
+
+func parseHistoryFilter(query url.Values) (procedure.HistoryFilter, error) {
+	var filter procedure.HistoryFilter
+	for _, kindStr := range query["kind"] {
+		kind, err := strconv.ParseUint(kindStr, 10, 32)
+		if err != nil {
+			return filter, errors.WithMessagef(err, "invalid kind:%s", kindStr)
+		}
+		filter.Kinds = append(filter.Kinds, procedure.Kind(kind))
+	}
+	if startTimeStr := query.Get("startTime"); len(startTimeStr) != 0 {
+		startTime, err := strconv.ParseInt(startTimeStr, 10, 64)
+		if err != nil {
+			return filter, errors.WithMessagef(err, "invalid startTime:%s", startTimeStr)
+		}
+		filter.StartTime = time.UnixMilli(startTime)
+	}
+	if endTimeStr := query.Get("endTime"); len(endTimeStr) != 0 {
+		endTime, err := strconv.ParseInt(endTimeStr, 10, 64)
+		if err != nil {
+			return filter, errors.WithMessagef(err, "invalid endTime:%s", endTimeStr)
+		}
+		filter.EndTime = time.UnixMilli(endTime)
+	}
+	if shardIDStr := query.Get("shardID"); len(shardIDStr) != 0 {
+		shardID, err := strconv.ParseUint(shardIDStr, 10, 32)
+		if err != nil {
+			return filter, errors.WithMessagef(err, "invalid shardID:%s", shardIDStr)
+		}
+		id := storage.ShardID(shardID)
+		filter.ShardID = &id
+	}
+	if offsetStr := query.Get("offset"); len(offsetStr) != 0 {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return filter, errors.Errorf("invalid offset:%s", offsetStr)
+		}
+		filter.Offset = offset
+	}
+	if limitStr := query.Get("limit"); len(limitStr) != 0 {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			return filter, errors.Errorf("invalid limit:%s", limitStr)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// listScheduleAudit lists the procedures submitted by the schedulers, and the optional query params are: shardID, node and limit.
+func (a *API) listScheduleAudit(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+	filter, err := parseScheduleAuditFilter(req.URL.Query())
+	if err != nil {
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	records, err := c.GetSchedulerManager().ListScheduleAuditRecords(ctx, filter)
+	if err != nil {
+		log.Error("list schedule audit failed", zap.String("clusterName", clusterName), zap.Error(err))
+		return errResult(ErrListScheduleAudit, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	return okResult(records)
+}
 
-	return okResult(infos)
+func parseScheduleAuditFilter(query url.Values) (manager.ScheduleAuditFilter, error) {
+	var filter manager.ScheduleAuditFilter
+	if shardIDStr := query.Get("shardID"); len(shardIDStr) != 0 {
+		shardID, err := strconv.ParseUint(shardIDStr, 10, 32)
+		if err != nil {
+			return filter, errors.WithMessagef(err, "invalid shardID:%s", shardIDStr)
+		}
+		id := storage.ShardID(shardID)
+		filter.ShardID = &id
+	}
+	filter.NodeName = query.Get("node")
+	if limitStr := query.Get("limit"); len(limitStr) != 0 {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			return filter, errors.Errorf("invalid limit:%s", limitStr)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
 }
 
 func (a *API) listShardAffinities(req *http.Request) apiFuncResult {
@@ -494,6 +789,39 @@ func (a *API) addShardAffinities(req *http.Request) apiFuncResult {
 	return okResult(nil)
 }
 
+// addShardAntiAffinities adds the anti-affinities of the shards, and they are removed together with the affinities of the shards.
+func (a *API) addShardAntiAffinities(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var antiAffinities []scheduler.ShardAntiAffinity
+	err := json.NewDecoder(req.Body).Decode(&antiAffinities)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	log.Info("try to apply shard anti-affinity rule", zap.String("cluster", clusterName), zap.String("antiAffinity", fmt.Sprintf("%+v", antiAffinities)))
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	err = c.GetSchedulerManager().AddShardAffinityRule(ctx, scheduler.ShardAffinityRule{AntiAffinities: antiAffinities})
+	if err != nil {
+		log.Error("failed to apply shard anti-affinity rule", zap.String("cluster", clusterName), zap.String("antiAffinity", fmt.Sprintf("%+v", antiAffinities)), zap.Error(err))
+		return errResult(ErrAddAffinityRule, fmt.Sprintf("err: %v", err))
+	}
+
+	log.Info("finish applying shard anti-affinity rule", zap.String("cluster", clusterName), zap.String("rules", fmt.Sprintf("%+v", antiAffinities)))
+
+	return okResult(nil)
+}
+
 func (a *API) removeShardAffinities(req *http.Request) apiFuncResult {
 	ctx := req.Context()
 	clusterName := Param(ctx, clusterNameParam)
@@ -525,6 +853,486 @@ func (a *API) removeShardAffinities(req *http.Request) apiFuncResult {
 	return okResult(nil)
 }
 
+func (a *API) getShardPlacement(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	rules, err := c.GetSchedulerManager().GetShardPlacementRules(ctx)
+	if err != nil {
+		return errResult(ErrGetShardPlacement, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(rules)
+}
+
+func (a *API) updateShardPlacement(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var updateShardPlacementRequest UpdateShardPlacementRequest
+	err := json.NewDecoder(req.Body).Decode(&updateShardPlacementRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to update shard placement", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", updateShardPlacementRequest)))
+	if err := c.GetSchedulerManager().UpdateShardPlacementRules(ctx, updateShardPlacementRequest.NodePicker, updateShardPlacementRequest.ShardGroups); err != nil {
+		log.Error("failed to update shard placement", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrUpdateShardPlacement, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) getNodeWeights(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	nodeWeights, err := c.GetSchedulerManager().GetNodeWeights(ctx)
+	if err != nil {
+		return errResult(ErrGetNodeWeights, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(nodeWeights)
+}
+
+func (a *API) updateNodeWeights(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var updateNodeWeightsRequest UpdateNodeWeightsRequest
+	err := json.NewDecoder(req.Body).Decode(&updateNodeWeightsRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to update node weights", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", updateNodeWeightsRequest)))
+	if err := c.GetSchedulerManager().UpdateNodeWeights(ctx, updateNodeWeightsRequest.NodeWeights); err != nil {
+		log.Error("failed to update node weights", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrUpdateNodeWeights, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) getReplicas(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	replicaNum, err := c.GetSchedulerManager().GetReplicaNum(ctx)
+	if err != nil {
+		return errResult(ErrGetReplicas, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(ReplicasResult{ReplicaNum: max(replicaNum, 1)})
+}
+
+func (a *API) updateReplicas(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var updateReplicasRequest UpdateReplicasRequest
+	err := json.NewDecoder(req.Body).Decode(&updateReplicasRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to update replicas", zap.String("cluster", clusterName), zap.Uint32("replicaNum", updateReplicasRequest.ReplicaNum))
+	if err := c.GetSchedulerManager().UpdateReplicaNum(ctx, updateReplicasRequest.ReplicaNum); err != nil {
+		log.Error("failed to update replicas", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrUpdateReplicas, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) expandShards(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var expandShardsRequest ExpandShardsRequest
+	err := json.NewDecoder(req.Body).Decode(&expandShardsRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to expand shards", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", expandShardsRequest)))
+	result, err := c.GetSchedulerManager().ExpandShards(ctx, manager.ExpandShardsRequest{
+		NumShards:   expandShardsRequest.NumShards,
+		SplitTables: expandShardsRequest.SplitTables,
+	})
+	if err != nil {
+		log.Error("failed to expand shards", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrExpandShards, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(result)
+}
+
+func (a *API) getClusterSettings(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	settings, err := c.GetSchedulerManager().GetSchedulerSettings(ctx)
+	if err != nil {
+		return errResult(ErrGetClusterSettings, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(settings)
+}
+
+func (a *API) updateClusterSettings(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var updateClusterSettingsRequest UpdateClusterSettingsRequest
+	err := json.NewDecoder(req.Body).Decode(&updateClusterSettingsRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to update cluster settings", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", updateClusterSettingsRequest)))
+	settings, err := c.GetSchedulerManager().UpdateSchedulerSettings(ctx, manager.UpdateSchedulerSettingsRequest{
+		EnableSchedule: updateClusterSettingsRequest.EnableSchedule,
+		Schedulers:     updateClusterSettingsRequest.Schedulers,
+	})
+	if err != nil {
+		log.Error("failed to update cluster settings", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrUpdateClusterSettings, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(settings)
+}
+
+func (a *API) getMaintenanceWindows(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	windows, err := c.GetSchedulerManager().GetMaintenanceWindows(ctx)
+	if err != nil {
+		return errResult(ErrGetMaintenanceWindows, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(windows)
+}
+
+func (a *API) updateMaintenanceWindows(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var updateMaintenanceWindowsRequest UpdateMaintenanceWindowsRequest
+	err := json.NewDecoder(req.Body).Decode(&updateMaintenanceWindowsRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to update maintenance windows", zap.String("cluster", clusterName), zap.String("request", fmt.Sprintf("%+v", updateMaintenanceWindowsRequest)))
+	if err := c.GetSchedulerManager().UpdateMaintenanceWindows(ctx, updateMaintenanceWindowsRequest.Windows); err != nil {
+		log.Error("failed to update maintenance windows", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrUpdateMaintenanceWindows, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) getSchedulers(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	schedulers, err := c.GetSchedulerManager().GetSchedulerList(ctx)
+	if err != nil {
+		return errResult(ErrGetSchedulers, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(schedulers)
+}
+
+func (a *API) updateSchedulers(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var updateSchedulersRequest UpdateSchedulersRequest
+	err := json.NewDecoder(req.Body).Decode(&updateSchedulersRequest)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to update schedulers", zap.String("cluster", clusterName), zap.Any("request", updateSchedulersRequest))
+	schedulers, err := c.GetSchedulerManager().UpdateSchedulerList(ctx, updateSchedulersRequest.Schedulers)
+	if err != nil {
+		log.Error("failed to update schedulers", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrUpdateSchedulers, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(schedulers)
+}
+
+func (a *API) drainNode(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	nodeName := Param(ctx, nodeNameParam)
+	if len(clusterName) == 0 || len(nodeName) == 0 {
+		return errResult(ErrParseRequest, "clusterName and nodeName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to drain node", zap.String("cluster", clusterName), zap.String("node", nodeName))
+	progress, err := c.GetSchedulerManager().DrainNode(ctx, nodeName)
+	if err != nil {
+		log.Error("failed to drain node", zap.String("cluster", clusterName), zap.String("node", nodeName), zap.Error(err))
+		return errResult(ErrDrainNode, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(progress)
+}
+
+func (a *API) undrainNode(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	nodeName := Param(ctx, nodeNameParam)
+	if len(clusterName) == 0 || len(nodeName) == 0 {
+		return errResult(ErrParseRequest, "clusterName and nodeName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	log.Info("try to undrain node", zap.String("cluster", clusterName), zap.String("node", nodeName))
+	if err := c.GetSchedulerManager().UndrainNode(ctx, nodeName); err != nil {
+		log.Error("failed to undrain node", zap.String("cluster", clusterName), zap.String("node", nodeName), zap.Error(err))
+		return errResult(ErrUndrainNode, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(nil)
+}
+
+func (a *API) getDrainProgress(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	nodeName := Param(ctx, nodeNameParam)
+	if len(clusterName) == 0 || len(nodeName) == 0 {
+		return errResult(ErrParseRequest, "clusterName and nodeName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	progress, err := c.GetSchedulerManager().GetDrainProgress(ctx, nodeName)
+	if err != nil {
+		return errResult(ErrGetDrainProgress, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(progress)
+}
+
+func (a *API) dryRunSchedule(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	// The body can be empty, which means the current cluster is scheduled.
+	var dryRunScheduleRequest DryRunScheduleRequest
+	if err := json.NewDecoder(req.Body).Decode(&dryRunScheduleRequest); err != nil && !errors.Is(err, io.EOF) {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	now := uint64(time.Now().UnixMilli())
+	addNodes := make([]storage.Node, 0, len(dryRunScheduleRequest.AddNodes))
+	for _, node := range dryRunScheduleRequest.AddNodes {
+		nodeStats := storage.NewEmptyNodeStats()
+		nodeStats.Zone = node.Zone
+		addNodes = append(addNodes, storage.Node{
+			Name:          node.Name,
+			NodeStats:     nodeStats,
+			LastTouchTime: now,
+			State:         storage.NodeStateOnline,
+		})
+	}
+
+	results, err := c.GetSchedulerManager().DryRun(ctx, manager.DryRunRequest{
+		AddNodes:        addNodes,
+		RemoveNodes:     dryRunScheduleRequest.RemoveNodes,
+		ShardAffinities: dryRunScheduleRequest.ShardAffinities,
+	})
+	if err != nil {
+		log.Error("failed to dry run schedule", zap.String("cluster", clusterName), zap.Error(err))
+		return errResult(ErrDryRunSchedule, fmt.Sprintf("err: %v", err))
+	}
+
+	return okResult(results)
+}
+
+func (a *API) reportNodeLoad(req *http.Request) apiFuncResult {
+	ctx := req.Context()
+	clusterName := Param(ctx, clusterNameParam)
+	if len(clusterName) == 0 {
+		return errResult(ErrParseRequest, "clusterName could not be empty")
+	}
+
+	var decodedReq ReportNodeLoadRequest
+	err := json.NewDecoder(req.Body).Decode(&decodedReq)
+	if err != nil {
+		log.Error("decode request body failed", zap.Error(err))
+		return errResult(ErrParseRequest, err.Error())
+	}
+	if len(decodedReq.NodeName) == 0 {
+		return errResult(ErrParseRequest, "nodeName could not be empty")
+	}
+
+	c, err := a.clusterManager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return errResult(ErrGetCluster, fmt.Sprintf("clusterName: %s, err: %s", clusterName, err.Error()))
+	}
+
+	shardLoads := make(map[storage.ShardID]storage.ShardLoad, len(decodedReq.ShardLoads))
+	for _, shardLoad := range decodedReq.ShardLoads {
+		shardLoads[shardLoad.ShardID] = storage.ShardLoad{
+			WriteQPS:  shardLoad.WriteQPS,
+			DiskBytes: shardLoad.DiskBytes,
+		}
+	}
+	load := storage.NodeLoad{
+		CPUUsage:    decodedReq.CPUUsage,
+		MemoryUsage: decodedReq.MemoryUsage,
+		DiskUsage:   decodedReq.DiskUsage,
+		WriteQPS:    decodedReq.WriteQPS,
+		ShardLoads:  shardLoads,
+		ReportedAt:  uint64(time.Now().UnixMilli()),
+	}
+	if err := c.GetMetadata().UpdateNodeLoad(decodedReq.NodeName, load); err != nil {
+		return errResult(ErrReportNodeLoad, err.Error())
+	}
+
+	return okResult(nil)
+}
+
 func (a *API) queryTable(r *http.Request) apiFuncResult {
 	var req QueryTableRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -46,4 +46,26 @@ var (
 	ErrListAffinityRules             = coderr.NewCodeError(coderr.Internal, "list affinity rules")
 	ErrAddAffinityRule               = coderr.NewCodeError(coderr.Internal, "add affinity rule")
 	ErrRemoveAffinityRule            = coderr.NewCodeError(coderr.Internal, "remove affinity rule")
+	ErrGetProcedure                  = coderr.NewCodeError(coderr.Internal, "get procedure")
+	ErrCancelProcedure               = coderr.NewCodeError(coderr.Internal, "cancel procedure")
+	ErrListProcedureHistory          = coderr.NewCodeError(coderr.Internal, "list procedure history")
+	ErrReportNodeLoad                = coderr.NewCodeError(coderr.Internal, "report node load")
+	ErrGetShardPlacement             = coderr.NewCodeError(coderr.Internal, "get shard placement")
+	ErrUpdateShardPlacement          = coderr.NewCodeError(coderr.Internal, "update shard placement")
+	ErrDrainNode                     = coderr.NewCodeError(coderr.Internal, "drain node")
+	ErrUndrainNode                   = coderr.NewCodeError(coderr.Internal, "undrain node")
+	ErrGetDrainProgress              = coderr.NewCodeError(coderr.Internal, "get drain progress")
+	ErrDryRunSchedule                = coderr.NewCodeError(coderr.Internal, "dry run schedule")
+	ErrGetClusterSettings            = coderr.NewCodeError(coderr.Internal, "get cluster settings")
+	ErrUpdateClusterSettings         = coderr.NewCodeError(coderr.Internal, "update cluster settings")
+	ErrListScheduleAudit             = coderr.NewCodeError(coderr.Internal, "list schedule audit")
+	ErrGetMaintenanceWindows         = coderr.NewCodeError(coderr.Internal, "get maintenance windows")
+	ErrUpdateMaintenanceWindows      = coderr.NewCodeError(coderr.Internal, "update maintenance windows")
+	ErrGetNodeWeights                = coderr.NewCodeError(coderr.Internal, "get node weights")
+	ErrUpdateNodeWeights             = coderr.NewCodeError(coderr.Internal, "update node weights")
+	ErrGetReplicas                   = coderr.NewCodeError(coderr.Internal, "get replicas")
+	ErrUpdateReplicas                = coderr.NewCodeError(coderr.Internal, "update replicas")
+	ErrExpandShards                  = coderr.NewCodeError(coderr.Internal, "expand shards")
+	ErrGetSchedulers                 = coderr.NewCodeError(coderr.Internal, "get schedulers")
+	ErrUpdateSchedulers              = coderr.NewCodeError(coderr.Internal, "update schedulers")
 )
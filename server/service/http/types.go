@@ -24,6 +24,8 @@ import (
 
 	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/server/cluster"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/procedure"
+	"github.com/apache/incubator-horaedb-meta/server/coordinator/scheduler"
 	"github.com/apache/incubator-horaedb-meta/server/limiter"
 	"github.com/apache/incubator-horaedb-meta/server/status"
 	"github.com/apache/incubator-horaedb-meta/server/storage"
@@ -33,6 +35,8 @@ const (
 	statusSuccess    string = "success"
 	statusError      string = "error"
 	clusterNameParam string = "cluster"
+	procedureIDParam string = "procedureID"
+	nodeNameParam    string = "node"
 
 	apiPrefix string = "/api/v1"
 )
@@ -90,6 +94,12 @@ type DiagnoseShardResult struct {
 	UnreadyShards      map[storage.ShardID]DiagnoseShardStatus `json:"unready_shards"`
 }
 
+type ListProceduresResult struct {
+	Procedures []*procedure.Info `json:"procedures"`
+	// WaitingDepth is the number of the waiting procedures of each priority.
+	WaitingDepth map[string]int `json:"waitingDepth"`
+}
+
 type QueryTableRequest struct {
 	ClusterName string   `json:"clusterName"`
 	SchemaName  string   `json:"schemaName"`
@@ -138,6 +148,20 @@ type SplitRequest struct {
 	NodeName    string   `json:"nodeName"`
 }
 
+type MigrateRequest struct {
+	ClusterName   string   `json:"clusterName"`
+	SchemaName    string   `json:"schemaName"`
+	SourceShardID uint32   `json:"sourceShardID"`
+	TargetShardID uint32   `json:"targetShardID"`
+	TableNames    []string `json:"tableNames"`
+}
+
+type MergeRequest struct {
+	ClusterName   string `json:"clusterName"`
+	SourceShardID uint32 `json:"sourceShardID"`
+	TargetShardID uint32 `json:"targetShardID"`
+}
+
 type CreateClusterRequest struct {
 	Name                        string `json:"Name"`
 	NodeCount                   uint32 `json:"NodeCount"`
@@ -165,6 +189,81 @@ type UpdateEnableScheduleRequest struct {
 	Enable bool `json:"enable"`
 }
 
+// UpdateClusterSettingsRequest updates the settings of the cluster partially, and the fields not given are kept unchanged.
+type UpdateClusterSettingsRequest struct {
+	EnableSchedule *bool `json:"enableSchedule"`
+	// Schedulers enables or disables the schedulers by name.
+	Schedulers map[string]bool `json:"schedulers"`
+}
+
+// UpdateMaintenanceWindowsRequest replaces the maintenance windows of the cluster, and empty windows means the schedulers can run at any time.
+type UpdateMaintenanceWindowsRequest struct {
+	Windows []storage.MaintenanceWindow `json:"windows"`
+}
+
+// UpdateSchedulersRequest replaces the ordered list of the schedulers run by the cluster, and empty schedulers means the default schedulers
+// of the topology type are run.
+type UpdateSchedulersRequest struct {
+	Schedulers []storage.SchedulerConfig `json:"schedulers"`
+}
+
+// UpdateShardPlacementRequest selects the node picker of the cluster, and the shards in the same group are spread across zones by the
+// zone aware node picker.
+type UpdateShardPlacementRequest struct {
+	NodePicker  string               `json:"nodePicker"`
+	ShardGroups []storage.ShardGroup `json:"shardGroups"`
+}
+
+// UpdateNodeWeightsRequest replaces the weights of the nodes, and the share of shards of a node is proportional to its weight.
+// The nodes not included get the default weight 100.
+type UpdateNodeWeightsRequest struct {
+	NodeWeights []storage.NodeWeight `json:"nodeWeights"`
+}
+
+// UpdateReplicasRequest sets the number of the replicas of every shard including the leader, and one means no follower.
+type UpdateReplicasRequest struct {
+	ReplicaNum uint32 `json:"replicaNum"`
+}
+
+type ReplicasResult struct {
+	ReplicaNum uint32 `json:"replicaNum"`
+}
+
+// ExpandShardsRequest adds new shards to the cluster, and the tables are split from the existing shards onto them if SplitTables is true.
+type ExpandShardsRequest struct {
+	NumShards   uint32 `json:"numShards"`
+	SplitTables bool   `json:"splitTables"`
+}
+
+// DryRunScheduleRequest describes the hypothetical changes of the cluster, and the current cluster is scheduled if no change is given.
+// The current shard affinity rules are replaced if ShardAffinities is given, even if it is empty.
+type DryRunScheduleRequest struct {
+	AddNodes        []DryRunNode              `json:"addNodes"`
+	RemoveNodes     []string                  `json:"removeNodes"`
+	ShardAffinities []scheduler.ShardAffinity `json:"shardAffinities"`
+}
+
+type DryRunNode struct {
+	Name string `json:"name"`
+	Zone string `json:"zone"`
+}
+
 type RemoveShardAffinitiesRequest struct {
 	ShardIDs []storage.ShardID `json:"shardIDs"`
 }
+
+// ReportNodeLoadRequest is reported by the node periodically, the usages are ratios in [0, 1].
+type ReportNodeLoadRequest struct {
+	NodeName    string      `json:"nodeName"`
+	CPUUsage    float64     `json:"cpuUsage"`
+	MemoryUsage float64     `json:"memoryUsage"`
+	DiskUsage   float64     `json:"diskUsage"`
+	WriteQPS    float64     `json:"writeQPS"`
+	ShardLoads  []ShardLoad `json:"shardLoads"`
+}
+
+type ShardLoad struct {
+	ShardID   storage.ShardID `json:"shardID"`
+	WriteQPS  float64         `json:"writeQPS"`
+	DiskBytes uint64          `json:"diskBytes"`
+}
@@ -25,13 +25,17 @@ var (
 	ErrEncode = coderr.NewCodeError(coderr.Internal, "storage encode")
 	ErrDecode = coderr.NewCodeError(coderr.Internal, "storage decode")
 
-	ErrCreateSchemaAgain         = coderr.NewCodeError(coderr.Internal, "storage create schemas")
-	ErrCreateClusterAgain        = coderr.NewCodeError(coderr.Internal, "storage create cluster")
-	ErrUpdateCluster             = coderr.NewCodeError(coderr.Internal, "storage update cluster")
-	ErrCreateClusterViewAgain    = coderr.NewCodeError(coderr.Internal, "storage create cluster view")
-	ErrUpdateClusterViewConflict = coderr.NewCodeError(coderr.Internal, "storage update cluster view")
-	ErrCreateTableAgain          = coderr.NewCodeError(coderr.Internal, "storage create tables")
-	ErrDeleteTableAgain          = coderr.NewCodeError(coderr.Internal, "storage delete table")
-	ErrCreateShardViewAgain      = coderr.NewCodeError(coderr.Internal, "storage create shard view")
-	ErrUpdateShardViewConflict   = coderr.NewCodeError(coderr.Internal, "storage update shard view")
+	ErrCreateSchemaAgain              = coderr.NewCodeError(coderr.Internal, "storage create schemas")
+	ErrCreateClusterAgain             = coderr.NewCodeError(coderr.Internal, "storage create cluster")
+	ErrUpdateCluster                  = coderr.NewCodeError(coderr.Internal, "storage update cluster")
+	ErrCreateClusterViewAgain         = coderr.NewCodeError(coderr.Internal, "storage create cluster view")
+	ErrUpdateClusterViewConflict      = coderr.NewCodeError(coderr.Internal, "storage update cluster view")
+	ErrCreateTableAgain               = coderr.NewCodeError(coderr.Internal, "storage create tables")
+	ErrDeleteTableAgain               = coderr.NewCodeError(coderr.Internal, "storage delete table")
+	ErrCreateShardViewAgain           = coderr.NewCodeError(coderr.Internal, "storage create shard view")
+	ErrUpdateShardViewConflict        = coderr.NewCodeError(coderr.Internal, "storage update shard view")
+	ErrDeleteShardViewConflict        = coderr.NewCodeError(coderr.Internal, "storage delete shard view")
+	ErrUpdateVersionedValueConflict   = coderr.NewCodeError(coderr.Internal, "storage update versioned value")
+	ErrAppendScheduleAuditConflict    = coderr.NewCodeError(coderr.Internal, "storage append schedule audit record")
+	ErrUpdateScheduleAuditOverwritten = coderr.NewCodeError(coderr.Internal, "storage update schedule audit record")
 )
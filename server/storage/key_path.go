@@ -37,6 +37,9 @@ const (
 	latestVersion = "latest_version"
 	info          = "info"
 	tableAssign   = "table_assign"
+	scheduleAudit = "schedule_audit"
+	latestID      = "latest_id"
+	record        = "record"
 )
 
 // makeSchemaKey returns the key path to the schema meta info.
@@ -142,6 +145,44 @@ func makeTableAssignPrefixKey(rootPath string, clusterID uint32, schemaID uint32
 	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), schema, fmtID(uint64(schemaID)), tableAssign)
 }
 
+// makeVersionedValueLatestVersionKey returns the latest version key path of the versioned value.
+func makeVersionedValueLatestVersionKey(rootPath string, clusterID uint32, key VersionedKey) string {
+	// Example:
+	//	v1/cluster/1/shard_affinity/latest_version -> 2
+	//	v1/cluster/1/node_weights/latest_version -> 1
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), string(key), latestVersion)
+}
+
+// makeVersionedValueKey returns the key path of the versioned value.
+func makeVersionedValueKey(rootPath string, clusterID uint32, key VersionedKey) string {
+	// Example:
+	//	v1/cluster/1/shard_affinity/info -> ShardAffinityRules
+	//	v1/cluster/1/node_weights/info -> NodeWeightList
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), string(key), info)
+}
+
+// makeScheduleAuditLatestIDKey returns the key path of the ID of the latest schedule audit record.
+func makeScheduleAuditLatestIDKey(rootPath string, clusterID uint32) string {
+	// Example:
+	//	v1/cluster/1/schedule_audit/latest_id -> 1024
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), scheduleAudit, latestID)
+}
+
+// makeScheduleAuditRecordKey returns the key path of the slot in the ring buffer of the schedule audit records.
+func makeScheduleAuditRecordKey(rootPath string, clusterID uint32, slot uint64) string {
+	// Example:
+	//	v1/cluster/1/schedule_audit/record/0 -> ScheduleAuditRecord
+	//	v1/cluster/1/schedule_audit/record/1 -> ScheduleAuditRecord
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), scheduleAudit, record, fmtID(slot))
+}
+
+// makeScheduleAuditRecordPrefixKey returns the key prefix of the schedule audit records.
+func makeScheduleAuditRecordPrefixKey(rootPath string, clusterID uint32) string {
+	// Example:
+	//	v1/cluster/1/schedule_audit/record/
+	return path.Join(rootPath, version, cluster, fmtID(uint64(clusterID)), scheduleAudit, record) + "/"
+}
+
 func fmtID(id uint64) string {
 	return fmt.Sprintf("%020d", id)
 }
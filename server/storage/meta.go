@@ -70,11 +70,27 @@ type Storage interface {
 	ListShardViews(ctx context.Context, req ListShardViewsRequest) (ListShardViewsResult, error)
 	// UpdateShardView update shard views in specified cluster.
 	UpdateShardView(ctx context.Context, req UpdateShardViewRequest) error
+	// DeleteShardView delete shard view in specified cluster, return error if the latest version is not matched.
+	DeleteShardView(ctx context.Context, req DeleteShardViewRequest) error
 
 	// ListNodes list all nodes in specified cluster.
 	ListNodes(ctx context.Context, req ListNodesRequest) (ListNodesResult, error)
 	// CreateOrUpdateNode create or update node in specified cluster.
 	CreateOrUpdateNode(ctx context.Context, req CreateOrUpdateNodeRequest) error
+
+	// GetVersionedValue get the value of the key in specified cluster, and the value of the request is left untouched if not exists.
+	GetVersionedValue(ctx context.Context, req GetVersionedValueRequest) (GetVersionedValueResult, error)
+	// UpdateVersionedValue update the value of the key in specified cluster, return error if the latest version is not matched.
+	UpdateVersionedValue(ctx context.Context, req UpdateVersionedValueRequest) error
+
+	// ListScheduleAuditRecords list all the schedule audit records kept in the ring buffer of specified cluster.
+	ListScheduleAuditRecords(ctx context.Context, req ListScheduleAuditRecordsRequest) (ListScheduleAuditRecordsResult, error)
+	// AppendScheduleAuditRecord append the record to the ring buffer of specified cluster, the oldest record is overwritten if the buffer is full,
+	// and return error if the ID of the record doesn't follow the latest one.
+	AppendScheduleAuditRecord(ctx context.Context, req AppendScheduleAuditRecordRequest) error
+	// UpdateScheduleAuditRecord overwrite the record kept in the ring buffer of specified cluster, and return error if it has been overwritten
+	// by the newer records.
+	UpdateScheduleAuditRecord(ctx context.Context, req UpdateScheduleAuditRecordRequest) error
 }
 
 // NewStorageWithEtcdBackend creates a new storage with etcd backend.
@@ -21,10 +21,12 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"math"
 	"strconv"
 	"strings"
 
+	"github.com/apache/incubator-horaedb-meta/pkg/coderr"
 	"github.com/apache/incubator-horaedb-meta/pkg/log"
 	"github.com/apache/incubator-horaedb-meta/server/etcdutil"
 	"github.com/apache/incubator-horaedb-proto/golang/pkg/clusterpb"
@@ -616,6 +618,29 @@ func (s *metaStorageImpl) UpdateShardView(ctx context.Context, req UpdateShardVi
 	return nil
 }
 
+func (s *metaStorageImpl) DeleteShardView(ctx context.Context, req DeleteShardViewRequest) error {
+	key := makeShardViewKey(s.rootPath, uint32(req.ClusterID), uint32(req.ShardID), fmtID(req.LatestVersion))
+	latestVersionKey := makeShardViewLatestVersionKey(s.rootPath, uint32(req.ClusterID), uint32(req.ShardID))
+
+	// Check whether the latest version is equal to that in etcd. If it is equal, delete shard view and latest version; Otherwise, return an error.
+	latestVersionEquals := clientv3.Compare(clientv3.Value(latestVersionKey), "=", fmtID(req.LatestVersion))
+	opDelShardView := clientv3.OpDelete(key)
+	opDelLatestVersion := clientv3.OpDelete(latestVersionKey)
+
+	resp, err := s.client.Txn(ctx).
+		If(latestVersionEquals).
+		Then(opDelShardView, opDelLatestVersion).
+		Commit()
+	if err != nil {
+		return errors.WithMessagef(err, "fail to delete shard view, clusterID:%d, shardID:%d, key:%s", req.ClusterID, req.ShardID, key)
+	}
+	if !resp.Succeeded {
+		return ErrDeleteShardViewConflict.WithCausef("shard view may have been modified, clusterID:%d, shardID:%d, key:%s, resp:%v", req.ClusterID, req.ShardID, key, resp)
+	}
+
+	return nil
+}
+
 func (s *metaStorageImpl) ListNodes(ctx context.Context, req ListNodesRequest) (ListNodesResult, error) {
 	startKey := makeNodeKey(s.rootPath, uint32(req.ClusterID), string([]byte{0}))
 	endKey := makeNodeKey(s.rootPath, uint32(req.ClusterID), string([]byte{255}))
@@ -659,3 +684,164 @@ func (s *metaStorageImpl) CreateOrUpdateNode(ctx context.Context, req CreateOrUp
 
 	return nil
 }
+
+func (s *metaStorageImpl) GetVersionedValue(ctx context.Context, req GetVersionedValueRequest) (GetVersionedValueResult, error) {
+	exists, err := s.getJSON(ctx, makeVersionedValueKey(s.rootPath, uint32(req.ClusterID), req.Key), req.Value)
+	if err != nil {
+		return GetVersionedValueResult{}, errors.WithMessagef(err, "get versioned value, clusterID:%d, key:%s", req.ClusterID, req.Key)
+	}
+
+	return GetVersionedValueResult{Exists: exists}, nil
+}
+
+func (s *metaStorageImpl) UpdateVersionedValue(ctx context.Context, req UpdateVersionedValueRequest) error {
+	key := makeVersionedValueKey(s.rootPath, uint32(req.ClusterID), req.Key)
+	latestVersionKey := makeVersionedValueLatestVersionKey(s.rootPath, uint32(req.ClusterID), req.Key)
+	if err := s.putVersionedJSON(ctx, key, latestVersionKey, req.LatestVersion, req.Version, req.Value, ErrUpdateVersionedValueConflict); err != nil {
+		return errors.WithMessagef(err, "update versioned value, clusterID:%d, key:%s", req.ClusterID, req.Key)
+	}
+
+	return nil
+}
+
+// getJSON decodes the value of the key into value, and false is returned if the key doesn't exist.
+// It is used by the records having no protobuf definition, which are encoded as json.
+func (s *metaStorageImpl) getJSON(ctx context.Context, key string, value any) (bool, error) {
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return false, errors.WithMessagef(err, "get key:%s", key)
+	}
+	if len(resp.Kvs) == 0 {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(resp.Kvs[0].Value, value); err != nil {
+		return false, ErrDecode.WithCausef("decode json value, key:%s, err:%v", key, err)
+	}
+	return true, nil
+}
+
+// putVersionedJSON puts the value encoded as json into the key, and newVersion into latestVersionKey.
+// The version in latestVersionKey must be equal to latestVersion, so the value updated by a stale leader won't overwrite the newer
+// one, and conflictErr is returned otherwise.
+func (s *metaStorageImpl) putVersionedJSON(ctx context.Context, key, latestVersionKey string, latestVersion, newVersion uint64, value any, conflictErr coderr.CodeError) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ErrEncode.WithCausef("encode json value, key:%s, err:%v", key, err)
+	}
+
+	latestVersionMatched := clientv3util.KeyMissing(latestVersionKey)
+	if latestVersion != 0 {
+		latestVersionMatched = clientv3.Compare(clientv3.Value(latestVersionKey), "=", fmtID(latestVersion))
+	}
+	opPutValue := clientv3.OpPut(key, string(encoded))
+	opPutLatestVersion := clientv3.OpPut(latestVersionKey, fmtID(newVersion))
+
+	resp, err := s.client.Txn(ctx).
+		If(latestVersionMatched).
+		Then(opPutValue, opPutLatestVersion).
+		Commit()
+	if err != nil {
+		return errors.WithMessagef(err, "put key:%s", key)
+	}
+	if !resp.Succeeded {
+		return conflictErr.WithCausef("value may have been modified, key:%s, latestVersion:%d, resp:%v", key, latestVersion, resp)
+	}
+
+	return nil
+}
+
+func (s *metaStorageImpl) ListScheduleAuditRecords(ctx context.Context, req ListScheduleAuditRecordsRequest) (ListScheduleAuditRecordsResult, error) {
+	latestIDKey := makeScheduleAuditLatestIDKey(s.rootPath, uint32(req.ClusterID))
+	resp, err := s.client.Get(ctx, latestIDKey)
+	if err != nil {
+		return ListScheduleAuditRecordsResult{}, errors.WithMessagef(err, "get latest schedule audit record id, clusterID:%d, key:%s", req.ClusterID, latestIDKey)
+	}
+	if len(resp.Kvs) == 0 {
+		return ListScheduleAuditRecordsResult{Records: []ScheduleAuditRecord{}, LatestID: 0}, nil
+	}
+	latestID, err := strconv.ParseUint(string(resp.Kvs[0].Value), 10, 64)
+	if err != nil {
+		return ListScheduleAuditRecordsResult{}, ErrDecode.WithCausef("decode latest schedule audit record id, clusterID:%d, err:%v", req.ClusterID, err)
+	}
+
+	prefix := makeScheduleAuditRecordPrefixKey(s.rootPath, uint32(req.ClusterID))
+	resp, err = s.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return ListScheduleAuditRecordsResult{}, errors.WithMessagef(err, "list schedule audit records, clusterID:%d, prefix:%s", req.ClusterID, prefix)
+	}
+	records := make([]ScheduleAuditRecord, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var record ScheduleAuditRecord
+		if err := json.Unmarshal(kv.Value, &record); err != nil {
+			return ListScheduleAuditRecordsResult{}, ErrDecode.WithCausef("decode schedule audit record, clusterID:%d, key:%s, err:%v", req.ClusterID, kv.Key, err)
+		}
+		records = append(records, record)
+	}
+
+	return ListScheduleAuditRecordsResult{Records: records, LatestID: latestID}, nil
+}
+
+func (s *metaStorageImpl) AppendScheduleAuditRecord(ctx context.Context, req AppendScheduleAuditRecordRequest) error {
+	if req.Capacity == 0 || req.Record.ID == 0 {
+		return errors.Errorf("invalid schedule audit record, clusterID:%d, recordID:%d, capacity:%d", req.ClusterID, req.Record.ID, req.Capacity)
+	}
+	value, err := json.Marshal(req.Record)
+	if err != nil {
+		return ErrEncode.WithCausef("encode schedule audit record, clusterID:%d, err:%v", req.ClusterID, err)
+	}
+
+	key := makeScheduleAuditRecordKey(s.rootPath, uint32(req.ClusterID), req.Record.ID%req.Capacity)
+	latestIDKey := makeScheduleAuditLatestIDKey(s.rootPath, uint32(req.ClusterID))
+
+	latestIDMatched := clientv3util.KeyMissing(latestIDKey)
+	if req.Record.ID > 1 {
+		latestIDMatched = clientv3.Compare(clientv3.Value(latestIDKey), "=", fmtID(req.Record.ID-1))
+	}
+	opPutRecord := clientv3.OpPut(key, string(value))
+	opPutLatestID := clientv3.OpPut(latestIDKey, fmtID(req.Record.ID))
+
+	resp, err := s.client.Txn(ctx).
+		If(latestIDMatched).
+		Then(opPutRecord, opPutLatestID).
+		Commit()
+	if err != nil {
+		return errors.WithMessagef(err, "put schedule audit record, clusterID:%d, key:%s", req.ClusterID, key)
+	}
+	if !resp.Succeeded {
+		return ErrAppendScheduleAuditConflict.WithCausef("schedule audit records may have been appended by others, clusterID:%d, recordID:%d, resp:%v", req.ClusterID, req.Record.ID, resp)
+	}
+
+	return nil
+}
+
+func (s *metaStorageImpl) UpdateScheduleAuditRecord(ctx context.Context, req UpdateScheduleAuditRecordRequest) error {
+	if req.Capacity == 0 || req.Record.ID == 0 {
+		return errors.Errorf("invalid schedule audit record, clusterID:%d, recordID:%d, capacity:%d", req.ClusterID, req.Record.ID, req.Capacity)
+	}
+	value, err := json.Marshal(req.Record)
+	if err != nil {
+		return ErrEncode.WithCausef("encode schedule audit record, clusterID:%d, err:%v", req.ClusterID, err)
+	}
+
+	key := makeScheduleAuditRecordKey(s.rootPath, uint32(req.ClusterID), req.Record.ID%req.Capacity)
+	latestIDKey := makeScheduleAuditLatestIDKey(s.rootPath, uint32(req.ClusterID))
+
+	// The slot is reused by the record whose ID is ID+Capacity, and the IDs are formatted with the same width, so they can be compared as strings.
+	notOverwritten := clientv3.Compare(clientv3.Value(latestIDKey), "<", fmtID(req.Record.ID+req.Capacity))
+	appended := clientv3.Compare(clientv3.Value(latestIDKey), ">", fmtID(req.Record.ID-1))
+	opPutRecord := clientv3.OpPut(key, string(value))
+
+	resp, err := s.client.Txn(ctx).
+		If(notOverwritten, appended).
+		Then(opPutRecord).
+		Commit()
+	if err != nil {
+		return errors.WithMessagef(err, "put schedule audit record, clusterID:%d, key:%s", req.ClusterID, key)
+	}
+	if !resp.Succeeded {
+		return ErrUpdateScheduleAuditOverwritten.WithCausef("schedule audit record may have been overwritten, clusterID:%d, recordID:%d, resp:%v", req.ClusterID, req.Record.ID, resp)
+	}
+
+	return nil
+}
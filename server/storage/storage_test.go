@@ -21,6 +21,7 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -301,6 +302,32 @@ func TestStorage_CreateAndListShardView(t *testing.T) {
 		re.Equal(expectShardViews[i].Version, ret.ShardViews[i].Version)
 		re.Equal(expectShardViews[i].CreatedAt, ret.ShardViews[i].CreatedAt)
 	}
+
+	// Test to delete shard view with mismatched version.
+	err = s.DeleteShardView(ctx, DeleteShardViewRequest{
+		ClusterID:     defaultClusterID,
+		ShardID:       expectShardViews[0].ShardID,
+		LatestVersion: defaultVersion,
+	})
+	re.Error(err)
+
+	// Test to delete shard view.
+	err = s.DeleteShardView(ctx, DeleteShardViewRequest{
+		ClusterID:     defaultClusterID,
+		ShardID:       expectShardViews[0].ShardID,
+		LatestVersion: newVersion,
+	})
+	re.NoError(err)
+
+	ret, err = s.ListShardViews(ctx, ListShardViewsRequest{
+		ClusterID: defaultClusterID,
+		ShardIDs:  shardIDs,
+	})
+	re.NoError(err)
+	re.Equal(defaultCount-1, len(ret.ShardViews))
+	for _, shardView := range ret.ShardViews {
+		re.NotEqual(expectShardViews[0].ShardID, shardView.ShardID)
+	}
 }
 
 func TestStorage_CreateOrUpdateNode(t *testing.T) {
@@ -340,6 +367,156 @@ func TestStorage_CreateOrUpdateNode(t *testing.T) {
 	}
 }
 
+func TestStorage_GetAndUpdateVersionedValue(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	testGetAndUpdateVersionedValue(ctx, re, s,
+		ShardAffinityRules{
+			Version:        1,
+			Affinities:     []ShardAffinity{{ShardID: 0, NumAllowedOtherShards: 1}},
+			AntiAffinities: []ShardAntiAffinity{{ShardID: 1, AntiAffinityShardIDs: []ShardID{2, 3}}},
+		},
+		ShardAffinityRules{Version: 1, Affinities: []ShardAffinity{}, AntiAffinities: []ShardAntiAffinity{}})
+	testGetAndUpdateVersionedValue(ctx, re, s,
+		ShardPlacementRules{Version: 1, NodePicker: "zone_aware", ShardGroups: []ShardGroup{{Name: "group0", ShardIDs: []ShardID{0, 1}}}},
+		ShardPlacementRules{Version: 1, NodePicker: "", ShardGroups: []ShardGroup{}})
+	testGetAndUpdateVersionedValue(ctx, re, s,
+		SchedulerSettings{Version: 1, EnableSchedule: true, DisabledSchedulers: []string{"reopen_scheduler"}},
+		SchedulerSettings{Version: 1, EnableSchedule: false, DisabledSchedulers: []string{}})
+	testGetAndUpdateVersionedValue(ctx, re, s,
+		DrainedNodeList{Version: 1, Nodes: []DrainedNode{{Name: "node0", NumShards: 2, DrainedAt: 1}}},
+		DrainedNodeList{Version: 1, Nodes: []DrainedNode{}})
+	testGetAndUpdateVersionedValue(ctx, re, s,
+		NodeWeightList{Version: 1, Weights: []NodeWeight{{Name: "node1", Weight: 200}}},
+		NodeWeightList{Version: 1, Weights: []NodeWeight{}})
+	testGetAndUpdateVersionedValue(ctx, re, s,
+		ReplicaSettings{Version: 1, ReplicaNum: 2},
+		ReplicaSettings{Version: 1, ReplicaNum: 3})
+	testGetAndUpdateVersionedValue(ctx, re, s,
+		MaintenanceWindowList{Version: 1, Windows: []MaintenanceWindow{{Name: "night", Cron: "* 0-5 * * *", TimeZone: "UTC"}}},
+		MaintenanceWindowList{Version: 1, Windows: []MaintenanceWindow{}})
+	testGetAndUpdateVersionedValue(ctx, re, s,
+		SchedulerList{Version: 1, Schedulers: []SchedulerConfig{{Name: "reopen_scheduler"}, {Name: "load_scheduler", Config: json.RawMessage(`{"threshold":0.5}`)}}},
+		SchedulerList{Version: 1, Schedulers: []SchedulerConfig{}})
+}
+
+// testGetAndUpdateVersionedValue persists expectValue, and checks that staleValue based on the stale version is rejected.
+func testGetAndUpdateVersionedValue[T VersionedValue](ctx context.Context, re *require.Assertions, s Storage, expectValue, staleValue T) {
+	// Test to get the value which is not persisted.
+	var value T
+	ret, err := s.GetVersionedValue(ctx, GetVersionedValueRequest{ClusterID: defaultClusterID, Key: expectValue.VersionedKey(), Value: &value})
+	re.NoError(err)
+	re.False(ret.Exists)
+
+	err = s.UpdateVersionedValue(ctx, UpdateVersionedValueRequest{
+		ClusterID:     defaultClusterID,
+		Key:           expectValue.VersionedKey(),
+		Value:         expectValue,
+		Version:       expectValue.GetVersion(),
+		LatestVersion: 0,
+	})
+	re.NoError(err)
+
+	ret, err = s.GetVersionedValue(ctx, GetVersionedValueRequest{ClusterID: defaultClusterID, Key: expectValue.VersionedKey(), Value: &value})
+	re.NoError(err)
+	re.True(ret.Exists)
+	re.Equal(expectValue, value)
+
+	// The value based on a stale version should be rejected.
+	err = s.UpdateVersionedValue(ctx, UpdateVersionedValueRequest{
+		ClusterID:     defaultClusterID,
+		Key:           staleValue.VersionedKey(),
+		Value:         staleValue,
+		Version:       staleValue.GetVersion(),
+		LatestVersion: 0,
+	})
+	re.Error(err)
+
+	var persistedValue T
+	ret, err = s.GetVersionedValue(ctx, GetVersionedValueRequest{ClusterID: defaultClusterID, Key: expectValue.VersionedKey(), Value: &persistedValue})
+	re.NoError(err)
+	re.True(ret.Exists)
+	re.Equal(expectValue, persistedValue)
+}
+
+func TestStorage_AppendAndListScheduleAuditRecords(t *testing.T) {
+	re := require.New(t)
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
+
+	ret, err := s.ListScheduleAuditRecords(ctx, ListScheduleAuditRecordsRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.Equal(uint64(0), ret.LatestID)
+	re.Empty(ret.Records)
+
+	// The ring buffer with 2 slots only keeps the latest 2 records.
+	capacity := uint64(2)
+	for id := uint64(1); id <= 3; id++ {
+		err = s.AppendScheduleAuditRecord(ctx, AppendScheduleAuditRecordRequest{
+			ClusterID: defaultClusterID,
+			Record: ScheduleAuditRecord{
+				ID:          id,
+				Timestamp:   id,
+				Scheduler:   "rebalanced_scheduler",
+				Reason:      "test",
+				ProcedureID: id,
+				ShardIDs:    []ShardID{ShardID(id)},
+				NodeNames:   []string{"node0"},
+				Outcome:     ScheduleAuditOutcomeSubmitted,
+			},
+			Capacity: capacity,
+		})
+		re.NoError(err)
+	}
+
+	// The record not following the latest one should be rejected.
+	err = s.AppendScheduleAuditRecord(ctx, AppendScheduleAuditRecordRequest{
+		ClusterID: defaultClusterID,
+		Record:    ScheduleAuditRecord{ID: 3},
+		Capacity:  capacity,
+	})
+	re.Error(err)
+
+	ret, err = s.ListScheduleAuditRecords(ctx, ListScheduleAuditRecordsRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.Equal(uint64(3), ret.LatestID)
+	re.Len(ret.Records, 2)
+	recordIDs := []uint64{ret.Records[0].ID, ret.Records[1].ID}
+	re.ElementsMatch([]uint64{2, 3}, recordIDs)
+
+	// The record kept in the ring buffer can be updated, but the overwritten or unappended one can't.
+	for _, id := range []uint64{1, 4} {
+		err = s.UpdateScheduleAuditRecord(ctx, UpdateScheduleAuditRecordRequest{
+			ClusterID: defaultClusterID,
+			Record:    ScheduleAuditRecord{ID: id, Outcome: ScheduleAuditOutcomeFinished},
+			Capacity:  capacity,
+		})
+		re.Error(err)
+	}
+	err = s.UpdateScheduleAuditRecord(ctx, UpdateScheduleAuditRecordRequest{
+		ClusterID: defaultClusterID,
+		Record:    ScheduleAuditRecord{ID: 2, ProcedureID: 2, Outcome: ScheduleAuditOutcomeFinished},
+		Capacity:  capacity,
+	})
+	re.NoError(err)
+
+	ret, err = s.ListScheduleAuditRecords(ctx, ListScheduleAuditRecordsRequest{ClusterID: defaultClusterID})
+	re.NoError(err)
+	re.Equal(uint64(3), ret.LatestID)
+	re.Len(ret.Records, 2)
+	for _, record := range ret.Records {
+		if record.ID == 2 {
+			re.Equal(ScheduleAuditOutcomeFinished, record.Outcome)
+		} else {
+			re.Equal(ScheduleAuditOutcomeSubmitted, record.Outcome)
+		}
+	}
+}
+
 func newTestStorage(t *testing.T) Storage {
 	cfg := etcdutil.NewTestSingleConfig()
 	etcd, err := embed.StartEtcd(cfg)
@@ -20,6 +20,7 @@
 package storage
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -96,6 +97,55 @@ type UpdateClusterViewRequest struct {
 	LatestVersion uint64
 }
 
+type GetVersionedValueRequest struct {
+	ClusterID ClusterID
+	Key       VersionedKey
+	// Value is the pointer which the persisted value is decoded into.
+	Value any
+}
+
+type GetVersionedValueResult struct {
+	// Exists is false if no value is persisted, and the value of the request is left untouched.
+	Exists bool
+}
+
+type UpdateVersionedValueRequest struct {
+	ClusterID ClusterID
+	Key       VersionedKey
+	Value     any
+	// Version is the version of the value, which will be the latest version after the update.
+	Version uint64
+	// LatestVersion is the version of the value which the update is based on, and 0 means there is no value persisted.
+	LatestVersion uint64
+}
+
+type ListScheduleAuditRecordsRequest struct {
+	ClusterID ClusterID
+}
+
+type ListScheduleAuditRecordsResult struct {
+	// Records are not sorted, and the records overwritten in the smaller ring buffer before may be included.
+	Records []ScheduleAuditRecord
+	// LatestID is the ID of the latest appended record, and 0 means no record is appended.
+	LatestID uint64
+}
+
+type AppendScheduleAuditRecordRequest struct {
+	ClusterID ClusterID
+	// The ID of the record must be the next of the latest ID.
+	Record ScheduleAuditRecord
+	// Capacity is the number of the slots in the ring buffer.
+	Capacity uint64
+}
+
+type UpdateScheduleAuditRecordRequest struct {
+	ClusterID ClusterID
+	// The record with the same ID will be overwritten, unless it has been overwritten by the newer records in the ring buffer.
+	Record ScheduleAuditRecord
+	// Capacity is the number of the slots in the ring buffer.
+	Capacity uint64
+}
+
 type ListSchemasRequest struct {
 	ClusterID ClusterID
 }
@@ -183,6 +233,12 @@ type UpdateShardViewRequest struct {
 	PrevVersion uint64
 }
 
+type DeleteShardViewRequest struct {
+	ClusterID     ClusterID
+	ShardID       ShardID
+	LatestVersion uint64
+}
+
 type ListNodesRequest struct {
 	ClusterID ClusterID
 }
@@ -275,10 +331,195 @@ func NewShardView(shardID ShardID, version uint64, tableIDs []TableID) ShardView
 	}
 }
 
+type ShardAffinity struct {
+	ShardID               ShardID `json:"shardID"`
+	NumAllowedOtherShards uint    `json:"numAllowedOtherShards"`
+}
+
+type ShardAntiAffinity struct {
+	ShardID              ShardID   `json:"shardID"`
+	AntiAffinityShardIDs []ShardID `json:"antiAffinityShardIDs"`
+}
+
+// VersionedKey is the key of the value persisted with the version in a cluster, and the value is encoded as json.
+type VersionedKey string
+
+const (
+	VersionedKeyShardAffinityRules  VersionedKey = "shard_affinity"
+	VersionedKeyShardPlacementRules VersionedKey = "shard_placement"
+	VersionedKeyDrainedNodes        VersionedKey = "drained_nodes"
+	VersionedKeyNodeWeights         VersionedKey = "node_weights"
+	VersionedKeyReplicaSettings     VersionedKey = "replica_settings"
+	VersionedKeySchedulerSettings   VersionedKey = "scheduler_settings"
+	VersionedKeyMaintenanceWindows  VersionedKey = "maintenance_windows"
+	VersionedKeySchedulerList       VersionedKey = "scheduler_list"
+)
+
+// VersionedValue is the value persisted in its VersionedKey, and the update based on a stale version is rejected.
+type VersionedValue interface {
+	VersionedKey() VersionedKey
+	GetVersion() uint64
+}
+
+func (ShardAffinityRules) VersionedKey() VersionedKey    { return VersionedKeyShardAffinityRules }
+func (r ShardAffinityRules) GetVersion() uint64          { return r.Version }
+func (ShardPlacementRules) VersionedKey() VersionedKey   { return VersionedKeyShardPlacementRules }
+func (r ShardPlacementRules) GetVersion() uint64         { return r.Version }
+func (DrainedNodeList) VersionedKey() VersionedKey       { return VersionedKeyDrainedNodes }
+func (l DrainedNodeList) GetVersion() uint64             { return l.Version }
+func (NodeWeightList) VersionedKey() VersionedKey        { return VersionedKeyNodeWeights }
+func (l NodeWeightList) GetVersion() uint64              { return l.Version }
+func (ReplicaSettings) VersionedKey() VersionedKey       { return VersionedKeyReplicaSettings }
+func (s ReplicaSettings) GetVersion() uint64             { return s.Version }
+func (SchedulerSettings) VersionedKey() VersionedKey     { return VersionedKeySchedulerSettings }
+func (s SchedulerSettings) GetVersion() uint64           { return s.Version }
+func (MaintenanceWindowList) VersionedKey() VersionedKey { return VersionedKeyMaintenanceWindows }
+func (l MaintenanceWindowList) GetVersion() uint64       { return l.Version }
+func (SchedulerList) VersionedKey() VersionedKey         { return VersionedKeySchedulerList }
+func (l SchedulerList) GetVersion() uint64               { return l.Version }
+
+// ShardAffinityRules is all the shard affinities and anti-affinities of a cluster, and the version is increased on every update.
+type ShardAffinityRules struct {
+	Version        uint64              `json:"version"`
+	Affinities     []ShardAffinity     `json:"affinities"`
+	AntiAffinities []ShardAntiAffinity `json:"antiAffinities"`
+}
+
+// ShardGroup is a set of shards which should be spread across zones, e.g. the shards holding the partitions of a table.
+type ShardGroup struct {
+	Name     string    `json:"name"`
+	ShardIDs []ShardID `json:"shardIDs"`
+}
+
+// ShardPlacementRules decides how the shards of a cluster are placed onto the nodes, and the version is increased on every update.
+type ShardPlacementRules struct {
+	Version uint64 `json:"version"`
+	// NodePicker is the name of the node picker used by the cluster, and the default one is used if it is empty.
+	NodePicker  string       `json:"nodePicker"`
+	ShardGroups []ShardGroup `json:"shardGroups"`
+}
+
+// DrainedNodeList is the drained nodes of a cluster, and the version is increased on every update.
+type DrainedNodeList struct {
+	Version uint64 `json:"version"`
+	// Nodes are excluded from the placement, and the shards on them are moved to other nodes.
+	Nodes []DrainedNode `json:"nodes"`
+}
+
+// NodeWeightList is the weights of the nodes of a cluster, and the version is increased on every update.
+type NodeWeightList struct {
+	Version uint64 `json:"version"`
+	// Weights scale the share of shards of the nodes, and the nodes not included have the default weight.
+	Weights []NodeWeight `json:"weights"`
+}
+
+// ReplicaSettings decides the replicas of the shards of a cluster, and the version is increased on every update.
+type ReplicaSettings struct {
+	Version uint64 `json:"version"`
+	// ReplicaNum is the number of the replicas of every shard including the leader, and the followers are placed on the other nodes.
+	// Zero means that only the leader is kept.
+	ReplicaNum uint32 `json:"replicaNum"`
+}
+
+// SchedulerSettings controls the schedulers of a cluster, and the version is increased on every update.
+type SchedulerSettings struct {
+	Version uint64 `json:"version"`
+	// EnableSchedule means that the shard topology is locked, see the config with the same name.
+	EnableSchedule bool `json:"enableSchedule"`
+	// DisabledSchedulers are the names of the schedulers which are skipped, and the others are enabled, so that the schedulers
+	// added later are enabled by default.
+	DisabledSchedulers []string `json:"disabledSchedulers"`
+}
+
+// SchedulerList is the schedulers run by a cluster, and the version is increased on every update.
+type SchedulerList struct {
+	Version uint64 `json:"version"`
+	// Schedulers is the ordered list of the schedulers, and the default schedulers of the topology type are run if it is empty.
+	Schedulers []SchedulerConfig `json:"schedulers"`
+}
+
+// MaintenanceWindowList is the maintenance windows of a cluster, and the version is increased on every update.
+type MaintenanceWindowList struct {
+	Version uint64 `json:"version"`
+	// Windows restrict when the schedulers changing the topology can run, and they can run at any time if no window is given.
+	Windows []MaintenanceWindow `json:"windows"`
+}
+
+// SchedulerConfig enables the scheduler registered with the name, and the config is decoded by the constructor of the scheduler.
+type SchedulerConfig struct {
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// MaintenanceWindow is open during the minutes matching the cron-like expression.
+type MaintenanceWindow struct {
+	Name string `json:"name"`
+	// Cron consists of five fields: minute, hour, day of month, month and day of week, e.g. `* 0-5 * * *`.
+	Cron string `json:"cron"`
+	// TimeZone is the IANA name of the time zone of the expression, and UTC is used if it is empty.
+	TimeZone string `json:"timeZone"`
+}
+
+type ScheduleAuditOutcome string
+
+const (
+	ScheduleAuditOutcomeSubmitted ScheduleAuditOutcome = "submitted"
+	ScheduleAuditOutcomeFailed    ScheduleAuditOutcome = "failed"
+	ScheduleAuditOutcomeFinished  ScheduleAuditOutcome = "finished"
+	ScheduleAuditOutcomeCancelled ScheduleAuditOutcome = "cancelled"
+)
+
+// ScheduleAuditRecord describes why a procedure is generated by the scheduler, and how the submission and the procedure end.
+type ScheduleAuditRecord struct {
+	ID uint64 `json:"id"`
+	// Timestamp is the time when the procedure is submitted, in milliseconds.
+	Timestamp     uint64               `json:"timestamp"`
+	Scheduler     string               `json:"scheduler"`
+	Reason        string               `json:"reason"`
+	ProcedureID   uint64               `json:"procedureID"`
+	ProcedureKind uint                 `json:"procedureKind"`
+	ShardIDs      []ShardID            `json:"shardIDs"`
+	NodeNames     []string             `json:"nodeNames"`
+	Outcome       ScheduleAuditOutcome `json:"outcome"`
+	// Error is the cause of the failed submission or the failed procedure.
+	Error string `json:"error"`
+}
+
+type DrainedNode struct {
+	Name string `json:"name"`
+	// NumShards is the number of the leader shards on the node when it starts to be drained.
+	NumShards int `json:"numShards"`
+	// DrainedAt is the time when the node starts to be drained, in milliseconds.
+	DrainedAt uint64 `json:"drainedAt"`
+}
+
+type NodeWeight struct {
+	Name   string `json:"name"`
+	Weight uint32 `json:"weight"`
+}
+
 type NodeStats struct {
 	Lease       uint32
 	Zone        string
 	NodeVersion string
+	// Load is reported by the node periodically, and it is only kept in memory.
+	Load NodeLoad
+}
+
+// NodeLoad describes how loaded a node is, the usages are ratios in [0, 1].
+type NodeLoad struct {
+	CPUUsage    float64
+	MemoryUsage float64
+	DiskUsage   float64
+	WriteQPS    float64
+	ShardLoads  map[ShardID]ShardLoad
+	// ReportedAt is the time when the load is reported, in milliseconds, and zero means the load is never reported.
+	ReportedAt uint64
+}
+
+type ShardLoad struct {
+	WriteQPS  float64
+	DiskBytes uint64
 }
 
 func NewEmptyNodeStats() NodeStats {